@@ -36,6 +36,8 @@ var (
 			discv5CrawlCommand,
 			discv5TestCommand,
 			discv5ListenCommand,
+			discv5RegisterTopicCommand,
+			discv5SearchTopicCommand,
 		},
 	}
 	discv5PingCommand = cli.Command{
@@ -77,8 +79,33 @@ var (
 			listenAddrFlag,
 		},
 	}
+	discv5RegisterTopicCommand = cli.Command{
+		Name:      "register-topic",
+		Usage:     "Runs a node which advertises itself under a topic",
+		ArgsUsage: "<topic>",
+		Action:    discv5RegisterTopic,
+		Flags: []cli.Flag{
+			bootnodesFlag,
+			nodekeyFlag,
+			nodedbFlag,
+			listenAddrFlag,
+		},
+	}
+	discv5SearchTopicCommand = cli.Command{
+		Name:      "search-topic",
+		Usage:     "Finds nodes advertising a topic",
+		ArgsUsage: "<topic>",
+		Action:    discv5SearchTopic,
+		Flags:     []cli.Flag{bootnodesFlag, topicSearchTimeoutFlag},
+	}
 )
 
+var topicSearchTimeoutFlag = cli.DurationFlag{
+	Name:  "timeout",
+	Usage: "Time limit for the search.",
+	Value: 5 * time.Minute,
+}
+
 func discv5Ping(ctx *cli.Context) error {
 	n := getNodeArg(ctx)
 	disc := startV5(ctx)
@@ -134,6 +161,34 @@ func discv5Listen(ctx *cli.Context) error {
 	select {}
 }
 
+func discv5RegisterTopic(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need topic as argument")
+	}
+	disc := startV5(ctx)
+	defer disc.Close()
+
+	disc.RegisterTopic(ctx.Args().First())
+	fmt.Println(disc.Self())
+	select {}
+}
+
+func discv5SearchTopic(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need topic as argument")
+	}
+	disc := startV5(ctx)
+	defer disc.Close()
+
+	it := disc.TopicSearch(ctx.Args().First())
+	timer := time.AfterFunc(ctx.Duration(topicSearchTimeoutFlag.Name), it.Close)
+	defer timer.Stop()
+	for it.Next() {
+		fmt.Println(it.Node())
+	}
+	return nil
+}
+
 // startV5 starts an ephemeral discovery v5 node.
 func startV5(ctx *cli.Context) *discover.UDPv5 {
 	ln, config := makeDiscoveryConfig(ctx)
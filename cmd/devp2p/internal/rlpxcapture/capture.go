@@ -0,0 +1,126 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package rlpxcapture implements recording and replaying of decrypted RLPx
+// traffic.
+//
+// A capture file contains one JSON object per line, each describing a single
+// message received from the remote peer:
+//
+//	{"elapsed":1500000,"proto":"eth/66","code":0,"size":92,"data":"0xf85a..."}
+//
+// Message codes are relative to the sub-protocol which carried them, so that a
+// capture can be replayed into sessions negotiating a different set of
+// capabilities. Messages of the base protocol are recorded as protocol "p2p".
+package rlpxcapture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// maxLineSize is the longest capture line accepted by Reader. It is large
+// enough to hold the hex encoding of a maximum size RLPx message.
+const maxLineSize = 2*16*1024*1024 + 1024
+
+// Message is a single recorded message.
+type Message struct {
+	Elapsed  time.Duration `json:"elapsed"` // Time since the session was established
+	Protocol string        `json:"proto"`   // Carrying protocol, e.g. "eth/66", or "p2p" for the base protocol
+	Code     uint64        `json:"code"`    // Message code relative to the protocol offset
+	Size     uint32        `json:"size"`    // Size of the message on the wire (after compression)
+	Data     hexutil.Bytes `json:"data"`    // RLP encoded message payload
+}
+
+// String implements fmt.Stringer.
+func (m *Message) String() string {
+	return fmt.Sprintf("%s msg %d (%d bytes)", m.Protocol, m.Code, len(m.Data))
+}
+
+// Writer writes messages to a capture file.
+type Writer struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewWriter creates a writer which appends messages to w.
+func NewWriter(w io.Writer) *Writer {
+	buf := bufio.NewWriter(w)
+	return &Writer{w: buf, enc: json.NewEncoder(buf)}
+}
+
+// Write appends a message to the capture.
+func (w *Writer) Write(msg *Message) error {
+	return w.enc.Encode(msg)
+}
+
+// Flush writes any buffered messages to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Reader reads messages from a capture file.
+type Reader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewReader creates a reader which decodes messages from r.
+func NewReader(r io.Reader) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxLineSize)
+	return &Reader{s: s}
+}
+
+// Read returns the next message of the capture. It returns io.EOF when there
+// are no more messages.
+func (r *Reader) Read() (*Message, error) {
+	for r.s.Scan() {
+		r.line++
+		if len(r.s.Bytes()) == 0 {
+			continue
+		}
+		msg := new(Message)
+		if err := json.Unmarshal(r.s.Bytes(), msg); err != nil {
+			return nil, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		return msg, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// ReadAll reads all remaining messages of the capture.
+func (r *Reader) ReadAll() ([]*Message, error) {
+	var msgs []*Message
+	for {
+		msg, err := r.Read()
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+}
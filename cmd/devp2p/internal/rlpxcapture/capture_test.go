@@ -0,0 +1,104 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package rlpxcapture
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+func TestCaptureRoundtrip(t *testing.T) {
+	msgs := []*Message{
+		{Elapsed: 0, Protocol: BaseProtocol, Code: 0, Size: 120, Data: []byte{0xc0}},
+		{Elapsed: time.Second, Protocol: "eth/66", Code: 3, Size: 12, Data: []byte{0xc2, 0x01, 0x02}},
+		{Elapsed: 2 * time.Second, Protocol: "snap/1", Code: 1, Size: 5, Data: []byte{0x80}},
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, msg := range msgs {
+		if err := w.Write(msg); err != nil {
+			t.Fatal("write error:", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal("flush error:", err)
+	}
+	have, err := NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal("read error:", err)
+	}
+	if !reflect.DeepEqual(have, msgs) {
+		t.Fatalf("capture mismatch:\nhave %v\nwant %v", have, msgs)
+	}
+}
+
+func TestCaptureReadError(t *testing.T) {
+	r := NewReader(bytes.NewBufferString(`{"proto":"p2p","code":0}` + "\n\n" + `{"code":"x"}` + "\n"))
+	if _, err := r.Read(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if _, err := r.Read(); err == nil || err.Error()[:7] != "line 3:" {
+		t.Fatalf("wrong error for invalid line: %v", err)
+	}
+	if _, err := NewReader(new(bytes.Buffer)).Read(); err != io.EOF {
+		t.Fatalf("wrong error for empty capture: %v", err)
+	}
+}
+
+func TestSessionCodes(t *testing.T) {
+	remote := []p2p.Cap{{Name: "snap", Version: 1}, {Name: "eth", Version: 66}, {Name: "eth", Version: 65}, {Name: "les", Version: 4}}
+	s := NewSession(Protocols, remote)
+
+	if have, want := s.Protocols(), []Protocol{Protocols[2], Protocols[3]}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("negotiated protocols mismatch: have %v, want %v", have, want)
+	}
+	tests := []struct {
+		code    uint64
+		proto   string
+		relcode uint64
+	}{
+		{0x01, BaseProtocol, 1},
+		{0x10, "eth/66", 0},
+		{0x20, "eth/66", 16},
+		{0x21, "snap/1", 0},
+		{0x28, "snap/1", 7},
+	}
+	for _, test := range tests {
+		proto, relcode, err := s.Decode(test.code)
+		if err != nil || proto != test.proto || relcode != test.relcode {
+			t.Errorf("decode %#x: have %s %d (%v), want %s %d", test.code, proto, relcode, err, test.proto, test.relcode)
+		}
+		code, err := s.Encode(test.proto, test.relcode)
+		if err != nil || code != test.code {
+			t.Errorf("encode %s %d: have %#x (%v), want %#x", test.proto, test.relcode, code, err, test.code)
+		}
+	}
+	if _, _, err := s.Decode(0x29); err == nil {
+		t.Errorf("decoded code outside of negotiated protocols")
+	}
+	if _, err := s.Encode("eth/65", 0); err == nil {
+		t.Errorf("encoded code of protocol version which was not negotiated")
+	}
+	if _, err := s.Encode("snap/1", 8); err == nil {
+		t.Errorf("encoded code outside of protocol length")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package rlpxcapture
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+const (
+	// BaseProtocol is the name under which base protocol messages are recorded.
+	BaseProtocol = "p2p"
+
+	// baseProtocolLength is the number of message codes reserved for the base
+	// protocol. Sub-protocol codes are offset after it.
+	baseProtocolLength = 16
+)
+
+// Protocol is a sub-protocol whose messages can be captured and replayed.
+type Protocol struct {
+	Name    string
+	Version uint
+	Length  uint64 // Number of message codes used by the protocol
+}
+
+// Cap returns the capability advertising the protocol.
+func (p Protocol) Cap() p2p.Cap {
+	return p2p.Cap{Name: p.Name, Version: p.Version}
+}
+
+// String returns the protocol identifier used in capture files.
+func (p Protocol) String() string {
+	return fmt.Sprintf("%s/%d", p.Name, p.Version)
+}
+
+// Protocols is the list of sub-protocols known to the capture tool.
+var Protocols = []Protocol{
+	{Name: "eth", Version: 64, Length: 17},
+	{Name: "eth", Version: 65, Length: 17},
+	{Name: "eth", Version: 66, Length: 17},
+	{Name: "snap", Version: 1, Length: 8},
+}
+
+// Caps returns the capabilities advertising the given protocols.
+func Caps(protos []Protocol) []p2p.Cap {
+	caps := make([]p2p.Cap, len(protos))
+	for i, proto := range protos {
+		caps[i] = proto.Cap()
+	}
+	return caps
+}
+
+// Known returns the known protocols advertised by the given capabilities.
+func Known(caps []p2p.Cap) []Protocol {
+	var protos []Protocol
+	for _, cap := range caps {
+		for _, proto := range Protocols {
+			if proto.Name == cap.Name && proto.Version == cap.Version {
+				protos = append(protos, proto)
+			}
+		}
+	}
+	return protos
+}
+
+// matchedProtocol is a protocol negotiated in a session.
+type matchedProtocol struct {
+	Protocol
+	offset uint64
+}
+
+// Session maps the absolute message codes of an RLPx session to the
+// sub-protocols negotiated in the devp2p handshake.
+type Session struct {
+	protos []matchedProtocol // sorted by offset
+}
+
+// NewSession negotiates the shared protocols of the local protocol list and the
+// capabilities announced by the remote side, in the same way as p2p.Server.
+func NewSession(local []Protocol, remote []p2p.Cap) *Session {
+	caps := make([]p2p.Cap, len(remote))
+	copy(caps, remote)
+	sort.Slice(caps, func(i, j int) bool {
+		return caps[i].Name < caps[j].Name || (caps[i].Name == caps[j].Name && caps[i].Version < caps[j].Version)
+	})
+	var (
+		offset  = uint64(baseProtocolLength)
+		matched = make(map[string]int)
+		s       = new(Session)
+	)
+outer:
+	for _, cap := range caps {
+		for _, proto := range local {
+			if proto.Name == cap.Name && proto.Version == cap.Version {
+				// If an old protocol version matched, replace it
+				if i, ok := matched[cap.Name]; ok {
+					offset -= s.protos[i].Length
+					s.protos = s.protos[:i]
+				}
+				matched[cap.Name] = len(s.protos)
+				s.protos = append(s.protos, matchedProtocol{Protocol: proto, offset: offset})
+				offset += proto.Length
+				continue outer
+			}
+		}
+	}
+	return s
+}
+
+// Protocols returns the negotiated protocols.
+func (s *Session) Protocols() []Protocol {
+	protos := make([]Protocol, len(s.protos))
+	for i, proto := range s.protos {
+		protos[i] = proto.Protocol
+	}
+	return protos
+}
+
+// Decode splits an absolute message code into the protocol carrying it and
+// the protocol-relative code.
+func (s *Session) Decode(code uint64) (proto string, relcode uint64, err error) {
+	if code < baseProtocolLength {
+		return BaseProtocol, code, nil
+	}
+	for _, p := range s.protos {
+		if code >= p.offset && code < p.offset+p.Length {
+			return p.String(), code - p.offset, nil
+		}
+	}
+	return "", 0, fmt.Errorf("message code %d outside of negotiated protocols", code)
+}
+
+// Encode computes the absolute message code of a protocol-relative code. It
+// fails if the exact protocol version was not negotiated in the session.
+func (s *Session) Encode(proto string, relcode uint64) (uint64, error) {
+	if proto == BaseProtocol {
+		if relcode >= baseProtocolLength {
+			return 0, fmt.Errorf("invalid base protocol message code %d", relcode)
+		}
+		return relcode, nil
+	}
+	for _, p := range s.protos {
+		if p.String() != proto {
+			continue
+		}
+		if relcode >= p.Length {
+			return 0, fmt.Errorf("invalid %v message code %d", p, relcode)
+		}
+		return p.offset + relcode, nil
+	}
+	return 0, fmt.Errorf("protocol %s not negotiated", proto)
+}
@@ -0,0 +1,392 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/devp2p/internal/ethtest"
+	"github.com/ethereum/go-ethereum/cmd/devp2p/internal/rlpxcapture"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/rlpx"
+	"github.com/ethereum/go-ethereum/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	rlpxCaptureCommand = cli.Command{
+		Name:      "capture",
+		Usage:     "Records the messages sent by a node",
+		ArgsUsage: "<node>",
+		Action:    rlpxCapture,
+		Flags: []cli.Flag{
+			captureOutFlag,
+			captureDurationFlag,
+		},
+	}
+	rlpxReplayCommand = cli.Command{
+		Name:      "replay",
+		Usage:     "Sends recorded messages to a node",
+		ArgsUsage: "<node> <capture>",
+		Action:    rlpxReplay,
+		Flags: []cli.Flag{
+			replayTimingFlag,
+			replayWaitFlag,
+		},
+	}
+)
+
+var (
+	captureOutFlag = cli.StringFlag{
+		Name:  "out",
+		Usage: "Capture file to write",
+		Value: "rlpx-capture.jsonl",
+	}
+	captureDurationFlag = cli.DurationFlag{
+		Name:  "duration",
+		Usage: "Time limit for the capture",
+		Value: time.Minute,
+	}
+	replayTimingFlag = cli.BoolFlag{
+		Name:  "timing",
+		Usage: "Reproduce the recorded delays between messages",
+	}
+	replayWaitFlag = cli.DurationFlag{
+		Name:  "wait",
+		Usage: "Time to wait for the node to respond after the last message",
+		Value: 5 * time.Second,
+	}
+)
+
+const (
+	// Base protocol message codes.
+	helloMsg = 0x00
+	discMsg  = 0x01
+	pingMsg  = 0x02
+	pongMsg  = 0x03
+
+	// ethStatusMsg is the code of the eth protocol handshake message.
+	ethStatusMsg = 0x00
+
+	// rlpxHandshakeTimeout is the time allowed for the devp2p handshake.
+	rlpxHandshakeTimeout = 10 * time.Second
+)
+
+// rlpxCapture connects to a node and records all messages it sends.
+func rlpxCapture(ctx *cli.Context) error {
+	n := getNodeArg(ctx)
+	out, err := os.Create(ctx.String(captureOutFlag.Name))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	conn, key, err := dialRLPx(n)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var (
+		w       = rlpxcapture.NewWriter(out)
+		start   = time.Now()
+		end     = start.Add(ctx.Duration(captureDurationFlag.Name))
+		session *rlpxcapture.Session
+		count   int
+	)
+	if err := writeHello(conn, key, "devp2p", rlpxcapture.Caps(rlpxcapture.Protocols)); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(start.Add(rlpxHandshakeTimeout))
+	err = func() error {
+		for {
+			code, data, size, err := conn.Read()
+			if err != nil {
+				return err
+			}
+			msg := &rlpxcapture.Message{
+				Elapsed: time.Since(start),
+				Code:    code,
+				Size:    uint32(size),
+				Data:    data,
+			}
+			if session == nil {
+				// The first message must be the handshake, which determines the
+				// message code offsets of all following messages.
+				hello, err := decodeHello(code, data)
+				if err != nil {
+					return err
+				}
+				if hello.Version >= 5 {
+					conn.SetSnappy(true)
+				}
+				session = rlpxcapture.NewSession(rlpxcapture.Protocols, hello.Caps)
+				conn.SetReadDeadline(end)
+				fmt.Printf("Connected to %q, protocols %v\n", hello.Name, session.Protocols())
+				msg.Protocol = rlpxcapture.BaseProtocol
+			} else {
+				if msg.Protocol, msg.Code, err = session.Decode(code); err != nil {
+					return err
+				}
+			}
+			if err := w.Write(msg); err != nil {
+				return err
+			}
+			count++
+
+			// Keep the session alive as long as possible. Echoing the eth status
+			// of the node makes it accept us as a peer on its own network.
+			switch {
+			case msg.Protocol == rlpxcapture.BaseProtocol && msg.Code == discMsg:
+				return decodeDisconnect(data)
+			case msg.Protocol == rlpxcapture.BaseProtocol && msg.Code == pingMsg:
+				if _, err := conn.Write(pongMsg, []byte{0xc0}); err != nil {
+					return err
+				}
+			case strings.HasPrefix(msg.Protocol, "eth/") && msg.Code == ethStatusMsg:
+				if _, err := conn.Write(code, data); err != nil {
+					return err
+				}
+			}
+		}
+	}()
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() && session != nil {
+		err = nil
+	}
+	if flushErr := w.Flush(); flushErr != nil && err == nil {
+		err = flushErr
+	}
+	fmt.Printf("Captured %d messages in %v\n", count, time.Since(start).Round(time.Millisecond))
+	return err
+}
+
+// rlpxReplay sends the messages of a capture file to a node.
+func rlpxReplay(ctx *cli.Context) error {
+	if ctx.NArg() < 2 {
+		exit("missing capture file as command-line argument")
+	}
+	n := getNodeArg(ctx)
+	msgs, err := loadCapture(ctx.Args()[1])
+	if err != nil {
+		return err
+	}
+	// Announce the capabilities of the recorded session if it contains the
+	// handshake, so the same protocol versions are negotiated.
+	var (
+		name   = "devp2p"
+		protos = rlpxcapture.Protocols
+	)
+	if len(msgs) > 0 && msgs[0].Protocol == rlpxcapture.BaseProtocol && msgs[0].Code == helloMsg {
+		hello, err := decodeHello(helloMsg, msgs[0].Data)
+		if err != nil {
+			return fmt.Errorf("invalid recorded handshake: %v", err)
+		}
+		name, protos = hello.Name, rlpxcapture.Known(hello.Caps)
+		msgs = msgs[1:]
+	}
+
+	fd, key, err := dialRLPx(n)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	conn := &replayConn{Conn: fd}
+
+	if err := writeHello(conn.Conn, key, name, rlpxcapture.Caps(protos)); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(rlpxHandshakeTimeout))
+	code, data, _, err := conn.Read()
+	if err != nil {
+		return err
+	}
+	hello, err := decodeHello(code, data)
+	if err != nil {
+		return err
+	}
+	if hello.Version >= 5 {
+		conn.SetSnappy(true)
+	}
+	conn.SetReadDeadline(time.Time{})
+	session := rlpxcapture.NewSession(protos, hello.Caps)
+	fmt.Printf("Connected to %q, protocols %v\n", hello.Name, session.Protocols())
+
+	// Consume the responses of the node in the background.
+	readErr := make(chan error, 1)
+	go func() { readErr <- conn.readLoop(session) }()
+
+	var (
+		start   = time.Now()
+		timing  = ctx.Bool(replayTimingFlag.Name)
+		sent    int
+		skipped int
+	)
+	for _, msg := range msgs {
+		code, err := session.Encode(msg.Protocol, msg.Code)
+		if err != nil {
+			fmt.Printf("Skipping %v: %v\n", msg, err)
+			skipped++
+			continue
+		}
+		var delay <-chan time.Time
+		if timing {
+			delay = time.After(time.Until(start.Add(msg.Elapsed)))
+		} else {
+			delay = time.After(0)
+		}
+		select {
+		case err := <-readErr:
+			return fmt.Errorf("connection closed after %d messages: %v", sent, err)
+		case <-delay:
+		}
+		if err := conn.write(code, msg.Data); err != nil {
+			return err
+		}
+		fmt.Printf(">> %v\n", msg)
+		sent++
+	}
+	fmt.Printf("Replayed %d messages, skipped %d\n", sent, skipped)
+
+	// Give the node some time to react to the last messages.
+	select {
+	case err := <-readErr:
+		fmt.Printf("Connection closed: %v\n", err)
+	case <-time.After(ctx.Duration(replayWaitFlag.Name)):
+	}
+	return nil
+}
+
+// replayConn wraps an RLPx connection to allow concurrent writes from the
+// replay and the read loop.
+type replayConn struct {
+	*rlpx.Conn
+	wmu sync.Mutex
+}
+
+func (c *replayConn) write(code uint64, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	_, err := c.Conn.Write(code, data)
+	return err
+}
+
+// readLoop prints the messages sent by the node and answers pings. It returns
+// when the connection fails or the node disconnects.
+func (c *replayConn) readLoop(session *rlpxcapture.Session) error {
+	for {
+		code, data, _, err := c.Read()
+		if err != nil {
+			return err
+		}
+		switch code {
+		case discMsg:
+			return decodeDisconnect(data)
+		case pingMsg:
+			if err := c.write(pongMsg, []byte{0xc0}); err != nil {
+				return err
+			}
+		}
+		if proto, relcode, err := session.Decode(code); err != nil {
+			fmt.Printf("<< %v\n", err)
+		} else {
+			fmt.Printf("<< %s msg %d (%d bytes)\n", proto, relcode, len(data))
+		}
+	}
+}
+
+// dialRLPx connects to a node and performs the RLPx encryption handshake
+// using a fresh session key.
+func dialRLPx(n *enode.Node) (*rlpx.Conn, *ecdsa.PrivateKey, error) {
+	fd, err := net.Dial("tcp", fmt.Sprintf("%v:%d", n.IP(), n.TCP()))
+	if err != nil {
+		return nil, nil, err
+	}
+	conn := rlpx.NewConn(fd, n.Pubkey())
+	key, _ := crypto.GenerateKey()
+	conn.SetDeadline(time.Now().Add(rlpxHandshakeTimeout))
+	if _, err := conn.Handshake(key); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, key, nil
+}
+
+// writeHello sends the devp2p handshake.
+func writeHello(conn *rlpx.Conn, key *ecdsa.PrivateKey, name string, caps []p2p.Cap) error {
+	hello := &ethtest.Hello{
+		Version: 5,
+		Name:    name,
+		Caps:    caps,
+		ID:      crypto.FromECDSAPub(&key.PublicKey)[1:],
+	}
+	data, err := rlp.EncodeToBytes(hello)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(helloMsg, data)
+	return err
+}
+
+// decodeHello decodes the devp2p handshake.
+func decodeHello(code uint64, data []byte) (*ethtest.Hello, error) {
+	switch code {
+	case helloMsg:
+		var hello ethtest.Hello
+		if err := rlp.DecodeBytes(data, &hello); err != nil {
+			return nil, fmt.Errorf("invalid handshake: %v", err)
+		}
+		return &hello, nil
+	case discMsg:
+		return nil, decodeDisconnect(data)
+	default:
+		return nil, fmt.Errorf("invalid message code %d, expected handshake (code zero)", code)
+	}
+}
+
+// decodeDisconnect turns a disconnect message into an error.
+func decodeDisconnect(data []byte) error {
+	var msg []p2p.DiscReason
+	if rlp.DecodeBytes(data, &msg); len(msg) == 0 {
+		return fmt.Errorf("invalid disconnect message")
+	}
+	return fmt.Errorf("received disconnect message: %v", msg[0])
+}
+
+// loadCapture reads all messages of a capture file.
+func loadCapture(file string) ([]*rlpxcapture.Message, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	msgs, err := rlpxcapture.NewReader(fd).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid capture %s: %v", file, err)
+	}
+	return msgs, nil
+}
@@ -36,6 +36,8 @@ var (
 		Subcommands: []cli.Command{
 			rlpxPingCommand,
 			rlpxEthTestCommand,
+			rlpxCaptureCommand,
+			rlpxReplayCommand,
 		},
 	}
 	rlpxPingCommand = cli.Command{
@@ -0,0 +1,94 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// Tests that named RPC listeners can be configured in the TOML config file.
+func TestConfigHTTPEndpoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geth-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.toml")
+	content := `
+[Node.HTTPEndpoints.public]
+Host = "0.0.0.0"
+Port = 8545
+Modules = ["eth", "net"]
+VirtualHosts = ["*"]
+
+[Node.HTTPEndpoints.admin]
+Host = "127.0.0.1"
+Port = 8550
+Modules = ["admin", "debug"]
+PathPrefix = "/admin"
+WS = true
+WSOrigins = ["localhost"]
+JWTSecret = "/tmp/jwtsecret"
+`
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]node.HTTPEndpointConfig{
+		"public": {Host: "0.0.0.0", Port: 8545, Modules: []string{"eth", "net"}, VirtualHosts: []string{"*"}},
+		"admin": {
+			Host:       "127.0.0.1",
+			Port:       8550,
+			Modules:    []string{"admin", "debug"},
+			PathPrefix: "/admin",
+			WS:         true,
+			WSOrigins:  []string{"localhost"},
+			JWTSecret:  "/tmp/jwtsecret",
+		},
+	}
+	cfg := gethConfig{Eth: ethconfig.Defaults, Node: defaultNodeConfig(), Metrics: metrics.DefaultConfig}
+	if err := loadConfig(file, &cfg); err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.Node.HTTPEndpoints, want) {
+		t.Fatalf("wrong endpoints loaded:\nhave %+v\nwant %+v", cfg.Node.HTTPEndpoints, want)
+	}
+
+	// Check that dumping the config and loading it again yields the same listeners.
+	out, err := tomlSettings.Marshal(&cfg)
+	if err != nil {
+		t.Fatalf("failed to encode config: %v", err)
+	}
+	if err := ioutil.WriteFile(file, out, 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := gethConfig{Eth: ethconfig.Defaults, Node: defaultNodeConfig(), Metrics: metrics.DefaultConfig}
+	if err := loadConfig(file, &reloaded); err != nil {
+		t.Fatalf("failed to load dumped config: %v", err)
+	}
+	if !reflect.DeepEqual(reloaded.Node.HTTPEndpoints, want) {
+		t.Fatalf("wrong endpoints after round trip:\nhave %+v\nwant %+v", reloaded.Node.HTTPEndpoints, want)
+	}
+}
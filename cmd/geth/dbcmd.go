@@ -17,10 +17,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
@@ -60,6 +62,10 @@ Remove blockchain and state databases`,
 			dbDeleteCmd,
 			dbPutCmd,
 			dbGetSlotsCmd,
+			dbPruneHistoryCmd,
+			dbVerifyAncientsCmd,
+			dbExportCmd,
+			dbImportCmd,
 		},
 	}
 	dbInspectCmd = cli.Command{
@@ -74,6 +80,7 @@ Remove blockchain and state databases`,
 			utils.RinkebyFlag,
 			utils.GoerliFlag,
 			utils.YoloV3Flag,
+			utils.RemoteDBFlag,
 		},
 		Usage:       "Inspect the storage size for each type of data in the database",
 		Description: `This commands iterates the entire database. If the optional 'prefix' and 'start' arguments are provided, then the iteration is limited to the given subset of data.`,
@@ -90,6 +97,7 @@ Remove blockchain and state databases`,
 			utils.RinkebyFlag,
 			utils.GoerliFlag,
 			utils.YoloV3Flag,
+			utils.RemoteDBFlag,
 		},
 	}
 	dbCompactCmd = cli.Command{
@@ -124,6 +132,7 @@ corruption if it is aborted during execution'!`,
 			utils.RinkebyFlag,
 			utils.GoerliFlag,
 			utils.YoloV3Flag,
+			utils.RemoteDBFlag,
 		},
 		Description: "This command looks up the specified database key from the database.",
 	}
@@ -174,9 +183,96 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 			utils.RinkebyFlag,
 			utils.GoerliFlag,
 			utils.YoloV3Flag,
+			utils.RemoteDBFlag,
 		},
 		Description: "This command looks up the specified database key from the database.",
 	}
+	dbPruneHistoryCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbPruneHistory),
+		Name:      "prune-history",
+		Usage:     "Delete ancient block bodies and receipts below a given block",
+		ArgsUsage: "<block number (optional)>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.RopstenFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			utils.YoloV3Flag,
+			configFileFlag,
+		},
+		Description: `This command deletes the block bodies and receipts below the given block
+number from the ancient store. If no block number is given, the HistoryPruneBlock
+value of the config file is used. Headers are retained, and data is deleted in
+whole data files, so some blocks below the given number might be kept.
+WARNING: Pruned chain history can only be restored by resyncing the node!`,
+	}
+	dbVerifyAncientsCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbVerifyAncients),
+		Name:      "verify-ancients",
+		Usage:     "Verify the consistency of the ancient chain data",
+		ArgsUsage: "<start block (optional)>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.RopstenFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			utils.YoloV3Flag,
+			verifyTruncateFlag,
+		},
+		Description: `This command walks the ancient store and checks that every header matches
+its stored hash and links to its parent, that the total difficulties accumulate,
+and that the transaction, uncle and receipt roots of the bodies and receipts match
+the headers. Verification stops at the first corrupt block. With --truncate, the
+ancient store is truncated at that block and the chain head is rewound below it.
+The node rewinds further to the most recent block with state on the next start,
+and resyncs the removed blocks from the network. A corrupt genesis block can't be
+truncated, the database needs to be resynced from scratch instead.`,
+	}
+	dbExportCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbExport),
+		Name:      "export",
+		Usage:     "Export a category of database entries into a file",
+		ArgsUsage: "<category> <dumpfile>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.RopstenFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			utils.YoloV3Flag,
+		},
+		Description: fmt.Sprintf(`This command exports all the database entries of a data category into a
+gzip compressed, checksummed dump. Supported categories: %s.`, strings.Join(rawdb.KeyCategories(), ", ")),
+	}
+	dbImportCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbImport),
+		Name:      "import",
+		Usage:     "Import database entries from a file",
+		ArgsUsage: "<dumpfile>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.RopstenFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			utils.YoloV3Flag,
+		},
+		Description: `This command imports the database entries of a dump created by 'geth db export'.
+The dump is verified against its checksum before anything is written. Existing
+entries with the same keys are overwritten.`,
+	}
+	verifyTruncateFlag = cli.BoolFlag{
+		Name:  "truncate",
+		Usage: "Truncate the ancient store at the first corrupt block and rewind the chain head",
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -400,6 +496,100 @@ func dbPut(ctx *cli.Context) error {
 	return db.Put(key, value)
 }
 
+// dbPruneHistory deletes the ancient chain history below a given block
+func dbPruneHistory(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return fmt.Errorf("max 1 argument: %v", ctx.Command.ArgsUsage)
+	}
+	stack, config := makeConfigNode(ctx)
+	defer stack.Close()
+
+	if ctx.NArg() == 1 {
+		number, err := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid block number: %v", err)
+		}
+		config.Eth.HistoryPruneBlock = number
+	}
+	if config.Eth.HistoryPruneBlock == 0 {
+		return errors.New("no block number to prune history below")
+	}
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	tail, err := rawdb.PruneHistory(db, config.Eth.HistoryPruneBlock)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Chain history available from block #%d\n", tail)
+	return nil
+}
+
+// dbVerifyAncients checks the consistency of the ancient store, optionally
+// truncating it at the first corrupt block.
+func dbVerifyAncients(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return fmt.Errorf("max 1 argument: %v", ctx.Command.ArgsUsage)
+	}
+	var start uint64
+	if ctx.NArg() == 1 {
+		number, err := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid block number: %v", err)
+		}
+		start = number
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	truncate := ctx.Bool(verifyTruncateFlag.Name)
+	db := utils.MakeChainDatabase(ctx, stack, !truncate)
+	defer db.Close()
+
+	verified, err := rawdb.VerifyAncients(db, start, trie.NewStackTrie(nil))
+	var corrupt *rawdb.AncientCorruptionError
+	if !errors.As(err, &corrupt) {
+		if err != nil {
+			return err
+		}
+		log.Info("Ancient store verified", "start", start, "blocks", verified)
+		return nil
+	}
+	log.Error("Ancient store corrupted", "number", corrupt.Number, "table", corrupt.Kind, "reason", corrupt.Reason, "verified", verified)
+	if !truncate {
+		return err
+	}
+	return rawdb.TruncateAncientChain(db, corrupt.Number)
+}
+
+// dbExport exports a category of database entries into a file
+func dbExport(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	return utils.ExportDatabase(db, ctx.Args().Get(0), ctx.Args().Get(1))
+}
+
+// dbImport imports database entries from a file
+func dbImport(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	return utils.ImportDatabase(db, ctx.Args().Get(0))
+}
+
 // dbDumpTrie shows the key-value slots of a given storage trie
 func dbDumpTrie(ctx *cli.Context) error {
 	if ctx.NArg() < 1 {
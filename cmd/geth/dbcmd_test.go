@@ -0,0 +1,131 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// testRemoteDbAPI serves a single database entry through the debug namespace.
+type testRemoteDbAPI struct{}
+
+func (testRemoteDbAPI) DbGet(key hexutil.Bytes) (hexutil.Bytes, error) {
+	return append(hexutil.Bytes{0xc0, 0xff, 0xee}, key...), nil
+}
+
+// Tests that the database commands read from the remote node given with the
+// --remotedb flag, instead of opening the local chain database.
+func TestDbGetRemote(t *testing.T) {
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("debug", testRemoteDbAPI{}); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+
+	geth := runGeth(t, "db", "get", "--datadir", datadir, "--remotedb", httpServer.URL, "0x01")
+	geth.ExpectRegexp("key 0x01: 0xc0ffee01")
+	geth.ExpectExit()
+
+	if _, err := os.Stat(filepath.Join(datadir, "geth", "chaindata")); !os.IsNotExist(err) {
+		t.Errorf("local chain database opened: %v", err)
+	}
+}
+
+// Tests that verifying the ancients only truncates a corrupt ancient store if
+// the --truncate flag is given.
+func TestDbVerifyAncientsTruncate(t *testing.T) {
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+
+	// Freeze a chain of ten blocks, with the total difficulty of block 5 corrupted
+	var (
+		chaindata = filepath.Join(datadir, "geth", "chaindata")
+		ancient   = filepath.Join(chaindata, "ancient")
+	)
+	db, err := rawdb.NewLevelDBDatabaseWithFreezer(chaindata, 16, 16, ancient, "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	var (
+		parent common.Hash
+		td     = new(big.Int)
+	)
+	for i := 0; i < 10; i++ {
+		header := &types.Header{
+			ParentHash: parent,
+			Number:     big.NewInt(int64(i)),
+			Difficulty: big.NewInt(1),
+		}
+		block := types.NewBlock(header, nil, nil, nil, trie.NewStackTrie(nil))
+		td.Add(td, header.Difficulty)
+		if i == 5 {
+			rawdb.WriteAncientBlock(db, block, nil, new(big.Int).Add(td, common.Big1))
+		} else {
+			rawdb.WriteAncientBlock(db, block, nil, td)
+		}
+		parent = block.Hash()
+	}
+	db.Close()
+
+	ancients := func() uint64 {
+		db, err := rawdb.NewLevelDBDatabaseWithFreezer(chaindata, 16, 16, ancient, "", true)
+		if err != nil {
+			t.Fatalf("failed to reopen database: %v", err)
+		}
+		defer db.Close()
+
+		frozen, err := db.Ancients()
+		if err != nil {
+			t.Fatalf("failed to retrieve ancient count: %v", err)
+		}
+		return frozen
+	}
+	// Verifying without the flag should only report the corruption
+	geth := runGeth(t, "db", "verify-ancients", "--datadir", datadir)
+	geth.WaitExit()
+	if status := geth.ExitStatus(); status == 0 {
+		t.Errorf("corrupt ancients verified successfully")
+	}
+	if frozen := ancients(); frozen != 10 {
+		t.Fatalf("ancients modified without truncation: have %d, want %d", frozen, 10)
+	}
+	// Verifying with the flag should cut the ancients at the corrupt block
+	geth = runGeth(t, "db", "verify-ancients", "--datadir", datadir, "--truncate")
+	geth.WaitExit()
+	if status := geth.ExitStatus(); status != 0 {
+		t.Errorf("truncation failed with exit status %d", status)
+	}
+	if frozen := ancients(); frozen != 5 {
+		t.Fatalf("ancient count mismatch after truncation: have %d, want %d", frozen, 5)
+	}
+}
@@ -17,8 +17,10 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
+	"os"
 	"time"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
@@ -108,6 +110,7 @@ In other words, this command does the snapshot to trie conversion.
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.AncientFlag,
+					utils.RemoteDBFlag,
 					utils.RopstenFlag,
 					utils.RinkebyFlag,
 					utils.GoerliFlag,
@@ -130,6 +133,7 @@ It's also usable without snapshot enabled.
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.AncientFlag,
+					utils.RemoteDBFlag,
 					utils.RopstenFlag,
 					utils.RinkebyFlag,
 					utils.GoerliFlag,
@@ -142,6 +146,47 @@ verification. The default checking target is the HEAD state. It's basically iden
 to traverse-state, but the check granularity is smaller. 
 
 It's also usable without snapshot enabled.
+`,
+			},
+			{
+				Name:      "export",
+				Usage:     "Export the state belonging to the given root into a file",
+				ArgsUsage: "<root> <file>",
+				Action:    utils.MigrateFlags(exportSnapshot),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.RopstenFlag,
+					utils.RinkebyFlag,
+					utils.GoerliFlag,
+				},
+				Description: `
+geth snapshot export <state-root> <file>
+will stream all the accounts, storage slots and contract codes of the given
+state into the specified file, based on the state snapshot. The file is split
+into checksummed, snappy-compressed chunks.
+`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Import a state export into an empty datadir",
+				ArgsUsage: "<file>",
+				Action:    utils.MigrateFlags(importSnapshot),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.RopstenFlag,
+					utils.RinkebyFlag,
+					utils.GoerliFlag,
+				},
+				Description: `
+geth snapshot import <file>
+will load a state export created by "geth snapshot export" into an empty
+datadir and regenerate the state trie from it. The imported state is marked
+as a complete snapshot, so it's immediately usable once the chain containing
+the state root is available.
 `,
 			},
 		},
@@ -266,7 +311,7 @@ func traverseState(ctx *cli.Context) error {
 			return err
 		}
 		if acc.Root != emptyRoot {
-			storageTrie, err := trie.NewSecure(acc.Root, triedb)
+			storageTrie, err := trie.NewSecureWithOwner(common.BytesToHash(accIter.Key), acc.Root, triedb)
 			if err != nil {
 				log.Error("Failed to open storage trie", "root", acc.Root, "error", err)
 				return err
@@ -335,6 +380,9 @@ func traverseRawState(ctx *cli.Context) error {
 		log.Info("Start traversing the state", "root", root, "number", headBlock.NumberU64())
 	}
 	triedb := trie.NewDatabase(chaindb)
+	if triedb.Scheme() == rawdb.PathScheme {
+		return errors.New("raw state traversal unsupported with path-based trie node storage")
+	}
 	t, err := trie.NewSecure(root, triedb)
 	if err != nil {
 		log.Error("Failed to open trie", "root", root, "error", err)
@@ -372,7 +420,7 @@ func traverseRawState(ctx *cli.Context) error {
 				return errors.New("invalid account")
 			}
 			if acc.Root != emptyRoot {
-				storageTrie, err := trie.NewSecure(acc.Root, triedb)
+				storageTrie, err := trie.NewSecureWithOwner(common.BytesToHash(accIter.LeafKey()), acc.Root, triedb)
 				if err != nil {
 					log.Error("Failed to open storage trie", "root", acc.Root, "error", err)
 					return errors.New("missing storage trie")
@@ -423,6 +471,81 @@ func traverseRawState(ctx *cli.Context) error {
 	return nil
 }
 
+// exportSnapshot streams the state belonging to the given root into a file
+// using the snapshot iterators.
+func exportSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		log.Error("Wrong number of arguments given")
+		return errors.New("need <root> and <file> arguments")
+	}
+	root, err := parseRoot(ctx.Args()[0])
+	if err != nil {
+		log.Error("Failed to resolve state root", "error", err)
+		return err
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, true)
+	headBlock := rawdb.ReadHeadBlock(chaindb)
+	if headBlock == nil {
+		log.Error("Failed to load head block")
+		return errors.New("no head block")
+	}
+	snaptree, err := snapshot.New(chaindb, trie.NewDatabase(chaindb), 256, headBlock.Root(), false, false, false)
+	if err != nil {
+		log.Error("Failed to open snapshot tree", "error", err)
+		return err
+	}
+	out, err := os.Create(ctx.Args()[1])
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer := bufio.NewWriter(out)
+	if err := snapshot.Export(snaptree, root, chaindb, writer); err != nil {
+		log.Error("Failed to export state", "root", root, "error", err)
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// importSnapshot loads a state export into an empty datadir, regenerating
+// the state trie from it.
+func importSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		log.Error("Wrong number of arguments given")
+		return errors.New("need <file> argument")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	defer chaindb.Close()
+
+	if rawdb.ReadHeadHeaderHash(chaindb) != (common.Hash{}) {
+		log.Error("Datadir is not empty")
+		return errors.New("datadir is not empty")
+	}
+	in, err := os.Open(ctx.Args()[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	root, err := snapshot.Import(in, chaindb)
+	if err != nil {
+		log.Error("Failed to import state", "error", err)
+		return err
+	}
+	log.Info("Imported state", "root", root)
+	return nil
+}
+
 func parseRoot(input string) (common.Hash, error) {
 	var h common.Hash
 	if err := h.UnmarshalText([]byte(input)); err != nil {
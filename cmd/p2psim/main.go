@@ -165,6 +165,34 @@ func main() {
 					Usage:     "disconnect a node from a peer node",
 					Action:    disconnectNode,
 				},
+				{
+					Name:      "link",
+					ArgsUsage: "<node> <peer>",
+					Usage:     "set the network conditions between a node and a peer node",
+					Action:    linkNode,
+					Flags: []cli.Flag{
+						cli.DurationFlag{
+							Name:  "latency",
+							Usage: "one-way delay of transmitted data",
+						},
+						cli.DurationFlag{
+							Name:  "jitter",
+							Usage: "maximum random deviation from the latency",
+						},
+						cli.Uint64Flag{
+							Name:  "bandwidth",
+							Usage: "throughput cap in bytes per second (0 = unlimited)",
+						},
+						cli.Float64Flag{
+							Name:  "loss",
+							Usage: "probability of a write being lost and retransmitted",
+						},
+						cli.BoolFlag{
+							Name:  "partition",
+							Usage: "cut the link entirely",
+						},
+					},
+				},
 				{
 					Name:      "rpc",
 					ArgsUsage: "<node> <method> [<args>]",
@@ -378,6 +406,27 @@ func disconnectNode(ctx *cli.Context) error {
 	return nil
 }
 
+func linkNode(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	nodeName := args[0]
+	peerName := args[1]
+	model := &adapters.LinkModel{
+		Latency:     ctx.Duration("latency"),
+		Jitter:      ctx.Duration("jitter"),
+		Bandwidth:   ctx.Uint64("bandwidth"),
+		Loss:        ctx.Float64("loss"),
+		Partitioned: ctx.Bool("partition"),
+	}
+	if err := client.SetLinkModel(nodeName, peerName, model); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.App.Writer, "Set link between %s and %s to %+v\n", nodeName, peerName, *model)
+	return nil
+}
+
 func rpcNode(ctx *cli.Context) error {
 	args := ctx.Args()
 	if len(args) < 2 {
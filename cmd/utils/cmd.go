@@ -18,9 +18,12 @@
 package utils
 
 import (
+	"bytes"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
 	"os/signal"
 	"runtime"
@@ -344,3 +347,204 @@ func ExportPreimages(db ethdb.Database, fn string) error {
 	log.Info("Exported preimages", "file", fn)
 	return nil
 }
+
+const (
+	// dbDumpMagic identifies the streams produced by ExportDatabase.
+	dbDumpMagic = "gethdbdump"
+
+	// dbDumpVersion is the version of the database dump format.
+	dbDumpVersion = 1
+)
+
+// Database dump entry operations.
+const (
+	dbDumpOpPut = iota // Store the value under the key
+	dbDumpOpEnd        // Closing entry, the key is the checksum and the value the entry count
+)
+
+// dbDumpHeader is the first item of a database dump, identifying its content.
+type dbDumpHeader struct {
+	Magic    string
+	Version  uint64
+	Category string
+	UnixTime uint64
+}
+
+// dbDumpEntry is a single database operation within a database dump.
+type dbDumpEntry struct {
+	Op  uint
+	Key []byte
+	Val []byte
+}
+
+// ExportDatabase exports all the database entries of the named data category
+// into the specified file, truncating any data already present in the file. The
+// dump is a gzip compressed stream of RLP items: a header, the entries, and a
+// closing entry carrying their count and a keccak256 checksum over them.
+func ExportDatabase(db ethdb.Database, category string, fn string) error {
+	it, err := rawdb.IterateKeyCategory(db, category)
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	log.Info("Exporting database entries", "category", category, "file", fn)
+
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	writer := gzip.NewWriter(fh)
+	header := &dbDumpHeader{
+		Magic:    dbDumpMagic,
+		Version:  dbDumpVersion,
+		Category: category,
+		UnixTime: uint64(time.Now().Unix()),
+	}
+	if err := rlp.Encode(writer, header); err != nil {
+		return err
+	}
+	var (
+		hasher = crypto.NewKeccakState()
+		count  uint64
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for it.Next() {
+		blob, err := rlp.EncodeToBytes(&dbDumpEntry{Op: dbDumpOpPut, Key: it.Key(), Val: it.Value()})
+		if err != nil {
+			return err
+		}
+		hasher.Write(blob)
+		if _, err := writer.Write(blob); err != nil {
+			return err
+		}
+		count++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting database entries", "category", category, "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	end := &dbDumpEntry{Op: dbDumpOpEnd, Key: hasher.Sum(nil), Val: new(big.Int).SetUint64(count).Bytes()}
+	if err := rlp.Encode(writer, end); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	log.Info("Exported database entries", "category", category, "file", fn, "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// ImportDatabase imports a database dump created by ExportDatabase. The whole
+// dump is verified against its checksum first, nothing is written to the
+// database if it's corrupted or contains keys outside of its data category.
+func ImportDatabase(db ethdb.Database, fn string) error {
+	category, count, err := iterateDatabaseDump(fn, nil)
+	if err != nil {
+		return err
+	}
+	log.Info("Importing database entries", "category", category, "file", fn, "count", count)
+
+	var (
+		batch    = db.NewBatch()
+		imported uint64
+		start    = time.Now()
+		logged   = time.Now()
+	)
+	_, _, err = iterateDatabaseDump(fn, func(entry *dbDumpEntry) error {
+		if err := batch.Put(entry.Key, entry.Val); err != nil {
+			return err
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		imported++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing database entries", "category", category, "imported", imported, "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Imported database entries", "category", category, "file", fn, "count", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// iterateDatabaseDump reads a database dump, invoking the callback for every
+// entry if it's set. The data category and the number of entries are returned
+// after the checksum of the dump was verified.
+func iterateDatabaseDump(fn string, callback func(entry *dbDumpEntry) error) (string, uint64, error) {
+	fh, err := os.Open(fn)
+	if err != nil {
+		return "", 0, err
+	}
+	defer fh.Close()
+
+	reader, err := gzip.NewReader(fh)
+	if err != nil {
+		return "", 0, err
+	}
+	stream := rlp.NewStream(reader, 0)
+
+	var header dbDumpHeader
+	if err := stream.Decode(&header); err != nil {
+		return "", 0, fmt.Errorf("invalid database dump header: %v", err)
+	}
+	switch {
+	case header.Magic != dbDumpMagic:
+		return "", 0, errors.New("not a database dump")
+	case header.Version != dbDumpVersion:
+		return "", 0, fmt.Errorf("unsupported database dump version %d", header.Version)
+	}
+	var (
+		hasher = crypto.NewKeccakState()
+		count  uint64
+	)
+	for {
+		blob, err := stream.Raw()
+		if err != nil {
+			return "", 0, fmt.Errorf("entry %d: %v", count, err)
+		}
+		var entry dbDumpEntry
+		if err := rlp.DecodeBytes(blob, &entry); err != nil {
+			return "", 0, fmt.Errorf("entry %d: %v", count, err)
+		}
+		switch entry.Op {
+		case dbDumpOpPut:
+			if !rawdb.IsKeyInCategory(header.Category, entry.Key) {
+				return "", 0, fmt.Errorf("entry %d: key %#x outside of category %q", count, entry.Key, header.Category)
+			}
+			hasher.Write(blob)
+			count++
+			if callback != nil {
+				if err := callback(&entry); err != nil {
+					return "", 0, err
+				}
+			}
+		case dbDumpOpEnd:
+			if have := new(big.Int).SetBytes(entry.Val); !have.IsUint64() || have.Uint64() != count {
+				return "", 0, fmt.Errorf("entry count mismatch: have %d, want %v", count, have)
+			}
+			if sum := hasher.Sum(nil); !bytes.Equal(sum, entry.Key) {
+				return "", 0, fmt.Errorf("checksum mismatch: have %x, want %x", sum, entry.Key)
+			}
+			return header.Category, count, nil
+		default:
+			return "", 0, fmt.Errorf("entry %d: unknown operation %d", count, entry.Op)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that database entries can be moved between databases by data category.
+func TestDatabaseExportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbdump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Fill a database with entries of several categories
+	src := rawdb.NewMemoryDatabase()
+	preimages := make(map[common.Hash][]byte)
+	for i := 0; i < 100; i++ {
+		blob := []byte{byte(i), 0xaa}
+		preimages[crypto.Keccak256Hash(blob)] = blob
+		rawdb.WriteCode(src, crypto.Keccak256Hash(blob, blob), append(blob, blob...))
+	}
+	rawdb.WritePreimages(src, preimages)
+	rawdb.WriteTrieNode(src, common.Hash{0x01}, []byte{0x01})
+
+	// Export and import the preimages, nothing else may be moved
+	fn := filepath.Join(dir, "preimages.gz")
+	if err := ExportDatabase(src, "preimage", fn); err != nil {
+		t.Fatalf("failed to export preimages: %v", err)
+	}
+	dst := rawdb.NewMemoryDatabase()
+	if err := ImportDatabase(dst, fn); err != nil {
+		t.Fatalf("failed to import preimages: %v", err)
+	}
+	for hash, blob := range preimages {
+		if have := rawdb.ReadPreimage(dst, hash); !bytes.Equal(have, blob) {
+			t.Errorf("preimage %x mismatch: have %x, want %x", hash, have, blob)
+		}
+	}
+	it := dst.NewIterator(nil, nil)
+	count := 0
+	for it.Next() {
+		count++
+	}
+	it.Release()
+	if count != len(preimages) {
+		t.Errorf("imported entry count mismatch: have %d, want %d", count, len(preimages))
+	}
+	if err := ExportDatabase(src, "unknown", fn); err == nil {
+		t.Errorf("unknown category exported")
+	}
+}
+
+// Tests that corrupted or mislabeled database dumps are rejected without writing
+// anything into the database.
+func TestDatabaseImportInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbdump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// writeDump creates a dump from raw entries, returning its file name
+	var dumps int
+	writeDump := func(category string, entries []*dbDumpEntry, checksum []byte) string {
+		dumps++
+		fn := filepath.Join(dir, fmt.Sprintf("%s-%d.gz", category, dumps))
+		fh, err := os.Create(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fh.Close()
+
+		writer := gzip.NewWriter(fh)
+		defer writer.Close()
+
+		rlp.Encode(writer, &dbDumpHeader{Magic: dbDumpMagic, Version: dbDumpVersion, Category: category})
+		hasher := crypto.NewKeccakState()
+		for _, entry := range entries {
+			blob, _ := rlp.EncodeToBytes(entry)
+			hasher.Write(blob)
+			writer.Write(blob)
+		}
+		if checksum == nil {
+			checksum = hasher.Sum(nil)
+		}
+		rlp.Encode(writer, &dbDumpEntry{Op: dbDumpOpEnd, Key: checksum, Val: []byte{byte(len(entries))}})
+		return fn
+	}
+	code := &dbDumpEntry{Op: dbDumpOpPut, Key: append(common.CopyBytes(rawdb.CodePrefix), make([]byte, common.HashLength)...), Val: []byte{0x01}}
+	tests := []struct {
+		fn   string
+		fail bool
+	}{
+		{writeDump("code", []*dbDumpEntry{code}, nil), false},
+		{writeDump("code", []*dbDumpEntry{code}, make([]byte, common.HashLength)), true},
+		{writeDump("preimage", []*dbDumpEntry{code}, nil), true},
+	}
+	for i, tt := range tests {
+		db := rawdb.NewMemoryDatabase()
+		err := ImportDatabase(db, tt.fn)
+		if (err != nil) != tt.fail {
+			t.Errorf("test %d: import failure mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+		if has, _ := db.Has(code.Key); has == tt.fail {
+			t.Errorf("test %d: entry presence mismatch: have %v, want %v", i, has, !tt.fail)
+		}
+	}
+}
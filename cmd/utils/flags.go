@@ -50,6 +50,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/remotedb"
 	"github.com/ethereum/go-ethereum/ethstats"
 	"github.com/ethereum/go-ethereum/graphql"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -66,6 +67,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	gopsutil "github.com/shirou/gopsutil/mem"
 	"gopkg.in/urfave/cli.v1"
@@ -117,6 +119,10 @@ var (
 		Name:  "datadir.ancient",
 		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
 	}
+	RemoteDBFlag = cli.StringFlag{
+		Name:  "remotedb",
+		Usage: "URL of a running node whose database is read through its debug RPC API (read-only)",
+	}
 	MinFreeDiskSpaceFlag = DirectoryFlag{
 		Name:  "datadir.minfreedisk",
 		Usage: "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
@@ -211,6 +217,15 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	StateSchemeFlag = cli.StringFlag{
+		Name:  "state.scheme",
+		Usage: `Scheme to use for storing the state trie nodes ("hash", "path"), defaults to the one in the database`,
+	}
+	StateHistoryFlag = cli.Uint64Flag{
+		Name:  "state.history",
+		Usage: "Number of recent blocks to keep reverse state diffs for, serving their state without an archive node (0 = disabled)",
+		Value: ethconfig.Defaults.StateHistory,
+	}
 	SnapshotFlag = cli.BoolTFlag{
 		Name:  "snapshot",
 		Usage: `Enables snapshot-database mode (default = enable)`,
@@ -612,6 +627,11 @@ var (
 		Name:  "rpc.allow-unprotected-txs",
 		Usage: "Allow for unprotected (non EIP155 signed) transactions to be submitted via RPC",
 	}
+	JWTSecretFlag = cli.StringFlag{
+		Name:  "rpc.jwtsecret",
+		Usage: "Path to a JWT secret used to authenticate HTTP-RPC and WS-RPC requests (created if missing)",
+		Value: "",
+	}
 
 	// Network Settings
 	MaxPeersFlag = cli.IntFlag{
@@ -958,6 +978,9 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(AllowUnprotectedTxs.Name) {
 		cfg.AllowUnprotectedTxs = ctx.GlobalBool(AllowUnprotectedTxs.Name)
 	}
+	if ctx.GlobalIsSet(JWTSecretFlag.Name) {
+		cfg.JWTSecret = ctx.GlobalString(JWTSecretFlag.Name)
+	}
 }
 
 // setGraphQL creates the GraphQL listener interface string from the set
@@ -1280,6 +1303,8 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config, light bool) {
 	if light {
 		cfg.Blocks = ethconfig.LightClientGPO.Blocks
 		cfg.Percentile = ethconfig.LightClientGPO.Percentile
+		cfg.MaxHeaderHistory = ethconfig.LightClientGPO.MaxHeaderHistory
+		cfg.MaxBlockHistory = ethconfig.LightClientGPO.MaxBlockHistory
 	}
 	if ctx.GlobalIsSet(GpoBlocksFlag.Name) {
 		cfg.Blocks = ctx.GlobalInt(GpoBlocksFlag.Name)
@@ -1513,6 +1538,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
+	if ctx.GlobalIsSet(StateSchemeFlag.Name) {
+		cfg.StateScheme = ctx.GlobalString(StateSchemeFlag.Name)
+	}
+	if ctx.GlobalIsSet(StateHistoryFlag.Name) {
+		cfg.StateHistory = ctx.GlobalUint64(StateHistoryFlag.Name)
+	}
 	if ctx.GlobalIsSet(GCModeFlag.Name) {
 		cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 	}
@@ -1785,6 +1816,16 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) ethdb.
 		err     error
 		chainDb ethdb.Database
 	)
+	if url := ctx.String(RemoteDBFlag.Name); url != "" {
+		if !readonly {
+			Fatalf("Remote database is read-only")
+		}
+		client, err := rpc.Dial(url)
+		if err != nil {
+			Fatalf("Could not connect to remote database: %v", err)
+		}
+		return remotedb.New(client)
+	}
 	if ctx.GlobalString(SyncModeFlag.Name) == "light" {
 		name := "lightchaindata"
 		chainDb, err = stack.OpenDatabase(name, cache, handles, "", readonly)
@@ -1821,6 +1862,13 @@ func MakeGenesis(ctx *cli.Context) *core.Genesis {
 func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chainDb ethdb.Database) {
 	var err error
 	chainDb = MakeChainDatabase(ctx, stack, false) // TODO(rjl493456442) support read-only database
+	scheme, err := rawdb.ParseStateScheme(ctx.GlobalString(StateSchemeFlag.Name), chainDb)
+	if err != nil {
+		Fatalf("%v", err)
+	}
+	if scheme == rawdb.PathScheme && ctx.GlobalString(GCModeFlag.Name) == "archive" {
+		Fatalf("--%s=path is incompatible with --%s=archive", StateSchemeFlag.Name, GCModeFlag.Name)
+	}
 	config, _, err := core.SetupGenesisBlock(chainDb, MakeGenesis(ctx))
 	if err != nil {
 		Fatalf("%v", err)
@@ -91,6 +91,14 @@ const (
 	maxTimeFutureBlocks = 30
 	TriesInMemory       = 128
 
+	// stateHistoryDelay is the number of blocks the reverse state diffs are kept
+	// in the key-value store before being moved into the state history, allowing
+	// them to be swapped out by reorgs.
+	stateHistoryDelay = TriesInMemory
+
+	exportBatchItems = 1024             // Maximum number of blocks read at once during export
+	exportBatchSize  = 16 * 1024 * 1024 // Maximum size of block bodies read at once during export
+
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
 	//
 	// Changelog:
@@ -129,6 +137,8 @@ type CacheConfig struct {
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whether to store preimage of trie key to the disk
+	StateHistory        uint64        // Number of recent blocks to keep reverse state diffs for (0 = disabled)
+	StateHistoryDir     string        // Directory of the freezer holding the reverse state diffs
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -161,10 +171,11 @@ type BlockChain struct {
 	chainConfig *params.ChainConfig // Chain & network configuration
 	cacheConfig *CacheConfig        // Cache configuration for pruning
 
-	db     ethdb.Database // Low level persistent database to store final content in
-	snaps  *snapshot.Tree // Snapshot tree for fast trie leaf access
-	triegc *prque.Prque   // Priority queue mapping block numbers to tries to gc
-	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
+	db           ethdb.Database      // Low level persistent database to store final content in
+	snaps        *snapshot.Tree      // Snapshot tree for fast trie leaf access
+	stateHistory *rawdb.StateHistory // Reverse state diffs of the recent canonical blocks, nil if disabled
+	triegc       *prque.Prque        // Priority queue mapping block numbers to tries to gc
+	gcproc       time.Duration       // Accumulates canonical block processing for trie dumping
 
 	// txLookupLimit is the maximum number of blocks from head whose tx indices
 	// are reserved:
@@ -256,7 +267,22 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if err != nil {
 		return nil, err
 	}
+	if cacheConfig.StateHistory > 0 {
+		if cacheConfig.SnapshotLimit == 0 {
+			return nil, errors.New("state history requires snapshots")
+		}
+		if bc.stateHistory, err = rawdb.OpenStateHistory(cacheConfig.StateHistoryDir); err != nil {
+			return nil, err
+		}
+	}
 	bc.genesisBlock = bc.GetBlockByNumber(0)
+	if bc.genesisBlock == nil {
+		// The genesis body might have been deleted by history expiry, but as it
+		// is always empty, the block can be reconstructed from the header.
+		if header := bc.GetHeaderByNumber(0); header != nil && header.TxHash == types.EmptyRootHash && header.UncleHash == types.EmptyUncleHash {
+			bc.genesisBlock = types.NewBlockWithHeader(header)
+		}
+	}
 	if bc.genesisBlock == nil {
 		return nil, ErrNoGenesis
 	}
@@ -621,6 +647,12 @@ func (bc *BlockChain) SetHeadBeyondRoot(head uint64, root common.Hash) (uint64,
 	bc.txLookupCache.Purge()
 	bc.futureBlocks.Purge()
 
+	// Drop the reverse state diffs of the rewound blocks
+	if bc.stateHistory != nil {
+		if err := bc.stateHistory.TruncateHead(bc.CurrentBlock().NumberU64() + 1); err != nil {
+			log.Error("Failed to truncate state history", "err", err)
+		}
+	}
 	return rootNumber, bc.loadLastState()
 }
 
@@ -747,16 +779,24 @@ func (bc *BlockChain) ExportN(w io.Writer, first uint64, last uint64) error {
 	log.Info("Exporting batch of blocks", "count", last-first+1)
 
 	start, reported := time.Now(), time.Now()
-	for nr := first; nr <= last; nr++ {
-		block := bc.GetBlockByNumber(nr)
-		if block == nil {
+	for nr := first; nr <= last; {
+		// Read the blocks in batches to benefit from ranged ancient reads
+		count := last - nr + 1
+		if count > exportBatchItems {
+			count = exportBatchItems
+		}
+		blocks := rawdb.ReadBlockRange(bc.db, nr, count, exportBatchSize)
+		if len(blocks) == 0 {
 			return fmt.Errorf("export failed on #%d: not found", nr)
 		}
-		if err := block.EncodeRLP(w); err != nil {
-			return err
+		for _, block := range blocks {
+			if err := block.EncodeRLP(w); err != nil {
+				return err
+			}
+			nr++
 		}
 		if time.Since(reported) >= statsReportLimit {
-			log.Info("Exporting blocks", "exported", block.NumberU64()-first, "elapsed", common.PrettyDuration(time.Since(start)))
+			log.Info("Exporting blocks", "exported", nr-first, "elapsed", common.PrettyDuration(time.Since(start)))
 			reported = time.Now()
 		}
 	}
@@ -1017,9 +1057,16 @@ func (bc *BlockChain) Stop() {
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
 	//  - HEAD-1:   So we don't do large reorgs if our HEAD becomes an uncle
 	//  - HEAD-127: So we have a hard limit on the number of blocks reexecuted
-	if !bc.cacheConfig.TrieDirtyDisabled {
-		triedb := bc.stateCache.TrieDB()
-
+	if triedb := bc.stateCache.TrieDB(); triedb.Scheme() == rawdb.PathScheme {
+		// Trie nodes stored by path only retain a single state on disk, flush
+		// the head one to avoid reprocessing any blocks.
+		if head := bc.CurrentBlock(); head.NumberU64() > 0 {
+			log.Info("Writing cached state to disk", "block", head.Number(), "hash", head.Hash(), "root", head.Root())
+			if err := triedb.Commit(head.Root(), true, nil); err != nil {
+				log.Error("Failed to commit recent state trie", "err", err)
+			}
+		}
+	} else if !bc.cacheConfig.TrieDirtyDisabled {
 		for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
 			if number := bc.CurrentBlock().NumberU64(); number > offset {
 				recent := bc.GetBlockByNumber(number - offset)
@@ -1049,6 +1096,11 @@ func (bc *BlockChain) Stop() {
 		triedb := bc.stateCache.TrieDB()
 		triedb.SaveCache(bc.cacheConfig.TrieCleanJournal)
 	}
+	if bc.stateHistory != nil {
+		if err := bc.stateHistory.Close(); err != nil {
+			log.Error("Failed to close state history", "err", err)
+		}
+	}
 	log.Info("Blockchain stopped")
 }
 
@@ -1531,10 +1583,30 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	if err != nil {
 		return NonStatTy, err
 	}
+	// Stage the reverse state diff until the block is deep enough to be frozen
+	if bc.stateHistory != nil {
+		if diff := state.ReverseDiff(); diff != nil {
+			blob, err := rlp.EncodeToBytes(diff)
+			if err != nil {
+				return NonStatTy, err
+			}
+			rawdb.WriteReverseDiff(bc.db, block.NumberU64(), block.Hash(), blob)
+		}
+	}
 	triedb := bc.stateCache.TrieDB()
 
-	// If we're running an archive node, always flush
-	if bc.cacheConfig.TrieDirtyDisabled {
+	// If trie nodes are stored by path, keep the recent states as diff layers
+	// and flatten the older ones into the disk, no garbage collection needed.
+	if triedb.Scheme() == rawdb.PathScheme {
+		parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+		if err := triedb.Update(root, parent.Root); err != nil {
+			return NonStatTy, err
+		}
+		if err := triedb.CapLayers(root, TriesInMemory); err != nil {
+			return NonStatTy, err
+		}
+	} else if bc.cacheConfig.TrieDirtyDisabled {
+		// If we're running an archive node, always flush
 		if err := triedb.Commit(root, false, nil); err != nil {
 			return NonStatTy, err
 		}
@@ -1617,6 +1689,9 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.writeHeadBlock(block)
+		if bc.stateHistory != nil {
+			bc.freezeStateHistory(block.NumberU64())
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 
@@ -1639,6 +1714,95 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	return status, nil
 }
 
+// freezeStateHistory moves the staged reverse state diffs of the canonical blocks
+// deep enough to be final into the state history, dropping the diffs of the side
+// blocks at the same heights. The history is then trimmed to the configured number
+// of recent blocks.
+func (bc *BlockChain) freezeStateHistory(head uint64) {
+	if head < stateHistoryDelay {
+		return
+	}
+	limit := head - stateHistoryDelay
+	// Staged diffs only exist for recently executed blocks, don't scan further back
+	// if the history fell behind, e.g. because it was disabled for a while or the
+	// chain was synced without execution. Skipped blocks are marked unavailable.
+	_, next := bc.stateHistory.Range()
+	if next+stateHistoryDelay < limit {
+		next = limit - stateHistoryDelay
+	}
+	var frozen []uint64
+	for number := next; number <= limit; number++ {
+		hash := rawdb.ReadCanonicalHash(bc.db, number)
+		diff := rawdb.ReadReverseDiff(bc.db, number, hash)
+		if err := bc.stateHistory.Append(number, hash, diff); err != nil {
+			log.Error("Failed to freeze reverse state diff", "number", number, "err", err)
+			return
+		}
+		frozen = append(frozen, number)
+	}
+	if len(frozen) == 0 {
+		return
+	}
+	if err := bc.stateHistory.Sync(); err != nil {
+		log.Error("Failed to sync state history", "err", err)
+		return
+	}
+	for _, number := range frozen {
+		rawdb.DeleteReverseDiffs(bc.db, number)
+	}
+	if retain := bc.cacheConfig.StateHistory; head >= retain {
+		if err := bc.stateHistory.TruncateTail(head - retain + 1); err != nil {
+			log.Error("Failed to prune state history", "err", err)
+		}
+	}
+}
+
+// HistoricStateAt returns a read-only state of the given canonical block, rolled
+// back from the snapshot of the current head with the reverse state diffs of the
+// blocks since. It's meant for blocks whose tries are no longer available.
+func (bc *BlockChain) HistoricStateAt(header *types.Header) (*state.StateDB, error) {
+	if bc.stateHistory == nil || bc.snaps == nil {
+		return nil, errors.New("state history not enabled")
+	}
+	var (
+		head   = bc.CurrentBlock()
+		number = header.Number.Uint64()
+	)
+	if number > head.NumberU64() {
+		return nil, fmt.Errorf("block #%d above head #%d", number, head.NumberU64())
+	}
+	if bc.GetCanonicalHash(number) != header.Hash() {
+		return nil, fmt.Errorf("block #%d [%x…] not canonical", number, header.Hash().Bytes()[:4])
+	}
+	base := bc.snaps.Snapshot(head.Root())
+	if base == nil {
+		return nil, fmt.Errorf("snapshot of head #%d missing", head.NumberU64())
+	}
+	diffs := make([]*snapshot.ReverseDiff, 0, head.NumberU64()-number)
+	for n := number + 1; n <= head.NumberU64(); n++ {
+		// Staged diffs are frozen before being deleted, check them first
+		hash := rawdb.ReadCanonicalHash(bc.db, n)
+		blob := rawdb.ReadReverseDiff(bc.db, n, hash)
+		if blob == nil {
+			stored, frozen, err := bc.stateHistory.Read(n)
+			if err != nil {
+				return nil, fmt.Errorf("reverse state diff of block #%d: %w", n, err)
+			}
+			if stored != hash {
+				return nil, fmt.Errorf("reverse state diff of block #%d: %w", n, rawdb.ErrStateHistoryUnavailable)
+			}
+			blob = frozen
+		}
+		diff := new(snapshot.ReverseDiff)
+		if err := rlp.DecodeBytes(blob, diff); err != nil {
+			return nil, fmt.Errorf("invalid reverse state diff of block #%d: %v", n, err)
+		}
+		diffs = append(diffs, diff)
+	}
+	db := state.NewHistoricDatabase(bc.stateCache, snapshot.NewHistoricState(base, diffs))
+	return state.New(header.Root, db, nil)
+}
+
 // addFutureBlock checks if the block is within the max allowed window to get
 // accepted for future processing, and returns an error if the block is too far
 // ahead and was not added.
@@ -1876,6 +2040,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		if err != nil {
 			return it.index, err
 		}
+		if bc.stateHistory != nil {
+			statedb.EnableReverseDiff()
+		}
 		// Enable prefetching to pull in trie node paths while processing transactions
 		statedb.StartPrefetcher("chain")
 		activeState = statedb
@@ -2242,6 +2409,12 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	} else {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
+	// Drop the frozen reverse state diffs of the blocks reorged out
+	if bc.stateHistory != nil {
+		if err := bc.stateHistory.TruncateHead(commonBlock.NumberU64() + 1); err != nil {
+			log.Error("Failed to truncate state history", "err", err)
+		}
+	}
 	// Insert the new chain(except the head block(reverse order)),
 	// taking care of the proper incremental order.
 	for i := len(newChain) - 1; i >= 1; i-- {
@@ -2498,6 +2671,12 @@ func (bc *BlockChain) GetHeaderByNumber(number uint64) *types.Header {
 	return bc.hc.GetHeaderByNumber(number)
 }
 
+// GetHeadersFrom retrieves up to count consecutive canonical headers starting at
+// number, limiting their combined encoded size to maxBytes.
+func (bc *BlockChain) GetHeadersFrom(number, count, maxBytes uint64) []*types.Header {
+	return bc.hc.GetHeadersFrom(number, count, maxBytes)
+}
+
 // GetTransactionLookup retrieves the lookup associate with the given transaction
 // hash from the cache or database.
 func (bc *BlockChain) GetTransactionLookup(hash common.Hash) *rawdb.LegacyTxLookupEntry {
@@ -3108,3 +3108,218 @@ func TestEIP2718Transition(t *testing.T) {
 
 	}
 }
+
+// Tests that a chain storing the trie nodes by path retains the recent states in
+// memory, persists a single state on disk and recovers it after a restart.
+func TestPathSchemeChain(t *testing.T) {
+	var (
+		bb = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+
+		engine = ethash.NewFaker()
+		gendb  = rawdb.NewMemoryDatabase()
+
+		// A sender who makes transactions, has some funds
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: funds},
+				// The address 0xBBBB stores the block number in the slot of the same index
+				bb: {
+					Code:    []byte{byte(vm.NUMBER), byte(vm.NUMBER), byte(vm.SSTORE)},
+					Balance: big.NewInt(0),
+				},
+			},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, gendb, 2*TriesInMemory, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), bb, big.NewInt(1), 50000, big.NewInt(1), nil), signer, key)
+		b.AddTx(tx)
+	})
+	// Import the chain into a database storing the trie nodes by path
+	diskdb := rawdb.NewMemoryDatabase()
+	rawdb.WriteStateScheme(diskdb, rawdb.PathScheme)
+	gspec.MustCommit(diskdb)
+
+	cacheConfig := &CacheConfig{
+		TrieCleanLimit: 256,
+		TrieDirtyLimit: 256,
+		TrieTimeLimit:  5 * time.Minute,
+	}
+	chain, err := NewBlockChain(diskdb, cacheConfig, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	// The recent states should be available, the older ones flattened away
+	for i, block := range blocks {
+		want := i >= len(blocks)-1-TriesInMemory
+		if have := chain.HasState(block.Root()); have != want {
+			t.Errorf("block %d: state availability mismatch: have %v, want %v", block.NumberU64(), have, want)
+		}
+	}
+	chain.Stop()
+
+	// Restart the chain and ensure the head state was persisted
+	chain, err = NewBlockChain(diskdb, cacheConfig, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	head := blocks[len(blocks)-1]
+	if have := chain.CurrentBlock().Hash(); have != head.Hash() {
+		t.Fatalf("head block mismatch: have %x, want %x", have, head.Hash())
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	for _, number := range []int64{1, TriesInMemory, 2 * TriesInMemory} {
+		slot := common.BigToHash(big.NewInt(number))
+		if have := statedb.GetState(bb, slot); have != slot {
+			t.Errorf("slot %d mismatch: have %x, want %x", number, have, slot)
+		}
+	}
+	if have, want := statedb.GetBalance(bb), big.NewInt(2*TriesInMemory); have.Cmp(want) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", have, want)
+	}
+	if chain.HasState(blocks[len(blocks)-2].Root()) {
+		t.Errorf("stale state available after restart")
+	}
+}
+
+// Tests that the state of old blocks, whose tries were garbage collected, can be
+// served by rolling the snapshot back with the reverse state diffs.
+func TestStateHistory(t *testing.T) {
+	var (
+		bb = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+		cc = common.HexToAddress("0x000000000000000000000000000000000000cccc")
+
+		engine = ethash.NewFaker()
+		gendb  = rawdb.NewMemoryDatabase()
+
+		// A sender who makes transactions, has some funds
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: funds},
+				// The address 0xBBBB stores the block number in slot zero
+				bb: {
+					Code:    []byte{byte(vm.NUMBER), byte(vm.PUSH1), 0x00, byte(vm.SSTORE)},
+					Balance: big.NewInt(0),
+				},
+				// The address 0xCCCC selfdestructs, wiping its storage
+				cc: {
+					Code:    []byte{byte(vm.PUSH2), 0xbb, 0xbb, byte(vm.SELFDESTRUCT)},
+					Storage: map[common.Hash]common.Hash{{0x01}: {0x01}},
+					Balance: big.NewInt(1),
+				},
+			},
+		}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, gendb, 2*TriesInMemory, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), bb, big.NewInt(1), 50000, big.NewInt(1), nil), signer, key)
+		b.AddTx(tx)
+		if i == TriesInMemory/2 {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), cc, big.NewInt(0), 50000, big.NewInt(1), nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	// Import the chain into an archive node as reference
+	archivedb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(archivedb)
+
+	archive, err := NewBlockChain(archivedb, &CacheConfig{TrieDirtyDisabled: true}, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create archive chain: %v", err)
+	}
+	defer archive.Stop()
+	if n, err := archive.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into archive chain: %v", n, err)
+	}
+	// Import the chain into a full node retaining the state history
+	dir, err := ioutil.TempDir("", "statehistory")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	cacheConfig := &CacheConfig{
+		TrieCleanLimit:  256,
+		TrieDirtyLimit:  256,
+		TrieTimeLimit:   5 * time.Minute,
+		SnapshotLimit:   256,
+		SnapshotWait:    true,
+		StateHistory:    4 * TriesInMemory,
+		StateHistoryDir: dir,
+	}
+	chain, err := NewBlockChain(diskdb, cacheConfig, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	if chain.HasState(blocks[0].Root()) {
+		t.Fatalf("old state not garbage collected")
+	}
+	check := func(chain *BlockChain) {
+		for number := uint64(0); number <= uint64(len(blocks)); number++ {
+			header := chain.GetHeaderByNumber(number)
+
+			have, err := chain.HistoricStateAt(header)
+			if err != nil {
+				t.Fatalf("block %d: failed to open historic state: %v", number, err)
+			}
+			want, err := archive.StateAt(header.Root)
+			if err != nil {
+				t.Fatalf("block %d: failed to open archive state: %v", number, err)
+			}
+			for _, addr := range []common.Address{address, bb, cc} {
+				if h, w := have.GetBalance(addr), want.GetBalance(addr); h.Cmp(w) != 0 {
+					t.Errorf("block %d, account %x: balance mismatch: have %v, want %v", number, addr, h, w)
+				}
+				if h, w := have.GetNonce(addr), want.GetNonce(addr); h != w {
+					t.Errorf("block %d, account %x: nonce mismatch: have %d, want %d", number, addr, h, w)
+				}
+				if h, w := have.GetCodeHash(addr), want.GetCodeHash(addr); h != w {
+					t.Errorf("block %d, account %x: code hash mismatch: have %x, want %x", number, addr, h, w)
+				}
+			}
+			if h, w := have.GetState(bb, common.Hash{}), want.GetState(bb, common.Hash{}); h != w {
+				t.Errorf("block %d: counter slot mismatch: have %x, want %x", number, h, w)
+			}
+			if h, w := have.GetState(cc, common.Hash{0x01}), want.GetState(cc, common.Hash{0x01}); h != w {
+				t.Errorf("block %d: wiped slot mismatch: have %x, want %x", number, h, w)
+			}
+		}
+	}
+	check(chain)
+	if first, next := chain.stateHistory.Range(); first != 0 || next != TriesInMemory+1 {
+		t.Errorf("state history range mismatch: have [%d, %d), want [0, %d)", first, next, TriesInMemory+1)
+	}
+	chain.Stop()
+
+	// Restart the chain and ensure the history survives
+	chain, err = NewBlockChain(diskdb, cacheConfig, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate tester chain: %v", err)
+	}
+	defer chain.Stop()
+	check(chain)
+}
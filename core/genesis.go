@@ -176,9 +176,10 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		return genesis.Config, block.Hash(), nil
 	}
 	// We have the genesis block in database(perhaps in ancient database)
-	// but the corresponding state is missing.
+	// but the corresponding state is missing. If trie nodes are stored by
+	// path, only the latest state is retained, so it's expected to be gone.
 	header := rawdb.ReadHeader(db, stored, 0)
-	if _, err := state.New(header.Root, state.NewDatabaseWithConfig(db, nil), nil); err != nil {
+	if _, err := state.New(header.Root, state.NewDatabaseWithConfig(db, nil), nil); err != nil && rawdb.ReadStateScheme(db) != rawdb.PathScheme {
 		if genesis == nil {
 			genesis = DefaultGenesisBlock()
 		}
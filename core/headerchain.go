@@ -524,6 +524,13 @@ func (hc *HeaderChain) GetHeaderByNumber(number uint64) *types.Header {
 	return hc.GetHeader(hash, number)
 }
 
+// GetHeadersFrom retrieves up to count consecutive canonical headers starting at
+// number, limiting their combined encoded size to maxBytes. Headers are read in
+// bulk from the database, bypassing the header cache.
+func (hc *HeaderChain) GetHeadersFrom(number, count, maxBytes uint64) []*types.Header {
+	return rawdb.ReadHeaderRange(hc.chainDb, number, count, maxBytes)
+}
+
 func (hc *HeaderChain) GetCanonicalHash(number uint64) common.Hash {
 	return rawdb.ReadCanonicalHash(hc.chainDb, number)
 }
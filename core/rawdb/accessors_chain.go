@@ -319,6 +319,58 @@ func ReadHeader(db ethdb.Reader, hash common.Hash, number uint64) *types.Header
 	return header
 }
 
+// ReadHeaderRange retrieves up to count consecutive canonical headers starting at
+// number. It stops at the first missing header, or once the combined size of the
+// encoded headers would exceed maxBytes, but returns at least one header if it is
+// available. Headers in the ancient store are read in bulk.
+func ReadHeaderRange(db ethdb.Reader, number, count, maxBytes uint64) []*types.Header {
+	var (
+		headers []*types.Header
+		size    uint64
+	)
+	// Read the frozen headers with a ranged retrieval first
+	if frozen, err := db.Ancients(); err == nil && number < frozen && count > 0 {
+		n := count
+		if n > frozen-number {
+			n = frozen - number
+		}
+		blobs, _ := db.AncientRange(freezerHeaderTable, number, n, maxBytes)
+		for _, blob := range blobs {
+			header := new(types.Header)
+			if err := rlp.DecodeBytes(blob, header); err != nil {
+				log.Error("Invalid block header RLP", "number", number, "err", err)
+				return headers
+			}
+			headers = append(headers, header)
+			size += uint64(len(blob))
+			number++
+		}
+		if uint64(len(blobs)) < n {
+			return headers
+		}
+	}
+	// Read the remaining headers from the key-value store
+	for uint64(len(headers)) < count {
+		hash := ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			break
+		}
+		data := ReadHeaderRLP(db, hash, number)
+		if len(data) == 0 || (len(headers) > 0 && size+uint64(len(data)) > maxBytes) {
+			break
+		}
+		header := new(types.Header)
+		if err := rlp.DecodeBytes(data, header); err != nil {
+			log.Error("Invalid block header RLP", "hash", hash, "err", err)
+			break
+		}
+		headers = append(headers, header)
+		size += uint64(len(data))
+		number++
+	}
+	return headers
+}
+
 // WriteHeader stores a block header into the database and also stores the hash-
 // to-number mapping.
 func WriteHeader(db ethdb.KeyValueWriter, header *types.Header) {
@@ -649,6 +701,70 @@ func ReadBlock(db ethdb.Reader, hash common.Hash, number uint64) *types.Block {
 	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles)
 }
 
+// ReadBlockRange retrieves up to count consecutive canonical blocks starting at
+// number. It stops at the first missing block, or once the combined size of the
+// encoded bodies would exceed maxBytes, but returns at least one block if it is
+// available. Blocks in the ancient store are read in bulk.
+func ReadBlockRange(db ethdb.Reader, number, count, maxBytes uint64) []*types.Block {
+	var (
+		blocks []*types.Block
+		size   uint64
+	)
+	// Read the frozen blocks with ranged retrievals first
+	if frozen, err := db.Ancients(); err == nil && number < frozen && count > 0 {
+		n := count
+		if n > frozen-number {
+			n = frozen - number
+		}
+		bodies, _ := db.AncientRange(freezerBodiesTable, number, n, maxBytes)
+		headers, _ := db.AncientRange(freezerHeaderTable, number, uint64(len(bodies)), maxBytes)
+		if len(headers) < len(bodies) {
+			bodies = bodies[:len(headers)]
+		}
+		for i := range bodies {
+			header, body := new(types.Header), new(types.Body)
+			if err := rlp.DecodeBytes(headers[i], header); err != nil {
+				log.Error("Invalid block header RLP", "number", number, "err", err)
+				return blocks
+			}
+			if err := rlp.DecodeBytes(bodies[i], body); err != nil {
+				log.Error("Invalid block body RLP", "number", number, "err", err)
+				return blocks
+			}
+			blocks = append(blocks, types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles))
+			size += uint64(len(bodies[i]))
+			number++
+		}
+		if uint64(len(bodies)) < n {
+			return blocks
+		}
+	}
+	// Read the remaining blocks from the key-value store
+	for uint64(len(blocks)) < count {
+		hash := ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			break
+		}
+		header := ReadHeader(db, hash, number)
+		if header == nil {
+			break
+		}
+		data := ReadBodyRLP(db, hash, number)
+		if len(data) == 0 || (len(blocks) > 0 && size+uint64(len(data)) > maxBytes) {
+			break
+		}
+		body := new(types.Body)
+		if err := rlp.DecodeBytes(data, body); err != nil {
+			log.Error("Invalid block body RLP", "hash", hash, "err", err)
+			break
+		}
+		blocks = append(blocks, types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles))
+		size += uint64(len(data))
+		number++
+	}
+	return blocks
+}
+
 // WriteBlock serializes a block into the database, header and body separately.
 func WriteBlock(db ethdb.KeyValueWriter, block *types.Block) {
 	WriteBody(db, block.Hash(), block.NumberU64(), block.Body())
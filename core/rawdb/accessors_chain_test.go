@@ -496,6 +496,70 @@ func TestAncientStorage(t *testing.T) {
 	}
 }
 
+// Tests that ranged header and block reads span the ancient and the key-value store.
+func TestHeaderAndBlockRange(t *testing.T) {
+	frdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(frdir)
+
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), frdir, "", false)
+	if err != nil {
+		t.Fatalf("failed to create database with ancient backend")
+	}
+	defer db.Close()
+
+	// Write the first half of the chain into the freezer, the rest into the db
+	var blocks []*types.Block
+	for i := 0; i < 10; i++ {
+		block := types.NewBlockWithHeader(&types.Header{
+			Number:      big.NewInt(int64(i)),
+			Extra:       []byte("test block"),
+			UncleHash:   types.EmptyUncleHash,
+			TxHash:      types.EmptyRootHash,
+			ReceiptHash: types.EmptyRootHash,
+		})
+		if i < 5 {
+			WriteAncientBlock(db, block, nil, big.NewInt(int64(i)))
+		} else {
+			WriteBlock(db, block)
+			WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		}
+		blocks = append(blocks, block)
+	}
+	for _, tt := range []struct {
+		number, count, maxBytes uint64
+		want                    int
+	}{
+		{0, 10, 1 << 20, 10}, // whole chain
+		{2, 2, 1 << 20, 2},   // ancients only
+		{3, 4, 1 << 20, 4},   // across the freezer boundary
+		{7, 10, 1 << 20, 3},  // beyond the head
+		{3, 4, 0, 1},         // byte limit
+		{10, 1, 1 << 20, 0},  // missing origin
+	} {
+		headers := ReadHeaderRange(db, tt.number, tt.count, tt.maxBytes)
+		if len(headers) != tt.want {
+			t.Errorf("headers %d+%d: count mismatch: have %d, want %d", tt.number, tt.count, len(headers), tt.want)
+		}
+		for i, header := range headers {
+			if want := blocks[int(tt.number)+i].Hash(); header.Hash() != want {
+				t.Errorf("headers %d+%d: header %d mismatch: have %x, want %x", tt.number, tt.count, i, header.Hash(), want)
+			}
+		}
+		blocks := ReadBlockRange(db, tt.number, tt.count, tt.maxBytes)
+		if len(blocks) != tt.want {
+			t.Errorf("blocks %d+%d: count mismatch: have %d, want %d", tt.number, tt.count, len(blocks), tt.want)
+		}
+		for i, block := range blocks {
+			if want := headers[i].Hash(); block.Hash() != want {
+				t.Errorf("blocks %d+%d: block %d mismatch: have %x, want %x", tt.number, tt.count, i, block.Hash(), want)
+			}
+		}
+	}
+}
+
 func TestCanonicalHashIteration(t *testing.T) {
 	var cases = []struct {
 		from, to uint64
@@ -17,6 +17,8 @@
 package rawdb
 
 import (
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
@@ -94,3 +96,133 @@ func DeleteTrieNode(db ethdb.KeyValueWriter, hash common.Hash) {
 		log.Crit("Failed to delete trie node", "err", err)
 	}
 }
+
+// The storage schemes of the trie nodes.
+const (
+	// HashScheme stores every trie node keyed by its hash. It is the default
+	// scheme, retaining all historical versions until pruned offline.
+	HashScheme = "hash"
+
+	// PathScheme stores every trie node keyed by its owner and its path in the
+	// trie, overwriting stale versions in place.
+	PathScheme = "path"
+)
+
+// ReadStateScheme retrieves the storage scheme of the trie nodes, or an empty
+// string if the database doesn't have one configured (legacy hash scheme).
+func ReadStateScheme(db ethdb.KeyValueReader) string {
+	data, _ := db.Get(stateSchemeKey)
+	return string(data)
+}
+
+// WriteStateScheme stores the storage scheme of the trie nodes.
+func WriteStateScheme(db ethdb.KeyValueWriter, scheme string) {
+	if err := db.Put(stateSchemeKey, []byte(scheme)); err != nil {
+		log.Crit("Failed to store trie node scheme", "err", err)
+	}
+}
+
+// ParseStateScheme checks the requested storage scheme of the trie nodes against
+// the one used by the database, returning the scheme to use. An empty request
+// picks the scheme of the database, defaulting to the hash scheme. Since the
+// scheme can't be changed once state is stored, it's persisted into fresh
+// databases.
+func ParseStateScheme(provided string, db ethdb.Database) (string, error) {
+	if provided != "" && provided != HashScheme && provided != PathScheme {
+		return "", fmt.Errorf("invalid state scheme %q", provided)
+	}
+	stored := ReadStateScheme(db)
+	if stored == "" && ReadHeadHeaderHash(db) != (common.Hash{}) {
+		stored = HashScheme // Legacy database, predating the scheme marker
+	}
+	switch {
+	case stored == "":
+		if provided == "" {
+			provided = HashScheme
+		}
+		WriteStateScheme(db, provided)
+		return provided, nil
+
+	case provided == "" || provided == stored:
+		return stored, nil
+
+	default:
+		return "", fmt.Errorf("incompatible state scheme, stored: %s, provided: %s", stored, provided)
+	}
+}
+
+// ReadAccountTrieNode retrieves the account trie node at the given path.
+func ReadAccountTrieNode(db ethdb.KeyValueReader, path []byte) []byte {
+	data, _ := db.Get(accountTrieNodeKey(path))
+	return data
+}
+
+// WriteAccountTrieNode writes the account trie node at the given path.
+func WriteAccountTrieNode(db ethdb.KeyValueWriter, path []byte, node []byte) {
+	if err := db.Put(accountTrieNodeKey(path), node); err != nil {
+		log.Crit("Failed to store account trie node", "err", err)
+	}
+}
+
+// DeleteAccountTrieNode deletes the account trie node at the given path.
+func DeleteAccountTrieNode(db ethdb.KeyValueWriter, path []byte) {
+	if err := db.Delete(accountTrieNodeKey(path)); err != nil {
+		log.Crit("Failed to delete account trie node", "err", err)
+	}
+}
+
+// ReadStorageTrieNode retrieves the storage trie node of an account at the
+// given path.
+func ReadStorageTrieNode(db ethdb.KeyValueReader, accountHash common.Hash, path []byte) []byte {
+	data, _ := db.Get(storageTrieNodeKey(accountHash, path))
+	return data
+}
+
+// WriteStorageTrieNode writes the storage trie node of an account at the given
+// path.
+func WriteStorageTrieNode(db ethdb.KeyValueWriter, accountHash common.Hash, path []byte, node []byte) {
+	if err := db.Put(storageTrieNodeKey(accountHash, path), node); err != nil {
+		log.Crit("Failed to store storage trie node", "err", err)
+	}
+}
+
+// DeleteStorageTrieNode deletes the storage trie node of an account at the
+// given path.
+func DeleteStorageTrieNode(db ethdb.KeyValueWriter, accountHash common.Hash, path []byte) {
+	if err := db.Delete(storageTrieNodeKey(accountHash, path)); err != nil {
+		log.Crit("Failed to delete storage trie node", "err", err)
+	}
+}
+
+// IterateStorageTrieNodes returns an iterator over all the path-keyed storage
+// trie nodes of an account.
+func IterateStorageTrieNodes(db ethdb.Iteratee, accountHash common.Hash) ethdb.Iterator {
+	return db.NewIterator(storageTrieNodeKey(accountHash, nil), nil)
+}
+
+// ReadReverseDiff retrieves the reverse state diff of the given block, staged
+// in the key-value store until it's moved into the state history.
+func ReadReverseDiff(db ethdb.KeyValueReader, number uint64, hash common.Hash) []byte {
+	data, _ := db.Get(reverseDiffKey(number, hash))
+	return data
+}
+
+// WriteReverseDiff stores the reverse state diff of the given block.
+func WriteReverseDiff(db ethdb.KeyValueWriter, number uint64, hash common.Hash, diff []byte) {
+	if err := db.Put(reverseDiffKey(number, hash), diff); err != nil {
+		log.Crit("Failed to store reverse state diff", "err", err)
+	}
+}
+
+// DeleteReverseDiffs removes the reverse state diffs of all the blocks with the
+// given number, regardless of whether they are canonical or not.
+func DeleteReverseDiffs(db ethdb.KeyValueStore, number uint64) {
+	it := db.NewIterator(append(reverseDiffPrefix, encodeBlockNumber(number)...), nil)
+	defer it.Release()
+
+	for it.Next() {
+		if err := db.Delete(it.Key()); err != nil {
+			log.Crit("Failed to delete reverse state diff", "err", err)
+		}
+	}
+}
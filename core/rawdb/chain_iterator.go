@@ -173,6 +173,10 @@ func iterateTransactions(db ethdb.Database, from uint64, to uint64, reverse bool
 // There is a passed channel, the whole procedure will be interrupted if any
 // signal received.
 func indexTransactions(db ethdb.Database, from uint64, to uint64, interrupt chan struct{}, hook func(uint64) bool) {
+	// Bodies deleted by history expiry can't be iterated, skip them
+	if tail := ReadHistoryTail(db); from < tail {
+		from = tail
+	}
 	// short circuit for invalid range
 	if from >= to {
 		return
@@ -264,6 +268,10 @@ func indexTransactionsForTesting(db ethdb.Database, from uint64, to uint64, inte
 // There is a passed channel, the whole procedure will be interrupted if any
 // signal received.
 func unindexTransactions(db ethdb.Database, from uint64, to uint64, interrupt chan struct{}, hook func(uint64) bool) {
+	// Bodies deleted by history expiry can't be iterated, skip them
+	if tail := ReadHistoryTail(db); from < tail {
+		from = tail
+	}
 	// short circuit for invalid range
 	if from >= to {
 		return
@@ -89,6 +89,16 @@ func (db *nofreezedb) Ancient(kind string, number uint64) ([]byte, error) {
 	return nil, errNotSupported
 }
 
+// AncientRange returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	return nil, errNotSupported
+}
+
+// AncientTail returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) AncientTail(kind string) (uint64, error) {
+	return 0, errNotSupported
+}
+
 // Ancients returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) Ancients() (uint64, error) {
 	return 0, errNotSupported
@@ -109,6 +119,11 @@ func (db *nofreezedb) TruncateAncients(items uint64) error {
 	return errNotSupported
 }
 
+// TruncateAncientTail returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) TruncateAncientTail(kind string, items uint64) error {
+	return errNotSupported
+}
+
 // Sync returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) Sync() error {
 	return errNotSupported
@@ -292,6 +307,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		numHashPairings stat
 		hashNumPairings stat
 		tries           stat
+		pathTries       stat
 		codes           stat
 		txLookups       stat
 		accountSnaps    stat
@@ -299,6 +315,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		preimages       stat
 		bloomBits       stat
 		cliqueSnaps     stat
+		reverseDiffs    stat
 
 		// Ancient store statistics
 		ancientHeadersSize  common.StorageSize
@@ -339,6 +356,12 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			numHashPairings.Add(size)
 		case bytes.HasPrefix(key, headerNumberPrefix) && len(key) == (len(headerNumberPrefix)+common.HashLength):
 			hashNumPairings.Add(size)
+		case bytes.HasPrefix(key, TrieNodeAccountPrefix) && isHexPath(key[len(TrieNodeAccountPrefix):]):
+			pathTries.Add(size)
+		case bytes.HasPrefix(key, TrieNodeStoragePrefix) && len(key) >= len(TrieNodeStoragePrefix)+common.HashLength && isHexPath(key[len(TrieNodeStoragePrefix)+common.HashLength:]):
+			pathTries.Add(size)
+		case bytes.HasPrefix(key, reverseDiffPrefix) && len(key) == (len(reverseDiffPrefix)+8+common.HashLength):
+			reverseDiffs.Add(size)
 		case len(key) == common.HashLength:
 			tries.Add(size)
 		case bytes.HasPrefix(key, CodePrefix) && len(key) == len(CodePrefix)+common.HashLength:
@@ -373,7 +396,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				databaseVersionKey, headHeaderKey, headBlockKey, headFastBlockKey, lastPivotKey,
 				fastTrieProgressKey, snapshotRootKey, snapshotJournalKey, snapshotGeneratorKey,
 				snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey, uncleanShutdownKey,
-				badBlockKey,
+				badBlockKey, stateSchemeKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -391,6 +414,9 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			logged = time.Now()
 		}
 	}
+	if err := it.Error(); err != nil {
+		return err
+	}
 	// Inspect append-only file store then.
 	ancientSizes := []*common.StorageSize{&ancientHeadersSize, &ancientBodiesSize, &ancientReceiptsSize, &ancientHashesSize, &ancientTdsSize}
 	for i, category := range []string{freezerHeaderTable, freezerBodiesTable, freezerReceiptTable, freezerHashTable, freezerDifficultyTable} {
@@ -416,9 +442,11 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
+		{"Key-Value store", "Path trie nodes", pathTries.Size(), pathTries.Count()},
 		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
 		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
 		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
+		{"Key-Value store", "Reverse state diffs", reverseDiffs.Size(), reverseDiffs.Count()},
 		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
 		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
 		{"Key-Value store", "Shutdown metadata", shutdownInfo.Size(), shutdownInfo.Count()},
@@ -177,6 +177,25 @@ func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
 	return nil, errUnknownTable
 }
 
+// AncientRange retrieves multiple consecutive items from the append-only immutable
+// files. It returns at most count items, and stops early once the combined size
+// of the items would exceed maxBytes, but always returns at least one item.
+func (f *freezer) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.RetrieveItems(start, count, maxBytes)
+	}
+	return nil, errUnknownTable
+}
+
+// AncientTail returns the number of the first item retained in the specified
+// category, all items below it were deleted from the tail.
+func (f *freezer) AncientTail(kind string) (uint64, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.tail(), nil
+	}
+	return 0, errUnknownTable
+}
+
 // Ancients returns the length of the frozen items.
 func (f *freezer) Ancients() (uint64, error) {
 	return atomic.LoadUint64(&f.frozen), nil
@@ -248,6 +267,12 @@ func (f *freezer) TruncateAncients(items uint64) error {
 	if atomic.LoadUint64(&f.frozen) <= items {
 		return nil
 	}
+	// Ensure no table is truncated unless all of them can be
+	for kind, table := range f.tables {
+		if tail := table.tail(); items < tail {
+			return fmt.Errorf("%w: table %s, tail %d, requested %d", errTruncateBelowTail, kind, tail, items)
+		}
+	}
 	for _, table := range f.tables {
 		if err := table.truncate(items); err != nil {
 			return err
@@ -257,6 +282,19 @@ func (f *freezer) TruncateAncients(items uint64) error {
 	return nil
 }
 
+// TruncateAncientTail discards the items below the provided threshold number from
+// the specified category. Data is deleted with data file granularity, so some of
+// the items below the threshold may be retained.
+func (f *freezer) TruncateAncientTail(kind string, items uint64) error {
+	if f.readonly {
+		return errReadOnly
+	}
+	if table := f.tables[kind]; table != nil {
+		return table.truncateTail(items)
+	}
+	return errUnknownTable
+}
+
 // Sync flushes all data tables to disk.
 func (f *freezer) Sync() error {
 	var errs []error
@@ -21,8 +21,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 
@@ -43,6 +45,10 @@ var (
 
 	// errNotSupported is returned if the database doesn't support the required operation.
 	errNotSupported = errors.New("this operation is not supported")
+
+	// errTruncateBelowTail is returned if the freezer table is truncated below the
+	// items already deleted from its tail.
+	errTruncateBelowTail = errors.New("truncation below the deleted tail")
 )
 
 // indexEntry contains the number/id of the file that the data resides in, aswell as the
@@ -55,6 +61,10 @@ type indexEntry struct {
 
 const indexEntrySize = 6
 
+// retrieveIndexBatch is the maximum number of index entries read at once when
+// retrieving a range of items, bounding the index buffer of large requests.
+const retrieveIndexBatch = 1024
+
 // unmarshallBinary deserializes binary b into the rawIndex entry.
 func (i *indexEntry) unmarshalBinary(b []byte) error {
 	i.filenum = uint32(binary.BigEndian.Uint16(b[:2]))
@@ -242,6 +252,10 @@ func (t *freezerTable) repair() error {
 
 	t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
 	lastIndex.unmarshalBinary(buffer)
+	if offsetsSize == indexEntrySize {
+		// The zero index entry only denotes the tail, the table is empty
+		lastIndex = indexEntry{filenum: t.tailId}
+	}
 	t.head, err = t.openFile(lastIndex.filenum, openFreezerFileForAppend)
 	if err != nil {
 		return err
@@ -273,6 +287,9 @@ func (t *freezerTable) repair() error {
 			t.index.ReadAt(buffer, offsetsSize-indexEntrySize)
 			var newLastIndex indexEntry
 			newLastIndex.unmarshalBinary(buffer)
+			if offsetsSize == indexEntrySize {
+				newLastIndex = indexEntry{filenum: t.tailId}
+			}
 			// We might have slipped back into an earlier head-file here
 			if newLastIndex.filenum != lastIndex.filenum {
 				// Release earlier opened file
@@ -339,6 +356,10 @@ func (t *freezerTable) truncate(items uint64) error {
 	if existing <= items {
 		return nil
 	}
+	// Items deleted from the tail can't be restored, refuse to truncate below
+	if items < uint64(t.itemOffset) {
+		return errTruncateBelowTail
+	}
 	// We need to truncate, save the old size for metrics tracking
 	oldSize, err := t.sizeNolock()
 	if err != nil {
@@ -350,17 +371,24 @@ func (t *freezerTable) truncate(items uint64) error {
 		log = t.logger.Warn // Only loud warn if we delete multiple items
 	}
 	log("Truncating freezer table", "items", existing, "limit", items)
-	if err := truncateFreezerFile(t.index, int64(items+1)*indexEntrySize); err != nil {
+	remaining := items - uint64(t.itemOffset)
+	if err := truncateFreezerFile(t.index, int64(remaining+1)*indexEntrySize); err != nil {
 		return err
 	}
 	// Calculate the new expected size of the data file and truncate it
 	buffer := make([]byte, indexEntrySize)
-	if _, err := t.index.ReadAt(buffer, int64(items*indexEntrySize)); err != nil {
+	if _, err := t.index.ReadAt(buffer, int64(remaining*indexEntrySize)); err != nil {
 		return err
 	}
 	var expected indexEntry
 	expected.unmarshalBinary(buffer)
 
+	// If the table is emptied, the zero index entry denotes the tail instead of
+	// the end of an item, the tail file starts over from scratch.
+	if remaining == 0 {
+		expected = indexEntry{filenum: t.tailId}
+	}
+
 	// We might need to truncate back to older files
 	if expected.filenum != t.headId {
 		// If already open for reading, force-reopen for writing
@@ -393,6 +421,131 @@ func (t *freezerTable) truncate(items uint64) error {
 	return nil
 }
 
+// truncateTail discards all data files containing only items below the provided
+// threshold number. Data is deleted with file granularity, so items sharing the
+// data file of the first retained item are kept. The index entries of the deleted
+// items are dropped and the zero entry is updated to denote the new tail.
+//
+// The new index is written to a temporary file and moved into place before any
+// data file is removed, so a crash leaves at worst a few unreferenced data files.
+func (t *freezerTable) truncateTail(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil || t.head == nil {
+		return errClosed
+	}
+	// Never delete the head file, otherwise find the file containing the item
+	// at the threshold, all earlier files can be deleted.
+	existing := atomic.LoadUint64(&t.items)
+	if items > existing {
+		items = existing
+	}
+	if items <= uint64(t.itemOffset) {
+		return nil
+	}
+	tailId := t.headId
+	if items < existing {
+		entry, err := t.readIndexEntry(items - uint64(t.itemOffset) + 1)
+		if err != nil {
+			return err
+		}
+		tailId = entry.filenum
+	}
+	if tailId <= t.tailId {
+		return nil
+	}
+	// Find the first item stored in the new tail file. Items crossing into a new
+	// file are stored in it in full, so this is the first item whose end offset
+	// points into the new tail file.
+	var (
+		count = existing - uint64(t.itemOffset)
+		first = uint64(sort.Search(int(count), func(i int) bool {
+			entry, err := t.readIndexEntry(uint64(i) + 1)
+			return err != nil || entry.filenum >= tailId
+		}))
+	)
+	// Write the new index into a temporary file and replace the old one with it
+	oldSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	indexPath := t.index.Name()
+	tmp, err := openFreezerFileTruncated(indexPath + ".tmp")
+	if err != nil {
+		return err
+	}
+	tail := indexEntry{filenum: tailId, offset: t.itemOffset + uint32(first)}
+	if _, err := tmp.Write(tail.marshallBinary()); err != nil {
+		tmp.Close()
+		return err
+	}
+	section := io.NewSectionReader(t.index, int64(first+1)*indexEntrySize, int64(count-first)*indexEntrySize)
+	if _, err := io.Copy(tmp, section); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := t.index.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(indexPath+".tmp", indexPath); err != nil {
+		return err
+	}
+	if t.index, err = openFreezerFileForAppend(indexPath); err != nil {
+		return err
+	}
+	// The index is switched over, remove the unreferenced data files
+	for num := t.tailId; num < tailId; num++ {
+		if f, exist := t.files[num]; exist {
+			delete(t.files, num)
+			f.Close()
+			if err := os.Remove(f.Name()); err != nil {
+				t.logger.Warn("Failed to remove freezer data file", "file", f.Name(), "err", err)
+			}
+		}
+	}
+	t.logger.Info("Deleted freezer table tail", "items", uint64(tail.offset)-uint64(t.itemOffset), "tail", tail.offset, "files", tailId-t.tailId)
+	t.tailId = tailId
+	t.itemOffset = tail.offset
+
+	// Retrieve the new size and update the total size counter
+	newSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.sizeGauge.Dec(int64(oldSize - newSize))
+	return nil
+}
+
+// readIndexEntry reads the index entry at the given position, relative to the
+// start of the index file.
+func (t *freezerTable) readIndexEntry(pos uint64) (indexEntry, error) {
+	var (
+		entry  indexEntry
+		buffer = make([]byte, indexEntrySize)
+	)
+	if _, err := t.index.ReadAt(buffer, int64(pos*indexEntrySize)); err != nil {
+		return entry, err
+	}
+	entry.unmarshalBinary(buffer)
+	return entry, nil
+}
+
+// tail returns the number of the first item retained in the table.
+func (t *freezerTable) tail() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return uint64(t.itemOffset)
+}
+
 // Close closes all opened files.
 func (t *freezerTable) Close() error {
 	t.lock.Lock()
@@ -602,10 +755,149 @@ func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
 	return snappy.Decode(nil, blob)
 }
 
+// RetrieveItems returns up to count consecutive items starting at start. It stops
+// early once the combined size of the returned items would exceed maxBytes, but
+// always returns at least one item, even if it is larger than maxBytes.
+//
+// Items stored contiguously in the same data file are read with a single disk read.
+func (t *freezerTable) RetrieveItems(start, count, maxBytes uint64) ([][]byte, error) {
+	if t.noCompression {
+		return t.retrieveItems(start, count, maxBytes)
+	}
+	// The byte limit applies to the decompressed items. Snappy may expand data
+	// slightly, so allow for its worst case overhead when limiting the disk reads.
+	if items := atomic.LoadUint64(&t.items); count > items {
+		count = items
+	}
+	readLimit := maxBytes + maxBytes/6 + 32*count
+	if readLimit < maxBytes {
+		readLimit = math.MaxUint64
+	}
+	blobs, err := t.retrieveItems(start, count, readLimit)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		items = make([][]byte, 0, len(blobs))
+		size  uint64
+	)
+	for i, blob := range blobs {
+		n, err := snappy.DecodedLen(blob)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && size+uint64(n) > maxBytes {
+			break
+		}
+		item, err := snappy.Decode(nil, blob)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		size += uint64(n)
+	}
+	return items, nil
+}
+
+// retrieveItems reads the raw (potentially compressed) blobs of up to count items
+// starting at start, limiting the combined size of the blobs to maxBytes.
+func (t *freezerTable) retrieveItems(start, count, maxBytes uint64) ([][]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	// Ensure the table and the items are accessible
+	if t.index == nil || t.head == nil {
+		return nil, errClosed
+	}
+	items := atomic.LoadUint64(&t.items)
+	if items <= start || uint64(t.itemOffset) > start {
+		return nil, errOutOfBounds
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	if count > items-start {
+		count = items - start
+	}
+	// Resolve the location of each item, stopping at the size limit. The index
+	// entries bounding the items are read in batches, so that a large count
+	// doesn't allocate for items beyond the size limit.
+	type location struct {
+		filenum    uint32
+		start, end uint32
+	}
+	var (
+		first     = start - uint64(t.itemOffset)
+		locations []location
+		size      uint64
+		indexSize int
+	)
+	batch := count
+	if batch > retrieveIndexBatch {
+		batch = retrieveIndexBatch
+	}
+	buffer := make([]byte, (batch+1)*indexEntrySize)
+resolve:
+	for i := uint64(0); i < count; {
+		if batch > count-i {
+			batch = count - i
+		}
+		// Read the entries bounding the next batch of items, the first one
+		// being the end of the previous item
+		buffer = buffer[:(batch+1)*indexEntrySize]
+		if _, err := t.index.ReadAt(buffer, int64((first+i)*indexEntrySize)); err != nil {
+			return nil, err
+		}
+		indexSize += len(buffer)
+
+		var prev, next indexEntry
+		prev.unmarshalBinary(buffer)
+		for j := uint64(1); j <= batch; j, i = j+1, i+1 {
+			next.unmarshalBinary(buffer[j*indexEntrySize:])
+
+			loc := location{filenum: next.filenum, end: next.offset}
+			// Same as in getBounds, the very first item and items crossing a data
+			// file start at the beginning of their file.
+			if first+i != 0 && prev.filenum == next.filenum {
+				loc.start = prev.offset
+			}
+			if i > 0 && size+uint64(loc.end-loc.start) > maxBytes {
+				break resolve
+			}
+			locations = append(locations, loc)
+			size += uint64(loc.end - loc.start)
+			prev = next
+		}
+	}
+	// Read the data of consecutive items in the same file with a single read
+	blobs := make([][]byte, 0, len(locations))
+	for i := 0; i < len(locations); {
+		j := i + 1
+		for j < len(locations) && locations[j].filenum == locations[i].filenum && locations[j].start == locations[j-1].end {
+			j++
+		}
+		dataFile, exist := t.files[locations[i].filenum]
+		if !exist {
+			return nil, fmt.Errorf("missing data file %d", locations[i].filenum)
+		}
+		data := make([]byte, locations[j-1].end-locations[i].start)
+		if _, err := dataFile.ReadAt(data, int64(locations[i].start)); err != nil {
+			return nil, err
+		}
+		for _, loc := range locations[i:j] {
+			offset := loc.start - locations[i].start
+			blobs = append(blobs, data[offset:offset+loc.end-loc.start])
+		}
+		i = j
+	}
+	t.readMeter.Mark(int64(size) + int64(indexSize))
+	return blobs, nil
+}
+
 // has returns an indicator whether the specified number data
 // exists in the freezer table.
 func (t *freezerTable) has(number uint64) bool {
-	return atomic.LoadUint64(&t.items) > number
+	return atomic.LoadUint64(&t.items) > number && t.tail() <= number
 }
 
 // size returns the total data size in the freezer table.
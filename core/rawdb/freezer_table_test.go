@@ -19,6 +19,7 @@ package rawdb
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -640,3 +641,198 @@ func TestOffset(t *testing.T) {
 // However, all 'normal' failure modes arising due to failing to sync() or save a file should be
 // handled already, and the case described above can only (?) happen if an external process/user
 // deletes files from the filesystem.
+
+// TestFreezerRetrieveItems tests that ranged retrievals return the same items as
+// single retrievals, honouring the count and byte limits.
+func TestFreezerRetrieveItems(t *testing.T) {
+	t.Parallel()
+	for _, noCompression := range []bool{true, false} {
+		f, err := newCustomTable(os.TempDir(),
+			fmt.Sprintf("retrieveitems-%d", rand.Uint64()),
+			metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge(), 50, noCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Write items of varying sizes, spread across many data files
+		for x := 0; x < 100; x++ {
+			f.Append(uint64(x), getChunk(x%20+1, x))
+		}
+		for _, tt := range []struct {
+			start, count, maxBytes uint64
+			want                   int
+		}{
+			{0, 100, 10000, 100},  // everything
+			{0, 1, 10000, 1},      // first item only
+			{10, 20, 10000, 20},   // range in the middle
+			{90, 20, 10000, 10},   // range beyond the head
+			{0, 10, 0, 1},         // byte limit below the first item
+			{5, 10, 6 + 7 + 8, 3}, // byte limit exactly at an item boundary
+			{5, 10, 6 + 7 + 7, 2}, // byte limit within an item
+			{20, 0, 10000, 0},     // nothing requested
+		} {
+			items, err := f.RetrieveItems(tt.start, tt.count, tt.maxBytes)
+			if err != nil {
+				t.Fatalf("start %d, count %d, maxBytes %d: %v", tt.start, tt.count, tt.maxBytes, err)
+			}
+			if len(items) != tt.want {
+				t.Fatalf("start %d, count %d, maxBytes %d: item count mismatch: have %d, want %d", tt.start, tt.count, tt.maxBytes, len(items), tt.want)
+			}
+			for i, item := range items {
+				n := int(tt.start) + i
+				if exp := getChunk(n%20+1, n); !bytes.Equal(item, exp) {
+					t.Fatalf("start %d, item %d: have %x, want %x", tt.start, n, item, exp)
+				}
+			}
+		}
+		if _, err := f.RetrieveItems(100, 1, 10000); err != errOutOfBounds {
+			t.Fatalf("out of bounds error mismatch: have %v, want %v", err, errOutOfBounds)
+		}
+		f.Close()
+	}
+}
+
+// TestFreezerRetrieveItemsBatched tests ranged retrievals spanning multiple
+// batches of index entries.
+func TestFreezerRetrieveItemsBatched(t *testing.T) {
+	t.Parallel()
+	f, err := newCustomTable(os.TempDir(),
+		fmt.Sprintf("retrieveitemsbatched-%d", rand.Uint64()),
+		metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge(), 4096, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	total := 2*retrieveIndexBatch + 10
+	for x := 0; x < total; x++ {
+		f.Append(uint64(x), getChunk(x%20+1, x))
+	}
+	for _, tt := range []struct {
+		start, count, maxBytes uint64
+		want                   int
+	}{
+		{0, uint64(total), math.MaxUint64, total},          // everything
+		{0, math.MaxUint64, math.MaxUint64, total},         // count capped at the head
+		{retrieveIndexBatch - 2, 4, math.MaxUint64, 4},     // across a batch boundary
+		{retrieveIndexBatch, math.MaxUint64, 5 + 6 + 7, 3}, // byte limit within an item
+		{0, math.MaxUint64, 10 * 210, 200},                 // byte limit in the first batch
+		{0, math.MaxUint64, 52 * 210, 1040},                // byte limit in a later batch
+	} {
+		items, err := f.RetrieveItems(tt.start, tt.count, tt.maxBytes)
+		if err != nil {
+			t.Fatalf("start %d, count %d, maxBytes %d: %v", tt.start, tt.count, tt.maxBytes, err)
+		}
+		if len(items) != tt.want {
+			t.Fatalf("start %d, count %d, maxBytes %d: item count mismatch: have %d, want %d", tt.start, tt.count, tt.maxBytes, len(items), tt.want)
+		}
+		for i, item := range items {
+			n := int(tt.start) + i
+			if exp := getChunk(n%20+1, n); !bytes.Equal(item, exp) {
+				t.Fatalf("start %d, item %d: have %x, want %x", tt.start, n, item, exp)
+			}
+		}
+	}
+}
+
+// TestFreezerTruncateTail tests that deleting items from the tail of a table drops
+// whole data files, keeps the remaining items accessible and survives reopening.
+func TestFreezerTruncateTail(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("truncatetail-%d", rand.Uint64())
+
+	// Write 30 x 15 bytes, 3 items per data file
+	f, err := newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 30; x++ {
+		f.Append(uint64(x), getChunk(15, x))
+	}
+	checkRetrieve := func(f *freezerTable, tail, head uint64) {
+		t.Helper()
+		if have := f.tail(); have != tail {
+			t.Fatalf("tail mismatch: have %d, want %d", have, tail)
+		}
+		for i := uint64(0); i < head; i++ {
+			got, err := f.Retrieve(i)
+			if i < tail {
+				if err != errOutOfBounds {
+					t.Fatalf("item %d: error mismatch: have %v, want %v", i, err, errOutOfBounds)
+				}
+				if f.has(i) {
+					t.Fatalf("item %d: reported as present", i)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("item %d: %v", i, err)
+			}
+			if exp := getChunk(15, int(i)); !bytes.Equal(got, exp) {
+				t.Fatalf("item %d: have %x, want %x", i, got, exp)
+			}
+		}
+	}
+	// Deleting within the first data file is a noop
+	if err := f.truncateTail(2); err != nil {
+		t.Fatal(err)
+	}
+	checkRetrieve(f, 0, 30)
+
+	// Deleting up to item 10 drops the first three files, item 9 is kept
+	if err := f.truncateTail(10); err != nil {
+		t.Fatal(err)
+	}
+	checkRetrieve(f, 9, 30)
+	for i := 0; i < 3; i++ {
+		if _, err := os.Stat(filepath.Join(os.TempDir(), fmt.Sprintf("%s.%04d.rdat", fname, i))); !os.IsNotExist(err) {
+			t.Fatalf("data file %d not deleted: %v", i, err)
+		}
+	}
+	// Ranged reads must honour the tail too
+	if items, err := f.RetrieveItems(9, 3, 1000); err != nil || len(items) != 3 {
+		t.Fatalf("ranged read mismatch: have %d items, err %v", len(items), err)
+	}
+	if _, err := f.RetrieveItems(8, 3, 1000); err != errOutOfBounds {
+		t.Fatalf("ranged read error mismatch: have %v, want %v", err, errOutOfBounds)
+	}
+	// Reopen the table and check that the tail is retained and writes continue
+	f.Close()
+	if f, err = newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, true); err != nil {
+		t.Fatal(err)
+	}
+	checkRetrieve(f, 9, 30)
+	if err := f.Append(30, getChunk(15, 30)); err != nil {
+		t.Fatal(err)
+	}
+	checkRetrieve(f, 9, 31)
+
+	// Truncating the head must work with a deleted tail, but not below it
+	if err := f.truncate(20); err != nil {
+		t.Fatal(err)
+	}
+	checkRetrieve(f, 9, 20)
+	if err := f.truncate(5); err != errTruncateBelowTail {
+		t.Fatalf("error mismatch: have %v, want %v", err, errTruncateBelowTail)
+	}
+	// Deleting everything retains the items of the head file only
+	if err := f.truncateTail(100); err != nil {
+		t.Fatal(err)
+	}
+	checkRetrieve(f, 18, 20)
+	f.Close()
+	if f, err = newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, true); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	checkRetrieve(f, 18, 20)
+
+	// Emptying the table keeps the tail, new items are appended after it
+	if err := f.truncate(18); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Append(18, getChunk(15, 18)); err != nil {
+		t.Fatal(err)
+	}
+	checkRetrieve(f, 18, 19)
+}
@@ -0,0 +1,245 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	verifyBatchItems = 1024             // Maximum number of items read at once per table
+	verifyBatchSize  = 16 * 1024 * 1024 // Maximum size of the items read at once per table
+)
+
+// AncientCorruptionError is returned by VerifyAncients for the first inconsistent
+// item found in the ancient store.
+type AncientCorruptionError struct {
+	Number uint64 // Number of the corrupt block
+	Kind   string // Ancient table containing the corrupt item
+	Reason string // Description of the inconsistency
+}
+
+func (e *AncientCorruptionError) Error() string {
+	return fmt.Sprintf("corrupt ancient %s #%d: %s", e.Kind, e.Number, e.Reason)
+}
+
+// VerifyAncients checks the consistency of the chain segment in the ancient store,
+// starting at block from. Each header must hash to the stored hash and link to its
+// parent, the total difficulties must accumulate, and the transaction, uncle and
+// receipt roots of the stored bodies and receipts must match their headers. Bodies
+// and receipts deleted by history expiry are skipped.
+//
+// The hasher is used to derive the roots of the transaction and receipt lists. The
+// number of verified blocks is returned, along with an *AncientCorruptionError for
+// the first inconsistent block, if any.
+func VerifyAncients(db ethdb.AncientReader, from uint64, hasher types.TrieHasher) (uint64, error) {
+	frozen, err := db.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		verified uint64
+
+		parentHash common.Hash
+		parentTd   *big.Int
+	)
+	// Retrieve the parent of the first block to check the links against
+	if from == 0 {
+		parentTd = new(big.Int)
+	} else if from <= frozen {
+		hash, err := db.Ancient(freezerHashTable, from-1)
+		if err != nil {
+			return 0, &AncientCorruptionError{from - 1, freezerHashTable, err.Error()}
+		}
+		parentHash = common.BytesToHash(hash)
+
+		blob, err := db.Ancient(freezerDifficultyTable, from-1)
+		if err != nil {
+			return 0, &AncientCorruptionError{from - 1, freezerDifficultyTable, err.Error()}
+		}
+		parentTd = new(big.Int)
+		if err := rlp.DecodeBytes(blob, parentTd); err != nil {
+			return 0, &AncientCorruptionError{from - 1, freezerDifficultyTable, err.Error()}
+		}
+	}
+	for number := from; number < frozen; {
+		// Read the next batch of all tables, bodies and receipts only above the tail
+		count := frozen - number
+		if count > verifyBatchItems {
+			count = verifyBatchItems
+		}
+		tail := ReadHistoryTail(db)
+		if number < tail && number+count > tail {
+			count = tail - number
+		}
+		kinds := []string{freezerHashTable, freezerHeaderTable, freezerDifficultyTable}
+		if number >= tail {
+			kinds = append(kinds, freezerBodiesTable, freezerReceiptTable)
+		}
+		batch := make(map[string][][]byte)
+		for _, kind := range kinds {
+			items, err := db.AncientRange(kind, number, count, verifyBatchSize)
+			if err != nil {
+				return verified, &AncientCorruptionError{number, kind, err.Error()}
+			}
+			if uint64(len(items)) < count {
+				count = uint64(len(items)) // byte limit reached
+			}
+			batch[kind] = items
+		}
+		for i := uint64(0); i < count; i++ {
+			var (
+				hash   = common.BytesToHash(batch[freezerHashTable][i])
+				header = new(types.Header)
+				td     = new(big.Int)
+			)
+			// Verify the header against its hash, number and parent
+			blob := batch[freezerHeaderTable][i]
+			if have := crypto.Keccak256Hash(blob); have != hash {
+				return verified, &AncientCorruptionError{number, freezerHeaderTable, fmt.Sprintf("hash mismatch: have %x, want %x", have, hash)}
+			}
+			if err := rlp.DecodeBytes(blob, header); err != nil {
+				return verified, &AncientCorruptionError{number, freezerHeaderTable, err.Error()}
+			}
+			if header.Number.Uint64() != number {
+				return verified, &AncientCorruptionError{number, freezerHeaderTable, fmt.Sprintf("number mismatch: have %d", header.Number)}
+			}
+			if parentTd != nil && header.ParentHash != parentHash {
+				return verified, &AncientCorruptionError{number, freezerHeaderTable, fmt.Sprintf("parent mismatch: have %x, want %x", header.ParentHash, parentHash)}
+			}
+			// Verify the total difficulty accumulation
+			if err := rlp.DecodeBytes(batch[freezerDifficultyTable][i], td); err != nil {
+				return verified, &AncientCorruptionError{number, freezerDifficultyTable, err.Error()}
+			}
+			if parentTd != nil {
+				if want := new(big.Int).Add(parentTd, header.Difficulty); td.Cmp(want) != 0 {
+					return verified, &AncientCorruptionError{number, freezerDifficultyTable, fmt.Sprintf("total difficulty mismatch: have %v, want %v", td, want)}
+				}
+			}
+			// Verify the body and receipts against the header roots
+			if bodies := batch[freezerBodiesTable]; bodies != nil {
+				if kind, err := verifyAncientBody(header, bodies[i], batch[freezerReceiptTable][i], hasher); err != nil {
+					return verified, &AncientCorruptionError{number, kind, err.Error()}
+				}
+			}
+			parentHash, parentTd = hash, td
+			verified++
+			number++
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying ancients", "number", number, "frozen", frozen, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	return verified, nil
+}
+
+// verifyAncientBody checks the encoded body and receipts of a block against the
+// roots and bloom of its header. The ancient table containing the inconsistent
+// item is returned along with the error.
+func verifyAncientBody(header *types.Header, bodyBlob, receiptsBlob []byte, hasher types.TrieHasher) (string, error) {
+	var body types.Body
+	if err := rlp.DecodeBytes(bodyBlob, &body); err != nil {
+		return freezerBodiesTable, err
+	}
+	if have := types.DeriveSha(types.Transactions(body.Transactions), hasher); have != header.TxHash {
+		return freezerBodiesTable, fmt.Errorf("transaction root mismatch: have %x, want %x", have, header.TxHash)
+	}
+	if have := types.CalcUncleHash(body.Uncles); have != header.UncleHash {
+		return freezerBodiesTable, fmt.Errorf("uncle root mismatch: have %x, want %x", have, header.UncleHash)
+	}
+	var stored []*types.ReceiptForStorage
+	if err := rlp.DecodeBytes(receiptsBlob, &stored); err != nil {
+		return freezerReceiptTable, err
+	}
+	if len(stored) != len(body.Transactions) {
+		return freezerReceiptTable, fmt.Errorf("receipt count mismatch: have %d, want %d", len(stored), len(body.Transactions))
+	}
+	// The stored receipts lack the consensus fields derived from the transactions
+	receipts := make(types.Receipts, len(stored))
+	for i, receipt := range stored {
+		receipts[i] = (*types.Receipt)(receipt)
+		receipts[i].Type = body.Transactions[i].Type()
+		receipts[i].Bloom = types.CreateBloom(types.Receipts{receipts[i]})
+	}
+	if have := types.DeriveSha(receipts, hasher); have != header.ReceiptHash {
+		return freezerReceiptTable, fmt.Errorf("receipt root mismatch: have %x, want %x", have, header.ReceiptHash)
+	}
+	if have := types.CreateBloom(receipts); have != header.Bloom {
+		return freezerReceiptTable, fmt.Errorf("bloom mismatch")
+	}
+	return "", nil
+}
+
+// TruncateAncientChain truncates the ancient store to the given number of blocks,
+// dropping a corrupt chain segment. The head header, block and fast block markers
+// pointing into or past the dropped segment are rewound to the last retained block
+// first, otherwise the key-value store would continue the chain beyond a gap.
+//
+// The head block will usually lack its state after the rewind, which the chain
+// repairs on startup by rewinding further and resyncing the removed blocks.
+func TruncateAncientChain(db ethdb.Database, items uint64) error {
+	if items == 0 {
+		return errors.New("can't truncate the genesis block")
+	}
+	if frozen, err := db.Ancients(); err != nil {
+		return err
+	} else if frozen <= items {
+		return nil
+	}
+	number := items - 1
+	hash := ReadCanonicalHash(db, number)
+	if hash == (common.Hash{}) {
+		return fmt.Errorf("missing canonical hash of block #%d", number)
+	}
+	// Rewind the markers in one batch, before anything is deleted. A crash in
+	// between leaves a head below the ancient limit, which is fine.
+	batch := db.NewBatch()
+	WriteHeaderNumber(batch, hash, number)
+	for _, marker := range []struct {
+		read  func(ethdb.KeyValueReader) common.Hash
+		write func(ethdb.KeyValueWriter, common.Hash)
+	}{
+		{ReadHeadHeaderHash, WriteHeadHeaderHash},
+		{ReadHeadBlockHash, WriteHeadBlockHash},
+		{ReadHeadFastBlockHash, WriteHeadFastBlockHash},
+	} {
+		if head := ReadHeaderNumber(db, marker.read(db)); head == nil || *head > number {
+			marker.write(batch, hash)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if err := db.TruncateAncients(items); err != nil {
+		return err
+	}
+	log.Warn("Truncated ancient chain", "items", items, "head", number, "hash", hash)
+	return nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// makeAncientChain writes a chain of blocks with transactions and receipts into
+// the ancient store. The corrupt callback may alter the receipts to be stored.
+//
+// Every block contains transactions, as the test hasher doesn't derive the empty
+// root hash for empty lists.
+func makeAncientChain(db ethdb.Database, n int, corrupt func(int, types.Receipts)) {
+	var (
+		parent common.Hash
+		td     = new(big.Int)
+	)
+	for i := 0; i < n; i++ {
+		var (
+			txs      types.Transactions
+			receipts types.Receipts
+		)
+		for j := 0; j <= i%3; j++ {
+			tx := types.NewTransaction(uint64(i*3+j), common.Address{byte(j)}, big.NewInt(int64(i)), 21000, big.NewInt(1), nil)
+			receipt := &types.Receipt{
+				Type:              tx.Type(),
+				Status:            types.ReceiptStatusSuccessful,
+				CumulativeGasUsed: uint64(21000 * (j + 1)),
+				Logs:              []*types.Log{{Address: common.Address{byte(i)}, Topics: []common.Hash{{byte(j)}}}},
+			}
+			receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+			txs, receipts = append(txs, tx), append(receipts, receipt)
+		}
+		header := &types.Header{
+			ParentHash: parent,
+			Number:     big.NewInt(int64(i)),
+			Difficulty: big.NewInt(int64(i + 1)),
+		}
+		block := types.NewBlock(header, txs, nil, receipts, newHasher())
+		td.Add(td, header.Difficulty)
+		if corrupt != nil {
+			corrupt(i, receipts)
+		}
+		WriteAncientBlock(db, block, receipts, td)
+		parent = block.Hash()
+	}
+}
+
+func TestVerifyAncients(t *testing.T) {
+	frdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(frdir)
+
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), frdir, "", false)
+	if err != nil {
+		t.Fatalf("failed to create database with ancient backend")
+	}
+	defer db.Close()
+
+	// Corrupt the receipts of block 5
+	makeAncientChain(db, 10, func(i int, receipts types.Receipts) {
+		if i == 5 {
+			receipts[0].CumulativeGasUsed++
+		}
+	})
+	verified, err := VerifyAncients(db, 0, newHasher())
+	var corrupt *AncientCorruptionError
+	if !errors.As(err, &corrupt) {
+		t.Fatalf("expected corruption error, have %v", err)
+	}
+	if corrupt.Number != 5 || corrupt.Kind != freezerReceiptTable || verified != 5 {
+		t.Fatalf("corruption mismatch: have #%d in %s after %d blocks, want #5 in %s after 5 blocks", corrupt.Number, corrupt.Kind, verified, freezerReceiptTable)
+	}
+	// Verification starting after the corrupt block must link to its parent
+	if verified, err := VerifyAncients(db, 6, newHasher()); err != nil || verified != 4 {
+		t.Fatalf("partial verification failed: verified %d, err %v", verified, err)
+	}
+	// Truncating at the corrupt block must leave a consistent store
+	if err := db.TruncateAncients(corrupt.Number); err != nil {
+		t.Fatal(err)
+	}
+	if verified, err := VerifyAncients(db, 0, newHasher()); err != nil || verified != 5 {
+		t.Fatalf("verification after truncation failed: verified %d, err %v", verified, err)
+	}
+}
+
+// Tests that truncating a corrupt ancient chain rewinds the head markers, so the
+// database can be reopened without a gap between the ancient and key-value store.
+func TestTruncateAncientChainReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		kvdir = filepath.Join(dir, "chaindata")
+		frdir = filepath.Join(kvdir, "ancient")
+	)
+	db, err := NewLevelDBDatabaseWithFreezer(kvdir, 16, 16, frdir, "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	// Freeze the first ten blocks, with corrupt receipts in block 5, and keep
+	// five more in the key-value store, with the chain head at the last one
+	makeAncientChain(db, 10, func(i int, receipts types.Receipts) {
+		if i == 5 {
+			receipts[0].CumulativeGasUsed++
+		}
+	})
+	parent := ReadCanonicalHash(db, 9)
+	for i := uint64(0); i < 10; i++ {
+		WriteHeaderNumber(db, ReadCanonicalHash(db, i), i)
+	}
+	WriteCanonicalHash(db, ReadCanonicalHash(db, 0), 0) // The genesis is kept in the key-value store
+	for i := 10; i < 15; i++ {
+		block := types.NewBlockWithHeader(&types.Header{
+			ParentHash: parent,
+			Number:     big.NewInt(int64(i)),
+			Difficulty: big.NewInt(int64(i + 1)),
+		})
+		WriteBlock(db, block)
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		parent = block.Hash()
+	}
+	WriteHeadHeaderHash(db, parent)
+	WriteHeadBlockHash(db, parent)
+	WriteHeadFastBlockHash(db, ReadCanonicalHash(db, 3)) // Below the corruption
+	fast := ReadHeadFastBlockHash(db)
+
+	_, err = VerifyAncients(db, 0, newHasher())
+	var corrupt *AncientCorruptionError
+	if !errors.As(err, &corrupt) {
+		t.Fatalf("expected corruption error, have %v", err)
+	}
+	if err := TruncateAncientChain(db, corrupt.Number); err != nil {
+		t.Fatalf("failed to truncate ancient chain: %v", err)
+	}
+	db.Close()
+
+	// Reopen the database and ensure the chain is consistent
+	db, err = NewLevelDBDatabaseWithFreezer(kvdir, 16, 16, frdir, "", false)
+	if err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	if frozen, _ := db.Ancients(); frozen != 5 {
+		t.Fatalf("ancient count mismatch: have %d, want 5", frozen)
+	}
+	head := ReadCanonicalHash(db, 4)
+	if have := ReadHeadHeaderHash(db); have != head {
+		t.Errorf("head header mismatch: have %x, want %x", have, head)
+	}
+	if have := ReadHeadBlockHash(db); have != head {
+		t.Errorf("head block mismatch: have %x, want %x", have, head)
+	}
+	if have := ReadHeadFastBlockHash(db); have != fast {
+		t.Errorf("head fast block mismatch: have %x, want %x", have, fast)
+	}
+	if verified, err := VerifyAncients(db, 0, newHasher()); err != nil || verified != 5 {
+		t.Fatalf("verification after reopen failed: verified %d, err %v", verified, err)
+	}
+	// The genesis block can't be truncated
+	if err := TruncateAncientChain(db, 0); err == nil {
+		t.Fatal("truncating the genesis block succeeded")
+	}
+}
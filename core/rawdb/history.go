@@ -0,0 +1,56 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// historyTables are the ancient tables whose data is subject to history expiry.
+// Headers, hashes and difficulties are retained to keep the chain verifiable.
+var historyTables = []string{freezerBodiesTable, freezerReceiptTable}
+
+// PruneHistory deletes the block bodies and receipts below the given block number
+// from the ancient store. Data is deleted with data file granularity, so some of
+// the blocks below the threshold may be retained. Blocks not yet moved into the
+// ancient store are never affected.
+//
+// The returned number is the first block whose history is still available.
+func PruneHistory(db ethdb.AncientStore, number uint64) (uint64, error) {
+	for _, kind := range historyTables {
+		if err := db.TruncateAncientTail(kind, number); err != nil {
+			return 0, err
+		}
+	}
+	tail := ReadHistoryTail(db)
+	log.Info("Pruned ancient chain history", "requested", number, "tail", tail)
+	return tail, nil
+}
+
+// ReadHistoryTail returns the number of the first block whose body and receipts
+// were not deleted by history expiry. Zero is returned if nothing was pruned or
+// if the database has no ancient store.
+func ReadHistoryTail(db ethdb.AncientReader) uint64 {
+	var tail uint64
+	for _, kind := range historyTables {
+		if n, err := db.AncientTail(kind); err == nil && n > tail {
+			tail = n
+		}
+	}
+	return tail
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// openHistoryFreezer opens a chain freezer in the given directory whose tables
+// store only a few items per data file.
+func openHistoryFreezer(t *testing.T, dir string) *freezer {
+	f := &freezer{
+		tables:  make(map[string]*freezerTable),
+		trigger: make(chan chan struct{}),
+		quit:    make(chan struct{}),
+	}
+	for name := range freezerNoSnappy {
+		table, err := newCustomTable(dir, name, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, 256, true)
+		if err != nil {
+			t.Fatalf("failed to open table %s: %v", name, err)
+		}
+		f.tables[name] = table
+	}
+	if err := f.repair(); err != nil {
+		t.Fatalf("failed to repair freezer: %v", err)
+	}
+	return f
+}
+
+// closeHistoryFreezer closes all tables of a freezer opened by openHistoryFreezer.
+func closeHistoryFreezer(f *freezer) {
+	for _, table := range f.tables {
+		table.Close()
+	}
+}
+
+// Tests that pruning the chain history deletes the bodies and receipts of old
+// blocks from the freezer, retains the headers and persists the new tail.
+func TestPruneHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := openHistoryFreezer(t, dir)
+	db := &freezerdb{KeyValueStore: NewMemoryDatabase(), AncientStore: f}
+
+	var blocks []*types.Block
+	for i := 0; i < 30; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		block := types.NewBlockWithHeader(&types.Header{
+			Number:      big.NewInt(int64(i)),
+			Extra:       []byte("test block"),
+			UncleHash:   types.EmptyUncleHash,
+			ReceiptHash: types.EmptyRootHash,
+		}).WithBody([]*types.Transaction{tx}, nil)
+		receipts := types.Receipts{{
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: 21000,
+			Logs:              []*types.Log{{Address: common.Address{0x01}, Data: make([]byte, 64)}},
+		}}
+		WriteAncientBlock(db, block, receipts, big.NewInt(int64(i)))
+		blocks = append(blocks, block)
+	}
+	if tail := ReadHistoryTail(db); tail != 0 {
+		t.Fatalf("history tail before pruning mismatch: have %d, want 0", tail)
+	}
+	tail, err := PruneHistory(db, 20)
+	if err != nil {
+		t.Fatalf("failed to prune history: %v", err)
+	}
+	if tail == 0 || tail > 20 {
+		t.Fatalf("history tail out of range: have %d, want (0, 20]", tail)
+	}
+	if have := ReadHistoryTail(db); have != tail {
+		t.Fatalf("history tail mismatch: have %d, want %d", have, tail)
+	}
+	for _, kind := range []string{freezerHeaderTable, freezerHashTable, freezerDifficultyTable} {
+		if n, err := db.AncientTail(kind); err != nil || n != 0 {
+			t.Errorf("table %s: tail mismatch: have %d, %v, want 0", kind, n, err)
+		}
+	}
+	// Bodies and receipts are deleted in whole data files, so their tables might
+	// be pruned up to different blocks. The history tail is the higher one.
+	bodyTail, _ := db.AncientTail(freezerBodiesTable)
+	receiptTail, _ := db.AncientTail(freezerReceiptTable)
+	want := bodyTail
+	if receiptTail > want {
+		want = receiptTail
+	}
+	if bodyTail == 0 || receiptTail == 0 || tail != want {
+		t.Fatalf("table tails mismatch: bodies %d, receipts %d, history %d", bodyTail, receiptTail, tail)
+	}
+	check := func() {
+		t.Helper()
+		for _, block := range blocks {
+			number, hash := block.NumberU64(), block.Hash()
+			if ReadHeader(db, hash, number) == nil {
+				t.Errorf("block %d: header missing", number)
+			}
+			if body, pruned := ReadBodyRLP(db, hash, number), number < bodyTail; (body == nil) != pruned {
+				t.Errorf("block %d: body presence mismatch: have %v, want %v", number, body != nil, !pruned)
+			}
+			if receipts, pruned := ReadReceiptsRLP(db, hash, number), number < receiptTail; (receipts == nil) != pruned {
+				t.Errorf("block %d: receipts presence mismatch: have %v, want %v", number, receipts != nil, !pruned)
+			}
+		}
+	}
+	check()
+
+	// Pruning below the current tail is a noop
+	if have, err := PruneHistory(db, tail/2); err != nil || have != tail {
+		t.Fatalf("repeated pruning mismatch: have %d, %v, want %d", have, err, tail)
+	}
+	// The freezer can't be truncated into the pruned history
+	if err := db.TruncateAncients(tail - 1); !errors.Is(err, errTruncateBelowTail) {
+		t.Fatalf("truncation below tail error mismatch: have %v, want %v", err, errTruncateBelowTail)
+	}
+	// The tail survives reopening the freezer
+	closeHistoryFreezer(f)
+	f = openHistoryFreezer(t, dir)
+	defer closeHistoryFreezer(f)
+	db = &freezerdb{KeyValueStore: db.KeyValueStore, AncientStore: f}
+
+	if have := ReadHistoryTail(db); have != tail {
+		t.Fatalf("history tail after reopen mismatch: have %d, want %d", have, tail)
+	}
+	if frozen, _ := db.Ancients(); frozen != uint64(len(blocks)) {
+		t.Fatalf("ancient count after reopen mismatch: have %d, want %d", frozen, len(blocks))
+	}
+	check()
+}
+
+// Tests that the history tail of a database without ancient store is zero.
+func TestHistoryTailNoFreezer(t *testing.T) {
+	db := NewMemoryDatabase()
+	if tail := ReadHistoryTail(db); tail != 0 {
+		t.Fatalf("history tail mismatch: have %d, want 0", tail)
+	}
+	if _, err := PruneHistory(db, 10); err == nil {
+		t.Fatal("pruning history without ancient store succeeded")
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// keyRange matches the database keys of a single data type by prefix and length.
+type keyRange struct {
+	prefix []byte
+	length int // Total length of the matching keys, zero for any
+}
+
+// matches reports whether the key falls into the range.
+func (r keyRange) matches(key []byte) bool {
+	return bytes.HasPrefix(key, r.prefix) && (r.length == 0 || len(key) == r.length)
+}
+
+// keyCategories are the named data categories which can be moved between databases
+// in bulk. Contract codes stored with the legacy scheme (without prefix) can't be
+// told apart from trie nodes and are not included.
+var keyCategories = map[string][]keyRange{
+	"preimage": {
+		{prefix: preimagePrefix, length: len(preimagePrefix) + common.HashLength},
+	},
+	"snapshot": {
+		{prefix: snapshotRootKey, length: len(snapshotRootKey)},
+		{prefix: snapshotGeneratorKey, length: len(snapshotGeneratorKey)},
+		{prefix: SnapshotAccountPrefix, length: len(SnapshotAccountPrefix) + common.HashLength},
+		{prefix: SnapshotStoragePrefix, length: len(SnapshotStoragePrefix) + 2*common.HashLength},
+	},
+	"bloombits": {
+		{prefix: bloomBitsPrefix, length: len(bloomBitsPrefix) + 10 + common.HashLength},
+		{prefix: BloomBitsIndexPrefix},
+	},
+	"txlookup": {
+		{prefix: txLookupPrefix, length: len(txLookupPrefix) + common.HashLength},
+	},
+	"code": {
+		{prefix: CodePrefix, length: len(CodePrefix) + common.HashLength},
+	},
+}
+
+// KeyCategories returns the names of the data categories supported by
+// IterateKeyCategory, sorted alphabetically.
+func KeyCategories() []string {
+	names := make([]string, 0, len(keyCategories))
+	for name := range keyCategories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsKeyInCategory reports whether the database key belongs to the named data
+// category.
+func IsKeyInCategory(category string, key []byte) bool {
+	for _, r := range keyCategories[category] {
+		if r.matches(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// IterateKeyCategory returns an iterator over all the database entries belonging
+// to the named data category.
+func IterateKeyCategory(db ethdb.Iteratee, category string) (ethdb.Iterator, error) {
+	ranges, ok := keyCategories[category]
+	if !ok {
+		return nil, fmt.Errorf("unknown data category %q", category)
+	}
+	return &categoryIterator{db: db, ranges: ranges}, nil
+}
+
+// categoryIterator chains the iterators over the key ranges of a data category,
+// skipping any keys of a matching prefix but mismatching length.
+type categoryIterator struct {
+	db     ethdb.Iteratee
+	ranges []keyRange     // Key ranges left to iterate, the first one is active
+	it     ethdb.Iterator // Iterator over the active key range
+	err    error
+}
+
+// Next moves the iterator to the next key/value pair. It returns whether the
+// iterator is exhausted.
+func (it *categoryIterator) Next() bool {
+	for it.err == nil && len(it.ranges) > 0 {
+		if it.it == nil {
+			it.it = it.db.NewIterator(it.ranges[0].prefix, nil)
+		}
+		for it.it.Next() {
+			if it.ranges[0].matches(it.it.Key()) {
+				return true
+			}
+		}
+		it.err = it.it.Error()
+		it.it.Release()
+		it.it, it.ranges = nil, it.ranges[1:]
+	}
+	return false
+}
+
+// Error returns any accumulated error.
+func (it *categoryIterator) Error() error {
+	return it.err
+}
+
+// Key returns the key of the current key/value pair, or nil if done.
+func (it *categoryIterator) Key() []byte {
+	if it.it == nil {
+		return nil
+	}
+	return it.it.Key()
+}
+
+// Value returns the value of the current key/value pair, or nil if done.
+func (it *categoryIterator) Value() []byte {
+	if it.it == nil {
+		return nil
+	}
+	return it.it.Value()
+}
+
+// Release releases associated resources.
+func (it *categoryIterator) Release() {
+	if it.it != nil {
+		it.it.Release()
+		it.it = nil
+	}
+	it.ranges = nil
+}
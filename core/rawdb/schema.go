@@ -72,6 +72,9 @@ var (
 	// uncleanShutdownKey tracks the list of local crashes
 	uncleanShutdownKey = []byte("unclean-shutdown") // config prefix for the db
 
+	// stateSchemeKey tracks the storage scheme of the trie nodes.
+	stateSchemeKey = []byte("TrieNodeScheme")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -86,6 +89,9 @@ var (
 	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
+	TrieNodeAccountPrefix = []byte("A") // TrieNodeAccountPrefix + hexPath -> trie node (path scheme)
+	TrieNodeStoragePrefix = []byte("O") // TrieNodeStoragePrefix + account hash + hexPath -> trie node (path scheme)
+	reverseDiffPrefix     = []byte("R") // reverseDiffPrefix + num (uint64 big endian) + hash -> reverse state diff
 
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
@@ -194,6 +200,11 @@ func storageSnapshotsKey(accountHash common.Hash) []byte {
 	return append(SnapshotStoragePrefix, accountHash.Bytes()...)
 }
 
+// reverseDiffKey = reverseDiffPrefix + num (uint64 big endian) + hash
+func reverseDiffKey(number uint64, hash common.Hash) []byte {
+	return append(append(reverseDiffPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // bloomBitsKey = bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash
 func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	key := append(append(bloomBitsPrefix, make([]byte, 10)...), hash.Bytes()...)
@@ -223,6 +234,30 @@ func IsCodeKey(key []byte) (bool, []byte) {
 	return false, nil
 }
 
+// accountTrieNodeKey = TrieNodeAccountPrefix + hexPath
+func accountTrieNodeKey(path []byte) []byte {
+	return append(TrieNodeAccountPrefix, path...)
+}
+
+// storageTrieNodeKey = TrieNodeStoragePrefix + account hash + hexPath
+func storageTrieNodeKey(accountHash common.Hash, path []byte) []byte {
+	return append(append(TrieNodeStoragePrefix, accountHash.Bytes()...), path...)
+}
+
+// isHexPath reports whether the given byte slice is a valid trie path in nibble
+// encoding, as used by the keys of the path-based trie nodes.
+func isHexPath(path []byte) bool {
+	if len(path) > 2*common.HashLength {
+		return false
+	}
+	for _, nibble := range path {
+		if nibble >= 16 {
+			return false
+		}
+	}
+	return true
+}
+
 // configKey = configPrefix + hash
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
@@ -0,0 +1,214 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// stateHistoryTable is the name of the freezer table storing reverse state diffs.
+const stateHistoryTable = "reverse"
+
+// ErrStateHistoryUnavailable is returned if the reverse state diff of a block is
+// not retained by the state history.
+var ErrStateHistoryUnavailable = errors.New("state history unavailable")
+
+// StateHistory is an append-only store of the reverse state diffs of a contiguous
+// range of canonical blocks, kept in a freezer table next to the ancient chain
+// data. Every item contains the block number, the block hash and the diff itself,
+// blocks whose diff is not known are recorded with an empty diff to keep the range
+// contiguous.
+type StateHistory struct {
+	table *freezerTable
+	base  uint64       // Block number of the item with index zero
+	lock  sync.RWMutex // Lock protecting the base number during appends
+}
+
+// OpenStateHistory opens the state history stored in the given directory, creating
+// it if it doesn't exist yet.
+func OpenStateHistory(path string) (*StateHistory, error) {
+	table, err := NewFreezerTable(path, stateHistoryTable, false)
+	if err != nil {
+		return nil, err
+	}
+	h := &StateHistory{table: table}
+
+	// Derive the block number of the first item from the retained items, if
+	// there's nothing retained, the next append will pick the base.
+	if tail, items := table.tail(), atomic.LoadUint64(&table.items); tail < items {
+		blob, err := table.Retrieve(tail)
+		if err != nil {
+			table.Close()
+			return nil, err
+		}
+		number, _, _, err := decodeStateHistory(blob)
+		if err != nil {
+			table.Close()
+			return nil, err
+		}
+		if number < tail {
+			table.Close()
+			return nil, fmt.Errorf("invalid state history item %d: block %d", tail, number)
+		}
+		h.base = number - tail
+	}
+	first, next := h.Range()
+	log.Info("Opened state history", "path", path, "first", first, "next", next)
+	return h, nil
+}
+
+// Close flushes and closes the state history.
+func (h *StateHistory) Close() error {
+	if err := h.table.Sync(); err != nil {
+		log.Error("Failed to sync state history", "err", err)
+	}
+	return h.table.Close()
+}
+
+// Sync flushes all appended items to disk.
+func (h *StateHistory) Sync() error {
+	return h.table.Sync()
+}
+
+// Range returns the number of the first block retained in the state history and
+// the number of the next block to append. The history is empty if they are equal.
+func (h *StateHistory) Range() (uint64, uint64) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return h.rangeNolock()
+}
+
+// rangeNolock is the internal version of Range without locking.
+func (h *StateHistory) rangeNolock() (uint64, uint64) {
+	return h.base + h.table.tail(), h.base + atomic.LoadUint64(&h.table.items)
+}
+
+// Append adds the reverse state diff of the given block to the state history. The
+// number must not be lower than the next one expected, blocks skipped over are
+// recorded as unavailable. A nil diff marks the block itself as unavailable.
+func (h *StateHistory) Append(number uint64, hash common.Hash, diff []byte) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	first, next := h.rangeNolock()
+	if first == next {
+		// Nothing is retained, move the range to start at the new block
+		items := atomic.LoadUint64(&h.table.items)
+		if number < items {
+			return fmt.Errorf("state history can't be rebased to block %d below %d items", number, items)
+		}
+		h.base, next = number-items, number
+	}
+	if number < next {
+		return fmt.Errorf("state history out of order: have %d, want %d", number, next)
+	}
+	for ; next < number; next++ {
+		if err := h.table.Append(next-h.base, encodeStateHistory(next, common.Hash{}, nil)); err != nil {
+			return err
+		}
+	}
+	return h.table.Append(number-h.base, encodeStateHistory(number, hash, diff))
+}
+
+// Read retrieves the block hash and the reverse state diff of the given block. If
+// the block is outside of the retained range or its diff is not known, the error
+// ErrStateHistoryUnavailable is returned.
+func (h *StateHistory) Read(number uint64) (common.Hash, []byte, error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if first, next := h.rangeNolock(); number < first || number >= next {
+		return common.Hash{}, nil, ErrStateHistoryUnavailable
+	}
+	blob, err := h.table.Retrieve(number - h.base)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	stored, hash, diff, err := decodeStateHistory(blob)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	if stored != number {
+		return common.Hash{}, nil, fmt.Errorf("state history item mismatch: have %d, want %d", stored, number)
+	}
+	if diff == nil {
+		return common.Hash{}, nil, ErrStateHistoryUnavailable
+	}
+	return hash, diff, nil
+}
+
+// TruncateHead discards the reverse state diffs of all the blocks from the given
+// number onwards, e.g. after they were reorged out of the canonical chain.
+func (h *StateHistory) TruncateHead(number uint64) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	first, next := h.rangeNolock()
+	if number >= next {
+		return nil
+	}
+	if number < first {
+		number = first
+	}
+	return h.table.truncate(number - h.base)
+}
+
+// TruncateTail discards the reverse state diffs of the blocks below the given
+// number. Data is deleted with data file granularity, so some of the blocks below
+// the threshold may be retained.
+func (h *StateHistory) TruncateTail(number uint64) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if number <= h.base {
+		return nil
+	}
+	return h.table.truncateTail(number - h.base)
+}
+
+// encodeStateHistory encodes a state history item as the block number, the block
+// hash and the reverse diff. Unavailable diffs are encoded without the hash.
+func encodeStateHistory(number uint64, hash common.Hash, diff []byte) []byte {
+	if diff == nil {
+		return encodeBlockNumber(number)
+	}
+	blob := make([]byte, 8+common.HashLength+len(diff))
+	binary.BigEndian.PutUint64(blob, number)
+	copy(blob[8:], hash[:])
+	copy(blob[8+common.HashLength:], diff)
+	return blob
+}
+
+// decodeStateHistory decodes a state history item, returning a nil diff if it's
+// not available.
+func decodeStateHistory(blob []byte) (uint64, common.Hash, []byte, error) {
+	switch {
+	case len(blob) == 8:
+		return binary.BigEndian.Uint64(blob), common.Hash{}, nil, nil
+	case len(blob) < 8+common.HashLength:
+		return 0, common.Hash{}, nil, fmt.Errorf("invalid state history item length %d", len(blob))
+	}
+	return binary.BigEndian.Uint64(blob), common.BytesToHash(blob[8 : 8+common.HashLength]), blob[8+common.HashLength:], nil
+}
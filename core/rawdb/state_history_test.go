@@ -0,0 +1,115 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that the state history keeps a contiguous range of blocks across gaps,
+// truncations and restarts.
+func TestStateHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statehistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	history, err := OpenStateHistory(dir)
+	if err != nil {
+		t.Fatalf("failed to open state history: %v", err)
+	}
+	if first, next := history.Range(); first != next {
+		t.Fatalf("fresh state history not empty: [%d, %d)", first, next)
+	}
+	// The first append picks the base, gaps are filled with unavailable blocks
+	for _, number := range []uint64{100, 101, 104} {
+		if err := history.Append(number, common.Hash{byte(number)}, []byte{byte(number)}); err != nil {
+			t.Fatalf("failed to append block %d: %v", number, err)
+		}
+	}
+	if err := history.Append(103, common.Hash{}, nil); err == nil {
+		t.Fatalf("out of order append succeeded")
+	}
+	check := func(history *StateHistory, first, next uint64, missing ...uint64) {
+		t.Helper()
+
+		if f, n := history.Range(); f != first || n != next {
+			t.Fatalf("range mismatch: have [%d, %d), want [%d, %d)", f, n, first, next)
+		}
+		unavailable := map[uint64]bool{first - 1: true, next: true}
+		for _, number := range missing {
+			unavailable[number] = true
+		}
+		for number := first - 1; number <= next; number++ {
+			hash, diff, err := history.Read(number)
+			if unavailable[number] {
+				if err != ErrStateHistoryUnavailable {
+					t.Errorf("block %d: unexpected error: have %v, want %v", number, err, ErrStateHistoryUnavailable)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("block %d: failed to read: %v", number, err)
+				continue
+			}
+			if hash != (common.Hash{byte(number)}) || !bytes.Equal(diff, []byte{byte(number)}) {
+				t.Errorf("block %d: item mismatch: have %x/%x", number, hash, diff)
+			}
+		}
+	}
+	check(history, 100, 105, 102, 103)
+
+	// Truncating the head allows rewriting the dropped blocks
+	if err := history.TruncateHead(103); err != nil {
+		t.Fatalf("failed to truncate head: %v", err)
+	}
+	check(history, 100, 103, 102)
+	for _, number := range []uint64{103, 104, 105} {
+		if err := history.Append(number, common.Hash{byte(number)}, []byte{byte(number)}); err != nil {
+			t.Fatalf("failed to append block %d: %v", number, err)
+		}
+	}
+	check(history, 100, 106, 102)
+
+	// Reopen the history and ensure the base is recovered
+	if err := history.Close(); err != nil {
+		t.Fatalf("failed to close state history: %v", err)
+	}
+	if history, err = OpenStateHistory(dir); err != nil {
+		t.Fatalf("failed to reopen state history: %v", err)
+	}
+	check(history, 100, 106, 102)
+
+	// Emptying the history allows rebasing it
+	if err := history.TruncateHead(0); err != nil {
+		t.Fatalf("failed to truncate head: %v", err)
+	}
+	if first, next := history.Range(); first != next {
+		t.Fatalf("truncated state history not empty: [%d, %d)", first, next)
+	}
+	if err := history.Append(200, common.Hash{200}, []byte{200}); err != nil {
+		t.Fatalf("failed to append block 200: %v", err)
+	}
+	check(history, 200, 201)
+	history.Close()
+}
@@ -62,6 +62,18 @@ func (t *table) Ancient(kind string, number uint64) ([]byte, error) {
 	return t.db.Ancient(kind, number)
 }
 
+// AncientRange is a noop passthrough that just forwards the request to the underlying
+// database.
+func (t *table) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	return t.db.AncientRange(kind, start, count, maxBytes)
+}
+
+// AncientTail is a noop passthrough that just forwards the request to the underlying
+// database.
+func (t *table) AncientTail(kind string) (uint64, error) {
+	return t.db.AncientTail(kind)
+}
+
 // Ancients is a noop passthrough that just forwards the request to the underlying
 // database.
 func (t *table) Ancients() (uint64, error) {
@@ -86,6 +98,12 @@ func (t *table) TruncateAncients(items uint64) error {
 	return t.db.TruncateAncients(items)
 }
 
+// TruncateAncientTail is a noop passthrough that just forwards the request to the
+// underlying database.
+func (t *table) TruncateAncientTail(kind string, items uint64) error {
+	return t.db.TruncateAncientTail(kind, items)
+}
+
 // Sync is a noop passthrough that just forwards the request to the underlying
 // database.
 func (t *table) Sync() error {
@@ -0,0 +1,164 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// errHistoricState is returned for operations not supported on historic states.
+var errHistoricState = errors.New("not supported on historic state")
+
+// historicDatabase serves the accounts and storage slots of an older block from a
+// snapshot rolled back with reverse state diffs, without needing its tries. The
+// tries it opens are read-only views, modifications are kept in memory but not
+// reflected in their hashes.
+type historicDatabase struct {
+	Database
+	state *snapshot.HistoricState
+}
+
+// NewHistoricDatabase wraps a state database to serve the state of an older block
+// from the given rolled back snapshot. Contract codes are still read from the
+// wrapped database.
+func NewHistoricDatabase(db Database, state *snapshot.HistoricState) Database {
+	return &historicDatabase{Database: db, state: state}
+}
+
+// OpenTrie opens the main account trie.
+func (db *historicDatabase) OpenTrie(root common.Hash) (Trie, error) {
+	return &historicTrie{state: db.state, root: root, dirties: make(map[common.Hash][]byte)}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account.
+func (db *historicDatabase) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
+	return &historicTrie{state: db.state, owner: addrHash, storage: true, root: root, dirties: make(map[common.Hash][]byte)}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *historicDatabase) CopyTrie(t Trie) Trie {
+	if t, ok := t.(*historicTrie); ok {
+		return t.copy()
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// Historic reports whether the state is served from a rolled back snapshot, in
+// which case it can't be committed.
+func (s *StateDB) Historic() bool {
+	_, ok := s.db.(*historicDatabase)
+	return ok
+}
+
+// historicTrie is a read-only view of an account or storage trie of a historic
+// state, looking up its values from the rolled back snapshot.
+type historicTrie struct {
+	state   *snapshot.HistoricState
+	owner   common.Hash // Hash of the account owning the storage trie
+	storage bool        // Whether the trie is a storage trie
+	root    common.Hash
+
+	dirties map[common.Hash][]byte // Values modified in memory, nil if deleted
+}
+
+// copy returns an independent copy of the trie.
+func (t *historicTrie) copy() *historicTrie {
+	cpy := *t
+	cpy.dirties = make(map[common.Hash][]byte, len(t.dirties))
+	for hash, value := range t.dirties {
+		cpy.dirties[hash] = value
+	}
+	return &cpy
+}
+
+// GetKey returns nil, preimages are not retained for historic states.
+func (t *historicTrie) GetKey([]byte) []byte {
+	return nil
+}
+
+// TryGet returns the value for key stored in the trie, in the same encoding as
+// the secure trie would.
+func (t *historicTrie) TryGet(key []byte) ([]byte, error) {
+	hash := crypto.Keccak256Hash(key)
+	if value, ok := t.dirties[hash]; ok {
+		return value, nil
+	}
+	if t.storage {
+		return t.state.Storage(t.owner, hash)
+	}
+	blob, err := t.state.AccountRLP(hash)
+	if err != nil || len(blob) == 0 {
+		return nil, err
+	}
+	return snapshot.FullAccountRLP(blob)
+}
+
+// TryUpdate associates key with value in memory.
+func (t *historicTrie) TryUpdate(key, value []byte) error {
+	if len(value) == 0 {
+		return t.TryDelete(key)
+	}
+	t.dirties[crypto.Keccak256Hash(key)] = common.CopyBytes(value)
+	return nil
+}
+
+// TryDelete removes any existing value for key in memory.
+func (t *historicTrie) TryDelete(key []byte) error {
+	t.dirties[crypto.Keccak256Hash(key)] = nil
+	return nil
+}
+
+// Hash returns the root hash of the historic trie, disregarding any modifications.
+func (t *historicTrie) Hash() common.Hash {
+	return t.root
+}
+
+// Commit is not supported, historic states can't be persisted.
+func (t *historicTrie) Commit(onleaf trie.LeafCallback) (common.Hash, error) {
+	return common.Hash{}, errHistoricState
+}
+
+// NodeIterator returns an iterator failing right away, historic states have no
+// trie nodes to iterate.
+func (t *historicTrie) NodeIterator(startKey []byte) trie.NodeIterator {
+	return historicIterator{}
+}
+
+// Prove is not supported, historic states have no trie nodes to prove with.
+func (t *historicTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	return errHistoricState
+}
+
+// historicIterator is the node iterator of historic tries, which never yields
+// anything and reports errHistoricState.
+type historicIterator struct{}
+
+func (historicIterator) Next(bool) bool      { return false }
+func (historicIterator) Error() error        { return errHistoricState }
+func (historicIterator) Hash() common.Hash   { return common.Hash{} }
+func (historicIterator) Parent() common.Hash { return common.Hash{} }
+func (historicIterator) Path() []byte        { return nil }
+func (historicIterator) Leaf() bool          { return false }
+func (historicIterator) LeafKey() []byte     { return nil }
+func (historicIterator) LeafBlob() []byte    { return nil }
+func (historicIterator) LeafProof() [][]byte { return nil }
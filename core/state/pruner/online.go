@@ -0,0 +1,598 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	onlineMarkedGauge   = metrics.NewRegisteredGauge("state/prune/online/marked", nil)
+	onlineSweptMeter    = metrics.NewRegisteredMeter("state/prune/online/swept", nil)
+	onlineDeletedMeter  = metrics.NewRegisteredMeter("state/prune/online/deleted", nil)
+	onlineDeletedBytes  = metrics.NewRegisteredMeter("state/prune/online/deleted/bytes", nil)
+	onlineProgressGauge = metrics.NewRegisteredGauge("state/prune/online/progress", nil)
+
+	// errPruningRunning is returned if a pruning run is requested while another
+	// one is still in progress.
+	errPruningRunning = errors.New("state pruning already running")
+
+	// errPruningStopped is returned if the pruner is stopped during a run.
+	errPruningStopped = errors.New("state pruning stopped")
+)
+
+// The phases of an online pruning run.
+const (
+	PhaseMarking  = "marking"
+	PhaseSweeping = "sweeping"
+	PhaseDone     = "done"
+	PhaseFailed   = "failed"
+)
+
+// OnlineConfig contains the settings of the online pruner.
+type OnlineConfig struct {
+	BloomSize uint64        // Megabytes of memory allowance for the state bloom
+	BatchSize int           // Number of database entries checked in a single sweep batch
+	Throttle  time.Duration // Pause between sweep batches, leaving room for block import
+}
+
+// DefaultOnlineConfig contains the default settings of the online pruner.
+var DefaultOnlineConfig = OnlineConfig{
+	BloomSize: 2048,
+	BatchSize: 10000,
+	Throttle:  50 * time.Millisecond,
+}
+
+// OnlineStatus is the progress report of an online pruning run.
+type OnlineStatus struct {
+	Phase    string             `json:"phase"`           // Current phase, empty if never started
+	Roots    int                `json:"roots"`           // Number of state roots retained
+	Marked   int                `json:"marked"`          // Number of state roots already marked
+	Swept    uint64             `json:"swept"`           // Number of database entries checked
+	Deleted  uint64             `json:"deleted"`         // Number of stale trie nodes deleted
+	Size     common.StorageSize `json:"size"`            // Total size of the deleted trie nodes
+	Position hexutil.Bytes      `json:"position"`        // Database key the sweep has reached
+	Started  time.Time          `json:"started"`         // Time the run was started
+	Elapsed  time.Duration      `json:"elapsed"`         // Time spent on the run so far
+	Error    string             `json:"error,omitempty"` // Failure reason if the run failed
+}
+
+// OnlinePruner deletes stale trie nodes from the database of a live node, while
+// blocks keep being imported. The workflow is similar to the offline pruner:
+//
+//   - mark the trie nodes reachable from the recent state roots into a bloom
+//     filter, iterating the oldest trie in full and walking only the paths
+//     modified by the snapshot diff layers for the newer ones
+//   - sweep the database in throttled batches, deleting all hash-keyed trie
+//     nodes missing from the bloom filter
+//
+// The retained tries are referenced in the trie database while being marked, so
+// they're not garbage collected in the meantime. The snapshot layers are never
+// held, only their modified keys are collected when the run starts.
+//
+// Since the node keeps running, any trie node flushed to disk while a run is in
+// progress is marked too, so that nodes reappearing in new states are retained.
+// Sweep batches are serialized with the flushes, which guarantees that a node is
+// either marked before being checked, or rewritten after being deleted.
+//
+// Contract codes are marked along with the accounts using them, as older versions
+// stored them keyed by their bare hash, same as the trie nodes. Codes stored with
+// the code prefix are never deleted.
+type OnlinePruner struct {
+	db       ethdb.Database
+	triedb   *trie.Database
+	snaptree *snapshot.Tree
+	config   OnlineConfig
+
+	bloom *stateBloom // Trie nodes retained by the current run
+	lock  sync.Mutex  // Lock serializing sweep batches and trie node flushes
+
+	status     OnlineStatus
+	running    bool
+	statusLock sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewOnlinePruner creates a pruner deleting stale trie nodes from a live database.
+func NewOnlinePruner(db ethdb.Database, triedb *trie.Database, snaptree *snapshot.Tree, config OnlineConfig) (*OnlinePruner, error) {
+	if snaptree == nil {
+		return nil, errors.New("online state pruning requires snapshots")
+	}
+	if triedb.Scheme() == rawdb.PathScheme {
+		return nil, errors.New("state pruning unsupported with path-based trie node storage")
+	}
+	if config.BloomSize < 256 {
+		log.Warn("Sanitizing bloomfilter size", "provided(MB)", config.BloomSize, "updated(MB)", 256)
+		config.BloomSize = 256
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultOnlineConfig.BatchSize
+	}
+	return &OnlinePruner{
+		db:       db,
+		triedb:   triedb,
+		snaptree: snaptree,
+		config:   config,
+		quit:     make(chan struct{}),
+	}, nil
+}
+
+// Prune starts a pruning run in the background. The roots callback is invoked
+// once the pruner tracks the trie nodes flushed to disk, and should return all
+// the recent state roots whose trie nodes need to be retained, including every
+// state the node still keeps in memory and the last one persisted to disk. The
+// first root must be the chain head.
+func (p *OnlinePruner) Prune(roots func() []common.Hash) error {
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+
+	if p.running {
+		return errPruningRunning
+	}
+	select {
+	case <-p.quit:
+		return errPruningStopped
+	default:
+	}
+	bloom, err := newStateBloomWithSize(p.config.BloomSize)
+	if err != nil {
+		return err
+	}
+	// Start tracking the flushed nodes before retrieving the roots, so that
+	// there's no gap between the two.
+	p.bloom = bloom
+	p.triedb.SetFlushHook(p.onFlush)
+
+	var (
+		targets []common.Hash
+		seen    = make(map[common.Hash]bool)
+	)
+	for _, root := range roots() {
+		if !seen[root] {
+			targets = append(targets, root)
+			seen[root] = true
+		}
+	}
+	if len(targets) == 0 {
+		p.triedb.SetFlushHook(nil)
+		return errors.New("no state roots to retain")
+	}
+	// Collect the keys modified by the diff layers, allowing the newer states to
+	// be marked incrementally, and pin the tries until they're marked
+	changes, err := p.snaptree.Changes(targets[0])
+	if err != nil {
+		log.Warn("Marking all retained states in full", "err", err)
+	}
+	for _, root := range targets {
+		p.triedb.Reference(root, common.Hash{})
+	}
+	p.running = true
+	p.status = OnlineStatus{
+		Phase:   PhaseMarking,
+		Roots:   len(targets),
+		Started: time.Now(),
+	}
+	onlineMarkedGauge.Update(0)
+	onlineProgressGauge.Update(0)
+
+	p.wg.Add(1)
+	go p.run(targets, changes)
+	return nil
+}
+
+// Status returns the progress of the current or last pruning run.
+func (p *OnlinePruner) Status() OnlineStatus {
+	p.statusLock.RLock()
+	defer p.statusLock.RUnlock()
+
+	status := p.status
+	if p.running {
+		status.Elapsed = time.Since(status.Started)
+	}
+	status.Position = common.CopyBytes(status.Position)
+	return status
+}
+
+// Stop aborts any pruning run in progress and waits for it to terminate.
+func (p *OnlinePruner) Stop() {
+	p.statusLock.Lock()
+	select {
+	case <-p.quit:
+	default:
+		close(p.quit)
+	}
+	p.statusLock.Unlock()
+
+	p.wg.Wait()
+}
+
+// onFlush marks a trie node written to disk while a pruning run is in progress.
+func (p *OnlinePruner) onFlush(hash common.Hash) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.bloom.Put(hash.Bytes(), nil)
+}
+
+// run executes a pruning run, retaining the trie nodes of the given roots.
+func (p *OnlinePruner) run(roots []common.Hash, changes []*snapshot.LayerChanges) {
+	defer p.wg.Done()
+
+	err := p.mark(roots, changes)
+	if err == nil {
+		err = p.sweep()
+	}
+	p.triedb.SetFlushHook(nil)
+
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+
+	p.running = false
+	p.status.Elapsed = time.Since(p.status.Started)
+	if err != nil {
+		p.status.Phase, p.status.Error = PhaseFailed, err.Error()
+		log.Error("Online state pruning failed", "err", err)
+		return
+	}
+	p.status.Phase = PhaseDone
+	log.Info("Online state pruning finished", "nodes", p.status.Deleted, "size", p.status.Size, "elapsed", common.PrettyDuration(p.status.Elapsed))
+}
+
+// mark records the trie nodes of all the given roots in the state bloom. The
+// oldest root reachable via the diff layer changes is iterated in full, every
+// newer one only along the paths modified on top of its parent. Any root not
+// covered by the changes is iterated in full too.
+func (p *OnlinePruner) mark(roots []common.Hash, changes []*snapshot.LayerChanges) error {
+	defer func() {
+		for _, root := range roots {
+			p.triedb.Dereference(root)
+		}
+	}()
+	// Keep the genesis state around, similarly to the offline pruner
+	if err := extractGenesis(p.db, p.bloom); err != nil {
+		log.Warn("Failed to retain genesis state", "err", err)
+	}
+	// Pick the base state and the consecutive changes on top of it
+	retain := make(map[common.Hash]bool)
+	for _, root := range roots {
+		retain[root] = true
+	}
+	var (
+		base        common.Hash
+		incremental []*snapshot.LayerChanges
+	)
+	for _, change := range changes {
+		if base == (common.Hash{}) {
+			if !retain[change.Parent] {
+				if retain[change.Root] {
+					base = change.Root
+				}
+				continue
+			}
+			base = change.Parent
+		}
+		if !retain[change.Root] {
+			break
+		}
+		incremental = append(incremental, change)
+	}
+	covered := make(map[common.Hash]bool)
+	for _, change := range incremental {
+		covered[change.Root] = true
+	}
+	// Mark all the uncovered roots in full, then the changes on top
+	var marked int
+	for _, root := range roots {
+		if covered[root] {
+			continue
+		}
+
+		log.Info("Marking state for online pruning", "root", root, "index", marked+1, "total", len(roots))
+		if err := p.markTrie(root); err != nil {
+			return err
+		}
+		marked++
+		if err := p.markProgress(marked); err != nil {
+			return err
+		}
+	}
+	for _, change := range incremental {
+		if err := p.markChanges(change); err != nil {
+			return err
+		}
+		marked++
+		if err := p.markProgress(marked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markProgress reports the number of states marked so far, returning an error
+// if the pruner was stopped in the meantime.
+func (p *OnlinePruner) markProgress(marked int) error {
+	p.statusLock.Lock()
+	p.status.Marked = marked
+	p.statusLock.Unlock()
+	onlineMarkedGauge.Update(int64(marked))
+
+	select {
+	case <-p.quit:
+		return errPruningStopped
+	default:
+		return nil
+	}
+}
+
+// markTrie iterates the entire account trie of the given root, along with all
+// the storage tries, marking every trie node and contract code in the state bloom.
+func (p *OnlinePruner) markTrie(root common.Hash) error {
+	tr, err := trie.New(root, p.triedb)
+	if err != nil {
+		return err
+	}
+	accIter := tr.NodeIterator(nil)
+	for accIter.Next(true) {
+		// Embedded nodes don't have hash.
+		if hash := accIter.Hash(); hash != (common.Hash{}) {
+			p.bloom.Put(hash.Bytes(), nil)
+		}
+		if !accIter.Leaf() {
+			continue
+		}
+		var acc state.Account
+		if err := rlp.DecodeBytes(accIter.LeafBlob(), &acc); err != nil {
+			return err
+		}
+		if !bytes.Equal(acc.CodeHash, emptyCode) {
+			p.bloom.Put(acc.CodeHash, nil)
+		}
+		if acc.Root == emptyRoot {
+			continue
+		}
+		storageTrie, err := trie.NewWithOwner(common.BytesToHash(accIter.LeafKey()), acc.Root, p.triedb)
+		if err != nil {
+			return err
+		}
+		storageIter := storageTrie.NodeIterator(nil)
+		for storageIter.Next(true) {
+			if hash := storageIter.Hash(); hash != (common.Hash{}) {
+				p.bloom.Put(hash.Bytes(), nil)
+			}
+		}
+		if storageIter.Error() != nil {
+			return storageIter.Error()
+		}
+		select {
+		case <-p.quit:
+			return errPruningStopped
+		default:
+		}
+	}
+	return accIter.Error()
+}
+
+// markChanges marks the trie nodes of a state which differ from its parent, by
+// walking the account and storage tries along the keys modified by the layer.
+// The nodes created off the walked paths are all referenced by the ones along
+// them, which are marked through the nodeMarker. The codes of the modified
+// accounts are marked too.
+func (p *OnlinePruner) markChanges(change *snapshot.LayerChanges) error {
+	tr, err := trie.New(change.Root, p.triedb)
+	if err != nil {
+		return err
+	}
+	var (
+		marker   = &nodeMarker{bloom: p.bloom}
+		accounts = make(map[common.Hash]*state.Account)
+	)
+	account := func(hash common.Hash) (*state.Account, error) {
+		if acc, ok := accounts[hash]; ok {
+			return acc, nil
+		}
+		blob, err := tr.TryGet(hash.Bytes())
+		if err != nil || len(blob) == 0 {
+			return nil, err // Account destructed, storage gone
+		}
+		acc := new(state.Account)
+		if err := rlp.DecodeBytes(blob, acc); err != nil {
+			return nil, err
+		}
+		accounts[hash] = acc
+		return acc, nil
+	}
+	for _, hash := range change.Accounts {
+		if err := tr.Prove(hash.Bytes(), 0, marker); err != nil {
+			return err
+		}
+		acc, err := account(hash)
+		if err != nil {
+			return err
+		}
+		if acc != nil && !bytes.Equal(acc.CodeHash, emptyCode) {
+			p.bloom.Put(acc.CodeHash, nil)
+		}
+	}
+	for hash, slots := range change.Storage {
+		acc, err := account(hash)
+		if err != nil {
+			return err
+		}
+		if acc == nil || acc.Root == emptyRoot {
+			continue
+		}
+		storageTrie, err := trie.NewWithOwner(hash, acc.Root, p.triedb)
+		if err != nil {
+			return err
+		}
+		for _, slot := range slots {
+			if err := storageTrie.Prove(slot.Bytes(), 0, marker); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nodeMarker is a proof database marking the proven trie nodes in a state bloom,
+// along with all the nodes they reference. The latter is needed as modifying a
+// trie may create nodes next to the modified paths, e.g. when a short node gets
+// split by an insertion.
+type nodeMarker struct {
+	bloom *stateBloom
+}
+
+// Put implements ethdb.KeyValueWriter, marking a proof node and its children.
+func (m *nodeMarker) Put(key []byte, value []byte) error {
+	if err := m.bloom.Put(key, nil); err != nil {
+		return err
+	}
+	elems, _, err := rlp.SplitList(value)
+	if err != nil {
+		return err
+	}
+	for len(elems) > 0 {
+		kind, content, rest, err := rlp.Split(elems)
+		if err != nil {
+			return err
+		}
+		// Child references are the only 32 byte strings, apart from the leaf
+		// values of the same size, which are harmless to mark.
+		if kind == rlp.String && len(content) == common.HashLength {
+			m.bloom.Put(content, nil)
+		}
+		elems = rest
+	}
+	return nil
+}
+
+// Delete implements ethdb.KeyValueWriter, but is not supported.
+func (m *nodeMarker) Delete(key []byte) error { panic("not supported") }
+
+// sweep iterates the database in throttled batches, deleting every hash-keyed
+// trie node not marked in the state bloom.
+func (p *OnlinePruner) sweep() error {
+	p.statusLock.Lock()
+	p.status.Phase = PhaseSweeping
+	p.statusLock.Unlock()
+
+	var (
+		next   []byte
+		logged = time.Now()
+	)
+	for {
+		done, last, err := p.sweepBatch(next)
+		if err != nil {
+			return err
+		}
+		if done {
+			onlineProgressGauge.Update(100)
+			return nil
+		}
+		// Continue right after the last checked key
+		next = append(common.CopyBytes(last), 0x00)
+
+		if time.Since(logged) > 8*time.Second {
+			status := p.Status()
+			log.Info("Pruning state data online", "nodes", status.Deleted, "size", status.Size, "position", hexutil.Bytes(last), "elapsed", common.PrettyDuration(status.Elapsed))
+			logged = time.Now()
+		}
+		select {
+		case <-p.quit:
+			return errPruningStopped
+		case <-time.After(p.config.Throttle):
+		}
+	}
+}
+
+// sweepBatch checks a single batch of database entries starting at the given
+// key, deleting the unmarked trie nodes. It returns whether the end of the
+// database was reached, along with the last key checked.
+func (p *OnlinePruner) sweepBatch(start []byte) (bool, []byte, error) {
+	// Hold the lock across the check and the deletion, so that no trie node can
+	// be flushed in between.
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var (
+		iter    = p.db.NewIterator(nil, start)
+		batch   = p.db.NewBatch()
+		last    []byte
+		checked int
+		deleted int
+		size    common.StorageSize
+	)
+	defer iter.Release()
+
+	for checked < p.config.BatchSize && iter.Next() {
+		key := iter.Key()
+		last = key
+		checked++
+
+		if len(key) != common.HashLength {
+			continue
+		}
+		if ok, err := p.bloom.Contain(key); err != nil {
+			return false, nil, err
+		} else if ok {
+			continue
+		}
+		deleted++
+		size += common.StorageSize(len(key) + len(iter.Value()))
+		batch.Delete(key)
+	}
+	if err := iter.Error(); err != nil {
+		return false, nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return false, nil, err
+	}
+	last = common.CopyBytes(last)
+
+	onlineSweptMeter.Mark(int64(checked))
+	onlineDeletedMeter.Mark(int64(deleted))
+	onlineDeletedBytes.Mark(int64(size))
+	if len(last) > 0 {
+		onlineProgressGauge.Update(int64(last[0]) * 100 / 256)
+	}
+	p.statusLock.Lock()
+	p.status.Swept += uint64(checked)
+	p.status.Deleted += uint64(deleted)
+	p.status.Size += size
+	if last != nil {
+		p.status.Position = last
+	}
+	p.statusLock.Unlock()
+
+	return checked < p.config.BatchSize, last, nil
+}
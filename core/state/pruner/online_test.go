@@ -0,0 +1,338 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// onlineTestCode is a contract storing the block number into one of 16 slots and
+// clearing another one, churning its storage trie on every call.
+var onlineTestCode = []byte{
+	byte(vm.NUMBER), byte(vm.PUSH1), 0x10, byte(vm.NUMBER), byte(vm.MOD), byte(vm.SSTORE),
+	byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x10, byte(vm.PUSH1), 0x08, byte(vm.NUMBER), byte(vm.ADD), byte(vm.MOD), byte(vm.SSTORE),
+	byte(vm.STOP),
+}
+
+// onlineTestCacheConfig is the chain cache configuration of the online pruning
+// tests, flushing a trie to disk on every block so stale nodes accumulate.
+var onlineTestCacheConfig = &core.CacheConfig{
+	TrieCleanLimit: 16,
+	TrieDirtyLimit: 16,
+	TrieTimeLimit:  time.Nanosecond,
+	SnapshotLimit:  16,
+	SnapshotWait:   true,
+}
+
+// onlineTestDeployCode is the init code deploying onlineTestCode.
+var onlineTestDeployCode = append([]byte{
+	byte(vm.PUSH1), byte(len(onlineTestCode)), byte(vm.DUP1), byte(vm.PUSH1), 0x0b, byte(vm.PUSH1), 0x00, byte(vm.CODECOPY),
+	byte(vm.PUSH1), 0x00, byte(vm.RETURN),
+}, onlineTestCode...)
+
+// newOnlineTestChain creates a blockchain with snapshots, along with a number of
+// blocks modifying accounts and storage to import on top.
+func newOnlineTestChain(t *testing.T, n int) (ethdb.Database, *core.BlockChain, []*types.Block) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = crypto.CreateAddress(sender, 0)
+		signer   = types.HomesteadSigner{}
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				sender: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+		db      = rawdb.NewMemoryDatabase()
+		gendb   = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(db)
+	)
+	gspec.MustCommit(gendb)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, n, func(i int, b *core.BlockGen) {
+		// Deploy the contract in the first block, calling it in all others
+		if i == 0 {
+			tx, _ := types.SignTx(types.NewContractCreation(b.TxNonce(sender), common.Big0, 100000, big.NewInt(1), onlineTestDeployCode), signer, key)
+			b.AddTx(tx)
+		} else {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), contract, common.Big0, 100000, big.NewInt(1), nil), signer, key)
+			b.AddTx(tx)
+		}
+		for j := 0; j < 4; j++ {
+			to := common.BigToAddress(big.NewInt(int64(1000 + (4*i+j)%256)))
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), to, big.NewInt(int64(i+1)), params.TxGas, big.NewInt(1), nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	chain, err := core.NewBlockChain(db, onlineTestCacheConfig, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	return db, chain, blocks
+}
+
+// onlineTestRoots returns the recent state roots available in the chain and the
+// last one persisted to disk, in the same way as the pruning API.
+func onlineTestRoots(db ethdb.Database, chain *core.BlockChain) []common.Hash {
+	var (
+		roots     []common.Hash
+		persisted bool
+	)
+	for header := chain.CurrentBlock().Header(); header != nil; {
+		ondisk := len(rawdb.ReadTrieNode(db, header.Root)) > 0
+		if len(roots) < core.TriesInMemory {
+			if chain.HasState(header.Root) {
+				roots = append(roots, header.Root)
+			}
+		} else if ondisk {
+			roots = append(roots, header.Root)
+		}
+		persisted = persisted || ondisk
+		if (persisted && len(roots) >= core.TriesInMemory) || header.Number.Uint64() == 0 {
+			break
+		}
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	return roots
+}
+
+// iterateState iterates all the trie nodes of a state, invoking the callback
+// with their hashes and failing the test if any of them is missing.
+func iterateState(t *testing.T, triedb *trie.Database, root common.Hash, onNode func(common.Hash)) {
+	tr, err := trie.New(root, triedb)
+	if err != nil {
+		t.Fatalf("state %x: failed to open account trie: %v", root, err)
+	}
+	accIter := tr.NodeIterator(nil)
+	for accIter.Next(true) {
+		if hash := accIter.Hash(); hash != (common.Hash{}) {
+			onNode(hash)
+		}
+		if !accIter.Leaf() {
+			continue
+		}
+		var acc state.Account
+		if err := rlp.DecodeBytes(accIter.LeafBlob(), &acc); err != nil {
+			t.Fatalf("state %x: failed to decode account: %v", root, err)
+		}
+		if acc.Root == emptyRoot {
+			continue
+		}
+		storageTrie, err := trie.NewWithOwner(common.BytesToHash(accIter.LeafKey()), acc.Root, triedb)
+		if err != nil {
+			t.Fatalf("state %x: failed to open storage trie: %v", root, err)
+		}
+		storageIter := storageTrie.NodeIterator(nil)
+		for storageIter.Next(true) {
+			if hash := storageIter.Hash(); hash != (common.Hash{}) {
+				onNode(hash)
+			}
+		}
+		if err := storageIter.Error(); err != nil {
+			t.Fatalf("state %x: failed to iterate storage trie: %v", root, err)
+		}
+	}
+	if err := accIter.Error(); err != nil {
+		t.Fatalf("state %x: failed to iterate account trie: %v", root, err)
+	}
+}
+
+// Tests that marking the newer states along the diff layer changes retains all
+// their trie nodes, same as marking each of them in full.
+func TestOnlinePruneMarkChanges(t *testing.T) {
+	db, chain, blocks := newOnlineTestChain(t, 160)
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	pruner, err := NewOnlinePruner(db, chain.StateCache().TrieDB(), chain.Snapshots(), OnlineConfig{})
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	roots := onlineTestRoots(db, chain)
+	changes, err := chain.Snapshots().Changes(roots[0])
+	if err != nil {
+		t.Fatalf("failed to retrieve layer changes: %v", err)
+	}
+	if len(changes) < len(roots)-1 {
+		t.Fatalf("layer change count mismatch: have %d, want at least %d", len(changes), len(roots)-1)
+	}
+	if pruner.bloom, err = newStateBloomWithSize(pruner.config.BloomSize); err != nil {
+		t.Fatalf("failed to create state bloom: %v", err)
+	}
+	for _, root := range roots {
+		chain.StateCache().TrieDB().Reference(root, common.Hash{})
+	}
+	if err := pruner.mark(roots, changes); err != nil {
+		t.Fatalf("failed to mark states: %v", err)
+	}
+	if marked := pruner.Status().Marked; marked != len(roots) {
+		t.Errorf("marked state count mismatch: have %d, want %d", marked, len(roots))
+	}
+	for _, root := range roots {
+		iterateState(t, chain.StateCache().TrieDB(), root, func(hash common.Hash) {
+			if ok, _ := pruner.bloom.Contain(hash.Bytes()); !ok {
+				t.Fatalf("state %x: trie node %x not marked", root, hash)
+			}
+		})
+	}
+}
+
+// Tests that pruning while blocks are being imported doesn't delete any trie
+// node of the retained or newly imported states, nor prevents the snapshot
+// layers from being flattened.
+func TestOnlinePruneDuringImport(t *testing.T) {
+	db, chain, blocks := newOnlineTestChain(t, 400)
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[:200]); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	pruner, err := NewOnlinePruner(db, chain.StateCache().TrieDB(), chain.Snapshots(), OnlineConfig{BatchSize: 64, Throttle: time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	defer pruner.Stop()
+
+	// Keep importing blocks while the pruning runs
+	imported := make(chan error, 1)
+	go func() {
+		for _, block := range blocks[200:] {
+			if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+				imported <- err
+				return
+			}
+		}
+		imported <- nil
+	}()
+	if err := pruner.Prune(func() []common.Hash { return onlineTestRoots(db, chain) }); err != nil {
+		t.Fatalf("failed to start pruning: %v", err)
+	}
+	if err := <-imported; err != nil {
+		t.Fatalf("failed to import chain during pruning: %v", err)
+	}
+	if status := waitOnlinePrune(t, pruner); status.Deleted == 0 {
+		t.Fatalf("no stale trie nodes deleted")
+	}
+	// Ensure the diff layers were flattened in the meantime
+	head := chain.CurrentBlock().Root()
+	if layers := len(chain.Snapshots().Snapshots(head, 2*core.TriesInMemory, true)); layers > core.TriesInMemory+1 {
+		t.Errorf("diff layers accumulated: have %d, want at most %d", layers, core.TriesInMemory+1)
+	}
+	// Ensure all the recent states are complete, both in memory and on disk
+	// after the chain persists them on shutdown
+	roots := onlineTestRoots(db, chain)
+	for _, root := range roots {
+		iterateState(t, chain.StateCache().TrieDB(), root, func(common.Hash) {})
+	}
+	chain.Stop()
+
+	triedb := trie.NewDatabase(db)
+	for _, number := range []uint64{0, 1, core.TriesInMemory - 1} {
+		block := blocks[len(blocks)-1-int(number)]
+		iterateState(t, triedb, block.Root(), func(common.Hash) {})
+	}
+}
+
+// waitOnlinePrune waits for a pruning run to finish, failing the test if it
+// doesn't succeed.
+func waitOnlinePrune(t *testing.T, pruner *OnlinePruner) OnlineStatus {
+	for timeout := time.After(time.Minute); ; {
+		status := pruner.Status()
+		switch status.Phase {
+		case PhaseFailed:
+			t.Fatalf("pruning failed: %s", status.Error)
+		case PhaseDone:
+			return status
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("pruning timed out in phase %s", status.Phase)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Tests that pruning retains the last state persisted to disk, even if it's older
+// than the states kept in memory, as well as the contract codes stored by their
+// bare hash by older versions.
+func TestOnlinePrunePersistedState(t *testing.T) {
+	db, chain, blocks := newOnlineTestChain(t, 300)
+	if _, err := chain.InsertChain(blocks[:100]); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	// Persist the state of block 100, then import more blocks than kept in memory
+	// without flushing any further state
+	chain.Stop()
+
+	cacheConfig := *onlineTestCacheConfig
+	cacheConfig.TrieTimeLimit = time.Hour
+
+	chain, err := core.NewBlockChain(db, &cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate blockchain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks[100:]); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	persisted := blocks[99].Root()
+	if roots := onlineTestRoots(db, chain); roots[len(roots)-1] != persisted {
+		t.Fatalf("persisted root not retained: have %x, want %x", roots[len(roots)-1], persisted)
+	}
+	// Move the contract code to the legacy scheme
+	codeHash := crypto.Keccak256Hash(onlineTestCode)
+	if code := rawdb.ReadCodeWithPrefix(db, codeHash); !bytes.Equal(code, onlineTestCode) {
+		t.Fatalf("contract not deployed: have code %x, want %x", code, onlineTestCode)
+	}
+	rawdb.DeleteCode(db, codeHash)
+	db.Put(codeHash.Bytes(), onlineTestCode)
+
+	pruner, err := NewOnlinePruner(db, chain.StateCache().TrieDB(), chain.Snapshots(), OnlineConfig{})
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	defer pruner.Stop()
+
+	if err := pruner.Prune(func() []common.Hash { return onlineTestRoots(db, chain) }); err != nil {
+		t.Fatalf("failed to start pruning: %v", err)
+	}
+	if status := waitOnlinePrune(t, pruner); status.Deleted == 0 {
+		t.Fatalf("no stale trie nodes deleted")
+	}
+	if code := rawdb.ReadCode(db, codeHash); !bytes.Equal(code, onlineTestCode) {
+		t.Errorf("legacy contract code mismatch: have %x, want %x", code, onlineTestCode)
+	}
+	iterateState(t, trie.NewDatabase(db), persisted, func(common.Hash) {})
+}
@@ -90,6 +90,10 @@ func NewPruner(db ethdb.Database, datadir, trieCachePath string, bloomSize uint6
 	if headBlock == nil {
 		return nil, errors.New("Failed to load head block")
 	}
+	// Trie nodes stored by path are overwritten in place, nothing to prune
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		return nil, errors.New("state pruning unsupported with path-based trie node storage")
+	}
 	snaptree, err := snapshot.New(db, trie.NewDatabase(db), 256, headBlock.Root(), false, false, false)
 	if err != nil {
 		return nil, err // The relevant snapshot(s) might not exist
@@ -407,11 +411,11 @@ func extractGenesis(db ethdb.Database, stateBloom *stateBloom) error {
 	if genesisHash == (common.Hash{}) {
 		return errors.New("missing genesis hash")
 	}
-	genesis := rawdb.ReadBlock(db, genesisHash, 0)
+	genesis := rawdb.ReadHeader(db, genesisHash, 0)
 	if genesis == nil {
-		return errors.New("missing genesis block")
+		return errors.New("missing genesis header")
 	}
-	t, err := trie.NewSecure(genesis.Root(), trie.NewDatabase(db))
+	t, err := trie.NewSecure(genesis.Root, trie.NewDatabase(db))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,398 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/golang/snappy"
+)
+
+// The export file starts with a fixed magic, followed by a sequence of frames.
+// Every frame is laid out as:
+//
+//	kind (1 byte) || length (4 bytes) || crc32c(payload) (4 bytes) || payload
+//
+// The payload is a snappy-compressed RLP blob, whose content depends on the
+// frame kind. The first frame is always the header, the last one the footer,
+// with an arbitrary number of data chunks in between.
+const (
+	exportVersion   = 1                // Version of the export file format
+	exportChunkSize = 4 * 1024 * 1024  // Uncompressed data size to accumulate before flushing a chunk
+	exportMaxFrame  = 64 * 1024 * 1024 // Maximum compressed frame size accepted on import
+)
+
+var exportMagic = []byte("GETHSNAP")
+
+// Frame kinds of the export file.
+const (
+	frameHeader byte = iota
+	frameChunk
+	frameFooter
+)
+
+// Entry kinds stored within a data chunk.
+const (
+	entryAccount uint8 = iota
+	entryStorage
+	entryCode
+)
+
+var (
+	// errExportCorrupted is returned if a frame checksum doesn't match.
+	errExportCorrupted = errors.New("corrupted snapshot export")
+
+	// errExportTruncated is returned if the export ends without a footer.
+	errExportTruncated = errors.New("truncated snapshot export")
+
+	// crc32c is the checksum table used for the export frames.
+	crc32c = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// exportHeader is the first frame of an export, identifying the state in it.
+type exportHeader struct {
+	Version uint64
+	Root    common.Hash
+}
+
+// exportEntry is a single account, storage slot or contract code item.
+type exportEntry struct {
+	Kind  uint8
+	Hash  common.Hash // Account hash, or code hash for contract codes
+	Slot  common.Hash // Storage slot hash, only set for storage entries
+	Value []byte      // Slim account RLP, storage value or contract code
+}
+
+// exportFooter is the last frame of an export, used to detect truncation and
+// cross-check the imported data.
+type exportFooter struct {
+	Accounts uint64
+	Slots    uint64
+	Codes    uint64
+}
+
+// exportWriter accumulates entries and flushes them as checksummed chunks.
+type exportWriter struct {
+	w       io.Writer
+	entries []exportEntry
+	size    int
+}
+
+// writeFrame compresses and writes a single frame into the export.
+func (ew *exportWriter) writeFrame(kind byte, val interface{}) error {
+	blob, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		return err
+	}
+	payload := snappy.Encode(nil, blob)
+
+	var head [9]byte
+	head[0] = kind
+	binary.BigEndian.PutUint32(head[1:5], uint32(len(payload)))
+	binary.BigEndian.PutUint32(head[5:9], crc32.Checksum(payload, crc32c))
+	if _, err := ew.w.Write(head[:]); err != nil {
+		return err
+	}
+	_, err = ew.w.Write(payload)
+	return err
+}
+
+// add appends an entry to the pending chunk, flushing it if it grew too large.
+func (ew *exportWriter) add(entry exportEntry) error {
+	ew.entries = append(ew.entries, entry)
+	ew.size += 2*common.HashLength + len(entry.Value)
+	if ew.size >= exportChunkSize {
+		return ew.flush()
+	}
+	return nil
+}
+
+// flush writes out all the pending entries as a data chunk.
+func (ew *exportWriter) flush() error {
+	if len(ew.entries) == 0 {
+		return nil
+	}
+	if err := ew.writeFrame(frameChunk, ew.entries); err != nil {
+		return err
+	}
+	ew.entries, ew.size = ew.entries[:0], 0
+	return nil
+}
+
+// Export streams the entire state (accounts, storage slots and contract codes)
+// belonging to the given root into w, using the snapshot iterators. The code
+// of contracts is resolved from codedb.
+func Export(snaptree *Tree, root common.Hash, codedb ethdb.KeyValueReader, w io.Writer) error {
+	acctIt, err := snaptree.AccountIterator(root, common.Hash{})
+	if err != nil {
+		return err // The required snapshot might not exist.
+	}
+	defer acctIt.Release()
+
+	ew := &exportWriter{w: w}
+	if _, err := w.Write(exportMagic); err != nil {
+		return err
+	}
+	if err := ew.writeFrame(frameHeader, &exportHeader{Version: exportVersion, Root: root}); err != nil {
+		return err
+	}
+	var (
+		footer exportFooter
+		codes  = make(map[common.Hash]struct{})
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for acctIt.Next() {
+		accHash := acctIt.Hash()
+		account, err := FullAccount(acctIt.Account())
+		if err != nil {
+			return err
+		}
+		if err := ew.add(exportEntry{Kind: entryAccount, Hash: accHash, Value: common.CopyBytes(acctIt.Account())}); err != nil {
+			return err
+		}
+		footer.Accounts++
+
+		// Export the contract code if it wasn't exported yet
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCode {
+			if _, ok := codes[codeHash]; !ok {
+				code := rawdb.ReadCode(codedb, codeHash)
+				if len(code) == 0 {
+					return fmt.Errorf("missing contract code %#x", codeHash)
+				}
+				if err := ew.add(exportEntry{Kind: entryCode, Hash: codeHash, Value: code}); err != nil {
+					return err
+				}
+				codes[codeHash] = struct{}{}
+				footer.Codes++
+			}
+		}
+		// Export all the storage slots of the account
+		if common.BytesToHash(account.Root) != emptyRoot {
+			storageIt, err := snaptree.StorageIterator(root, accHash, common.Hash{})
+			if err != nil {
+				return err
+			}
+			for storageIt.Next() {
+				if err := ew.add(exportEntry{Kind: entryStorage, Hash: accHash, Slot: storageIt.Hash(), Value: common.CopyBytes(storageIt.Slot())}); err != nil {
+					storageIt.Release()
+					return err
+				}
+				footer.Slots++
+			}
+			err = storageIt.Error()
+			storageIt.Release()
+			if err != nil {
+				return err
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state snapshot", "at", accHash, "accounts", footer.Accounts, "slots", footer.Slots, "codes", footer.Codes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := acctIt.Error(); err != nil {
+		return err
+	}
+	if err := ew.flush(); err != nil {
+		return err
+	}
+	if err := ew.writeFrame(frameFooter, &footer); err != nil {
+		return err
+	}
+	log.Info("Exported state snapshot", "root", root, "accounts", footer.Accounts, "slots", footer.Slots, "codes", footer.Codes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// readFrame reads the next frame from the export, verifying its checksum and
+// returning its kind along with the decompressed payload.
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [9]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil, errExportTruncated
+		}
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(head[1:5])
+	if size > exportMaxFrame {
+		return 0, nil, fmt.Errorf("%w: frame too large (%d bytes)", errExportCorrupted, size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, nil, errExportTruncated
+		}
+		return 0, nil, err
+	}
+	if crc32.Checksum(payload, crc32c) != binary.BigEndian.Uint32(head[5:9]) {
+		return 0, nil, fmt.Errorf("%w: checksum mismatch", errExportCorrupted)
+	}
+	blob, err := snappy.Decode(nil, payload)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %v", errExportCorrupted, err)
+	}
+	return head[0], blob, nil
+}
+
+// Import loads a state export produced by Export into db, which must not
+// contain a snapshot yet. After all the flat state is written, the account and
+// storage tries are regenerated from it and only if they match the exported
+// root is the snapshot marked as complete. If the import fails, the flat state
+// written so far is wiped. The root of the imported state is returned.
+func Import(r io.Reader, db ethdb.Database) (root common.Hash, err error) {
+	if rawdb.ReadSnapshotRoot(db) != (common.Hash{}) {
+		return common.Hash{}, errors.New("database already contains a snapshot")
+	}
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(exportMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != string(exportMagic) {
+		return common.Hash{}, errors.New("not a snapshot export")
+	}
+	kind, blob, err := readFrame(br)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var header exportHeader
+	if kind != frameHeader {
+		return common.Hash{}, fmt.Errorf("%w: missing header", errExportCorrupted)
+	}
+	if err := rlp.DecodeBytes(blob, &header); err != nil {
+		return common.Hash{}, err
+	}
+	if header.Version != exportVersion {
+		return common.Hash{}, fmt.Errorf("unsupported export version %d", header.Version)
+	}
+	// Write all the flat state entries into the database, deleting them again
+	// if the import fails midway or the state doesn't match the exported root
+	defer func() {
+		if err != nil {
+			if werr := wipeContent(db); werr != nil {
+				log.Error("Failed to wipe partially imported snapshot", "err", werr)
+			}
+		}
+	}()
+	var (
+		have   exportFooter
+		want   exportFooter
+		batch  = db.NewBatch()
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for done := false; !done; {
+		kind, blob, err := readFrame(br)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		switch kind {
+		case frameChunk:
+			var entries []exportEntry
+			if err := rlp.DecodeBytes(blob, &entries); err != nil {
+				return common.Hash{}, err
+			}
+			for _, entry := range entries {
+				switch entry.Kind {
+				case entryAccount:
+					rawdb.WriteAccountSnapshot(batch, entry.Hash, entry.Value)
+					have.Accounts++
+				case entryStorage:
+					rawdb.WriteStorageSnapshot(batch, entry.Hash, entry.Slot, entry.Value)
+					have.Slots++
+				case entryCode:
+					if crypto.Keccak256Hash(entry.Value) != entry.Hash {
+						return common.Hash{}, fmt.Errorf("%w: code hash mismatch %#x", errExportCorrupted, entry.Hash)
+					}
+					rawdb.WriteCode(batch, entry.Hash, entry.Value)
+					have.Codes++
+				default:
+					return common.Hash{}, fmt.Errorf("%w: unknown entry kind %d", errExportCorrupted, entry.Kind)
+				}
+				if batch.ValueSize() > ethdb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						return common.Hash{}, err
+					}
+					batch.Reset()
+				}
+			}
+		case frameFooter:
+			if err := rlp.DecodeBytes(blob, &want); err != nil {
+				return common.Hash{}, err
+			}
+			done = true
+		default:
+			return common.Hash{}, fmt.Errorf("%w: unexpected frame kind %d", errExportCorrupted, kind)
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state snapshot", "accounts", have.Accounts, "slots", have.Slots, "codes", have.Codes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if have != want {
+		return common.Hash{}, fmt.Errorf("%w: imported %d accounts, %d slots, %d codes, want %d, %d, %d",
+			errExportCorrupted, have.Accounts, have.Slots, have.Codes, want.Accounts, want.Slots, want.Codes)
+	}
+	if err := batch.Write(); err != nil {
+		return common.Hash{}, err
+	}
+	batch.Reset()
+	log.Info("Imported state snapshot", "root", header.Root, "accounts", have.Accounts, "slots", have.Slots, "codes", have.Codes, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Regenerate the account and storage tries from the imported flat state. The
+	// snapshot isn't marked in the database yet, so a transient tree is assembled
+	// around the disk layer.
+	triedb := trie.NewDatabase(db)
+	snaptree := &Tree{
+		diskdb: db,
+		triedb: triedb,
+		cache:  16,
+		layers: map[common.Hash]snapshot{
+			header.Root: &diskLayer{
+				diskdb: db,
+				triedb: triedb,
+				cache:  fastcache.New(16 * 1024 * 1024),
+				root:   header.Root,
+			},
+		},
+	}
+	if err := GenerateTrie(snaptree, header.Root, db, db); err != nil {
+		return common.Hash{}, err
+	}
+	log.Info("Regenerated state trie", "root", header.Root, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// The state is verified, mark the snapshot as fully generated
+	rawdb.WriteSnapshotRoot(batch, header.Root)
+	journalProgress(batch, nil, nil)
+	if err := batch.Write(); err != nil {
+		return common.Hash{}, err
+	}
+	return header.Root, nil
+}
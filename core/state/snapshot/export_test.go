@@ -0,0 +1,178 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// makeExportState creates a small state with a few accounts, storage slots and
+// contract codes, and generates the snapshot for it.
+func makeExportState() (ethdb.Database, *Tree, common.Hash) {
+	var (
+		diskdb = rawdb.NewMemoryDatabase()
+		triedb = trie.NewDatabase(diskdb)
+		code   = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	)
+	stTrie, _ := trie.NewSecure(common.Hash{}, triedb)
+	stTrie.Update([]byte("key-1"), []byte("val-1"))
+	stTrie.Update([]byte("key-2"), []byte("val-2"))
+	stTrie.Update([]byte("key-3"), []byte("val-3"))
+	stRoot, _ := stTrie.Commit(nil)
+
+	rawdb.WriteCode(diskdb, crypto.Keccak256Hash(code), code)
+
+	accTrie, _ := trie.NewSecure(common.Hash{}, triedb)
+	for i := 0; i < 3; i++ {
+		acc := &Account{Balance: big.NewInt(int64(i + 1)), Root: emptyRoot.Bytes(), CodeHash: emptyCode.Bytes()}
+		if i != 1 {
+			acc.Root, acc.CodeHash = stRoot.Bytes(), crypto.Keccak256(code)
+		}
+		val, _ := rlp.EncodeToBytes(acc)
+		accTrie.Update([]byte{byte(i)}, val)
+	}
+	root, _ := accTrie.Commit(func(path []byte, leaf []byte, parent common.Hash) error {
+		var acc Account
+		if err := rlp.DecodeBytes(leaf, &acc); err != nil {
+			return err
+		}
+		triedb.Reference(common.BytesToHash(acc.Root), parent)
+		return nil
+	})
+	triedb.Commit(root, false, nil)
+
+	snap := generateSnapshot(diskdb, triedb, 16, root, nil)
+	<-snap.genPending
+
+	snaps := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		cache:  16,
+		layers: map[common.Hash]snapshot{root: snap},
+	}
+	return diskdb, snaps, root
+}
+
+// Tests that a state exported from a snapshot can be imported into an empty
+// database, regenerating the same state trie.
+func TestExportImport(t *testing.T) {
+	srcdb, snaps, root := makeExportState()
+
+	var buf bytes.Buffer
+	if err := Export(snaps, root, srcdb, &buf); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	dstdb := rawdb.NewMemoryDatabase()
+	imported, err := Import(bytes.NewReader(buf.Bytes()), dstdb)
+	if err != nil {
+		t.Fatalf("failed to import snapshot: %v", err)
+	}
+	if imported != root {
+		t.Fatalf("imported root mismatch: have %x, want %x", imported, root)
+	}
+	// Ensure the flat state and the regenerated tries are all present
+	it := srcdb.NewIterator(nil, nil)
+	defer it.Release()
+
+	var items int
+	for it.Next() {
+		if len(it.Key()) == common.HashLength || bytes.HasPrefix(it.Key(), rawdb.SnapshotAccountPrefix) ||
+			bytes.HasPrefix(it.Key(), rawdb.SnapshotStoragePrefix) || bytes.HasPrefix(it.Key(), rawdb.CodePrefix) {
+			if have, _ := dstdb.Get(it.Key()); !bytes.Equal(have, it.Value()) {
+				t.Errorf("item %x mismatch: have %x, want %x", it.Key(), have, it.Value())
+			}
+			items++
+		}
+	}
+	if items == 0 {
+		t.Fatalf("no state items found in source database")
+	}
+	if rawdb.ReadSnapshotRoot(dstdb) != root {
+		t.Fatalf("snapshot root not persisted")
+	}
+	// Importing into a database with a snapshot should fail
+	if _, err := Import(bytes.NewReader(buf.Bytes()), dstdb); err == nil {
+		t.Fatalf("import into non-empty database succeeded")
+	}
+}
+
+// Tests that corrupted or truncated exports are rejected.
+func TestImportCorrupted(t *testing.T) {
+	srcdb, snaps, root := makeExportState()
+
+	var buf bytes.Buffer
+	if err := Export(snaps, root, srcdb, &buf); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	blob := buf.Bytes()
+
+	corrupt := common.CopyBytes(blob)
+	corrupt[len(corrupt)/2] ^= 0xff
+	if _, err := Import(bytes.NewReader(corrupt), rawdb.NewMemoryDatabase()); !errors.Is(err, errExportCorrupted) {
+		t.Errorf("corrupted export: have error %v, want %v", err, errExportCorrupted)
+	}
+	if _, err := Import(bytes.NewReader(blob[:len(blob)-4]), rawdb.NewMemoryDatabase()); err != errExportTruncated {
+		t.Errorf("truncated export: have error %v, want %v", err, errExportTruncated)
+	}
+}
+
+// Tests that an export whose flat state doesn't match its root is rejected and
+// the imported flat state is wiped again.
+func TestImportRootMismatch(t *testing.T) {
+	srcdb, snaps, root := makeExportState()
+
+	// Tamper with an account of the flat state before exporting it
+	it := srcdb.NewIterator(rawdb.SnapshotAccountPrefix, nil)
+	if !it.Next() {
+		t.Fatalf("no accounts in source snapshot")
+	}
+	hash := common.BytesToHash(it.Key()[len(rawdb.SnapshotAccountPrefix):])
+	it.Release()
+	rawdb.WriteAccountSnapshot(srcdb, hash, SlimAccountRLP(0, big.NewInt(100), emptyRoot, emptyCode.Bytes()))
+
+	var buf bytes.Buffer
+	if err := Export(snaps, root, srcdb, &buf); err != nil {
+		t.Fatalf("failed to export snapshot: %v", err)
+	}
+	dstdb := rawdb.NewMemoryDatabase()
+	if _, err := Import(bytes.NewReader(buf.Bytes()), dstdb); err == nil {
+		t.Fatalf("import of mismatching state succeeded")
+	}
+	if rawdb.ReadSnapshotRoot(dstdb) != (common.Hash{}) {
+		t.Fatalf("snapshot root persisted for unverified state")
+	}
+	it = dstdb.NewIterator(nil, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if (bytes.HasPrefix(key, rawdb.SnapshotAccountPrefix) && len(key) == len(rawdb.SnapshotAccountPrefix)+common.HashLength) ||
+			(bytes.HasPrefix(key, rawdb.SnapshotStoragePrefix) && len(key) == len(rawdb.SnapshotStoragePrefix)+2*common.HashLength) {
+			t.Errorf("flat state entry %x left after failed import", key)
+		}
+	}
+}
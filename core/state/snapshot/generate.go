@@ -249,7 +249,7 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 		}
 		// If the account is in-progress, continue where we left off (otherwise iterate all)
 		if acc.Root != emptyRoot {
-			storeTrie, err := trie.NewSecure(acc.Root, dl.triedb)
+			storeTrie, err := trie.NewSecureWithOwner(accountHash, acc.Root, dl.triedb)
 			if err != nil {
 				log.Error("Generator failed to access storage trie", "root", dl.root, "account", accountHash, "stroot", acc.Root, "err", err)
 				abort := <-dl.genAbort
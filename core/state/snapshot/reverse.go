@@ -0,0 +1,235 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ReverseDiff contains the values of all the accounts and storage slots modified
+// by a block, as they were before the block was applied. Applying the reverse
+// diffs of consecutive blocks in descending order rolls a flat state back to an
+// older block.
+type ReverseDiff struct {
+	Accounts []ReverseAccount // Pre-values of the modified accounts, sorted by hash
+	Storage  []ReverseStorage // Pre-values of the modified slots, sorted by account hash
+}
+
+// ReverseAccount is the pre-value of a single account in a reverse diff.
+type ReverseAccount struct {
+	Hash common.Hash
+	Blob []byte // Account in slim RLP format, empty if it didn't exist
+}
+
+// ReverseStorage is the pre-value of the modified slots of a single account in
+// a reverse diff.
+type ReverseStorage struct {
+	Account common.Hash
+	Hashes  []common.Hash // Hashes of the modified slots, sorted
+	Values  [][]byte      // RLP encoded slot values, empty if they didn't exist
+}
+
+// NewReverseDiff collects the pre-values of all the accounts and storage slots
+// about to be changed on top of the given parent snapshot. The storage of the
+// destructed accounts is recorded in full, as it is wiped by the change, along
+// with an empty pre-value for every slot written after their resurrection.
+func NewReverseDiff(tree *Tree, parent common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) (*ReverseDiff, error) {
+	snap := tree.Snapshot(parent)
+	if snap == nil {
+		return nil, fmt.Errorf("snapshot [%#x] missing", parent)
+	}
+	var (
+		accountPre = make(map[common.Hash][]byte)
+		storagePre = make(map[common.Hash]map[common.Hash][]byte)
+	)
+	for hash := range destructs {
+		blob, err := snap.AccountRLP(hash)
+		if err != nil {
+			return nil, err
+		}
+		accountPre[hash] = common.CopyBytes(blob)
+		if len(blob) == 0 {
+			continue
+		}
+		it, err := tree.StorageIterator(parent, hash, common.Hash{})
+		if err != nil {
+			return nil, err
+		}
+		slots := make(map[common.Hash][]byte)
+		for it.Next() {
+			slots[it.Hash()] = common.CopyBytes(it.Slot())
+		}
+		err = it.Error()
+		it.Release()
+		if err != nil {
+			return nil, err
+		}
+		storagePre[hash] = slots
+	}
+	for hash := range accounts {
+		if _, ok := accountPre[hash]; ok {
+			continue
+		}
+		blob, err := snap.AccountRLP(hash)
+		if err != nil {
+			return nil, err
+		}
+		accountPre[hash] = common.CopyBytes(blob)
+	}
+	for hash, changes := range storage {
+		slots := storagePre[hash]
+		if slots == nil {
+			slots = make(map[common.Hash][]byte)
+			storagePre[hash] = slots
+		}
+		_, destructed := destructs[hash]
+		for slot := range changes {
+			if _, ok := slots[slot]; ok {
+				continue // Recorded with the destructed storage
+			}
+			// Slots of a destructed account not recorded yet were only written
+			// after the resurrection, so they didn't exist before
+			if destructed {
+				slots[slot] = nil
+				continue
+			}
+			blob, err := snap.Storage(hash, slot)
+			if err != nil {
+				return nil, err
+			}
+			slots[slot] = common.CopyBytes(blob)
+		}
+	}
+	// Flatten the pre-values into a deterministic order
+	diff := new(ReverseDiff)
+	for _, hash := range sortedHashes(accountPre) {
+		diff.Accounts = append(diff.Accounts, ReverseAccount{Hash: hash, Blob: accountPre[hash]})
+	}
+	owners := make(hashes, 0, len(storagePre))
+	for account := range storagePre {
+		owners = append(owners, account)
+	}
+	sort.Sort(owners)
+	for _, account := range owners {
+		entry := ReverseStorage{Account: account}
+		for _, slot := range sortedHashes(storagePre[account]) {
+			entry.Hashes = append(entry.Hashes, slot)
+			entry.Values = append(entry.Values, storagePre[account][slot])
+		}
+		diff.Storage = append(diff.Storage, entry)
+	}
+	return diff, nil
+}
+
+// sortedHashes returns the keys of the given map in ascending order.
+func sortedHashes(m map[common.Hash][]byte) hashes {
+	keys := make(hashes, 0, len(m))
+	for hash := range m {
+		keys = append(keys, hash)
+	}
+	sort.Sort(keys)
+	return keys
+}
+
+// reverseLookup is a decoded reverse diff indexed for lookups.
+type reverseLookup struct {
+	accounts map[common.Hash][]byte
+	storage  map[common.Hash]map[common.Hash][]byte
+}
+
+// HistoricState answers account and storage queries of an older block by rolling
+// a newer flat snapshot back with the reverse diffs of the blocks applied since.
+// The value of an entry is its pre-value in the oldest diff modifying it, or the
+// value in the snapshot if none of the diffs touched it.
+type HistoricState struct {
+	base  Snapshot         // Snapshot of the newer state being rolled back
+	diffs []*reverseLookup // Reverse diffs of the blocks applied on top, oldest first
+}
+
+// NewHistoricState creates a rolled back view of the given snapshot. The reverse
+// diffs must be ordered from the block right after the requested one up to the
+// block of the snapshot.
+func NewHistoricState(base Snapshot, diffs []*ReverseDiff) *HistoricState {
+	state := &HistoricState{base: base}
+	for _, diff := range diffs {
+		lookup := &reverseLookup{
+			accounts: make(map[common.Hash][]byte, len(diff.Accounts)),
+			storage:  make(map[common.Hash]map[common.Hash][]byte, len(diff.Storage)),
+		}
+		for _, account := range diff.Accounts {
+			lookup.accounts[account.Hash] = account.Blob
+		}
+		for _, entry := range diff.Storage {
+			slots := make(map[common.Hash][]byte, len(entry.Hashes))
+			for i, hash := range entry.Hashes {
+				if i < len(entry.Values) {
+					slots[hash] = entry.Values[i]
+				}
+			}
+			lookup.storage[entry.Account] = slots
+		}
+		state.diffs = append(state.diffs, lookup)
+	}
+	return state
+}
+
+// Account directly retrieves the account associated with a particular hash in
+// the snapshot slim data format.
+func (s *HistoricState) Account(hash common.Hash) (*Account, error) {
+	data, err := s.AccountRLP(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	account := new(Account)
+	if err := rlp.DecodeBytes(data, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// AccountRLP directly retrieves the account RLP associated with a particular
+// hash in the snapshot slim data format.
+func (s *HistoricState) AccountRLP(hash common.Hash) ([]byte, error) {
+	for _, diff := range s.diffs {
+		if blob, ok := diff.accounts[hash]; ok {
+			return blob, nil
+		}
+	}
+	return s.base.AccountRLP(hash)
+}
+
+// Storage directly retrieves the storage data associated with a particular hash,
+// within a particular account.
+func (s *HistoricState) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	for _, diff := range s.diffs {
+		if blob, ok := diff.storage[accountHash][storageHash]; ok {
+			return blob, nil
+		}
+		// An account not existing before the block had no storage either
+		if blob, ok := diff.accounts[accountHash]; ok && len(blob) == 0 {
+			return nil, nil
+		}
+	}
+	return s.base.Storage(accountHash, storageHash)
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// Tests that the reverse diff of an account destructed and recreated within the
+// same block rolls back both its wiped slots and the ones written afterwards.
+func TestReverseDiffResurrection(t *testing.T) {
+	var (
+		account = common.HexToHash("0xa1")
+		wiped   = common.HexToHash("0xb1") // Slot existing before the destruction
+		rewrite = common.HexToHash("0xb2") // Slot existing before and rewritten after
+		created = common.HexToHash("0xb3") // Slot only written after the resurrection
+
+		oldAccount = randomAccount()
+	)
+	// Create a disk layer holding the account with some storage
+	diskdb := rawdb.NewMemoryDatabase()
+	rawdb.WriteAccountSnapshot(diskdb, account, oldAccount)
+	rawdb.WriteStorageSnapshot(diskdb, account, wiped, []byte{0x01})
+	rawdb.WriteStorageSnapshot(diskdb, account, rewrite, []byte{0x02})
+
+	base := &diskLayer{
+		diskdb: diskdb,
+		root:   common.HexToHash("0x01"),
+		cache:  fastcache.New(1024 * 500),
+	}
+	snaps := &Tree{
+		layers: map[common.Hash]snapshot{
+			base.root: base,
+		},
+	}
+	// Destruct and recreate the account, writing a few slots afterwards
+	var (
+		destructs = map[common.Hash]struct{}{account: {}}
+		accounts  = map[common.Hash][]byte{account: randomAccount()}
+		storage   = map[common.Hash]map[common.Hash][]byte{
+			account: {
+				rewrite: {0x12},
+				created: {0x13},
+			},
+		}
+	)
+	diff, err := NewReverseDiff(snaps, base.root, destructs, accounts, storage)
+	if err != nil {
+		t.Fatalf("failed to create reverse diff: %v", err)
+	}
+	if err := snaps.Update(common.HexToHash("0x02"), base.root, destructs, accounts, storage); err != nil {
+		t.Fatalf("failed to create a diff layer: %v", err)
+	}
+	// Roll the new state back and ensure all slots have their old values
+	state := NewHistoricState(snaps.Snapshot(common.HexToHash("0x02")), []*ReverseDiff{diff})
+
+	if blob, err := state.AccountRLP(account); err != nil || !bytes.Equal(blob, oldAccount) {
+		t.Errorf("account mismatch: have %x (err: %v), want %x", blob, err, oldAccount)
+	}
+	for slot, want := range map[common.Hash][]byte{wiped: {0x01}, rewrite: {0x02}, created: nil} {
+		if blob, err := state.Storage(account, slot); err != nil || !bytes.Equal(blob, want) {
+			t.Errorf("slot %x mismatch: have %x (err: %v), want %x", slot, blob, err, want)
+		}
+	}
+}
@@ -270,6 +270,65 @@ func (t *Tree) Snapshots(root common.Hash, limits int, nodisk bool) []Snapshot {
 	return ret
 }
 
+// LayerChanges is the set of accounts and storage slots modified by a single
+// diff layer on top of its parent.
+type LayerChanges struct {
+	Root     common.Hash                   // State root of the diff layer
+	Parent   common.Hash                   // State root of the parent layer
+	Accounts []common.Hash                 // Destructed or modified accounts
+	Storage  map[common.Hash][]common.Hash // Modified storage slots, keyed by account hash
+}
+
+// Changes returns the keys modified by every diff layer below the given root,
+// ordered from the bottom-most diff layer upwards. Since the layers are only
+// referenced while collecting the keys, they remain free to be flattened.
+func (t *Tree) Changes(root common.Hash) ([]*LayerChanges, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	layer := t.layers[root]
+	if layer == nil {
+		return nil, fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	var changes []*LayerChanges
+	for {
+		diff, ok := layer.(*diffLayer)
+		if !ok {
+			break
+		}
+		diff.lock.RLock()
+		change := &LayerChanges{
+			Root:    diff.root,
+			Parent:  diff.parent.Root(),
+			Storage: make(map[common.Hash][]common.Hash, len(diff.storageData)),
+		}
+		for hash := range diff.destructSet {
+			change.Accounts = append(change.Accounts, hash)
+		}
+		for hash := range diff.accountData {
+			if _, destructed := diff.destructSet[hash]; !destructed {
+				change.Accounts = append(change.Accounts, hash)
+			}
+		}
+		for account, slots := range diff.storageData {
+			hashes := make([]common.Hash, 0, len(slots))
+			for hash := range slots {
+				hashes = append(hashes, hash)
+			}
+			change.Storage[account] = hashes
+		}
+		layer = diff.parent
+		diff.lock.RUnlock()
+
+		changes = append(changes, change)
+	}
+	// Reverse the changes to apply them in order
+	for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+		changes[i], changes[j] = changes[j], changes[i]
+	}
+	return changes, nil
+}
+
 // Update adds a new snapshot into the tree, if that can be linked to an existing
 // old parent. It is disallowed to insert a disk layer (the origin of all).
 func (t *Tree) Update(blockRoot common.Hash, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
@@ -152,7 +152,7 @@ func TestDiskLayerExternalInvalidationPartialFlatten(t *testing.T) {
 	if err := snaps.Update(common.HexToHash("0x02"), common.HexToHash("0x01"), nil, accounts, nil); err != nil {
 		t.Fatalf("failed to create a diff layer: %v", err)
 	}
-	if err := snaps.Update(common.HexToHash("0x03"), common.HexToHash("0x02"), nil, accounts, nil); err != nil {
+	if err := snaps.Update(common.HexToHash("0x03"), common.HexToHash("0x02"), nil, accounts, make(map[common.Hash]map[common.Hash][]byte)); err != nil {
 		t.Fatalf("failed to create a diff layer: %v", err)
 	}
 	if n := len(snaps.layers); n != 3 {
@@ -200,7 +200,7 @@ func TestDiffLayerExternalInvalidationPartialFlatten(t *testing.T) {
 	if err := snaps.Update(common.HexToHash("0x02"), common.HexToHash("0x01"), nil, accounts, nil); err != nil {
 		t.Fatalf("failed to create a diff layer: %v", err)
 	}
-	if err := snaps.Update(common.HexToHash("0x03"), common.HexToHash("0x02"), nil, accounts, nil); err != nil {
+	if err := snaps.Update(common.HexToHash("0x03"), common.HexToHash("0x02"), nil, accounts, make(map[common.Hash]map[common.Hash][]byte)); err != nil {
 		t.Fatalf("failed to create a diff layer: %v", err)
 	}
 	if err := snaps.Update(common.HexToHash("0x04"), common.HexToHash("0x03"), nil, accounts, nil); err != nil {
@@ -236,6 +236,78 @@ func TestDiffLayerExternalInvalidationPartialFlatten(t *testing.T) {
 	}
 }
 
+// Tests that the changes of the diff layers are reported bottom up, and that
+// collecting them doesn't prevent the layers from being flattened.
+func TestLayerChanges(t *testing.T) {
+	// Create an empty base layer and a snapshot tree out of it
+	base := &diskLayer{
+		diskdb: rawdb.NewMemoryDatabase(),
+		root:   common.HexToHash("0x01"),
+		cache:  fastcache.New(1024 * 500),
+	}
+	snaps := &Tree{
+		layers: map[common.Hash]snapshot{
+			base.root: base,
+		},
+	}
+	// Commit three diffs on top, destructing and modifying various accounts
+	var (
+		destructs = map[common.Hash]struct{}{
+			common.HexToHash("0xa1"): {},
+		}
+		accounts = map[common.Hash][]byte{
+			common.HexToHash("0xa1"): randomAccount(),
+			common.HexToHash("0xa2"): randomAccount(),
+		}
+		storage = map[common.Hash]map[common.Hash][]byte{
+			common.HexToHash("0xa2"): {
+				common.HexToHash("0xb1"): randomAccount(),
+				common.HexToHash("0xb2"): nil,
+			},
+		}
+	)
+	if err := snaps.Update(common.HexToHash("0x02"), common.HexToHash("0x01"), destructs, make(map[common.Hash][]byte), make(map[common.Hash]map[common.Hash][]byte)); err != nil {
+		t.Fatalf("failed to create a diff layer: %v", err)
+	}
+	if err := snaps.Update(common.HexToHash("0x03"), common.HexToHash("0x02"), nil, accounts, make(map[common.Hash]map[common.Hash][]byte)); err != nil {
+		t.Fatalf("failed to create a diff layer: %v", err)
+	}
+	if err := snaps.Update(common.HexToHash("0x04"), common.HexToHash("0x03"), destructs, accounts, storage); err != nil {
+		t.Fatalf("failed to create a diff layer: %v", err)
+	}
+	changes, err := snaps.Changes(common.HexToHash("0x04"))
+	if err != nil {
+		t.Fatalf("failed to retrieve layer changes: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("change count mismatch: have %d, want %d", len(changes), 3)
+	}
+	for i, change := range changes {
+		if want := common.BigToHash(big.NewInt(int64(i + 2))); change.Root != want {
+			t.Errorf("change %d: root mismatch: have %x, want %x", i, change.Root, want)
+		}
+		if want := common.BigToHash(big.NewInt(int64(i + 1))); change.Parent != want {
+			t.Errorf("change %d: parent mismatch: have %x, want %x", i, change.Parent, want)
+		}
+	}
+	if n := len(changes[0].Accounts); n != 1 {
+		t.Errorf("destructed account count mismatch: have %d, want %d", n, 1)
+	}
+	if n := len(changes[2].Accounts); n != 2 {
+		t.Errorf("modified account count mismatch: have %d, want %d", n, 2)
+	}
+	if n := len(changes[2].Storage[common.HexToHash("0xa2")]); n != 2 {
+		t.Errorf("modified slot count mismatch: have %d, want %d", n, 2)
+	}
+	// Flattening the layers afterwards should not be blocked
+	if err := snaps.Cap(common.HexToHash("0x04"), 1); err != nil {
+		t.Fatalf("failed to flatten diff layer into accumulator: %v", err)
+	}
+	if n := len(snaps.layers); n != 3 {
+		t.Errorf("post-cap layer count mismatch: have %d, want %d", n, 3)
+	}
+}
+
 // TestPostCapBasicDataAccess tests some functionality regarding capping/flattening.
 func TestPostCapBasicDataAccess(t *testing.T) {
 	// setAccount is a helper to construct a random account entry and assign it to
@@ -74,6 +74,9 @@ type StateDB struct {
 	snapAccounts  map[common.Hash][]byte
 	snapStorage   map[common.Hash]map[common.Hash][]byte
 
+	recordReverse bool                  // Whether to collect the reverse diff on commit
+	reverseDiff   *snapshot.ReverseDiff // Reverse diff of the last commit, if collected
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects        map[common.Address]*stateObject
 	stateObjectsPending map[common.Address]struct{} // State objects finalized but not yet written to the trie
@@ -930,6 +933,9 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 			if err := obj.CommitTrie(s.db); err != nil {
 				return common.Hash{}, err
 			}
+		} else {
+			// Drop the storage of the destructed account if trie nodes are stored by path
+			s.db.TrieDB().DeleteStorage(obj.addrHash)
 		}
 	}
 	if len(s.stateObjectsDirty) > 0 {
@@ -965,6 +971,14 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		if metrics.EnabledExpensive {
 			defer func(start time.Time) { s.SnapshotCommits += time.Since(start) }(time.Now())
 		}
+		// Collect the pre-values of the changes before the parent may be flattened
+		if s.recordReverse {
+			diff, err := snapshot.NewReverseDiff(s.snaps, s.snap.Root(), s.snapDestructs, s.snapAccounts, s.snapStorage)
+			if err != nil {
+				log.Warn("Failed to collect reverse state diff", "root", root, "err", err)
+			}
+			s.reverseDiff = diff
+		}
 		// Only update if there's a state transition (skip empty Clique blocks)
 		if parent := s.snap.Root(); parent != root {
 			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
@@ -983,6 +997,19 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	return root, err
 }
 
+// EnableReverseDiff instructs Commit to collect the pre-values of all the accounts
+// and storage slots modified since the state was opened. It requires the state to
+// be backed by a snapshot.
+func (s *StateDB) EnableReverseDiff() {
+	s.recordReverse = true
+}
+
+// ReverseDiff returns the reverse diff collected by the last commit, or nil if it
+// was not requested or could not be collected.
+func (s *StateDB) ReverseDiff() *snapshot.ReverseDiff {
+	return s.reverseDiff
+}
+
 // PrepareAccessList handles the preparatory steps for executing a state transition with
 // regards to both EIP-2929 and EIP-2930:
 //
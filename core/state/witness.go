@@ -0,0 +1,171 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// nodeRecorder is a key-value store used as the disk layer of a trie database,
+// which resolves all trie nodes from a source trie database and records them.
+// Any other operation is forwarded to the source disk database.
+type nodeRecorder struct {
+	ethdb.KeyValueStore
+	source *trie.Database
+
+	nodes map[common.Hash][]byte
+	lock  sync.Mutex
+}
+
+// Has retrieves if a key is present in the key-value data store.
+func (r *nodeRecorder) Has(key []byte) (bool, error) {
+	blob, err := r.Get(key)
+	return len(blob) > 0, err
+}
+
+// Get retrieves the given key if it's present in the key-value data store,
+// recording it if it's a trie node.
+func (r *nodeRecorder) Get(key []byte) ([]byte, error) {
+	if len(key) != common.HashLength {
+		return r.KeyValueStore.Get(key)
+	}
+	hash := common.BytesToHash(key)
+	blob, err := r.source.Node(hash)
+	if err != nil {
+		return nil, err
+	}
+	r.lock.Lock()
+	r.nodes[hash] = blob
+	r.lock.Unlock()
+	return blob, nil
+}
+
+// WitnessDatabase is a state database wrapper which records every trie node and
+// contract code accessed through it. The recorded data is sufficient to replay
+// the same state accesses from a stateless database.
+type WitnessDatabase struct {
+	Database
+	triedb   *trie.Database
+	recorder *nodeRecorder
+
+	codes map[common.Hash][]byte
+	lock  sync.Mutex
+}
+
+// NewWitnessDatabase creates a state database recording all the trie nodes and
+// contract codes resolved from db.
+func NewWitnessDatabase(db Database) *WitnessDatabase {
+	recorder := &nodeRecorder{
+		KeyValueStore: db.TrieDB().DiskDB(),
+		source:        db.TrieDB(),
+		nodes:         make(map[common.Hash][]byte),
+	}
+	return &WitnessDatabase{
+		Database: db,
+		triedb:   trie.NewDatabase(recorder),
+		recorder: recorder,
+		codes:    make(map[common.Hash][]byte),
+	}
+}
+
+// OpenTrie opens the main account trie at a specific root hash.
+func (db *WitnessDatabase) OpenTrie(root common.Hash) (Trie, error) {
+	return trie.NewSecure(root, db.triedb)
+}
+
+// OpenStorageTrie opens the storage trie of an account.
+func (db *WitnessDatabase) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
+	return trie.NewSecureWithOwner(addrHash, root, db.triedb)
+}
+
+// ContractCode retrieves a particular contract's code, recording it.
+func (db *WitnessDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	code, err := db.Database.ContractCode(addrHash, codeHash)
+	if err != nil {
+		return nil, err
+	}
+	db.lock.Lock()
+	db.codes[codeHash] = code
+	db.lock.Unlock()
+	return code, nil
+}
+
+// ContractCodeSize retrieves a particular contracts code's size. The entire
+// code is recorded, as a stateless database can only derive the size from it.
+func (db *WitnessDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, error) {
+	code, err := db.ContractCode(addrHash, codeHash)
+	return len(code), err
+}
+
+// TrieDB retrieves the recording trie database.
+func (db *WitnessDatabase) TrieDB() *trie.Database {
+	return db.triedb
+}
+
+// Nodes returns all the trie nodes recorded so far, ordered by hash.
+func (db *WitnessDatabase) Nodes() [][]byte {
+	db.recorder.lock.Lock()
+	defer db.recorder.lock.Unlock()
+
+	return sortedBlobs(db.recorder.nodes)
+}
+
+// Codes returns all the contract codes recorded so far, ordered by hash.
+func (db *WitnessDatabase) Codes() [][]byte {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	return sortedBlobs(db.codes)
+}
+
+// sortedBlobs flattens a hash->blob map into a list ordered by hash.
+func sortedBlobs(blobs map[common.Hash][]byte) [][]byte {
+	hashes := make([]common.Hash, 0, len(blobs))
+	for hash := range blobs {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return bytes.Compare(hashes[i][:], hashes[j][:]) < 0
+	})
+	list := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		list[i] = blobs[hash]
+	}
+	return list
+}
+
+// NewStatelessDatabase creates an in-memory state database containing nothing
+// but the given trie nodes and contract codes, keyed by their hashes. Accessing
+// any state not covered by them results in a missing trie node error.
+func NewStatelessDatabase(nodes [][]byte, codes [][]byte) Database {
+	db := rawdb.NewMemoryDatabase()
+	for _, node := range nodes {
+		rawdb.WriteTrieNode(db, crypto.Keccak256Hash(node), node)
+	}
+	for _, code := range codes {
+		rawdb.WriteCode(db, crypto.Keccak256Hash(code), code)
+	}
+	return NewDatabase(db)
+}
@@ -45,12 +45,18 @@ type (
 // ActivePrecompiles returns the addresses of the precompiles enabled with the current
 // configuration
 func (evm *EVM) ActivePrecompiles() []common.Address {
+	return ActivePrecompiles(evm.chainRules)
+}
+
+// ActivePrecompiles returns the addresses of the precompiles enabled with the given
+// chain rules.
+func ActivePrecompiles(rules params.Rules) []common.Address {
 	switch {
-	case evm.chainRules.IsBerlin:
+	case rules.IsBerlin:
 		return PrecompiledAddressesBerlin
-	case evm.chainRules.IsIstanbul:
+	case rules.IsIstanbul:
 		return PrecompiledAddressesIstanbul
-	case evm.chainRules.IsByzantium:
+	case rules.IsByzantium:
 		return PrecompiledAddressesByzantium
 	default:
 		return PrecompiledAddressesHomestead
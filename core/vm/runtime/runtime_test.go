@@ -608,3 +608,42 @@ func TestEip2929Cases(t *testing.T) {
 			"account (cheap)", code)
 	}
 }
+
+// TestAccessListTracer checks that the access list tracer picks up touched
+// accounts and storage slots, but leaves out the sender, the recipient and
+// the precompiles.
+func TestAccessListTracer(t *testing.T) {
+	var (
+		origin  = common.HexToAddress("0xc0ffee")
+		address = common.BytesToAddress([]byte("contract"))
+		other   = common.HexToAddress("0xf1")
+	)
+	code := []byte{
+		// Touch a storage slot and an external account
+		byte(vm.PUSH1), 0x01, byte(vm.SLOAD), byte(vm.POP),
+		byte(vm.PUSH1), 0xf1, byte(vm.BALANCE), byte(vm.POP),
+		// Touch a precompile, the sender and ourselves
+		byte(vm.PUSH1), 0x02, byte(vm.EXTCODESIZE), byte(vm.POP),
+		byte(vm.ORIGIN), byte(vm.BALANCE), byte(vm.POP),
+		byte(vm.ADDRESS), byte(vm.BALANCE), byte(vm.POP),
+		byte(vm.STOP),
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(address, code)
+
+	cfg := &Config{State: statedb, Origin: origin}
+	setDefaults(cfg)
+
+	tracer := vm.NewAccessListTracer(nil, origin, address, vm.ActivePrecompiles(cfg.ChainConfig.Rules(cfg.BlockNumber)))
+	cfg.EVMConfig = vm.Config{Debug: true, Tracer: tracer}
+	if _, _, err := Call(address, nil, cfg); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	want := vm.NewAccessListTracer(types.AccessList{
+		{Address: address, StorageKeys: []common.Hash{common.BigToHash(big.NewInt(1))}},
+		{Address: other, StorageKeys: []common.Hash{}},
+	}, origin, address, nil)
+	if !tracer.Equal(want) {
+		t.Errorf("access list mismatch: have %v, want %v", tracer.AccessList(), want.AccessList())
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Witness contains everything needed to execute a block statelessly: the parent
+// header, any ancestor headers accessed via BLOCKHASH, and all the trie nodes
+// and contract codes touched while executing the block and hashing the result.
+type Witness struct {
+	Headers []*types.Header // Parent header first, followed by accessed ancestors in descending order
+	Codes   [][]byte        // Contract codes accessed during execution
+	Nodes   [][]byte        // Account and storage trie nodes accessed during execution
+}
+
+// witnessChain is a chain context which serves ancestor headers either from a
+// backing chain, recording every header accessed, or from a fixed set of headers
+// if executing statelessly.
+type witnessChain struct {
+	config *params.ChainConfig
+	engine consensus.Engine
+	chain  consensus.ChainHeaderReader // Backing chain to record from, nil if stateless
+	parent *types.Header
+
+	headers map[common.Hash]*types.Header
+	lock    sync.Mutex
+}
+
+// newWitnessChain creates a chain context for executing the child of parent. If
+// chain is nil, only the given headers are available.
+func newWitnessChain(config *params.ChainConfig, engine consensus.Engine, chain consensus.ChainHeaderReader, parent *types.Header, headers []*types.Header) *witnessChain {
+	wc := &witnessChain{
+		config:  config,
+		engine:  engine,
+		chain:   chain,
+		parent:  parent,
+		headers: make(map[common.Hash]*types.Header),
+	}
+	for _, header := range headers {
+		wc.headers[header.Hash()] = header
+	}
+	return wc
+}
+
+// Config retrieves the chain configuration.
+func (wc *witnessChain) Config() *params.ChainConfig { return wc.config }
+
+// Engine retrieves the consensus engine.
+func (wc *witnessChain) Engine() consensus.Engine { return wc.engine }
+
+// CurrentHeader retrieves the parent of the block being executed.
+func (wc *witnessChain) CurrentHeader() *types.Header { return wc.parent }
+
+// GetHeader retrieves a block header by hash and number, recording it if it's
+// resolved from the backing chain.
+func (wc *witnessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	if header, ok := wc.headers[hash]; ok {
+		if header.Number.Uint64() != number {
+			return nil
+		}
+		return header
+	}
+	if wc.chain == nil {
+		return nil
+	}
+	header := wc.chain.GetHeader(hash, number)
+	if header != nil {
+		wc.headers[hash] = header
+	}
+	return header
+}
+
+// GetHeaderByHash retrieves a block header by hash.
+func (wc *witnessChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	if header, ok := wc.headers[hash]; ok {
+		return header
+	}
+	if wc.chain == nil {
+		return nil
+	}
+	header := wc.chain.GetHeaderByHash(hash)
+	if header != nil {
+		wc.headers[hash] = header
+	}
+	return header
+}
+
+// GetHeaderByNumber retrieves a block header by number. In stateless mode only
+// the known headers are searched, which are not necessarily canonical.
+func (wc *witnessChain) GetHeaderByNumber(number uint64) *types.Header {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	if wc.chain == nil {
+		for _, header := range wc.headers {
+			if header.Number.Uint64() == number {
+				return header
+			}
+		}
+		return nil
+	}
+	header := wc.chain.GetHeaderByNumber(number)
+	if header != nil {
+		wc.headers[header.Hash()] = header
+	}
+	return header
+}
+
+// recorded returns all the headers accessed, with the parent first and the
+// rest of the ancestors in descending order.
+func (wc *witnessChain) recorded() []*types.Header {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	headers := []*types.Header{wc.parent}
+	for hash, header := range wc.headers {
+		if hash != wc.parent.Hash() {
+			headers = append(headers, header)
+		}
+	}
+	sort.SliceStable(headers[1:], func(i, j int) bool {
+		return headers[i+1].Number.Uint64() > headers[j+1].Number.Uint64()
+	})
+	return headers
+}
+
+// ProcessWitness executes the block on top of its parent state, recording every
+// ancestor header, trie node and contract code accessed. The resulting state is
+// validated against the block, and the recorded data returned as a witness that
+// allows re-executing the block without a database.
+func (p *StateProcessor) ProcessWitness(block *types.Block, cfg vm.Config) (*Witness, error) {
+	parent := p.bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	// Witnesses are made of hash addressed trie nodes, which can't be recorded
+	// if the nodes are stored by path.
+	if p.bc.StateCache().TrieDB().Scheme() == rawdb.PathScheme {
+		return nil, errors.New("witnesses unsupported with path-based trie node storage")
+	}
+	db := state.NewWitnessDatabase(p.bc.StateCache())
+	statedb, err := state.New(parent.Root, db, nil)
+	if err != nil {
+		return nil, err
+	}
+	chain := newWitnessChain(p.config, p.engine, p.bc, parent, nil)
+	receipts, _, usedGas, err := p.process(block, statedb, chain, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Validating the state hashes all the modified tries, pulling in any extra
+	// nodes needed to collapse the tries after deletions.
+	validator := &BlockValidator{config: p.config}
+	if err := validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
+		return nil, err
+	}
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	return &Witness{
+		Headers: chain.recorded(),
+		Codes:   db.Codes(),
+		Nodes:   db.Nodes(),
+	}, nil
+}
+
+// VerifyWitness re-executes a block using nothing but the data contained in the
+// witness, and validates the resulting receipts and state root against the block.
+func VerifyWitness(config *params.ChainConfig, engine consensus.Engine, block *types.Block, witness *Witness, cfg vm.Config) error {
+	if len(witness.Headers) == 0 {
+		return errors.New("witness without parent header")
+	}
+	parent := witness.Headers[0]
+	if parent.Hash() != block.ParentHash() || parent.Number.Uint64()+1 != block.NumberU64() {
+		return fmt.Errorf("witness parent mismatch: have %d [%x], want %d [%x]", parent.Number, parent.Hash(), block.NumberU64()-1, block.ParentHash())
+	}
+	statedb, err := state.New(parent.Root, state.NewStatelessDatabase(witness.Nodes, witness.Codes), nil)
+	if err != nil {
+		return err
+	}
+	var (
+		chain     = newWitnessChain(config, engine, nil, parent, witness.Headers)
+		processor = &StateProcessor{config: config, engine: engine}
+	)
+	receipts, _, usedGas, err := processor.process(block, statedb, chain, cfg)
+	if err != nil {
+		return err
+	}
+	// A missing trie node makes the state silently empty, so check for database
+	// errors before the state root to give a meaningful error.
+	statedb.IntermediateRoot(config.IsEIP158(block.Number()))
+	if err := statedb.Error(); err != nil {
+		return err
+	}
+	validator := &BlockValidator{config: config}
+	return validator.ValidateState(block, statedb, receipts, usedGas)
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that the witness recorded while processing a block is enough to execute
+// the same block statelessly, and that incomplete witnesses are rejected.
+func TestBlockWitness(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xaaaa")
+		engine   = ethash.NewFaker()
+		db       = rawdb.NewMemoryDatabase()
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(1000000000000000)},
+				contract: {
+					// Stores BLOCKHASH(NUMBER-3) at slot NUMBER, clears slot 1
+					// and increments slot 0.
+					Code: common.FromHex("600343034043556000600155600054600101600055"),
+					Storage: map[common.Hash]common.Hash{
+						common.HexToHash("0x00"): common.HexToHash("0x01"),
+						common.HexToHash("0x01"): common.HexToHash("0x01"),
+						common.HexToHash("0x02"): common.HexToHash("0x02"),
+					},
+					Balance: big.NewInt(0),
+				},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	chain, err := NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Generate the blocks one by one, as BLOCKHASH needs the ancestors imported
+	var (
+		parent = genesis
+		blocks []*types.Block
+	)
+	for i := 0; i < 4; i++ {
+		generated, _ := GenerateChain(gspec.Config, parent, engine, db, 1, func(_ int, block *BlockGen) {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(address), contract, big.NewInt(0), 100000, nil, nil), signer, key)
+			block.AddTxWithChain(chain, tx)
+			tx, _ = types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{byte(i + 1)}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+			block.AddTxWithChain(chain, tx)
+		})
+		if _, err := chain.InsertChain(generated); err != nil {
+			t.Fatalf("failed to insert block %d: %v", i+1, err)
+		}
+		parent = generated[0]
+		blocks = append(blocks, parent)
+	}
+	processor := chain.Processor().(*StateProcessor)
+	for _, block := range blocks {
+		witness, err := processor.ProcessWitness(block, vm.Config{})
+		if err != nil {
+			t.Fatalf("block %d: failed to record witness: %v", block.NumberU64(), err)
+		}
+		if len(witness.Nodes) == 0 || len(witness.Codes) != 1 {
+			t.Fatalf("block %d: incomplete witness: %d nodes, %d codes", block.NumberU64(), len(witness.Nodes), len(witness.Codes))
+		}
+		// The BLOCKHASH access pulls in the grandparent too, if the target exists
+		if block.NumberU64() >= 3 && len(witness.Headers) != 2 {
+			t.Fatalf("block %d: header count mismatch: have %d, want 2", block.NumberU64(), len(witness.Headers))
+		}
+		// Round trip the witness through RLP and verify it statelessly
+		blob, err := rlp.EncodeToBytes(witness)
+		if err != nil {
+			t.Fatalf("block %d: failed to encode witness: %v", block.NumberU64(), err)
+		}
+		var decoded Witness
+		if err := rlp.DecodeBytes(blob, &decoded); err != nil {
+			t.Fatalf("block %d: failed to decode witness: %v", block.NumberU64(), err)
+		}
+		if err := VerifyWitness(gspec.Config, engine, block, &decoded, vm.Config{}); err != nil {
+			t.Fatalf("block %d: failed to verify witness: %v", block.NumberU64(), err)
+		}
+		// Drop every trie node one by one and ensure verification fails
+		for i := range decoded.Nodes {
+			nodes := append(append([][]byte{}, decoded.Nodes[:i]...), decoded.Nodes[i+1:]...)
+			partial := &Witness{Headers: decoded.Headers, Codes: decoded.Codes, Nodes: nodes}
+			if err := VerifyWitness(gspec.Config, engine, block, partial, vm.Config{}); err == nil {
+				t.Fatalf("block %d: verified witness without node %d", block.NumberU64(), i)
+			}
+		}
+		// Ensure a witness without the contract code fails too
+		partial := &Witness{Headers: decoded.Headers, Nodes: decoded.Nodes}
+		if err := VerifyWitness(gspec.Config, engine, block, partial, vm.Config{}); err == nil {
+			t.Fatalf("block %d: verified witness without code", block.NumberU64())
+		}
+	}
+}
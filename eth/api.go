@@ -33,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -302,6 +303,55 @@ func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hex
 	return nil, errors.New("unknown preimage")
 }
 
+// PruneState starts deleting the stale trie nodes from the database in the
+// background, retaining the states of the recent blocks. The progress can be
+// tracked via PruneStatus.
+func (api *PrivateDebugAPI) PruneState() error {
+	if api.eth.statePruner == nil {
+		return errors.New("online state pruning requires snapshots and hash-based state storage")
+	}
+	if !api.eth.Synced() {
+		return errors.New("state pruning unavailable while syncing")
+	}
+	var (
+		chain = api.eth.blockchain
+		db    = api.eth.ChainDb()
+	)
+	return api.eth.statePruner.Prune(func() []common.Hash {
+		// Retain every state the chain may still keep in memory, along with the
+		// last one persisted to disk, which the chain rewinds to after a crash
+		var (
+			roots     []common.Hash
+			persisted bool
+		)
+		for header := chain.CurrentBlock().Header(); header != nil; {
+			ondisk := len(rawdb.ReadTrieNode(db, header.Root)) > 0
+			if len(roots) < core.TriesInMemory {
+				if chain.HasState(header.Root) {
+					roots = append(roots, header.Root)
+				}
+			} else if ondisk {
+				roots = append(roots, header.Root)
+			}
+			persisted = persisted || ondisk
+			if (persisted && len(roots) >= core.TriesInMemory) || header.Number.Uint64() == 0 {
+				break
+			}
+			header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+		}
+		return roots
+	})
+}
+
+// PruneStatus returns the progress of the current or last online state pruning.
+func (api *PrivateDebugAPI) PruneStatus() (*pruner.OnlineStatus, error) {
+	if api.eth.statePruner == nil {
+		return nil, errors.New("online state pruning requires snapshots and hash-based state storage")
+	}
+	status := api.eth.statePruner.Status()
+	return &status, nil
+}
+
 // BadBlockArgs represents the entries in the list returned when bad blocks are queried.
 type BadBlockArgs struct {
 	Hash  common.Hash            `json:"hash"`
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -72,7 +73,8 @@ type Ethereum struct {
 	snapDialCandidates enode.Iterator
 
 	// DB interfaces
-	chainDb ethdb.Database // Block chain database
+	chainDb     ethdb.Database       // Block chain database
+	statePruner *pruner.OnlinePruner // Background pruner of stale trie nodes, nil if unsupported
 
 	eventMux       *event.TypeMux
 	engine         consensus.Engine
@@ -130,6 +132,20 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Expire the ancient chain history if requested, before anything reads it
+	if config.HistoryPruneBlock > 0 {
+		if _, err := rawdb.PruneHistory(chainDb, config.HistoryPruneBlock); err != nil {
+			log.Error("Failed to prune chain history", "err", err)
+		}
+	}
+	// Resolve the trie node storage scheme before any state is written
+	scheme, err := rawdb.ParseStateScheme(config.StateScheme, chainDb)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == rawdb.PathScheme && (config.SyncMode != downloader.FullSync || config.NoPruning) {
+		return nil, errors.New("path-based state scheme requires full sync and non-archive mode")
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideBerlin)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -187,6 +203,14 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			Preimages:           config.Preimages,
 		}
 	)
+	if config.StateHistory > 0 {
+		if stack.Config().DataDir == "" {
+			log.Warn("State history unavailable for ephemeral nodes")
+		} else {
+			cacheConfig.StateHistory = config.StateHistory
+			cacheConfig.StateHistoryDir = stateHistoryDir(stack, config.DatabaseFreezer)
+		}
+	}
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
 	if err != nil {
 		return nil, err
@@ -199,6 +223,14 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
+	// Set up the online state pruner, which needs snapshots and hash-keyed trie nodes
+	if snaps := eth.blockchain.Snapshots(); snaps != nil && scheme == rawdb.HashScheme && !config.NoPruning {
+		eth.statePruner, err = pruner.NewOnlinePruner(chainDb, eth.blockchain.StateCache().TrieDB(), snaps, pruner.DefaultOnlineConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
@@ -267,6 +299,18 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	return eth, nil
 }
 
+// stateHistoryDir returns the directory of the state history, placed into the
+// ancient store of the chain database.
+func stateHistoryDir(stack *node.Node, freezer string) string {
+	switch {
+	case freezer == "":
+		freezer = filepath.Join(stack.ResolvePath("chaindata"), "ancient")
+	case !filepath.IsAbs(freezer):
+		freezer = stack.ResolvePath(freezer)
+	}
+	return filepath.Join(freezer, "state")
+}
+
 func makeExtraData(extra []byte) []byte {
 	if len(extra) == 0 {
 		// create default extradata
@@ -548,6 +592,9 @@ func (s *Ethereum) Stop() error {
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
 	s.miner.Stop()
+	if s.statePruner != nil {
+		s.statePruner.Stop()
+	}
 	s.blockchain.Stop()
 	s.engine.Close()
 	rawdb.PopUncleanShutdownMarker(s.chainDb)
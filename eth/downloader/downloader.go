@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/peerscore"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -122,7 +123,8 @@ type Downloader struct {
 	blockchain BlockChain
 
 	// Callbacks
-	dropPeer peerDropFn // Drops a peer for misbehaving
+	dropPeer peerDropFn         // Drops a peer for misbehaving
+	scores   *peerscore.Tracker // Reputation tracker to report peer behaviour to (may be nil)
 
 	// Status
 	synchroniseMock func(id string, hash common.Hash) error // Replacement for synchronise during testing
@@ -216,7 +218,7 @@ type BlockChain interface {
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
-func New(checkpoint uint64, stateDb ethdb.Database, stateBloom *trie.SyncBloom, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
+func New(checkpoint uint64, stateDb ethdb.Database, stateBloom *trie.SyncBloom, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn, scores *peerscore.Tracker) *Downloader {
 	if lightchain == nil {
 		lightchain = chain
 	}
@@ -232,6 +234,7 @@ func New(checkpoint uint64, stateDb ethdb.Database, stateBloom *trie.SyncBloom,
 		blockchain:     chain,
 		lightchain:     lightchain,
 		dropPeer:       dropPeer,
+		scores:         scores,
 		headerCh:       make(chan dataPack, 1),
 		bodyCh:         make(chan dataPack, 1),
 		receiptCh:      make(chan dataPack, 1),
@@ -350,6 +353,14 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 		errors.Is(err, errStallingPeer) || errors.Is(err, errUnsyncedPeer) || errors.Is(err, errEmptyHeaderSet) ||
 		errors.Is(err, errPeersUnavailable) || errors.Is(err, errTooOld) || errors.Is(err, errInvalidAncestor) {
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
+		switch {
+		case errors.Is(err, errInvalidChain) || errors.Is(err, errBadPeer) || errors.Is(err, errInvalidAncestor):
+			d.scores.Record(id, peerscore.InvalidBlock)
+		case errors.Is(err, errTimeout) || errors.Is(err, errStallingPeer):
+			d.scores.Record(id, peerscore.Timeout)
+		default:
+			d.scores.Record(id, peerscore.UselessData)
+		}
 		if d.dropPeer == nil {
 			// The dropPeer method is nil when `--copydb` is used for a local copy.
 			// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
@@ -1215,6 +1226,7 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64) error {
 			// Header retrieval timed out, consider the peer bad and drop
 			p.log.Debug("Header request timed out", "elapsed", ttl)
 			headerTimeoutMeter.Mark(1)
+			d.scores.Record(p.id, peerscore.Timeout)
 			d.dropPeer(p.id)
 
 			// Finish the sync gracefully instead of dumping the gathered data though
@@ -1380,14 +1392,19 @@ func (d *Downloader) fetchParts(deliveryCh chan dataPack, deliver func(dataPack)
 				if !errors.Is(err, errStaleDelivery) {
 					setIdle(peer, accepted, deliveryTime)
 				}
-				// Issue a log to the user to see what's going on
+				// Issue a log to the user to see what's going on and score the peer
 				switch {
 				case err == nil && packet.Items() == 0:
 					peer.log.Trace("Requested data not delivered", "type", kind)
+					d.scores.Record(peer.id, peerscore.UselessData)
 				case err == nil:
 					peer.log.Trace("Delivered new batch of data", "type", kind, "count", packet.Stats())
+					d.scores.Record(peer.id, peerscore.UsefulData)
 				default:
 					peer.log.Debug("Failed to deliver retrieved data", "type", kind, "err", err)
+					if !errors.Is(err, errStaleDelivery) {
+						d.scores.Record(peer.id, peerscore.UselessData)
+					}
 				}
 			}
 			// Blocks assembled, try to update the progress
@@ -1422,6 +1439,8 @@ func (d *Downloader) fetchParts(deliveryCh chan dataPack, deliver func(dataPack)
 			// Check for fetch request timeouts and demote the responsible peers
 			for pid, fails := range expire() {
 				if peer := d.peers.Peer(pid); peer != nil {
+					d.scores.Record(pid, peerscore.Timeout)
+
 					// If a lot of retrieval elements expired, we might have overestimated the remote peer or perhaps
 					// ourselves. Only reset to minimal throughput but don't drop just yet. If even the minimal times
 					// out that sync wise we need to get rid of the peer.
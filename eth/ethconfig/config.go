@@ -41,16 +41,20 @@ import (
 
 // FullNodeGPO contains default gasprice oracle settings for full node.
 var FullNodeGPO = gasprice.Config{
-	Blocks:     20,
-	Percentile: 60,
-	MaxPrice:   gasprice.DefaultMaxPrice,
+	Blocks:           20,
+	Percentile:       60,
+	MaxHeaderHistory: 1024,
+	MaxBlockHistory:  1024,
+	MaxPrice:         gasprice.DefaultMaxPrice,
 }
 
 // LightClientGPO contains default gasprice oracle settings for light client.
 var LightClientGPO = gasprice.Config{
-	Blocks:     2,
-	Percentile: 60,
-	MaxPrice:   gasprice.DefaultMaxPrice,
+	Blocks:           2,
+	Percentile:       60,
+	MaxHeaderHistory: 300,
+	MaxBlockHistory:  5,
+	MaxPrice:         gasprice.DefaultMaxPrice,
 }
 
 // Defaults contains default settings for use on the Ethereum main net.
@@ -129,8 +133,21 @@ type Config struct {
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
+	// StateScheme is the storage scheme of the trie nodes, either "hash" or
+	// "path". Empty picks the scheme of the existing database.
+	StateScheme string `toml:",omitempty"`
+
+	// StateHistory is the number of recent blocks to keep reverse state diffs for,
+	// serving their state without the tries. Zero disables the state history.
+	StateHistory uint64 `toml:",omitempty"`
+
 	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 
+	// HistoryPruneBlock is the block number below which the bodies and receipts
+	// in the ancient store are deleted at startup (history expiry). Zero retains
+	// the full chain history.
+	HistoryPruneBlock uint64 `toml:",omitempty"`
+
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
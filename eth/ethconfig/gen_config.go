@@ -24,7 +24,10 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SnapDiscoveryURLs       []string
 		NoPruning               bool
 		NoPrefetch              bool
+		StateScheme             string                 `toml:",omitempty"`
+		StateHistory            uint64                 `toml:",omitempty"`
 		TxLookupLimit           uint64                 `toml:",omitempty"`
+		HistoryPruneBlock       uint64                 `toml:",omitempty"`
 		Whitelist               map[uint64]common.Hash `toml:"-"`
 		LightServ               int                    `toml:",omitempty"`
 		LightIngress            int                    `toml:",omitempty"`
@@ -68,7 +71,10 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SnapDiscoveryURLs = c.SnapDiscoveryURLs
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
+	enc.StateScheme = c.StateScheme
+	enc.StateHistory = c.StateHistory
 	enc.TxLookupLimit = c.TxLookupLimit
+	enc.HistoryPruneBlock = c.HistoryPruneBlock
 	enc.Whitelist = c.Whitelist
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
@@ -116,7 +122,10 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		SnapDiscoveryURLs       []string
 		NoPruning               *bool
 		NoPrefetch              *bool
+		StateScheme             *string                `toml:",omitempty"`
+		StateHistory            *uint64                `toml:",omitempty"`
 		TxLookupLimit           *uint64                `toml:",omitempty"`
+		HistoryPruneBlock       *uint64                `toml:",omitempty"`
 		Whitelist               map[uint64]common.Hash `toml:"-"`
 		LightServ               *int                   `toml:",omitempty"`
 		LightIngress            *int                   `toml:",omitempty"`
@@ -177,9 +186,18 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPrefetch != nil {
 		c.NoPrefetch = *dec.NoPrefetch
 	}
+	if dec.StateScheme != nil {
+		c.StateScheme = *dec.StateScheme
+	}
+	if dec.StateHistory != nil {
+		c.StateHistory = *dec.StateHistory
+	}
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
+	if dec.HistoryPruneBlock != nil {
+		c.HistoryPruneBlock = *dec.HistoryPruneBlock
+	}
 	if dec.Whitelist != nil {
 		c.Whitelist = dec.Whitelist
 	}
@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/prque"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/peerscore"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/trie"
@@ -187,6 +188,7 @@ type BlockFetcher struct {
 	insertHeaders  headersInsertFn    // Injects a batch of headers into the chain
 	insertChain    chainInsertFn      // Injects a batch of blocks into the chain
 	dropPeer       peerDropFn         // Drops a peer for misbehaving
+	scores         *peerscore.Tracker // Reputation tracker to report to and pick fetch targets by (may be nil)
 
 	// Testing hooks
 	announceChangeHook func(common.Hash, bool)           // Method to call upon adding or deleting a hash from the blockAnnounce list
@@ -197,7 +199,7 @@ type BlockFetcher struct {
 }
 
 // NewBlockFetcher creates a block fetcher to retrieve blocks based on hash announcements.
-func NewBlockFetcher(light bool, getHeader HeaderRetrievalFn, getBlock blockRetrievalFn, verifyHeader headerVerifierFn, broadcastBlock blockBroadcasterFn, chainHeight chainHeightFn, insertHeaders headersInsertFn, insertChain chainInsertFn, dropPeer peerDropFn) *BlockFetcher {
+func NewBlockFetcher(light bool, getHeader HeaderRetrievalFn, getBlock blockRetrievalFn, verifyHeader headerVerifierFn, broadcastBlock blockBroadcasterFn, chainHeight chainHeightFn, insertHeaders headersInsertFn, insertChain chainInsertFn, dropPeer peerDropFn, scores *peerscore.Tracker) *BlockFetcher {
 	return &BlockFetcher{
 		light:          light,
 		notify:         make(chan *blockAnnounce),
@@ -222,6 +224,7 @@ func NewBlockFetcher(light bool, getHeader HeaderRetrievalFn, getBlock blockRetr
 		insertHeaders:  insertHeaders,
 		insertChain:    insertChain,
 		dropPeer:       dropPeer,
+		scores:         scores,
 	}
 }
 
@@ -340,6 +343,7 @@ func (f *BlockFetcher) loop() {
 		// Clean up any expired block fetches
 		for hash, announce := range f.fetching {
 			if time.Since(announce.time) > fetchTimeout {
+				f.scores.Record(announce.origin, peerscore.UndeliveredBlock)
 				f.forgetHash(hash)
 			}
 		}
@@ -439,8 +443,8 @@ func (f *BlockFetcher) loop() {
 					timeout = 0
 				}
 				if time.Since(announces[0].time) > timeout {
-					// Pick a random peer to retrieve from, reset all others
-					announce := announces[rand.Intn(len(announces))]
+					// Pick the best peer to retrieve from, reset all others
+					announce := f.pickAnnounce(announces)
 					f.forgetHash(hash)
 
 					// If the block still didn't arrive, queue for fetching
@@ -474,8 +478,8 @@ func (f *BlockFetcher) loop() {
 			request := make(map[string][]common.Hash)
 
 			for hash, announces := range f.fetched {
-				// Pick a random peer to retrieve from, reset all others
-				announce := announces[rand.Intn(len(announces))]
+				// Pick the best peer to retrieve from, reset all others
+				announce := f.pickAnnounce(announces)
 				f.forgetHash(hash)
 
 				// If the block still didn't arrive, queue for completion
@@ -660,6 +664,29 @@ func (f *BlockFetcher) loop() {
 	}
 }
 
+// pickAnnounce selects the announcement to retrieve a block by, preferring the
+// announcing peer with the highest score. Ties are broken randomly.
+func (f *BlockFetcher) pickAnnounce(announces []*blockAnnounce) *blockAnnounce {
+	var (
+		best      *blockAnnounce
+		bestScore float64
+		ties      int
+	)
+	for _, announce := range announces {
+		score := f.scores.Score(announce.origin)
+		switch {
+		case best == nil || score > bestScore:
+			best, bestScore, ties = announce, score, 1
+		case score == bestScore:
+			// Reservoir sampling keeps the choice uniform among equal scores
+			if ties++; rand.Intn(ties) == 0 {
+				best = announce
+			}
+		}
+	}
+	return best
+}
+
 // rescheduleFetch resets the specified fetch timer to the next blockAnnounce timeout.
 func (f *BlockFetcher) rescheduleFetch(fetch *time.Timer) {
 	// Short circuit if no blocks are announced
@@ -761,6 +788,7 @@ func (f *BlockFetcher) importHeaders(peer string, header *types.Header) {
 		// Validate the header and if something went wrong, drop the peer
 		if err := f.verifyHeader(header); err != nil && err != consensus.ErrFutureBlock {
 			log.Debug("Propagated header verification failed", "peer", peer, "number", header.Number, "hash", hash, "err", err)
+			f.scores.Record(peer, peerscore.InvalidBlock)
 			f.dropPeer(peer)
 			return
 		}
@@ -806,6 +834,7 @@ func (f *BlockFetcher) importBlocks(peer string, block *types.Block) {
 		default:
 			// Something went very wrong, drop the peer
 			log.Debug("Propagated block verification failed", "peer", peer, "number", block.Number(), "hash", hash, "err", err)
+			f.scores.Record(peer, peerscore.InvalidBlock)
 			f.dropPeer(peer)
 			return
 		}
@@ -817,6 +846,7 @@ func (f *BlockFetcher) importBlocks(peer string, block *types.Block) {
 		// If import succeeded, broadcast the block
 		blockAnnounceOutTimer.UpdateSince(block.ReceivedAt)
 		go f.broadcastBlock(block, false)
+		f.scores.Record(peer, peerscore.UsefulBlock)
 
 		// Invoke the testing hook if needed
 		if f.importedHook != nil {
@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/peerscore"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 )
@@ -172,6 +173,8 @@ type TxFetcher struct {
 	addTxs   func([]*types.Transaction) []error // Insert a batch of transactions into local txpool
 	fetchTxs func(string, []common.Hash) error  // Retrieves a set of txs from a remote peer
 
+	scores *peerscore.Tracker // Reputation tracker to report peer behaviour to (may be nil)
+
 	step  chan struct{} // Notification channel when the fetcher loop iterates
 	clock mclock.Clock  // Time wrapper to simulate in tests
 	rand  *mrand.Rand   // Randomizer to use in tests instead of map range loops (soft-random)
@@ -179,8 +182,10 @@ type TxFetcher struct {
 
 // NewTxFetcher creates a transaction fetcher to retrieve transaction
 // based on hash announcements.
-func NewTxFetcher(hasTx func(common.Hash) bool, addTxs func([]*types.Transaction) []error, fetchTxs func(string, []common.Hash) error) *TxFetcher {
-	return NewTxFetcherForTests(hasTx, addTxs, fetchTxs, mclock.System{}, nil)
+func NewTxFetcher(hasTx func(common.Hash) bool, addTxs func([]*types.Transaction) []error, fetchTxs func(string, []common.Hash) error, scores *peerscore.Tracker) *TxFetcher {
+	f := NewTxFetcherForTests(hasTx, addTxs, fetchTxs, mclock.System{}, nil)
+	f.scores = scores
+	return f
 }
 
 // NewTxFetcherForTests is a testing method to mock out the realtime clock with
@@ -274,6 +279,7 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 		duplicate   int64
 		underpriced int64
 		otherreject int64
+		invalid     int64
 	)
 	errs := f.addTxs(txs)
 	for i, err := range errs {
@@ -297,6 +303,11 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 			case core.ErrUnderpriced, core.ErrReplaceUnderpriced:
 				underpriced++
 
+			case core.ErrInvalidSender, core.ErrOversizedData, core.ErrNegativeValue,
+				core.ErrIntrinsicGas, core.ErrGasUintOverflow, core.ErrTxTypeNotSupported:
+				otherreject++
+				invalid++
+
 			default:
 				otherreject++
 			}
@@ -312,6 +323,15 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 		txBroadcastUnderpricedMeter.Mark(underpriced)
 		txBroadcastOtherRejectMeter.Mark(otherreject)
 	}
+	// Score the peer by the usefulness of the batch. Only transactions which can
+	// never be valid are penalized, rejections depending on the local pool state
+	// (duplicates, underpriced, nonce gaps, balances) are expected in normal operation.
+	if invalid > 0 {
+		f.scores.Record(peer, peerscore.InvalidTxs)
+	}
+	if int64(len(txs)) > duplicate+underpriced+otherreject {
+		f.scores.Record(peer, peerscore.UsefulTxs)
+	}
 	select {
 	case f.cleanup <- &txDelivery{origin: peer, hashes: added, direct: direct}:
 		return nil
@@ -481,6 +501,7 @@ func (f *TxFetcher) loop() {
 			for peer, req := range f.requests {
 				if time.Duration(f.clock.Now()-req.time)+txGatherSlack > txFetchTimeout {
 					txRequestTimeoutMeter.Mark(int64(len(req.hashes)))
+					f.scores.Record(peer, peerscore.Timeout)
 
 					// Reschedule all the not-yet-delivered fetches to alternate peers
 					for _, hash := range req.hashes {
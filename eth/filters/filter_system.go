@@ -102,6 +102,9 @@ type EventSystem struct {
 	pendingLogsCh chan []*types.Log          // Channel to receive new log event
 	rmLogsCh      chan core.RemovedLogsEvent // Channel to receive removed log event
 	chainCh       chan core.ChainEvent       // Channel to receive new chain event
+
+	quit     chan struct{} // Channel closed to terminate the event loop
+	quitOnce sync.Once     // Ensures quit is only closed once
 }
 
 // NewEventSystem creates a new manager that listens for event on the given mux,
@@ -121,6 +124,7 @@ func NewEventSystem(backend Backend, lightMode bool) *EventSystem {
 		rmLogsCh:      make(chan core.RemovedLogsEvent, rmLogsChanSize),
 		pendingLogsCh: make(chan []*types.Log, logsChanSize),
 		chainCh:       make(chan core.ChainEvent, chainEvChanSize),
+		quit:          make(chan struct{}),
 	}
 
 	// Subscribe events
@@ -164,6 +168,9 @@ func (sub *Subscription) Unsubscribe() {
 			select {
 			case sub.es.uninstall <- sub.f:
 				break uninstallLoop
+			case <-sub.es.quit:
+				// event loop terminated, the filter was already uninstalled
+				break uninstallLoop
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
@@ -179,11 +186,22 @@ func (sub *Subscription) Unsubscribe() {
 
 // subscribe installs the subscription in the event broadcast loop.
 func (es *EventSystem) subscribe(sub *subscription) *Subscription {
-	es.install <- sub
-	<-sub.installed
+	select {
+	case es.install <- sub:
+		<-sub.installed
+	case <-es.quit:
+		// event loop terminated, report the subscription as ended right away
+		close(sub.err)
+	}
 	return &Subscription{ID: sub.id, f: sub, es: es}
 }
 
+// Stop terminates the event loop and releases the backend subscriptions. All
+// installed subscriptions are ended by closing their error channel.
+func (es *EventSystem) Stop() {
+	es.quitOnce.Do(func() { close(es.quit) })
+}
+
 // SubscribeLogs creates a subscription that will write all logs matching the
 // given criteria to the given logs channel. Default value for the from and to
 // block is "latest". If the fromBlock > toBlock an error is returned.
@@ -489,6 +507,17 @@ func (es *EventSystem) eventLoop() {
 			close(f.err)
 
 		// System stopped
+		case <-es.quit:
+			for typ, filters := range index {
+				for _, f := range filters {
+					// mined and pending log filters are indexed under both log types
+					if typ == PendingLogsSubscription && f.typ == MinedAndPendingLogsSubscription {
+						continue
+					}
+					close(f.err)
+				}
+			}
+			return
 		case <-es.txsSub.Err():
 			return
 		case <-es.logsSub.Err():
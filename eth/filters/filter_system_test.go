@@ -673,6 +673,50 @@ func TestPendingTxFilterDeadlock(t *testing.T) {
 	}
 }
 
+// TestEventSystemStop tests that stopping the event system ends all installed
+// subscriptions and releases the backend feeds.
+func TestEventSystemStop(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		es      = NewEventSystem(backend, false)
+	)
+	txSub := es.SubscribePendingTxs(make(chan []common.Hash))
+	logSub, err := es.SubscribeLogs(ethereum.FilterQuery{FromBlock: big.NewInt(rpc.LatestBlockNumber.Int64()), ToBlock: big.NewInt(rpc.PendingBlockNumber.Int64())}, make(chan []*types.Log))
+	if err != nil {
+		t.Fatalf("failed to subscribe to logs: %v", err)
+	}
+	es.Stop()
+	es.Stop() // idempotent
+
+	for _, sub := range []*Subscription{txSub, logSub} {
+		select {
+		case <-sub.Err():
+		case <-time.After(time.Second):
+			t.Fatalf("subscription %s not ended", sub.ID)
+		}
+		sub.Unsubscribe()
+	}
+	// Subscriptions created after stopping end right away.
+	sub := es.SubscribeNewHeads(make(chan *types.Header))
+	select {
+	case <-sub.Err():
+	case <-time.After(time.Second):
+		t.Fatal("subscription after stop not ended")
+	}
+	sub.Unsubscribe()
+
+	// The backend feeds are released once the event loop exits.
+	for i := 0; backend.txFeed.Send(core.NewTxsEvent{}) != 0; i++ {
+		if i == 100 {
+			t.Fatal("transaction feed still subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func flattenLogs(pl [][]*types.Log) []*types.Log {
 	var logs []*types.Log
 	for _, l := range pl {
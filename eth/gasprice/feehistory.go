@@ -0,0 +1,282 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	errInvalidPercentile = errors.New("invalid reward percentile")
+	errRequestBeyondHead = errors.New("request beyond head block")
+)
+
+const (
+	// maxBlockFetchers is the max number of goroutines to spin up to pull blocks
+	// for the fee history calculation (mostly relevant for LES).
+	maxBlockFetchers = 4
+)
+
+// blockFees represents a single block for processing
+type blockFees struct {
+	// set by the caller
+	blockNumber uint64
+	header      *types.Header
+	block       *types.Block // only set if reward percentiles are requested
+	receipts    types.Receipts
+	// filled by processBlock
+	results processedFees
+	err     error
+}
+
+// processedFees contains the results of a processed block and is also used for caching
+type processedFees struct {
+	reward       []*big.Int
+	gasUsedRatio float64
+}
+
+// txGasAndReward is sorted in ascending order based on reward
+type (
+	txGasAndReward struct {
+		gasUsed uint64
+		reward  *big.Int
+	}
+	sortGasAndReward []txGasAndReward
+)
+
+func (s sortGasAndReward) Len() int           { return len(s) }
+func (s sortGasAndReward) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortGasAndReward) Less(i, j int) bool { return s[i].reward.Cmp(s[j].reward) < 0 }
+
+// processBlock takes a blockFees structure with the blockNumber, the header and optionally
+// the block field filled in, retrieves the block from the backend if not present yet and
+// fills in the rest of the fields.
+func (oracle *Oracle) processBlock(bf *blockFees, percentiles []float64) {
+	if bf.header.GasLimit != 0 {
+		bf.results.gasUsedRatio = float64(bf.header.GasUsed) / float64(bf.header.GasLimit)
+	}
+	if len(percentiles) == 0 {
+		// rewards were not requested, return null
+		return
+	}
+	if bf.block == nil || (bf.receipts == nil && len(bf.block.Transactions()) != 0) {
+		log.Error("Block or receipts are missing while reward percentiles are requested")
+		return
+	}
+	if bf.receipts != nil && len(bf.receipts) != len(bf.block.Transactions()) {
+		log.Error("Receipts don't match block transactions", "number", bf.blockNumber, "txs", len(bf.block.Transactions()), "receipts", len(bf.receipts))
+		return
+	}
+	bf.results.reward = make([]*big.Int, len(percentiles))
+	if len(bf.block.Transactions()) == 0 {
+		// return an all zero row if there are no transactions to gather data from
+		for i := range bf.results.reward {
+			bf.results.reward[i] = new(big.Int)
+		}
+		return
+	}
+	sorter := make(sortGasAndReward, len(bf.block.Transactions()))
+	for i, tx := range bf.block.Transactions() {
+		sorter[i] = txGasAndReward{gasUsed: bf.receipts[i].GasUsed, reward: tx.GasPrice()}
+	}
+	sort.Sort(sorter)
+
+	var txIndex int
+	sumGasUsed := sorter[0].gasUsed
+
+	for i, p := range percentiles {
+		thresholdGasUsed := uint64(float64(bf.block.GasUsed()) * p / 100)
+		for sumGasUsed < thresholdGasUsed && txIndex < len(bf.block.Transactions())-1 {
+			txIndex++
+			sumGasUsed += sorter[txIndex].gasUsed
+		}
+		bf.results.reward[i] = sorter[txIndex].reward
+	}
+}
+
+// resolveBlockRange resolves the specified block range to absolute block numbers while also
+// enforcing backend specific limitations. The pending block is not available for fee
+// history calculation, so requests for it are served from the latest block instead.
+// Note: an error is only returned if retrieving the head header has failed. If there are no
+// retrievable blocks in the specified range then zero block count is returned with no error.
+func (oracle *Oracle) resolveBlockRange(ctx context.Context, lastBlock rpc.BlockNumber, blocks int) (*types.Header, uint64, int, error) {
+	// Get the chain's current head.
+	headHeader, err := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	head := rpc.BlockNumber(headHeader.Number.Uint64())
+
+	// Fail if request block is beyond the chain's current head.
+	if lastBlock > head {
+		return nil, 0, 0, fmt.Errorf("%w: requested %d, head %d", errRequestBeyondHead, lastBlock, head)
+	}
+	// Resolve block tag.
+	if lastBlock < 0 {
+		lastBlock = head
+	}
+	// Ensure not trying to retrieve before genesis.
+	if int(lastBlock+1) < blocks {
+		blocks = int(lastBlock + 1)
+	}
+	return headHeader, uint64(lastBlock), blocks, nil
+}
+
+// FeeHistory returns data relevant for fee estimation based on the specified range of blocks.
+// The range can be specified either with absolute block numbers or ending with the latest
+// block. Requests for the pending block are served from the latest one. Blocks are always
+// processed in ascending order and the results are cached by block hash, so repeated
+// queries for the same heads are cheap.
+//
+// Note: an error is only returned if retrieving the head header has failed. If there are no
+// retrievable blocks in the specified range then zero block count is returned with no error.
+func (oracle *Oracle) FeeHistory(ctx context.Context, blocks int, unresolvedLastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	if blocks < 1 {
+		return common.Big0, nil, nil, nil // returning with no data and no error means there are no retrievable blocks
+	}
+	maxFeeHistory := oracle.maxHeaderHistory
+	if len(rewardPercentiles) != 0 {
+		maxFeeHistory = oracle.maxBlockHistory
+	}
+	if blocks > maxFeeHistory {
+		log.Warn("Sanitizing fee history length", "requested", blocks, "truncated", maxFeeHistory)
+		blocks = maxFeeHistory
+	}
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 {
+			return common.Big0, nil, nil, fmt.Errorf("%w: %f", errInvalidPercentile, p)
+		}
+		if i > 0 && p < rewardPercentiles[i-1] {
+			return common.Big0, nil, nil, fmt.Errorf("%w: #%d:%f > #%d:%f", errInvalidPercentile, i-1, rewardPercentiles[i-1], i, p)
+		}
+	}
+	headHeader, lastBlock, blocks, err := oracle.resolveBlockRange(ctx, unresolvedLastBlock, blocks)
+	if err != nil || blocks == 0 {
+		return common.Big0, nil, nil, err
+	}
+	oldestBlock := lastBlock + 1 - uint64(blocks)
+
+	var (
+		next    = oldestBlock
+		results = make(chan *blockFees, blocks)
+	)
+	percentileKey := make([]byte, 8*len(rewardPercentiles))
+	for i, p := range rewardPercentiles {
+		binary.LittleEndian.PutUint64(percentileKey[i*8:(i+1)*8], math.Float64bits(p))
+	}
+	for i := 0; i < maxBlockFetchers && i < blocks; i++ {
+		go func() {
+			for {
+				// Retrieve the next block number to fetch with this goroutine
+				blockNumber := atomic.AddUint64(&next, 1) - 1
+				if blockNumber > lastBlock {
+					return
+				}
+				fees := &blockFees{blockNumber: blockNumber}
+				if blockNumber == headHeader.Number.Uint64() {
+					fees.header = headHeader
+				} else {
+					fees.header, fees.err = oracle.backend.HeaderByNumber(ctx, rpc.BlockNumber(blockNumber))
+				}
+				if fees.header == nil && fees.err == nil {
+					fees.err = fmt.Errorf("header #%d not found", blockNumber)
+				}
+				if fees.err != nil {
+					results <- fees
+					continue
+				}
+				// Serve the request from the cache if the same block was processed
+				// with the same percentiles before.
+				cacheKey := historyCacheKey{hash: fees.header.Hash(), percentiles: string(percentileKey)}
+				if p, ok := oracle.historyCache.Get(cacheKey); ok {
+					fees.results = p.(processedFees)
+					results <- fees
+					continue
+				}
+				if len(rewardPercentiles) != 0 {
+					fees.block, fees.err = oracle.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNumber))
+					if fees.block != nil && fees.err == nil {
+						fees.header = fees.block.Header()
+						fees.receipts, fees.err = oracle.backend.GetReceipts(ctx, fees.block.Hash())
+					}
+					if fees.block == nil && fees.err == nil {
+						fees.err = fmt.Errorf("block #%d not found", blockNumber)
+					}
+				}
+				if fees.err == nil {
+					oracle.processBlock(fees, rewardPercentiles)
+					// Blocks which couldn't be processed are not cached, their data
+					// may become available on a later request.
+					if fees.results.reward != nil || len(rewardPercentiles) == 0 {
+						oracle.historyCache.Add(cacheKey, fees.results)
+					}
+				}
+				// send to results even if empty to guarantee that blocks items are sent in total
+				results <- fees
+			}
+		}()
+	}
+	var (
+		reward       = make([][]*big.Int, blocks)
+		gasUsedRatio = make([]float64, blocks)
+		firstMissing = blocks
+	)
+	for ; blocks > 0; blocks-- {
+		fees := <-results
+		if fees.err != nil {
+			return common.Big0, nil, nil, fees.err
+		}
+		i := int(fees.blockNumber - oldestBlock)
+		if fees.results.reward != nil || len(rewardPercentiles) == 0 {
+			reward[i], gasUsedRatio[i] = fees.results.reward, fees.results.gasUsedRatio
+		} else {
+			// getting no block and no error means we are requesting into the future
+			// (might happen because of a reorg)
+			if i < firstMissing {
+				firstMissing = i
+			}
+		}
+	}
+	if firstMissing == 0 {
+		return common.Big0, nil, nil, nil
+	}
+	if len(rewardPercentiles) != 0 {
+		reward = reward[:firstMissing]
+	} else {
+		reward = nil
+	}
+	gasUsedRatio = gasUsedRatio[:firstMissing]
+	return new(big.Int).SetUint64(oldestBlock), reward, gasUsedRatio, nil
+}
+
+// historyCacheKey identifies a processed block in the oracle's history cache.
+type historyCacheKey struct {
+	hash        common.Hash
+	percentiles string
+}
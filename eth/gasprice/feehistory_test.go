@@ -0,0 +1,191 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestFeeHistory(t *testing.T) {
+	var cases = []struct {
+		maxHeader, maxBlock int
+		count               int
+		last                rpc.BlockNumber
+		percent             []float64
+		expFirst            uint64
+		expCount            int
+		expErr              error
+	}{
+		{1000, 1000, 10, 30, nil, 21, 10, nil},
+		{1000, 1000, 10, 30, []float64{0, 10}, 21, 10, nil},
+		{1000, 1000, 10, 30, []float64{20, 10}, 0, 0, errInvalidPercentile},
+		{1000, 1000, 1000000000, 30, nil, 0, 31, nil},
+		{1000, 1000, 1000000000, rpc.LatestBlockNumber, nil, 0, 33, nil},
+		{1000, 1000, 10, 40, nil, 0, 0, errRequestBeyondHead},
+		{20, 2, 100, rpc.LatestBlockNumber, nil, 13, 20, nil},
+		{20, 2, 100, rpc.LatestBlockNumber, []float64{0, 10}, 31, 2, nil},
+		{20, 2, 100, 32, []float64{0, 10}, 31, 2, nil},
+		{1000, 1000, 1, rpc.PendingBlockNumber, nil, 32, 1, nil},
+		{1000, 1000, 2, rpc.PendingBlockNumber, []float64{0, 10}, 31, 2, nil},
+	}
+	for i, c := range cases {
+		config := Config{
+			MaxHeaderHistory: c.maxHeader,
+			MaxBlockHistory:  c.maxBlock,
+		}
+		backend := newTestBackend(t)
+		oracle := NewOracle(backend, config)
+
+		first, reward, ratio, err := oracle.FeeHistory(context.Background(), c.count, c.last, c.percent)
+
+		expReward := c.expCount
+		if len(c.percent) == 0 {
+			expReward = 0
+		}
+		if first.Uint64() != c.expFirst {
+			t.Fatalf("Test case %d: first block mismatch, want %d, got %d", i, c.expFirst, first)
+		}
+		if len(reward) != expReward {
+			t.Fatalf("Test case %d: reward array length mismatch, want %d, got %d", i, expReward, len(reward))
+		}
+		if len(ratio) != c.expCount {
+			t.Fatalf("Test case %d: gasUsedRatio array length mismatch, want %d, got %d", i, c.expCount, len(ratio))
+		}
+		if err != c.expErr && !errors.Is(err, c.expErr) {
+			t.Fatalf("Test case %d: error mismatch, want %v, got %v", i, c.expErr, err)
+		}
+	}
+}
+
+func TestFeeHistoryRewards(t *testing.T) {
+	backend := newTestBackend(t)
+	oracle := NewOracle(backend, Config{MaxHeaderHistory: 1000, MaxBlockHistory: 1000})
+
+	// Every block contains a single transaction priced at blocknumber gwei.
+	for run := 0; run < 2; run++ {
+		first, reward, ratio, err := oracle.FeeHistory(context.Background(), 3, 32, []float64{0, 50, 100})
+		if err != nil {
+			t.Fatalf("Failed to retrieve fee history: %v", err)
+		}
+		if first.Uint64() != 30 {
+			t.Fatalf("First block mismatch, want %d, got %d", 30, first)
+		}
+		for i, row := range reward {
+			expect := big.NewInt(int64(30+i) * params.GWei)
+			for j, r := range row {
+				if r.Cmp(expect) != 0 {
+					t.Fatalf("Run %d: reward %d/%d mismatch, want %d, got %d", run, i, j, expect, r)
+				}
+			}
+			if ratio[i] <= 0 || ratio[i] > 1 {
+				t.Fatalf("Run %d: gas used ratio %d out of range: %f", run, i, ratio[i])
+			}
+		}
+	}
+	if oracle.historyCache.Len() != 3 {
+		t.Fatalf("History cache size mismatch, want %d, got %d", 3, oracle.historyCache.Len())
+	}
+}
+
+// missingReceiptsBackend is a test backend which fails to deliver receipts, e.g.
+// because they are not retrieved yet by a light client.
+type missingReceiptsBackend struct {
+	*testBackend
+	missing bool
+}
+
+func (b *missingReceiptsBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	if b.missing {
+		return nil, nil
+	}
+	return b.testBackend.GetReceipts(ctx, hash)
+}
+
+func TestFeeHistoryNoCacheOnFailure(t *testing.T) {
+	backend := &missingReceiptsBackend{testBackend: newTestBackend(t), missing: true}
+	oracle := NewOracle(backend, Config{MaxHeaderHistory: 1000, MaxBlockHistory: 1000})
+
+	// Blocks without receipts can't be processed and must not be cached.
+	_, reward, _, err := oracle.FeeHistory(context.Background(), 3, 32, []float64{50})
+	if err != nil {
+		t.Fatalf("Failed to retrieve fee history: %v", err)
+	}
+	if len(reward) != 0 {
+		t.Fatalf("Reward returned for unprocessed blocks: %v", reward)
+	}
+	if oracle.historyCache.Len() != 0 {
+		t.Fatalf("Failed results cached: %d entries", oracle.historyCache.Len())
+	}
+
+	// Once the receipts are available, the blocks are processed.
+	backend.missing = false
+	_, reward, _, err = oracle.FeeHistory(context.Background(), 3, 32, []float64{50})
+	if err != nil {
+		t.Fatalf("Failed to retrieve fee history: %v", err)
+	}
+	if len(reward) != 3 {
+		t.Fatalf("Reward length mismatch, want %d, got %d", 3, len(reward))
+	}
+	if oracle.historyCache.Len() != 3 {
+		t.Fatalf("History cache size mismatch, want %d, got %d", 3, oracle.historyCache.Len())
+	}
+}
+
+// partialReceiptsBackend is a test backend which delivers fewer receipts than
+// there are transactions in the block.
+type partialReceiptsBackend struct {
+	*testBackend
+}
+
+func (b *partialReceiptsBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return types.Receipts{}, nil
+}
+
+func TestFeeHistoryReceiptsMismatch(t *testing.T) {
+	backend := &partialReceiptsBackend{testBackend: newTestBackend(t)}
+	oracle := NewOracle(backend, Config{MaxHeaderHistory: 1000, MaxBlockHistory: 1000})
+
+	// Blocks whose receipts don't match the transactions can't be processed.
+	_, reward, _, err := oracle.FeeHistory(context.Background(), 3, 32, []float64{50})
+	if err != nil {
+		t.Fatalf("Failed to retrieve fee history: %v", err)
+	}
+	if len(reward) != 0 {
+		t.Fatalf("Reward returned for unprocessed blocks: %v", reward)
+	}
+	if oracle.historyCache.Len() != 0 {
+		t.Fatalf("Failed results cached: %d entries", oracle.historyCache.Len())
+	}
+}
+
+func TestFeeHistoryZeroGasLimit(t *testing.T) {
+	oracle := NewOracle(newTestBackend(t), Config{})
+
+	fees := &blockFees{header: &types.Header{GasUsed: 0, GasLimit: 0}}
+	oracle.processBlock(fees, nil)
+	if fees.results.gasUsedRatio != 0 {
+		t.Fatalf("Gas used ratio mismatch, want 0, got %f", fees.results.gasUsedRatio)
+	}
+}
@@ -27,23 +27,31 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	lru "github.com/hashicorp/golang-lru"
 )
 
-const sampleNumber = 3 // Number of transactions sampled in a block
+const (
+	sampleNumber = 3 // Number of transactions sampled in a block
+
+	historyCacheSize = 2048 // Number of processed blocks kept for fee history and price queries
+)
 
 var DefaultMaxPrice = big.NewInt(500 * params.GWei)
 
 type Config struct {
-	Blocks     int
-	Percentile int
-	Default    *big.Int `toml:",omitempty"`
-	MaxPrice   *big.Int `toml:",omitempty"`
+	Blocks           int
+	Percentile       int
+	MaxHeaderHistory int
+	MaxBlockHistory  int
+	Default          *big.Int `toml:",omitempty"`
+	MaxPrice         *big.Int `toml:",omitempty"`
 }
 
 // OracleBackend includes all necessary background APIs for oracle.
 type OracleBackend interface {
 	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
 	BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error)
+	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
 	ChainConfig() *params.ChainConfig
 }
 
@@ -59,6 +67,13 @@ type Oracle struct {
 
 	checkBlocks int
 	percentile  int
+
+	maxHeaderHistory, maxBlockHistory int
+
+	// historyCache holds the processed data of recent blocks by hash. It is shared
+	// by FeeHistory, which caches the reward percentiles of a block, and
+	// SuggestPrice, which caches the cheapest transaction prices sampled from it.
+	historyCache *lru.Cache
 }
 
 // NewOracle returns a new gasprice oracle which can recommend suitable
@@ -83,12 +98,26 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 		maxPrice = DefaultMaxPrice
 		log.Warn("Sanitizing invalid gasprice oracle price cap", "provided", params.MaxPrice, "updated", maxPrice)
 	}
+	maxHeaderHistory := params.MaxHeaderHistory
+	if maxHeaderHistory < 1 {
+		maxHeaderHistory = 1
+		log.Warn("Sanitizing invalid gasprice oracle max header history", "provided", params.MaxHeaderHistory, "updated", maxHeaderHistory)
+	}
+	maxBlockHistory := params.MaxBlockHistory
+	if maxBlockHistory < 1 {
+		maxBlockHistory = 1
+		log.Warn("Sanitizing invalid gasprice oracle max block history", "provided", params.MaxBlockHistory, "updated", maxBlockHistory)
+	}
+	cache, _ := lru.New(historyCacheSize)
 	return &Oracle{
-		backend:     backend,
-		lastPrice:   params.Default,
-		maxPrice:    maxPrice,
-		checkBlocks: blocks,
-		percentile:  percent,
+		backend:          backend,
+		lastPrice:        params.Default,
+		maxPrice:         maxPrice,
+		checkBlocks:      blocks,
+		percentile:       percent,
+		maxHeaderHistory: maxHeaderHistory,
+		maxBlockHistory:  maxBlockHistory,
+		historyCache:     cache,
 	}
 }
 
@@ -184,6 +213,23 @@ func (t transactionsByGasPrice) Less(i, j int) bool { return t[i].GasPriceCmp(t[
 // are sent by the miner itself(it doesn't make any sense to include this kind of
 // transaction prices for sampling), nil gasprice is returned.
 func (gpo *Oracle) getBlockPrices(ctx context.Context, signer types.Signer, blockNum uint64, limit int, result chan getBlockPricesResult, quit chan struct{}) {
+	header, err := gpo.backend.HeaderByNumber(ctx, rpc.BlockNumber(blockNum))
+	if header == nil {
+		select {
+		case result <- getBlockPricesResult{nil, err}:
+		case <-quit:
+		}
+		return
+	}
+	// Serve the prices from the cache if the same block was sampled before.
+	cacheKey := blockPricesCacheKey{hash: header.Hash(), limit: limit}
+	if prices, ok := gpo.historyCache.Get(cacheKey); ok {
+		select {
+		case result <- getBlockPricesResult{prices.([]*big.Int), nil}:
+		case <-quit:
+		}
+		return
+	}
 	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNum))
 	if block == nil {
 		select {
@@ -207,12 +253,21 @@ func (gpo *Oracle) getBlockPrices(ctx context.Context, signer types.Signer, bloc
 			}
 		}
 	}
+	gpo.historyCache.Add(blockPricesCacheKey{hash: block.Hash(), limit: limit}, prices)
+
 	select {
 	case result <- getBlockPricesResult{prices, nil}:
 	case <-quit:
 	}
 }
 
+// blockPricesCacheKey identifies the sampled prices of a block in the oracle's
+// history cache.
+type blockPricesCacheKey struct {
+	hash  common.Hash
+	limit int
+}
+
 type bigIntArray []*big.Int
 
 func (s bigIntArray) Len() int           { return len(s) }
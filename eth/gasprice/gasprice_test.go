@@ -51,6 +51,10 @@ func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber)
 	return b.chain.GetBlockByNumber(uint64(number)), nil
 }
 
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.chain.GetReceiptsByHash(hash), nil
+}
+
 func (b *testBackend) ChainConfig() *params.ChainConfig {
 	return b.chain.Config()
 }
@@ -115,4 +119,20 @@ func TestSuggestPrice(t *testing.T) {
 	if got.Cmp(expect) != 0 {
 		t.Fatalf("Gas price mismatch, want %d, got %d", expect, got)
 	}
+	// The sampled blocks are cached and reused once the head is recalculated.
+	cached := oracle.historyCache.Len()
+	if cached != 6 {
+		t.Fatalf("History cache size mismatch, want %d, got %d", 6, cached)
+	}
+	oracle.lastHead = common.Hash{}
+	got, err = oracle.SuggestPrice(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to retrieve recommended gas price: %v", err)
+	}
+	if got.Cmp(expect) != 0 {
+		t.Fatalf("Cached gas price mismatch, want %d, got %d", expect, got)
+	}
+	if oracle.historyCache.Len() != cached {
+		t.Fatalf("History cache size mismatch, want %d, got %d", cached, oracle.historyCache.Len())
+	}
 }
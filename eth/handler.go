@@ -25,11 +25,13 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/fetcher"
+	"github.com/ethereum/go-ethereum/eth/peerscore"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -44,6 +46,10 @@ const (
 	// txChanSize is the size of channel listening to NewTxsEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+
+	// scoreEvictInterval is the time interval at which stale peer scores are
+	// pruned and badly behaving peers are evicted if all peer slots are taken.
+	scoreEvictInterval = 30 * time.Second
 )
 
 var (
@@ -108,6 +114,7 @@ type handler struct {
 	blockFetcher *fetcher.BlockFetcher
 	txFetcher    *fetcher.TxFetcher
 	peers        *peerSet
+	scores       *peerscore.Tracker
 
 	eventMux      *event.TypeMux
 	txsCh         chan core.NewTxsEvent
@@ -139,6 +146,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		txpool:     config.TxPool,
 		chain:      config.Chain,
 		peers:      newPeerSet(),
+		scores:     peerscore.NewTracker(mclock.System{}),
 		whitelist:  config.Whitelist,
 		txsyncCh:   make(chan *txsync),
 		quitSync:   make(chan struct{}),
@@ -184,7 +192,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 	if atomic.LoadUint32(&h.fastSync) == 1 && atomic.LoadUint32(&h.snapSync) == 0 {
 		h.stateBloom = trie.NewSyncBloom(config.BloomCache, config.Database)
 	}
-	h.downloader = downloader.New(h.checkpointNumber, config.Database, h.stateBloom, h.eventMux, h.chain, nil, h.removePeer)
+	h.downloader = downloader.New(h.checkpointNumber, config.Database, h.stateBloom, h.eventMux, h.chain, nil, h.removePeer, h.scores)
 
 	// Construct the fetcher (short sync)
 	validator := func(header *types.Header) error {
@@ -219,7 +227,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return n, err
 	}
-	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.removePeer)
+	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.removePeer, h.scores)
 
 	fetchTx := func(peer string, hashes []common.Hash) error {
 		p := h.peers.peer(peer)
@@ -228,7 +236,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return p.RequestTxs(hashes)
 	}
-	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotes, fetchTx)
+	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotes, fetchTx, h.scores)
 	h.chainSync = newChainSyncer(h)
 	return h, nil
 }
@@ -274,9 +282,13 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 			}
 		}
 	}
-	// Ignore maxPeers if this is a trusted peer
+	// Ignore maxPeers if this is a trusted peer. Otherwise if all slots are
+	// taken, try to make room by evicting a worse behaving peer.
 	if !peer.Peer.Info().Network.Trusted {
-		if reject || h.peers.len() >= h.maxPeers {
+		if reject {
+			return p2p.DiscTooManyPeers
+		}
+		if h.peers.len() >= h.maxPeers && !h.evictPeer(h.scores.Score(peer.ID())) {
 			return p2p.DiscTooManyPeers
 		}
 	}
@@ -319,6 +331,7 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 		// Start a timer to disconnect if the peer doesn't reply in time
 		p.syncDrop = time.AfterFunc(syncChallengeTimeout, func() {
 			peer.Log().Warn("Checkpoint challenge timed out, dropping", "addr", peer.RemoteAddr(), "type", peer.Name())
+			h.scores.Record(peer.ID(), peerscore.Timeout)
 			h.removePeer(peer.ID())
 		})
 		// Make sure it's cleaned up if the peer dies off
@@ -388,6 +401,48 @@ func (h *handler) removePeer(id string) {
 	peer.Peer.Disconnect(p2p.DiscUselessPeer)
 }
 
+// evictPeer disconnects the lowest scoring untrusted peer if its score is below
+// both the eviction threshold and the given limit. It reports whether a peer was
+// evicted.
+func (h *handler) evictPeer(limit float64) bool {
+	var (
+		worst      *ethPeer
+		worstScore = math.Min(limit, peerscore.EvictThreshold)
+	)
+	for _, p := range h.peers.all() {
+		if score := h.scores.Score(p.ID()); score < worstScore && !p.Peer.Peer.Info().Network.Trusted {
+			worst, worstScore = p, score
+		}
+	}
+	if worst == nil {
+		return false
+	}
+	worst.Log().Debug("Evicting low scoring peer", "score", worstScore)
+	h.removePeer(worst.ID())
+	return true
+}
+
+// scoreLoop periodically forgets stale peer scores and, if all peer slots are
+// taken, evicts badly behaving peers to make room for better ones.
+func (h *handler) scoreLoop() {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(scoreEvictInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.scores.Prune()
+			if h.peers.len() >= h.maxPeers {
+				h.evictPeer(peerscore.EvictThreshold)
+			}
+		case <-h.quitSync:
+			return
+		}
+	}
+}
+
 func (h *handler) Start(maxPeers int) {
 	h.maxPeers = maxPeers
 
@@ -406,6 +461,10 @@ func (h *handler) Start(maxPeers int) {
 	h.wg.Add(2)
 	go h.chainSync.loop()
 	go h.txsyncLoop64() // TODO(karalabe): Legacy initial tx echange, drop with eth/64.
+
+	// start peer reputation maintenance
+	h.wg.Add(1)
+	go h.scoreLoop()
 }
 
 func (h *handler) Stop() {
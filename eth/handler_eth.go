@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/peerscore"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -48,7 +49,9 @@ func (h *ethHandler) RunPeer(peer *eth.Peer, hand eth.Handler) error {
 // PeerInfo retrieves all known `eth` information about a peer.
 func (h *ethHandler) PeerInfo(id enode.ID) interface{} {
 	if p := h.peers.peer(id.String()); p != nil {
-		return p.info()
+		info := p.info()
+		info.Score = h.scores.Score(p.ID())
+		return info
 	}
 	return nil
 }
@@ -122,6 +125,7 @@ func (h *ethHandler) handleHeaders(peer *eth.Peer, headers []*types.Header) erro
 		// joining the network
 		if atomic.LoadUint32(&h.fastSync) == 1 {
 			peer.Log().Warn("Dropping unsynced node during sync", "addr", peer.RemoteAddr(), "type", peer.Name())
+			h.scores.Record(peer.ID(), peerscore.UselessData)
 			return errors.New("unsynced node cannot serve sync")
 		}
 	}
@@ -136,6 +140,7 @@ func (h *ethHandler) handleHeaders(peer *eth.Peer, headers []*types.Header) erro
 
 			// Validate the header and either drop the peer or continue
 			if headers[0].Hash() != h.checkpointHash {
+				h.scores.Record(peer.ID(), peerscore.InvalidBlock)
 				return errors.New("checkpoint hash mismatch")
 			}
 			return nil
@@ -144,6 +149,7 @@ func (h *ethHandler) handleHeaders(peer *eth.Peer, headers []*types.Header) erro
 		if want, ok := h.whitelist[headers[0].Number.Uint64()]; ok {
 			if hash := headers[0].Hash(); want != hash {
 				peer.Log().Info("Whitelist mismatch, dropping peer", "number", headers[0].Number.Uint64(), "hash", hash, "want", want)
+				h.scores.Record(peer.ID(), peerscore.InvalidBlock)
 				return errors.New("whitelist block mismatch")
 			}
 			peer.Log().Debug("Whitelist block verified", "number", headers[0].Number.Uint64(), "hash", want)
@@ -31,6 +31,7 @@ type ethPeerInfo struct {
 	Version    uint     `json:"version"`    // Ethereum protocol version negotiated
 	Difficulty *big.Int `json:"difficulty"` // Total difficulty of the peer's blockchain
 	Head       string   `json:"head"`       // Hex hash of the peer's best owned block
+	Score      float64  `json:"score"`      // Reputation score of the peer
 }
 
 // ethPeer is a wrapper around eth.Peer to maintain a few extra metadata.
@@ -0,0 +1,195 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package peerscore implements reputation tracking for `eth` protocol peers.
+//
+// Subsystems interacting with remote peers (message handling, the block and
+// transaction fetchers and the downloader) report good and bad behaviour as
+// events. Every event kind carries a fixed weight, and the accumulated score
+// decays exponentially towards zero so that old behaviour is gradually
+// forgotten. Scores are retained after a peer disconnects until they decay,
+// so a misbehaving peer can't reset its reputation by reconnecting.
+package peerscore
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Event is a kind of peer behaviour reported to the tracker.
+type Event uint8
+
+const (
+	UsefulBlock      Event = iota // Propagated block was imported
+	UsefulTxs                     // Delivered transactions were added to the pool
+	UsefulData                    // Requested sync data was delivered
+	Timeout                       // Request was not answered in time
+	UselessData                   // Delivered data that was not requested or is empty
+	UndeliveredBlock              // Announced block was not delivered on request
+	InvalidTxs                    // Relayed transactions failed stateless validation
+	InvalidBlock                  // Delivered header or block failed verification
+
+	eventCount
+)
+
+var eventNames = [eventCount]string{
+	UsefulBlock:      "usefulblock",
+	UsefulTxs:        "usefultxs",
+	UsefulData:       "usefuldata",
+	Timeout:          "timeout",
+	UselessData:      "uselessdata",
+	UndeliveredBlock: "undeliveredblock",
+	InvalidTxs:       "invalidtxs",
+	InvalidBlock:     "invalidblock",
+}
+
+// eventWeights is the score change caused by a single event of each kind.
+var eventWeights = [eventCount]float64{
+	UsefulBlock:      5,
+	UsefulTxs:        0.5,
+	UsefulData:       1,
+	Timeout:          -5,
+	UselessData:      -2,
+	UndeliveredBlock: -5,
+	InvalidTxs:       -3,
+	InvalidBlock:     -50,
+}
+
+var eventMeters [eventCount]metrics.Meter
+
+func init() {
+	for ev, name := range eventNames {
+		eventMeters[ev] = metrics.NewRegisteredMeter("eth/peerscore/"+name, nil)
+	}
+}
+
+// String returns the name of the event.
+func (ev Event) String() string {
+	if ev >= eventCount {
+		return "unknown"
+	}
+	return eventNames[ev]
+}
+
+const (
+	MinScore = -100 // Lower bound of peer scores
+	MaxScore = 100  // Upper bound of peer scores
+
+	// EvictThreshold is the score below which a peer may be disconnected to make
+	// room for better ones when all peer slots are taken.
+	EvictThreshold = -20
+
+	halfLife   = 10 * time.Minute // Time after which a score decays to half its value
+	pruneLimit = 0.1              // Absolute score below which entries are forgotten
+)
+
+// Tracker maintains the scores of remote peers. All methods are safe for
+// concurrent use and may be called on a nil tracker, which ignores events and
+// reports a neutral score for every peer.
+type Tracker struct {
+	clock mclock.Clock
+	peers map[string]*score
+	lock  sync.Mutex
+}
+
+// score is the decaying score of a single peer.
+type score struct {
+	value   float64
+	updated mclock.AbsTime
+}
+
+// NewTracker creates an empty score tracker.
+func NewTracker(clock mclock.Clock) *Tracker {
+	return &Tracker{
+		clock: clock,
+		peers: make(map[string]*score),
+	}
+}
+
+// Record applies the weight of the given event to the score of a peer.
+func (t *Tracker) Record(id string, ev Event) {
+	if t == nil || ev >= eventCount {
+		return
+	}
+	eventMeters[ev].Mark(1)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.clock.Now()
+	s := t.peers[id]
+	if s == nil {
+		s = &score{updated: now}
+		t.peers[id] = s
+	}
+	s.decay(now)
+	s.value = math.Max(MinScore, math.Min(MaxScore, s.value+eventWeights[ev]))
+}
+
+// Score returns the current score of a peer. Unknown peers have a score of zero.
+func (t *Tracker) Score(id string) float64 {
+	if t == nil {
+		return 0
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	s := t.peers[id]
+	if s == nil {
+		return 0
+	}
+	s.decay(t.clock.Now())
+	return s.value
+}
+
+// Prune forgets all peers whose score has decayed close to zero.
+func (t *Tracker) Prune() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.clock.Now()
+	for id, s := range t.peers {
+		if s.decay(now); math.Abs(s.value) < pruneLimit {
+			delete(t.peers, id)
+		}
+	}
+}
+
+// Len returns the number of peers with a tracked score.
+func (t *Tracker) Len() int {
+	if t == nil {
+		return 0
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return len(t.peers)
+}
+
+// decay scales the score down according to the time elapsed since its last update.
+func (s *score) decay(now mclock.AbsTime) {
+	if elapsed := now.Sub(s.updated); elapsed > 0 {
+		s.value *= math.Exp2(-float64(elapsed) / float64(halfLife))
+		s.updated = now
+	}
+}
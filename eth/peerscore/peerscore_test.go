@@ -0,0 +1,88 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package peerscore
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+func TestTrackerRecord(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		tracker = NewTracker(clock)
+	)
+	tracker.Record("a", UsefulBlock)
+	tracker.Record("a", UsefulData)
+	tracker.Record("b", Timeout)
+	tracker.Record("b", Event(200)) // unknown events are ignored
+
+	if score := tracker.Score("a"); score != 6 {
+		t.Errorf("wrong score for a: have %v, want %v", score, 6)
+	}
+	if score := tracker.Score("b"); score != -5 {
+		t.Errorf("wrong score for b: have %v, want %v", score, -5)
+	}
+	if score := tracker.Score("c"); score != 0 {
+		t.Errorf("wrong score for unknown peer: have %v, want %v", score, 0)
+	}
+	// Scores must be clamped to the allowed range.
+	for i := 0; i < 10; i++ {
+		tracker.Record("b", InvalidBlock)
+	}
+	if score := tracker.Score("b"); score != MinScore {
+		t.Errorf("score not clamped: have %v, want %v", score, MinScore)
+	}
+}
+
+func TestTrackerDecay(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		tracker = NewTracker(clock)
+	)
+	tracker.Record("a", InvalidBlock)
+	tracker.Record("b", UsefulData)
+
+	clock.Run(halfLife)
+	if score := tracker.Score("a"); math.Abs(score+25) > 1e-9 {
+		t.Errorf("wrong score after one half-life: have %v, want %v", score, -25)
+	}
+	// Entries which decayed to almost zero are pruned, others are retained.
+	clock.Run(4 * halfLife)
+	tracker.Prune()
+	if n := tracker.Len(); n != 1 {
+		t.Fatalf("wrong number of tracked peers after pruning: have %d, want %d", n, 1)
+	}
+	if score := tracker.Score("a"); score >= 0 {
+		t.Errorf("pruned peer with significant score %v", score)
+	}
+}
+
+func TestTrackerNil(t *testing.T) {
+	var tracker *Tracker
+
+	tracker.Record("a", InvalidBlock)
+	tracker.Prune()
+	if score := tracker.Score("a"); score != 0 {
+		t.Errorf("nil tracker returned non-zero score %v", score)
+	}
+	if n := tracker.Len(); n != 0 {
+		t.Errorf("nil tracker returned non-zero length %d", n)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/peerscore"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -229,20 +230,44 @@ func (ps *peerSet) snapLen() int {
 	return ps.snapPeers
 }
 
+// all retrieves a list of all registered peers.
+func (ps *peerSet) all() []*ethPeer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*ethPeer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		list = append(list, p)
+	}
+	return list
+}
+
 // peerWithHighestTD retrieves the known peer with the currently highest total
-// difficulty.
-func (ps *peerSet) peerWithHighestTD() *eth.Peer {
+// difficulty. Peers scoring below the eviction threshold are only picked if no
+// better behaving peer is available, and equal difficulties are decided by score.
+func (ps *peerSet) peerWithHighestTD(scores *peerscore.Tracker) *eth.Peer {
 	ps.lock.RLock()
 	defer ps.lock.RUnlock()
 
 	var (
-		bestPeer *eth.Peer
-		bestTd   *big.Int
+		bestPeer  *eth.Peer
+		bestTd    *big.Int
+		bestScore float64
 	)
 	for _, p := range ps.peers {
-		if _, td := p.Head(); bestPeer == nil || td.Cmp(bestTd) > 0 {
-			bestPeer, bestTd = p.Peer, td
+		_, td := p.Head()
+		score := scores.Score(p.ID())
+
+		switch good, bestGood := score >= peerscore.EvictThreshold, bestScore >= peerscore.EvictThreshold; {
+		case bestPeer == nil, good && !bestGood:
+		case good != bestGood:
+			continue
+		case td.Cmp(bestTd) > 0:
+		case td.Cmp(bestTd) == 0 && score > bestScore:
+		default:
+			continue
 		}
+		bestPeer, bestTd, bestScore = p.Peer, td, score
 	}
 	return bestPeer
 }
@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -50,6 +51,12 @@ func handleGetBlockHeaders66(backend Backend, msg Decoder, peer *Peer) error {
 }
 
 func answerGetBlockHeadersQuery(backend Backend, query *GetBlockHeadersPacket, peer *Peer) []*types.Header {
+	// Gapless queries along the canonical chain can be served with a bulk read
+	if query.Skip == 0 {
+		if headers := answerContiguousHeadersQuery(backend.Chain(), query); headers != nil {
+			return headers
+		}
+	}
 	hashMode := query.Origin.Hash != (common.Hash{})
 	first := true
 	maxNonCanonical := uint64(100)
@@ -135,6 +142,48 @@ func answerGetBlockHeadersQuery(backend Backend, query *GetBlockHeadersPacket, p
 	return headers
 }
 
+// answerContiguousHeadersQuery answers a header query without gaps by reading the
+// requested canonical headers in bulk. It returns nil if the origin is not part of
+// the canonical chain or the range can't be read in full, in which case the query
+// needs to be answered header by header.
+func answerContiguousHeadersQuery(chain *core.BlockChain, query *GetBlockHeadersPacket) []*types.Header {
+	count := query.Amount
+	if count > maxHeadersServe {
+		count = maxHeadersServe
+	}
+	if count == 0 {
+		return nil
+	}
+	origin := query.Origin.Number
+	if query.Origin.Hash != (common.Hash{}) {
+		header := chain.GetHeaderByHash(query.Origin.Hash)
+		if header == nil || chain.GetCanonicalHash(header.Number.Uint64()) != query.Origin.Hash {
+			return nil
+		}
+		origin = header.Number.Uint64()
+	}
+	if !query.Reverse {
+		headers := chain.GetHeadersFrom(origin, count, softResponseLimit)
+		if len(headers) == 0 {
+			return nil
+		}
+		return headers
+	}
+	// Reverse queries are read towards the origin and flipped afterwards. Since the
+	// headers closest to the origin must not be dropped, only accept full ranges.
+	if count > origin+1 {
+		count = origin + 1
+	}
+	headers := chain.GetHeadersFrom(origin+1-count, count, softResponseLimit)
+	if uint64(len(headers)) != count {
+		return nil
+	}
+	for i, j := 0, len(headers)-1; i < j; i, j = i+1, j-1 {
+		headers[i], headers[j] = headers[j], headers[i]
+	}
+	return headers
+}
+
 func handleGetBlockBodies(backend Backend, msg Decoder, peer *Peer) error {
 	// Decode the block body retrieval message
 	var query GetBlockBodiesPacket
@@ -49,6 +49,13 @@ func (eth *Ethereum) stateAtBlock(block *types.Block, reexec uint64, base *state
 			return statedb, nil
 		}
 	}
+	// If the state history covers the block, roll the snapshot back instead of
+	// reexecuting. Historic states can't be committed, so never build on one.
+	if eth.config.StateHistory > 0 && (base == nil || base.Historic()) {
+		if statedb, err = eth.blockchain.HistoricStateAt(block.Header()); err == nil {
+			return statedb, nil
+		}
+	}
 	if base != nil {
 		// The optional base statedb is given, mark the start point as parent block
 		statedb, database, report = base, base.Database(), false
@@ -17,11 +17,14 @@
 package eth
 
 import (
+	"math/big"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/peerscore"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -70,7 +73,7 @@ func testFastSyncDisabling(t *testing.T, protocol uint) {
 	time.Sleep(250 * time.Millisecond)
 
 	// Check that fast sync was disabled
-	op := peerToSyncOp(downloader.FastSync, empty.handler.peers.peerWithHighestTD())
+	op := peerToSyncOp(downloader.FastSync, empty.handler.peers.peerWithHighestTD(empty.handler.scores))
 	if err := empty.handler.doSync(op); err != nil {
 		t.Fatal("sync failed:", err)
 	}
@@ -78,3 +81,72 @@ func testFastSyncDisabling(t *testing.T, protocol uint) {
 		t.Fatalf("fast sync not disabled after successful synchronisation")
 	}
 }
+
+// Tests that sync peer selection prefers well behaving peers over ones with a
+// bad reputation, and that low scoring peers are evicted to make room.
+func TestPeerScoringSelection(t *testing.T) {
+	t.Parallel()
+
+	handler := newTestHandler()
+	defer handler.close()
+
+	var (
+		genesis = handler.chain.Genesis()
+		forkID  = forkid.NewIDWithChain(handler.chain)
+		filter  = forkid.NewFilter(handler.chain)
+	)
+	tds := []int64{100, 300, 200, 200}
+	peers := make([]*eth.Peer, len(tds))
+	for i, td := range tds {
+		app, net := p2p.MsgPipe()
+		defer app.Close()
+		defer net.Close()
+
+		peers[i] = eth.NewPeer(eth.ETH65, p2p.NewPeer(enode.ID{byte(i + 1)}, "", nil), app, handler.txpool)
+		defer peers[i].Close()
+
+		remote := eth.NewPeer(eth.ETH65, p2p.NewPeer(enode.ID{}, "", nil), net, handler.txpool)
+		defer remote.Close()
+
+		// Run a handshake to have the local peer learn the remote difficulty
+		errc := make(chan error, 1)
+		go func() {
+			errc <- remote.Handshake(1, big.NewInt(td), genesis.Hash(), genesis.Hash(), forkID, filter)
+		}()
+		if err := peers[i].Handshake(1, handler.chain.GetTd(genesis.Hash(), 0), genesis.Hash(), genesis.Hash(), forkID, filter); err != nil {
+			t.Fatalf("failed to run handshake %d: %v", i, err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("failed to run remote handshake %d: %v", i, err)
+		}
+
+		if err := handler.handler.peers.registerPeer(peers[i], nil); err != nil {
+			t.Fatalf("failed to register peer %d: %v", i, err)
+		}
+	}
+	scores := handler.handler.scores
+	if best := handler.handler.peers.peerWithHighestTD(scores); best != peers[1] {
+		t.Fatalf("best peer mismatch: have %v, want %v", best.ID(), peers[1].ID())
+	}
+	// Break the tie between the two remaining peers by reputation
+	for i := 0; i < 10; i++ {
+		scores.Record(peers[1].ID(), peerscore.InvalidBlock)
+		scores.Record(peers[3].ID(), peerscore.UsefulBlock)
+	}
+	if best := handler.handler.peers.peerWithHighestTD(scores); best != peers[3] {
+		t.Fatalf("best peer mismatch: have %v, want %v", best.ID(), peers[3].ID())
+	}
+	// Ensure eviction only picks peers below the threshold and the given limit
+	if handler.handler.evictPeer(peerscore.MinScore) {
+		t.Fatalf("evicted peer scoring above limit")
+	}
+	if !handler.handler.evictPeer(0) {
+		t.Fatalf("failed to evict bad peer")
+	}
+	if handler.handler.peers.peer(peers[1].ID()) != nil {
+		t.Fatalf("bad peer not evicted")
+	}
+	if handler.handler.evictPeer(0) {
+		t.Fatalf("evicted well behaving peer")
+	}
+}
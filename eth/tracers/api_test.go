@@ -198,7 +198,7 @@ func TestTraceCall(t *testing.T) {
 	var testSuite = []struct {
 		blockNumber rpc.BlockNumber
 		call        ethapi.CallArgs
-		config      *TraceConfig
+		config      *TraceCallConfig
 		expectErr   error
 		expect      interface{}
 	}{
@@ -305,6 +305,93 @@ func TestTraceCall(t *testing.T) {
 	}
 }
 
+func TestTraceCallWithOverrides(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts
+	accounts := newAccounts(3)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		accounts[1].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {}))
+
+	var (
+		// Contract returning the current block number
+		number = &hexutil.Bytes{
+			byte(vm.NUMBER), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+		}
+		richer = (*hexutil.Big)(big.NewInt(params.Ether))
+		latest = rpc.LatestBlockNumber
+	)
+	var testSuite = []struct {
+		call   ethapi.CallArgs
+		config *TraceCallConfig
+		expect string
+		failed bool
+	}{
+		// Transfer from an account without funds, fails without overrides
+		{
+			call: ethapi.CallArgs{
+				From:  &accounts[2].addr,
+				To:    &accounts[1].addr,
+				Value: (*hexutil.Big)(big.NewInt(1000)),
+			},
+			failed: true,
+		},
+		// Transfer from an account without funds, balance overridden
+		{
+			call: ethapi.CallArgs{
+				From:  &accounts[2].addr,
+				To:    &accounts[1].addr,
+				Value: (*hexutil.Big)(big.NewInt(1000)),
+			},
+			config: &TraceCallConfig{
+				StateOverrides: &ethapi.StateOverride{
+					accounts[2].addr: ethapi.OverrideAccount{Balance: &richer},
+				},
+			},
+		},
+		// Patched contract code executed in an overridden block context
+		{
+			call: ethapi.CallArgs{
+				From: &accounts[0].addr,
+				To:   &accounts[1].addr,
+			},
+			config: &TraceCallConfig{
+				StateOverrides: &ethapi.StateOverride{
+					accounts[1].addr: ethapi.OverrideAccount{Code: number},
+				},
+				BlockOverrides: &ethapi.BlockOverrides{
+					Number: (*hexutil.Big)(big.NewInt(0x1337)),
+				},
+			},
+			expect: fmt.Sprintf("%064x", 0x1337),
+		},
+	}
+	for i, testspec := range testSuite {
+		result, err := api.TraceCall(context.Background(), testspec.call, rpc.BlockNumberOrHash{BlockNumber: &latest}, testspec.config)
+		if testspec.failed {
+			if err == nil {
+				t.Errorf("test %d: expect error, get nothing", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: expect no error, get %v", i, err)
+			continue
+		}
+		res := result.(*ethapi.ExecutionResult)
+		if res.Failed {
+			t.Errorf("test %d: execution failed", i)
+		}
+		if res.ReturnValue != testspec.expect {
+			t.Errorf("test %d: return value mismatch, want %v, get %v", i, testspec.expect, res.ReturnValue)
+		}
+	}
+}
+
 func TestTraceTransaction(t *testing.T) {
 	t.Parallel()
 
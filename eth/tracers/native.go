@@ -0,0 +1,87 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+)
+
+// nativeTracer is a vm.Tracer implemented in Go. Just like the JavaScript Tracer,
+// it can be interrupted and returns its result JSON encoded.
+type nativeTracer interface {
+	vm.Tracer
+
+	// GetResult returns the JSON encoded result of the trace, or any error
+	// accumulated while tracing.
+	GetResult() (json.RawMessage, error)
+
+	// Stop terminates the tracing, making GetResult return the given error.
+	Stop(err error)
+}
+
+// nativeTracers contains all the built-in tracers implemented in Go by name.
+// They take precedence over the JavaScript tracers of the same name and produce
+// the exact same output.
+var nativeTracers = map[string]func() nativeTracer{
+	"callTracer":     newCallTracer,
+	"prestateTracer": newPrestateTracer,
+	"4byteTracer":    newFourByteTracer,
+}
+
+// isPrecompiled checks whether the given address is one of the precompiled
+// contracts, mirroring the isPrecompiled helper of the JavaScript tracers.
+func isPrecompiled(addr common.Address) bool {
+	_, ok := vm.PrecompiledContractsIstanbul[addr]
+	return ok
+}
+
+// stackPeek returns the n-th item from the top of the stack, or zero if the
+// stack is not deep enough.
+func stackPeek(stack *vm.Stack, n int) *uint256.Int {
+	data := stack.Data()
+	if n < 0 || len(data) <= n {
+		return new(uint256.Int)
+	}
+	return &data[len(data)-1-n]
+}
+
+// stackPeekUint64 returns the n-th item from the top of the stack as an uint64,
+// saturating at the maximum value if it doesn't fit.
+func stackPeekUint64(stack *vm.Stack, n int) uint64 {
+	val := stackPeek(stack, n)
+	if !val.IsUint64() {
+		return math.MaxUint64
+	}
+	return val.Uint64()
+}
+
+// memorySlice returns a copy of the given memory range, or nil if the range
+// is out of bounds.
+func memorySlice(memory *vm.Memory, begin, end uint64) []byte {
+	if end == begin {
+		return []byte{}
+	}
+	if end < begin || uint64(memory.Len()) < end {
+		return nil
+	}
+	return memory.GetCopy(int64(begin), int64(end-begin))
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// fourByteTracer is the native Go implementation of the JavaScript 4byteTracer.
+// It searches for 4byte-identifiers and collects them for post-processing,
+// keyed by the identifier and the size of the remaining call data.
+type fourByteTracer struct {
+	ids   map[string]int // ids aggregates the 4byte ids found
+	input []byte         // Call data of the outer transaction
+
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newFourByteTracer creates a new native 4byte tracer.
+func newFourByteTracer() nativeTracer {
+	return &fourByteTracer{ids: make(map[string]int)}
+}
+
+// store saves the given identifier and datasize.
+func (t *fourByteTracer) store(id []byte, size uint64) {
+	t.ids[fmt.Sprintf("%s-%d", hexutil.Bytes(id), size)]++
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *fourByteTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.input = common.CopyBytes(input)
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *fourByteTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// If tracing was interrupted, abort the execution
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		env.Cancel()
+		return
+	}
+	// Skip any opcodes that are not internal calls, retrieving the stack
+	// position of the input offset for the ones that are
+	var inOffIdx int
+	switch op {
+	case vm.CALL, vm.CALLCODE:
+		inOffIdx = 3 // gas, addr, val, memin, meminsz, memout, memoutsz
+	case vm.DELEGATECALL, vm.STATICCALL:
+		inOffIdx = 2 // gas, addr, memin, meminsz, memout, memoutsz
+	default:
+		return
+	}
+	stack := scope.Stack
+
+	// Skip any pre-compile invocations, those are just fancy opcodes
+	if isPrecompiled(common.Address(stackPeek(stack, 1).Bytes20())) {
+		return
+	}
+	// Gather internal call details
+	if inSz := stackPeekUint64(stack, inOffIdx+1); inSz >= 4 {
+		inOff := stackPeekUint64(stack, inOffIdx)
+		t.store(memorySlice(scope.Memory, inOff, inOff+4), inSz-4)
+	}
+}
+
+// CaptureFault implements the vm.Tracer interface, faults don't affect the ids.
+func (t *fourByteTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *fourByteTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+}
+
+// GetResult returns the collected identifiers, or the reason the tracing was
+// interrupted.
+func (t *fourByteTracer) GetResult() (json.RawMessage, error) {
+	if t.reason != nil {
+		return nil, t.reason
+	}
+	// Save the outer calldata also
+	if len(t.input) >= 4 {
+		t.store(t.input[:4], uint64(len(t.input)-4))
+	}
+	return json.Marshal(t.ids)
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *fourByteTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
@@ -0,0 +1,290 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+const (
+	executionReverted = "execution reverted" // Error of a call terminated by REVERT
+	internalFailure   = "internal failure"   // Error of a call failing without a reason
+)
+
+// callFrame is a single call in the call tree assembled by the callTracer. The
+// fields are ordered the same way as the JavaScript callTracer serializes them.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    *common.Address `json:"from,omitempty"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     *hexutil.Uint64 `json:"gas,omitempty"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Input   *hexutil.Bytes  `json:"input,omitempty"`
+	Output  *hexutil.Bytes  `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Time    string          `json:"time,omitempty"`
+	Calls   []*callFrame    `json:"calls,omitempty"`
+
+	gasIn   uint64 // Gas available when the call was made
+	gasCost uint64 // Cost of the call opcode itself
+	outOff  uint64 // Memory offset of the return data
+	outLen  uint64 // Length of the return data
+}
+
+// callTracer is the native Go implementation of the JavaScript callTracer. It
+// tracks all the internal calls made by a transaction and assembles them into
+// a call tree.
+type callTracer struct {
+	callstack []*callFrame // Current recursive call stack of the EVM execution
+	descended bool         // Whether we've just descended into an inner call
+
+	ctx       *callFrame // Outermost call, assembled from the capture events
+	interrupt uint32     // Atomic flag to signal execution interruption
+	reason    error      // Textual reason for the interruption
+}
+
+// newCallTracer creates a new native call tracer.
+func newCallTracer() nativeTracer {
+	return &callTracer{callstack: []*callFrame{{}}}
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *callTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	typ := "CALL"
+	if create {
+		typ = "CREATE"
+	}
+	t.ctx = &callFrame{
+		Type:  typ,
+		From:  &from,
+		To:    &to,
+		Value: (*hexutil.Big)(new(big.Int).Set(value)),
+		Gas:   (*hexutil.Uint64)(&gas),
+		Input: (*hexutil.Bytes)(&input),
+	}
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// If tracing was interrupted, abort the execution
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		env.Cancel()
+		return
+	}
+	// Capture any errors immediately
+	if err != nil {
+		t.fault(err)
+		return
+	}
+	var (
+		stack  = scope.Stack
+		memory = scope.Memory
+		self   = scope.Contract.Address()
+	)
+	// We only care about system opcodes, faster if we pre-check once
+	syscall := op&0xf0 == 0xf0
+
+	// If a new contract is being created, add to the call stack
+	if syscall && (op == vm.CREATE || op == vm.CREATE2) {
+		inOff := stackPeekUint64(stack, 1)
+		input := hexutil.Bytes(memorySlice(memory, inOff, inOff+stackPeekUint64(stack, 2)))
+
+		t.callstack = append(t.callstack, &callFrame{
+			Type:    op.String(),
+			From:    &self,
+			Input:   &input,
+			Value:   (*hexutil.Big)(stackPeek(stack, 0).ToBig()),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return
+	}
+	// If a contract is being self destructed, gather that as a subcall too
+	if syscall && op == vm.SELFDESTRUCT {
+		to := common.Address(stackPeek(stack, 0).Bytes20())
+
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, &callFrame{
+			Type:  op.String(),
+			From:  &self,
+			To:    &to,
+			Value: (*hexutil.Big)(new(big.Int).Set(env.StateDB.GetBalance(self))),
+		})
+		return
+	}
+	// If a new method invocation is being done, add to the call stack
+	if syscall && (op == vm.CALL || op == vm.CALLCODE || op == vm.DELEGATECALL || op == vm.STATICCALL) {
+		// Skip any pre-compile invocations, those are just fancy opcodes
+		to := common.Address(stackPeek(stack, 1).Bytes20())
+		if isPrecompiled(to) {
+			return
+		}
+		off := 1
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		inOff := stackPeekUint64(stack, 2+off)
+		input := hexutil.Bytes(memorySlice(memory, inOff, inOff+stackPeekUint64(stack, 3+off)))
+
+		call := &callFrame{
+			Type:    op.String(),
+			From:    &self,
+			To:      &to,
+			Input:   &input,
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  stackPeekUint64(stack, 4+off),
+			outLen:  stackPeekUint64(stack, 5+off),
+		}
+		if op != vm.DELEGATECALL && op != vm.STATICCALL {
+			call.Value = (*hexutil.Big)(stackPeek(stack, 2).ToBig())
+		}
+		t.callstack = append(t.callstack, call)
+		t.descended = true
+		return
+	}
+	// If we've just descended into an inner call, retrieve it's true allowance. We
+	// need to extract if from within the call as there may be funky gas dynamics
+	// with regard to requested and actually given gas (2300 stipend, 63/64 rule).
+	if t.descended {
+		if depth >= len(t.callstack) {
+			t.callstack[len(t.callstack)-1].Gas = (*hexutil.Uint64)(&gas)
+		}
+		t.descended = false
+	}
+	// If an existing call is returning, pop off the call stack
+	if syscall && op == vm.REVERT {
+		t.callstack[len(t.callstack)-1].Error = executionReverted
+		return
+	}
+	if depth == len(t.callstack)-1 {
+		// Pop off the last call and get the execution results
+		call := t.callstack[len(t.callstack)-1]
+		t.callstack = t.callstack[:len(t.callstack)-1]
+
+		ret := stackPeek(stack, 0)
+		if call.Type == vm.CREATE.String() || call.Type == vm.CREATE2.String() {
+			// If the call was a CREATE, retrieve the contract address and output code
+			used := hexutil.Uint64(call.gasIn - call.gasCost - gas)
+			call.GasUsed = &used
+
+			if !ret.IsZero() {
+				to := common.Address(ret.Bytes20())
+				code := hexutil.Bytes(env.StateDB.GetCode(to))
+				call.To, call.Output = &to, &code
+			} else if call.Error == "" {
+				call.Error = internalFailure
+			}
+		} else {
+			// If the call was a contract call, retrieve the gas usage and output
+			if call.Gas != nil {
+				used := hexutil.Uint64(call.gasIn - call.gasCost + uint64(*call.Gas) - gas)
+				call.GasUsed = &used
+			}
+			if !ret.IsZero() {
+				output := hexutil.Bytes(memorySlice(memory, call.outOff, call.outOff+call.outLen))
+				call.Output = &output
+			} else if call.Error == "" {
+				call.Error = internalFailure
+			}
+		}
+		// Inject the call into the previous one
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+	}
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *callTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		return
+	}
+	t.fault(err)
+}
+
+// fault handles an execution fault of the currently running call.
+func (t *callTracer) fault(err error) {
+	// If the topmost call already reverted, don't handle the additional fault again
+	if t.callstack[len(t.callstack)-1].Error != "" {
+		return
+	}
+	// Pop off the just failed call
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+	call.Error = err.Error()
+
+	// Consume all available gas and clean any leftovers
+	if call.Gas != nil {
+		call.GasUsed = call.Gas
+	}
+	// Flatten the failed call into its parent
+	if len(t.callstack) > 0 {
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+		return
+	}
+	// Last call failed too, leave it in the stack
+	t.callstack = append(t.callstack, call)
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+	t.ctx.GasUsed = (*hexutil.Uint64)(&gasUsed)
+	t.ctx.Output = (*hexutil.Bytes)(&output)
+	t.ctx.Time = d.String()
+
+	if err != nil {
+		t.ctx.Error = err.Error()
+	}
+}
+
+// GetResult returns the assembled call tree, or the reason the tracing was
+// interrupted.
+func (t *callTracer) GetResult() (json.RawMessage, error) {
+	if t.reason != nil {
+		return nil, t.reason
+	}
+	if t.ctx == nil {
+		return nil, errors.New("no call captured")
+	}
+	result := *t.ctx
+	result.Calls = t.callstack[0].Calls
+	if t.callstack[0].Error != "" {
+		result.Error = t.callstack[0].Error
+	}
+	if result.Error != "" && (result.Error != executionReverted || len(*result.Output) == 0) {
+		result.Output = nil
+	}
+	return json.Marshal(&result)
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *callTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
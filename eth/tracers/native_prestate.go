@@ -0,0 +1,184 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// prestateAccount is the state of a single account before the traced
+// transaction was executed.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// prestateTracer is the native Go implementation of the JavaScript
+// prestateTracer. It collects every account and storage slot the transaction
+// touches, and reassembles the state they were in before the execution.
+type prestateTracer struct {
+	env      *vm.EVM
+	prestate map[common.Address]*prestateAccount // Genesis-like allocations being built
+
+	create       bool // Whether the transaction is a contract creation
+	from, to     common.Address
+	value        *big.Int // Value transferred by the transaction
+	gasUsed      uint64   // Gas used by the execution, excluding the intrinsic gas
+	intrinsicGas uint64   // Intrinsic gas of the transaction
+
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newPrestateTracer creates a new native prestate tracer.
+func newPrestateTracer() nativeTracer {
+	return new(prestateTracer)
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	t.create, t.from, t.to = create, from, to
+	t.value = new(big.Int).Set(value)
+
+	// Compute intrinsic gas
+	isHomestead := env.ChainConfig().IsHomestead(env.Context.BlockNumber)
+	isIstanbul := env.ChainConfig().IsIstanbul(env.Context.BlockNumber)
+	t.intrinsicGas, _ = core.IntrinsicGas(input, nil, create, isHomestead, isIstanbul)
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// If tracing was interrupted, abort the execution
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		env.Cancel()
+		return
+	}
+	var (
+		stack = scope.Stack
+		self  = scope.Contract.Address()
+	)
+	// Add the current account if we just started tracing. Balance will
+	// potentially be wrong here, since this will include the value sent
+	// along with the message. We fix that in GetResult.
+	if t.prestate == nil {
+		t.prestate = make(map[common.Address]*prestateAccount)
+		t.lookupAccount(self)
+	}
+	// Whenever new state is accessed, add it to the prestate
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.BALANCE:
+		t.lookupAccount(common.Address(stackPeek(stack, 0).Bytes20()))
+
+	case vm.CREATE:
+		t.lookupAccount(crypto.CreateAddress(self, env.StateDB.GetNonce(self)))
+
+	case vm.CREATE2:
+		// stack: endowment, offset, size, salt
+		offset := stackPeekUint64(stack, 1)
+		code := memorySlice(scope.Memory, offset, offset+stackPeekUint64(stack, 2))
+		salt := stackPeek(stack, 3).Bytes32()
+		t.lookupAccount(crypto.CreateAddress2(self, salt, crypto.Keccak256(code)))
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(common.Address(stackPeek(stack, 1).Bytes20()))
+
+	case vm.SSTORE, vm.SLOAD:
+		t.lookupStorage(self, common.Hash(stackPeek(stack, 0).Bytes32()))
+	}
+}
+
+// CaptureFault implements the vm.Tracer interface, faults don't affect the prestate.
+func (t *prestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+	t.gasUsed = gasUsed
+}
+
+// lookupAccount injects the specified account into the prestate.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.prestate[addr]; ok {
+		return
+	}
+	t.prestate[addr] = &prestateAccount{
+		Balance: (*hexutil.Big)(new(big.Int).Set(t.env.StateDB.GetBalance(addr))),
+		Nonce:   t.env.StateDB.GetNonce(addr),
+		Code:    t.env.StateDB.GetCode(addr),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+}
+
+// lookupStorage injects the specified storage entry of the given account into
+// the prestate.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+	if _, ok := t.prestate[addr].Storage[key]; ok {
+		return
+	}
+	t.prestate[addr].Storage[key] = t.env.StateDB.GetState(addr, key)
+}
+
+// GetResult returns the assembled prestate, or the reason the tracing was
+// interrupted.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	if t.reason != nil {
+		return nil, t.reason
+	}
+	if t.prestate == nil {
+		t.prestate = make(map[common.Address]*prestateAccount)
+	}
+	// At this point, we need to deduct the 'value' from the outer transaction,
+	// and move it back to the origin
+	t.lookupAccount(t.from)
+	t.lookupAccount(t.to)
+
+	var (
+		fromBal = t.prestate[t.from].Balance.ToInt()
+		toBal   = t.prestate[t.to].Balance.ToInt()
+		fee     = new(big.Int).Mul(new(big.Int).SetUint64(t.gasUsed+t.intrinsicGas), t.env.TxContext.GasPrice)
+	)
+	t.prestate[t.to].Balance = (*hexutil.Big)(new(big.Int).Sub(toBal, t.value))
+	t.prestate[t.from].Balance = (*hexutil.Big)(new(big.Int).Add(new(big.Int).Add(fromBal, t.value), fee))
+
+	// Decrement the caller's nonce, and remove empty create targets
+	t.prestate[t.from].Nonce--
+	if t.create {
+		// We can blindly delete the contract prestate, as any existing state would
+		// have caused the transaction to be rejected as invalid in the first place.
+		delete(t.prestate, t.to)
+	}
+	return json.Marshal(t.prestate)
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *prestateTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
@@ -20,6 +20,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"math/big"
 	"path/filepath"
@@ -204,6 +205,77 @@ func TestPrestateTracerCreate2(t *testing.T) {
 // Iterates over all the input-output datasets in the tracer test harness and
 // runs the JavaScript tracers against them.
 func TestCallTracer(t *testing.T) {
+	testCallTracer(t, func(txContext vm.TxContext) (nativeTracer, error) {
+		return New("callTracer", txContext)
+	})
+}
+
+// Iterates over all the input-output datasets in the tracer test harness and
+// runs the native Go tracers against them.
+func TestCallTracerNative(t *testing.T) {
+	testCallTracer(t, func(vm.TxContext) (nativeTracer, error) {
+		return newCallTracer(), nil
+	})
+}
+
+func testCallTracer(t *testing.T, newTracer func(txContext vm.TxContext) (nativeTracer, error)) {
+	forEachCallTracerTest(t, func(t *testing.T, test *callTracerTest) {
+		// Create the tracer and run the transaction through it
+		res, err := runCallTracerTest(test, newTracer)
+		if err != nil {
+			t.Fatalf("failed to trace transaction: %v", err)
+		}
+		ret := new(callTrace)
+		if err := json.Unmarshal(res, ret); err != nil {
+			t.Fatalf("failed to unmarshal trace result: %v", err)
+		}
+
+		if !jsonEqual(ret, test.Result) {
+			// uncomment this for easier debugging
+			//have, _ := json.MarshalIndent(ret, "", " ")
+			//want, _ := json.MarshalIndent(test.Result, "", " ")
+			//t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", string(have), string(want))
+			t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", ret, test.Result)
+		}
+	})
+}
+
+// Iterates over all the input-output datasets in the tracer test harness and
+// checks that the native Go tracers produce the same output as the JavaScript
+// ones for the tracers without a dedicated dataset.
+func TestNativeTracersMatchJavaScript(t *testing.T) {
+	for _, name := range []string{"prestateTracer", "4byteTracer"} {
+		name := name // capture range variable
+		forEachCallTracerTest(t, func(t *testing.T, test *callTracerTest) {
+			want, err := runCallTracerTest(test, func(txContext vm.TxContext) (nativeTracer, error) {
+				return New(name, txContext)
+			})
+			if err != nil {
+				t.Fatalf("failed to trace transaction with JavaScript %s: %v", name, err)
+			}
+			have, err := runCallTracerTest(test, func(vm.TxContext) (nativeTracer, error) {
+				return nativeTracers[name](), nil
+			})
+			if err != nil {
+				t.Fatalf("failed to trace transaction with native %s: %v", name, err)
+			}
+			var wantObj, haveObj interface{}
+			if err := json.Unmarshal(want, &wantObj); err != nil {
+				t.Fatalf("failed to unmarshal JavaScript trace result: %v", err)
+			}
+			if err := json.Unmarshal(have, &haveObj); err != nil {
+				t.Fatalf("failed to unmarshal native trace result: %v", err)
+			}
+			if !reflect.DeepEqual(haveObj, wantObj) {
+				t.Fatalf("%s mismatch: \nhave %s\nwant %s", name, have, want)
+			}
+		})
+	}
+}
+
+// forEachCallTracerTest loads all the call tracer datasets and runs the given
+// function on each of them as a parallel subtest.
+func forEachCallTracerTest(t *testing.T, fn func(t *testing.T, test *callTracerTest)) {
 	files, err := ioutil.ReadDir("testdata")
 	if err != nil {
 		t.Fatalf("failed to retrieve tracer test suite: %v", err)
@@ -225,62 +297,53 @@ func TestCallTracer(t *testing.T) {
 			if err := json.Unmarshal(blob, test); err != nil {
 				t.Fatalf("failed to parse testcase: %v", err)
 			}
-			// Configure a blockchain with the given prestate
-			tx := new(types.Transaction)
-			if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
-				t.Fatalf("failed to parse testcase input: %v", err)
-			}
-			signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
-			origin, _ := signer.Sender(tx)
-			txContext := vm.TxContext{
-				Origin:   origin,
-				GasPrice: tx.GasPrice(),
-			}
-			context := vm.BlockContext{
-				CanTransfer: core.CanTransfer,
-				Transfer:    core.Transfer,
-				Coinbase:    test.Context.Miner,
-				BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
-				Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
-				Difficulty:  (*big.Int)(test.Context.Difficulty),
-				GasLimit:    uint64(test.Context.GasLimit),
-			}
-			_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
+			fn(t, test)
+		})
+	}
+}
 
-			// Create the tracer, the EVM environment and run it
-			tracer, err := New("callTracer", txContext)
-			if err != nil {
-				t.Fatalf("failed to create call tracer: %v", err)
-			}
-			evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+// runCallTracerTest executes the transaction of the dataset on top of its
+// prestate with the given tracer, returning the trace result.
+func runCallTracerTest(test *callTracerTest, newTracer func(txContext vm.TxContext) (nativeTracer, error)) (json.RawMessage, error) {
+	// Configure a blockchain with the given prestate
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		return nil, fmt.Errorf("failed to parse testcase input: %v", err)
+	}
+	signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+	origin, _ := signer.Sender(tx)
+	txContext := vm.TxContext{
+		Origin:   origin,
+		GasPrice: tx.GasPrice(),
+	}
+	context := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Coinbase:    test.Context.Miner,
+		BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+		Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		GasLimit:    uint64(test.Context.GasLimit),
+	}
+	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
 
-			msg, err := tx.AsMessage(signer)
-			if err != nil {
-				t.Fatalf("failed to prepare transaction for tracing: %v", err)
-			}
-			st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
-			if _, err = st.TransitionDb(); err != nil {
-				t.Fatalf("failed to execute transaction: %v", err)
-			}
-			// Retrieve the trace result and compare against the etalon
-			res, err := tracer.GetResult()
-			if err != nil {
-				t.Fatalf("failed to retrieve trace result: %v", err)
-			}
-			ret := new(callTrace)
-			if err := json.Unmarshal(res, ret); err != nil {
-				t.Fatalf("failed to unmarshal trace result: %v", err)
-			}
+	// Create the tracer, the EVM environment and run it
+	tracer, err := newTracer(txContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracer: %v", err)
+	}
+	evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
 
-			if !jsonEqual(ret, test.Result) {
-				// uncomment this for easier debugging
-				//have, _ := json.MarshalIndent(ret, "", " ")
-				//want, _ := json.MarshalIndent(test.Result, "", " ")
-				//t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", string(have), string(want))
-				t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", ret, test.Result)
-			}
-		})
+	msg, err := tx.AsMessage(signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, err = st.TransitionDb(); err != nil {
+		return nil, fmt.Errorf("failed to execute transaction: %v", err)
 	}
+	// Retrieve the trace result
+	return tracer.GetResult()
 }
 
 // jsonEqual is similar to reflect.DeepEqual, but does a 'bounce' via json prior to
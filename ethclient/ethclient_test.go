@@ -256,6 +256,9 @@ func TestEthClient(t *testing.T) {
 		"TestGetBlock": {
 			func(t *testing.T) { testGetBlock(t, client) },
 		},
+		"TestBlockReceipts": {
+			func(t *testing.T) { testBlockReceipts(t, chain, client) },
+		},
 		"TestStatusFunctions": {
 			func(t *testing.T) { testStatusFunctions(t, client) },
 		},
@@ -462,6 +465,33 @@ func testStatusFunctions(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testBlockReceipts(t *testing.T, chain []*types.Block, client *rpc.Client) {
+	ec := NewClient(client)
+	head := chain[len(chain)-1]
+
+	// Get receipts by number
+	receipts, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(head.NumberU64())))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(receipts) != len(head.Transactions()) {
+		t.Fatalf("BlockReceipts returned wrong number of receipts: want %d got %d", len(head.Transactions()), len(receipts))
+	}
+	// Get receipts by hash
+	receipts, err = ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithHash(head.Hash(), false))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(receipts) != len(head.Transactions()) {
+		t.Fatalf("BlockReceipts returned wrong number of receipts: want %d got %d", len(head.Transactions()), len(receipts))
+	}
+	// Unknown blocks should be reported as not found
+	_, err = ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(head.NumberU64()+1)))
+	if err != ethereum.NotFound {
+		t.Fatalf("error mismatch: want %v got %v", ethereum.NotFound, err)
+	}
+}
+
 func testCallContract(t *testing.T, client *rpc.Client) {
 	ec := NewClient(client)
 
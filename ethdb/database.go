@@ -19,6 +19,10 @@ package ethdb
 
 import "io"
 
+// NotFoundErrorCode is the RPC error code the database debug methods report for
+// items which don't exist, letting remote readers tell them apart from failures.
+const NotFoundErrorCode = -32001
+
 // KeyValueReader wraps the Has and Get method of a backing data store.
 type KeyValueReader interface {
 	// Has retrieves if a key is present in the key-value data store.
@@ -76,6 +80,16 @@ type AncientReader interface {
 	// Ancient retrieves an ancient binary blob from the append-only immutable files.
 	Ancient(kind string, number uint64) ([]byte, error)
 
+	// AncientRange retrieves multiple consecutive items from the append-only
+	// immutable files, starting at start. It returns at most count items, and
+	// stops early once the combined size of the items would exceed maxBytes, but
+	// always returns at least one item.
+	AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error)
+
+	// AncientTail returns the number of the first item retained in the specified
+	// category of the ancient store.
+	AncientTail(kind string) (uint64, error)
+
 	// Ancients returns the ancient item numbers in the ancient store.
 	Ancients() (uint64, error)
 
@@ -92,6 +106,11 @@ type AncientWriter interface {
 	// TruncateAncients discards all but the first n ancient data from the ancient store.
 	TruncateAncients(n uint64) error
 
+	// TruncateAncientTail discards the items below n from the specified category
+	// of the ancient store. Some of the items below n may be retained, depending
+	// on the storage granularity of the ancient store.
+	TruncateAncientTail(kind string, n uint64) error
+
 	// Sync flushes all in-memory ancient store data to disk.
 	Sync() error
 }
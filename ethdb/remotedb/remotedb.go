@@ -0,0 +1,313 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package remotedb implements a read-only database backed by the debug RPC
+// namespace of a running node.
+//
+// The key-value and ancient reader methods as well as iteration are forwarded to
+// the remote node. All write operations are unsupported.
+package remotedb
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// iteratorPageSize is the number of entries an iterator fetches per request.
+const iteratorPageSize = 1024
+
+var (
+	// errReadOnly is returned if a write operation is attempted on the remote
+	// database.
+	errReadOnly = errors.New("remote database is read-only")
+
+	// errNotSupported is returned if an operation is attempted which cannot be
+	// served through the remote API.
+	errNotSupported = errors.New("operation not supported by remote database")
+)
+
+// Database is a read-only key-value and ancient store accessed through the RPC
+// API of a remote node.
+type Database struct {
+	remote *rpc.Client
+}
+
+// New creates a database forwarding all reads to the given RPC client.
+func New(client *rpc.Client) ethdb.Database {
+	return &Database{remote: client}
+}
+
+// isNotFound reports whether err is the remote node's response for a missing
+// database item.
+func isNotFound(err error) bool {
+	rerr, ok := err.(rpc.Error)
+	return ok && rerr.ErrorCode() == ethdb.NotFoundErrorCode
+}
+
+// Has retrieves if a key is present in the remote database.
+func (db *Database) Has(key []byte) (bool, error) {
+	if _, err := db.Get(key); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Get retrieves the given key from the remote database.
+func (db *Database) Get(key []byte) ([]byte, error) {
+	var resp hexutil.Bytes
+	if err := db.remote.Call(&resp, "debug_dbGet", hexutil.Bytes(key)); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// HasAncient returns an indicator whether the specified data exists in the
+// ancient store of the remote node.
+func (db *Database) HasAncient(kind string, number uint64) (bool, error) {
+	if _, err := db.Ancient(kind, number); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Ancient retrieves an ancient binary blob from the remote ancient store.
+func (db *Database) Ancient(kind string, number uint64) ([]byte, error) {
+	var resp hexutil.Bytes
+	if err := db.remote.Call(&resp, "debug_dbAncient", kind, number); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// AncientRange retrieves multiple consecutive items from the remote ancient store.
+// The size limit is enforced by the remote node, so only the returned items are
+// transferred.
+func (db *Database) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	if count == 0 {
+		return nil, nil
+	}
+	var resp []hexutil.Bytes
+	if err := db.remote.Call(&resp, "debug_dbAncientRange", kind, start, count, maxBytes); err != nil {
+		return nil, err
+	}
+	items := make([][]byte, len(resp))
+	for i, item := range resp {
+		items[i] = item
+	}
+	return items, nil
+}
+
+// AncientTail is not supported by the remote database.
+func (db *Database) AncientTail(kind string) (uint64, error) {
+	return 0, errNotSupported
+}
+
+// Ancients returns the ancient item numbers in the remote ancient store.
+func (db *Database) Ancients() (uint64, error) {
+	var resp uint64
+	err := db.remote.Call(&resp, "debug_dbAncients")
+	return resp, err
+}
+
+// AncientSize is not supported by the remote database.
+func (db *Database) AncientSize(kind string) (uint64, error) {
+	return 0, errNotSupported
+}
+
+// Put is not supported by the read-only remote database.
+func (db *Database) Put(key []byte, value []byte) error {
+	return errReadOnly
+}
+
+// Delete is not supported by the read-only remote database.
+func (db *Database) Delete(key []byte) error {
+	return errReadOnly
+}
+
+// AppendAncient is not supported by the read-only remote database.
+func (db *Database) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
+	return errReadOnly
+}
+
+// TruncateAncients is not supported by the read-only remote database.
+func (db *Database) TruncateAncients(n uint64) error {
+	return errReadOnly
+}
+
+// TruncateAncientTail is not supported by the read-only remote database.
+func (db *Database) TruncateAncientTail(kind string, n uint64) error {
+	return errReadOnly
+}
+
+// Sync is a noop, the remote database is never written to.
+func (db *Database) Sync() error {
+	return nil
+}
+
+// NewBatch creates a batch which fails to write to the read-only remote database.
+func (db *Database) NewBatch() ethdb.Batch {
+	return new(batch)
+}
+
+// NewIterator creates a binary-alphabetical iterator over a subset of the remote
+// database content with a particular key prefix, starting at a particular
+// initial key (or after, if it does not exist). Entries are fetched from the
+// remote node page by page.
+func (db *Database) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	return &iterator{
+		remote: db.remote,
+		prefix: common.CopyBytes(prefix),
+		next:   common.CopyBytes(start),
+		pos:    -1,
+	}
+}
+
+// Stat returns a particular internal stat of the remote database.
+func (db *Database) Stat(property string) (string, error) {
+	var resp string
+	err := db.remote.Call(&resp, "debug_chaindbProperty", property)
+	return resp, err
+}
+
+// Compact is not supported by the read-only remote database.
+func (db *Database) Compact(start []byte, limit []byte) error {
+	return errReadOnly
+}
+
+// Close terminates the connection to the remote node.
+func (db *Database) Close() error {
+	db.remote.Close()
+	return nil
+}
+
+// batch is a write-only batch that can't be flushed to the remote database.
+type batch struct {
+	size int
+}
+
+// Put records the size of the inserted value.
+func (b *batch) Put(key, value []byte) error {
+	b.size += len(value)
+	return nil
+}
+
+// Delete records the size of the deleted key.
+func (b *batch) Delete(key []byte) error {
+	b.size += len(key)
+	return nil
+}
+
+// ValueSize retrieves the amount of data queued up for writing.
+func (b *batch) ValueSize() int {
+	return b.size
+}
+
+// Write fails, the remote database is read-only.
+func (b *batch) Write() error {
+	return errReadOnly
+}
+
+// Reset resets the batch for reuse.
+func (b *batch) Reset() {
+	b.size = 0
+}
+
+// Replay fails, the batch does not retain its contents.
+func (b *batch) Replay(w ethdb.KeyValueWriter) error {
+	return errReadOnly
+}
+
+// iteratorPage is a batch of consecutive database entries returned by the
+// debug_dbIterate RPC method.
+type iteratorPage struct {
+	Keys   []hexutil.Bytes `json:"keys"`
+	Values []hexutil.Bytes `json:"values"`
+	Next   hexutil.Bytes   `json:"next,omitempty"` // Start of the next page, nil if done
+}
+
+// iterator walks the remote database, requesting a new page of entries whenever
+// the current one is exhausted.
+type iterator struct {
+	remote *rpc.Client
+	prefix []byte
+	next   []byte // Start key of the next page, relative to the prefix
+	page   iteratorPage
+	pos    int
+	done   bool
+	err    error
+}
+
+// Next moves the iterator to the next key/value pair. It returns whether the
+// iterator is exhausted.
+func (it *iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for it.pos+1 >= len(it.page.Keys) {
+		if it.done {
+			return false
+		}
+		var page iteratorPage
+		if err := it.remote.Call(&page, "debug_dbIterate", hexutil.Bytes(it.prefix), hexutil.Bytes(it.next), iteratorPageSize); err != nil {
+			it.err = err
+			return false
+		}
+		if len(page.Keys) != len(page.Values) {
+			it.err = errors.New("remote iterator page keys and values mismatch")
+			return false
+		}
+		it.page, it.pos = page, -1
+		it.next, it.done = page.Next, len(page.Next) == 0
+	}
+	it.pos++
+	return true
+}
+
+// Error returns any accumulated error.
+func (it *iterator) Error() error { return it.err }
+
+// Key returns the key of the current key/value pair, or nil if done.
+func (it *iterator) Key() []byte {
+	if it.pos < 0 || it.pos >= len(it.page.Keys) {
+		return nil
+	}
+	return it.page.Keys[it.pos]
+}
+
+// Value returns the value of the current key/value pair, or nil if done.
+func (it *iterator) Value() []byte {
+	if it.pos < 0 || it.pos >= len(it.page.Values) {
+		return nil
+	}
+	return it.page.Values[it.pos]
+}
+
+// Release drops the buffered page.
+func (it *iterator) Release() {
+	it.page = iteratorPage{}
+	it.pos = -1
+	it.done = true
+}
@@ -0,0 +1,216 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package remotedb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testNotFoundError mirrors the not found error of the debug API.
+type testNotFoundError struct{}
+
+func (testNotFoundError) Error() string  { return "not found" }
+func (testNotFoundError) ErrorCode() int { return ethdb.NotFoundErrorCode }
+
+// errBroken is returned by the test API for items whose retrieval fails.
+var errBroken = errors.New("broken")
+
+// testDebugAPI mirrors the database methods of the debug API.
+type testDebugAPI struct {
+	db      ethdb.KeyValueStore
+	ancient map[string][][]byte
+}
+
+func (api *testDebugAPI) DbGet(key hexutil.Bytes) (hexutil.Bytes, error) {
+	if string(key) == "broken" {
+		return nil, errBroken
+	}
+	if has, _ := api.db.Has(key); !has {
+		return nil, testNotFoundError{}
+	}
+	return api.db.Get(key)
+}
+
+func (api *testDebugAPI) DbIterate(prefix, start hexutil.Bytes, limit int) (*iteratorPage, error) {
+	it := api.db.NewIterator(prefix, start)
+	defer it.Release()
+
+	page := new(iteratorPage)
+	for it.Next() {
+		if len(page.Keys) == limit {
+			page.Next = common.CopyBytes(it.Key()[len(prefix):])
+			break
+		}
+		page.Keys = append(page.Keys, common.CopyBytes(it.Key()))
+		page.Values = append(page.Values, common.CopyBytes(it.Value()))
+	}
+	return page, it.Error()
+}
+
+func (api *testDebugAPI) DbAncient(kind string, number uint64) (hexutil.Bytes, error) {
+	items, ok := api.ancient[kind]
+	if !ok {
+		return nil, errBroken
+	}
+	if number >= uint64(len(items)) {
+		return nil, testNotFoundError{}
+	}
+	return items[number], nil
+}
+
+func (api *testDebugAPI) DbAncients() (uint64, error) {
+	return uint64(len(api.ancient["headers"])), nil
+}
+
+func newTestDatabase(t *testing.T) ethdb.Database {
+	kv := memorydb.New()
+	kv.Put([]byte("key"), []byte("value"))
+	kv.Put([]byte("other"), []byte("value"))
+	for i := 0; i < 2*iteratorPageSize; i++ {
+		kv.Put([]byte(fmt.Sprintf("item-%05d", i)), []byte(fmt.Sprintf("value-%d", i)))
+	}
+
+	server := rpc.NewServer()
+	api := &testDebugAPI{
+		db:      kv,
+		ancient: map[string][][]byte{"headers": {{0x01}, {0x02}}},
+	}
+	if err := server.RegisterName("debug", api); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	return New(rpc.DialInProc(server))
+}
+
+func TestRemoteDatabaseReads(t *testing.T) {
+	db := newTestDatabase(t)
+	defer db.Close()
+
+	if has, err := db.Has([]byte("key")); err != nil || !has {
+		t.Errorf("Has(key) = %v, %v; want true, nil", has, err)
+	}
+	if has, err := db.Has([]byte("missing")); err != nil || has {
+		t.Errorf("Has(missing) = %v, %v; want false, nil", has, err)
+	}
+	if val, err := db.Get([]byte("key")); err != nil || !bytes.Equal(val, []byte("value")) {
+		t.Errorf("Get(key) = %x, %v; want %x, nil", val, err, []byte("value"))
+	}
+	if _, err := db.Get([]byte("missing")); err == nil {
+		t.Error("Get(missing) succeeded")
+	}
+	if n, err := db.Ancients(); err != nil || n != 2 {
+		t.Errorf("Ancients() = %d, %v; want 2, nil", n, err)
+	}
+	if blob, err := db.Ancient("headers", 1); err != nil || !bytes.Equal(blob, []byte{0x02}) {
+		t.Errorf("Ancient(headers, 1) = %x, %v; want 02, nil", blob, err)
+	}
+	if has, err := db.HasAncient("headers", 2); err != nil || has {
+		t.Errorf("HasAncient(headers, 2) = %v, %v; want false, nil", has, err)
+	}
+}
+
+func TestRemoteDatabaseErrors(t *testing.T) {
+	db := newTestDatabase(t)
+	defer db.Close()
+
+	if has, err := db.Has([]byte("broken")); err == nil || err.Error() != errBroken.Error() {
+		t.Errorf("Has(broken) = %v, %v; want false, %v", has, err, errBroken)
+	}
+	if has, err := db.HasAncient("bodies", 0); err == nil || err.Error() != errBroken.Error() {
+		t.Errorf("HasAncient(bodies, 0) = %v, %v; want false, %v", has, err, errBroken)
+	}
+	// Connection failures must not be reported as missing items either.
+	db.Close()
+	if has, err := db.Has([]byte("key")); err == nil {
+		t.Errorf("Has(key) on closed client = %v, nil; want error", has)
+	}
+}
+
+func TestRemoteDatabaseIterator(t *testing.T) {
+	db := newTestDatabase(t)
+	defer db.Close()
+
+	// Iterate across multiple pages, with and without prefix and start.
+	tests := []struct {
+		prefix, start string
+		want          int
+	}{
+		{"", "", 2*iteratorPageSize + 2},
+		{"item-", "", 2 * iteratorPageSize},
+		{"item-", fmt.Sprintf("%05d", iteratorPageSize/2), 2*iteratorPageSize - iteratorPageSize/2},
+		{"missing", "", 0},
+	}
+	for i, tt := range tests {
+		it := db.NewIterator([]byte(tt.prefix), []byte(tt.start))
+		var (
+			count int
+			prev  []byte
+		)
+		for it.Next() {
+			if !bytes.HasPrefix(it.Key(), []byte(tt.prefix)) {
+				t.Fatalf("test %d: key %q without prefix %q", i, it.Key(), tt.prefix)
+			}
+			if prev != nil && bytes.Compare(prev, it.Key()) >= 0 {
+				t.Fatalf("test %d: keys out of order: %q after %q", i, it.Key(), prev)
+			}
+			if want, _ := db.Get(it.Key()); !bytes.Equal(it.Value(), want) {
+				t.Fatalf("test %d: value mismatch for %q: have %q, want %q", i, it.Key(), it.Value(), want)
+			}
+			prev = common.CopyBytes(it.Key())
+			count++
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("test %d: iteration failed: %v", i, err)
+		}
+		it.Release()
+		if count != tt.want {
+			t.Errorf("test %d: item count mismatch: have %d, want %d", i, count, tt.want)
+		}
+	}
+}
+
+func TestRemoteDatabaseReadOnly(t *testing.T) {
+	db := newTestDatabase(t)
+	defer db.Close()
+
+	if err := db.Put([]byte("key"), []byte("other")); err != errReadOnly {
+		t.Errorf("Put error mismatch: have %v, want %v", err, errReadOnly)
+	}
+	if err := db.Delete([]byte("key")); err != errReadOnly {
+		t.Errorf("Delete error mismatch: have %v, want %v", err, errReadOnly)
+	}
+	if err := db.AppendAncient(2, nil, nil, nil, nil, nil); err != errReadOnly {
+		t.Errorf("AppendAncient error mismatch: have %v, want %v", err, errReadOnly)
+	}
+	batch := db.NewBatch()
+	batch.Put([]byte("key"), []byte("other"))
+	if err := batch.Write(); err != errReadOnly {
+		t.Errorf("batch write error mismatch: have %v, want %v", err, errReadOnly)
+	}
+	if val, _ := db.Get([]byte("key")); !bytes.Equal(val, []byte("value")) {
+		t.Errorf("value modified: have %x, want %x", val, []byte("value"))
+	}
+}
@@ -17,15 +17,20 @@
 package graphql
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -35,7 +40,8 @@ import (
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
-
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -213,6 +219,361 @@ func TestGraphQLHTTPOnSamePort_GQLRequest_Unsuccessful(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 }
 
+// Tests that GraphQL requires JWT authentication if it is configured for HTTP RPC.
+func TestGraphQLJWTAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "graphql-jwt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secret := make([]byte, 32)
+	secretFile := filepath.Join(dir, "jwtsecret")
+	if err := ioutil.WriteFile(secretFile, []byte(hexutil.Encode(secret)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stack, err := node.New(&node.Config{HTTPHost: "127.0.0.1", JWTSecret: secretFile})
+	if err != nil {
+		t.Fatalf("could not create node: %v", err)
+	}
+	defer stack.Close()
+	createGQLService(t, stack)
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+
+	request := func(token string) *http.Response {
+		body := strings.NewReader(`{"query": "{block{number}}","variables": null}`)
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/graphql", stack.HTTPEndpoint()), body)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("could not post: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := request(""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated request: have status %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iat": time.Now().Unix()}).SignedString(secret)
+	if resp := request(token); resp.StatusCode != http.StatusOK {
+		t.Fatalf("authenticated request: have status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// Subscriptions are protected as well.
+	dialer := websocket.Dialer{Subprotocols: []string{subscriptionProtocol}}
+	url := "ws" + strings.TrimPrefix(stack.HTTPEndpoint(), "http") + "/graphql"
+	if conn, resp, err := dialer.Dial(url, nil); err == nil {
+		conn.Close()
+		t.Fatal("unauthenticated websocket connection accepted")
+	} else if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated websocket connection: unexpected response %v, %v", resp, err)
+	}
+}
+
+// Tests that new blocks are delivered to websocket subscribers.
+func TestGraphQLSubscribeNewBlocks(t *testing.T) {
+	stack := createNode(t, false, false)
+	defer stack.Close()
+	ethBackend := createGQLService(t, stack)
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	conn, recv := subscribe(t, stack, "subscription{newBlocks{number}}")
+	defer conn.Close()
+
+	// The subscription is set up asynchronously, keep importing blocks until
+	// one of them is delivered.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		chain := ethBackend.BlockChain()
+		for {
+			blocks, _ := core.GenerateChain(params.AllEthashProtocolChanges, chain.CurrentBlock(),
+				ethash.NewFaker(), ethBackend.ChainDb(), 1, func(i int, gen *core.BlockGen) {})
+			chain.InsertChain(blocks)
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}()
+	var resp struct {
+		Data struct {
+			NewBlocks struct {
+				Number uint64
+			}
+		}
+	}
+	recv(&resp)
+	if resp.Data.NewBlocks.Number <= 10 {
+		t.Fatalf("wrong block number: have %d, want > 10", resp.Data.NewBlocks.Number)
+	}
+}
+
+func TestGraphQLSubscribePendingTransactions(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	stack := createNode(t, false, false)
+	defer stack.Close()
+	ethBackend := createGQLServiceWithAlloc(t, stack, core.GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	conn, recv := subscribe(t, stack, "subscription{pendingTransactions{hash from{address}}}")
+	defer conn.Close()
+
+	// The subscription is set up asynchronously, keep adding transactions to
+	// the pool until one of them is delivered.
+	var (
+		signer = types.LatestSigner(params.AllEthashProtocolChanges)
+		sent   = make(map[common.Hash]bool)
+		lock   sync.Mutex
+		stop   = make(chan struct{})
+	)
+	defer close(stop)
+	go func() {
+		for nonce := uint64(0); ; nonce++ {
+			tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+				Nonce:    nonce,
+				To:       &common.Address{0xaa},
+				Value:    big.NewInt(1),
+				Gas:      params.TxGas,
+				GasPrice: big.NewInt(10 * params.GWei),
+			})
+			lock.Lock()
+			sent[tx.Hash()] = true
+			lock.Unlock()
+			if err := ethBackend.TxPool().AddLocal(tx); err != nil {
+				t.Errorf("could not add transaction: %v", err)
+				return
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}()
+	var resp struct {
+		Data struct {
+			PendingTransactions struct {
+				Hash common.Hash
+				From struct {
+					Address common.Address
+				}
+			}
+		}
+	}
+	recv(&resp)
+	lock.Lock()
+	defer lock.Unlock()
+	if tx := resp.Data.PendingTransactions; !sent[tx.Hash] {
+		t.Fatalf("unexpected transaction %x", tx.Hash)
+	}
+	if from := resp.Data.PendingTransactions.From.Address; from != address {
+		t.Fatalf("wrong sender: have %x, want %x", from, address)
+	}
+}
+
+func TestGraphQLSubscribeLogs(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		emitter = common.HexToAddress("0x00000000000000000000000000000000000e1117")
+		other   = common.HexToAddress("0x000000000000000000000000000000000000a7e2")
+		topic   = common.BigToHash(big.NewInt(0x2a))
+	)
+	// Both contracts emit a LOG1 with topic 0x2a, only the emitter is subscribed to.
+	code := []byte{byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.LOG1)}
+
+	stack := createNode(t, false, false)
+	defer stack.Close()
+	ethBackend := createGQLServiceWithAlloc(t, stack, core.GenesisAlloc{
+		address: {Balance: big.NewInt(params.Ether)},
+		emitter: {Code: code, Balance: big.NewInt(0)},
+		other:   {Code: code, Balance: big.NewInt(0)},
+	})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	query := fmt.Sprintf(`subscription{logs(filter:{addresses:[\"%s\"]}){account{address} topics transaction{hash}}}`, emitter.Hex())
+	conn, recv := subscribe(t, stack, query)
+	defer conn.Close()
+
+	// Keep importing blocks calling both contracts until a log is delivered.
+	var (
+		signer = types.LatestSigner(params.AllEthashProtocolChanges)
+		stop   = make(chan struct{})
+	)
+	defer close(stop)
+	go func() {
+		chain := ethBackend.BlockChain()
+		for {
+			blocks, _ := core.GenerateChain(params.AllEthashProtocolChanges, chain.CurrentBlock(),
+				ethash.NewFaker(), ethBackend.ChainDb(), 1, func(i int, gen *core.BlockGen) {
+					for _, addr := range []common.Address{other, emitter} {
+						to := addr
+						tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+							Nonce:    gen.TxNonce(address),
+							To:       &to,
+							Gas:      50000,
+							GasPrice: big.NewInt(10 * params.GWei),
+						})
+						gen.AddTx(tx)
+					}
+				})
+			chain.InsertChain(blocks)
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}()
+	var resp struct {
+		Data struct {
+			Logs struct {
+				Account struct {
+					Address common.Address
+				}
+				Topics      []common.Hash
+				Transaction struct {
+					Hash common.Hash
+				}
+			}
+		}
+	}
+	recv(&resp)
+	if have := resp.Data.Logs.Account.Address; have != emitter {
+		t.Fatalf("wrong log account: have %x, want %x", have, emitter)
+	}
+	if have := resp.Data.Logs.Topics; len(have) != 1 || have[0] != topic {
+		t.Fatalf("wrong log topics: have %x, want [%x]", have, topic)
+	}
+	if resp.Data.Logs.Transaction.Hash == (common.Hash{}) {
+		t.Fatal("missing log transaction")
+	}
+}
+
+func TestGraphQLSubscribeVHosts(t *testing.T) {
+	stack := createNode(t, true, false)
+	defer stack.Close()
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	dialer := websocket.Dialer{Subprotocols: []string{subscriptionProtocol}}
+	url := "ws" + strings.TrimPrefix(stack.HTTPEndpoint(), "http") + "/graphql"
+	_, resp, err := dialer.Dial(url, http.Header{"Host": {"evil.example"}})
+	if err == nil {
+		t.Fatal("websocket dial with unknown virtual host succeeded")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("wrong response to unknown virtual host: %v", resp)
+	}
+}
+
+// Tests that queries and mutations of the HTTP schema are served over websocket.
+func TestGraphQLSubscriptionQuery(t *testing.T) {
+	stack := createNode(t, true, false)
+	defer stack.Close()
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	conn, recv := subscribe(t, stack, "{blocks(from:1,to:2){number} chainID}")
+	defer conn.Close()
+
+	var resp struct {
+		Data struct {
+			Blocks []struct {
+				Number uint64
+			}
+			ChainID string
+		}
+		Errors []interface{}
+	}
+	recv(&resp)
+	if len(resp.Errors) != 0 {
+		t.Fatalf("query failed: %v", resp.Errors)
+	}
+	if len(resp.Data.Blocks) != 2 || resp.Data.Blocks[0].Number != 1 || resp.Data.Blocks[1].Number != 2 {
+		t.Fatalf("wrong blocks: %v", resp.Data.Blocks)
+	}
+	if resp.Data.ChainID == "" {
+		t.Fatal("missing chain ID")
+	}
+}
+
+// Tests that subscriptions fail gracefully if the handler has no event system.
+func TestGraphQLSubscribeNoEvents(t *testing.T) {
+	stack := createNode(t, false, false)
+	defer stack.Close()
+	if err := newHandler(stack, nil, []string{}, []string{}); err != nil {
+		t.Fatalf("could not create graphql service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	conn, recv := subscribe(t, stack, "subscription{newBlocks{number}}")
+	defer conn.Close()
+
+	var resp struct {
+		Errors []struct {
+			Message string
+		}
+	}
+	recv(&resp)
+	if len(resp.Errors) != 1 || resp.Errors[0].Message != errSubscriptionsUnavailable.Error() {
+		t.Fatalf("wrong errors: have %v, want %q", resp.Errors, errSubscriptionsUnavailable)
+	}
+}
+
+// subscribe dials the GraphQL websocket endpoint of the given node and starts a
+// subscription with the given query. The returned function decodes the payload
+// of the next data message into the given value.
+func subscribe(t *testing.T, stack *node.Node, query string) (*websocket.Conn, func(interface{})) {
+	dialer := websocket.Dialer{Subprotocols: []string{subscriptionProtocol}}
+	url := "ws" + strings.TrimPrefix(stack.HTTPEndpoint(), "http") + "/graphql"
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	send := func(msg string) {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("could not send message: %v", err)
+		}
+	}
+	read := func() gqlMessage {
+		var msg gqlMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("could not read message: %v", err)
+		}
+		return msg
+	}
+	send(`{"type":"connection_init"}`)
+	if msg := read(); msg.Type != gqlConnectionAck {
+		t.Fatalf("wrong message type: have %q, want %q", msg.Type, gqlConnectionAck)
+	}
+	send(fmt.Sprintf(`{"id":"1","type":"start","payload":{"query":"%s"}}`, query))
+
+	return conn, func(v interface{}) {
+		msg := read()
+		if msg.Type != gqlData || msg.ID != "1" {
+			t.Fatalf("wrong message: have %q (id %q) %s, want %q (id %q)", msg.Type, msg.ID, msg.Payload, gqlData, "1")
+		}
+		if err := json.Unmarshal(msg.Payload, v); err != nil {
+			t.Fatalf("could not decode payload %s: %v", msg.Payload, err)
+		}
+	}
+}
+
 func createNode(t *testing.T, gqlEnabled bool, txEnabled bool) *node.Node {
 	stack, err := node.New(&node.Config{
 		HTTPHost: "127.0.0.1",
@@ -234,13 +595,18 @@ func createNode(t *testing.T, gqlEnabled bool, txEnabled bool) *node.Node {
 	return stack
 }
 
-func createGQLService(t *testing.T, stack *node.Node) {
+func createGQLService(t *testing.T, stack *node.Node) *eth.Ethereum {
+	return createGQLServiceWithAlloc(t, stack, nil)
+}
+
+func createGQLServiceWithAlloc(t *testing.T, stack *node.Node, alloc core.GenesisAlloc) *eth.Ethereum {
 	// create backend
 	ethConf := &ethconfig.Config{
 		Genesis: &core.Genesis{
 			Config:     params.AllEthashProtocolChanges,
 			GasLimit:   11500000,
 			Difficulty: big.NewInt(1048576),
+			Alloc:      alloc,
 		},
 		Ethash: ethash.Config{
 			PowMode: ethash.ModeFake,
@@ -269,6 +635,7 @@ func createGQLService(t *testing.T, stack *node.Node) {
 	if err != nil {
 		t.Fatalf("could not create graphql service: %v", err)
 	}
+	return ethBackend
 }
 
 func createGQLServiceWithTransactions(t *testing.T, stack *node.Node) {
@@ -16,7 +16,9 @@
 
 package graphql
 
-const schema string = `
+// schemaTypes contains the type definitions shared by the query and subscription
+// schemas.
+const schemaTypes string = `
     # Bytes32 is a 32 byte binary string, represented as 0x-prefixed hexadecimal.
     scalar Bytes32
     # Address is a 20 byte Ethereum address, represented as 0x-prefixed hexadecimal.
@@ -31,11 +33,6 @@ const schema string = `
     # Long is a 64 bit unsigned integer.
     scalar Long
 
-    schema {
-        query: Query
-        mutation: Mutation
-    }
-
     # Account is an Ethereum account at a particular block.
     type Account {
         # Address is the address owning the account.
@@ -302,8 +299,11 @@ const schema string = `
       # successful execution of a transaction for the pending state.
       estimateGas(data: CallData!): Long!
     }
+`
 
-    type Query {
+// queryFields contains the fields of the root query type shared by the query
+// and subscription schemas.
+const queryFields string = `
         # Block fetches an Ethereum block by number or by hash. If neither is
         # supplied, the most recent known block is returned.
         block(number: Long, hash: Bytes32): Block
@@ -314,8 +314,6 @@ const schema string = `
         pending: Pending!
         # Transaction returns a transaction specified by its hash.
         transaction(hash: Bytes32!): Transaction
-        # Logs returns log entries matching the provided filter.
-        logs(filter: FilterCriteria!): [Log!]!
         # GasPrice returns the node's estimate of a gas price sufficient to
         # ensure a transaction is mined in a timely fashion.
         gasPrice: BigInt!
@@ -323,10 +321,50 @@ const schema string = `
         syncing: SyncState
         # ChainID returns the current chain ID for transaction replay protection.
         chainID: BigInt!
-    }
+`
 
+// mutationType is the root mutation type shared by the query and subscription
+// schemas.
+const mutationType string = `
     type Mutation {
         # SendRawTransaction sends an RLP-encoded transaction to the network.
         sendRawTransaction(data: Bytes!): Bytes32!
     }
 `
+
+// schema is the GraphQL schema served over HTTP.
+const schema string = schemaTypes + `
+    schema {
+        query: Query
+        mutation: Mutation
+    }
+
+    type Query {` + queryFields + `        # Logs returns log entries matching the provided filter.
+        logs(filter: FilterCriteria!): [Log!]!
+    }
+` + mutationType
+
+// subscriptionSchema is the GraphQL schema served over WebSocket. It extends the
+// schema served over HTTP with the subscription type, except for the logs query:
+// fields are resolved by name on a single root resolver, so the logs query can't
+// be served next to the logs subscription.
+const subscriptionSchema string = schemaTypes + `
+    schema {
+        query: Query
+        mutation: Mutation
+        subscription: Subscription
+    }
+
+    type Query {` + queryFields + `    }
+` + mutationType + `
+    type Subscription {
+        # NewBlocks emits every block that becomes the new head of the canonical
+        # chain.
+        newBlocks: Block!
+        # PendingTransactions emits every transaction added to the transaction pool.
+        pendingTransactions: Transaction!
+        # Logs emits the log entries of newly imported blocks matching the provided
+        # filter.
+        logs(filter: BlockFilterCriteria!): Log!
+    }
+`
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/graph-gophers/graphql-go"
@@ -65,7 +66,8 @@ func New(stack *node.Node, backend ethapi.Backend, cors, vhosts []string) error
 }
 
 // newHandler returns a new `http.Handler` that will answer GraphQL queries.
-// It additionally exports an interactive query browser on the / endpoint.
+// It additionally exports an interactive query browser on the / endpoint and
+// serves subscriptions to websocket clients.
 func newHandler(stack *node.Node, backend ethapi.Backend, cors, vhosts []string) error {
 	q := Resolver{backend}
 
@@ -73,12 +75,52 @@ func newHandler(stack *node.Node, backend ethapi.Backend, cors, vhosts []string)
 	if err != nil {
 		return err
 	}
+	sq := SubscriptionResolver{Resolver: &q}
+	if backend != nil {
+		sq.events = filters.NewEventSystem(filters.Backend(backend), false)
+	}
+	ss, err := graphql.ParseSchema(subscriptionSchema, &sq)
+	if err != nil {
+		return err
+	}
 	h := handler{Schema: s}
-	handler := node.NewHTTPHandlerStack(h, cors, vhosts)
+	handler := newUpgradeHandler(node.NewHTTPHandlerStack(h, cors, vhosts, nil), newSubscriptionHandler(ss, cors))
+	handler = node.NewVHostHandler(vhosts, handler)
 
 	stack.RegisterHandler("GraphQL UI", "/graphql/ui", GraphiQL{})
 	stack.RegisterHandler("GraphQL", "/graphql", handler)
 	stack.RegisterHandler("GraphQL", "/graphql/", handler)
+	stack.RegisterLifecycle(&service{events: sq.events})
 
 	return nil
 }
+
+// service tears down the event system feeding subscriptions when the node stops.
+type service struct {
+	events *filters.EventSystem
+}
+
+// Start implements node.Lifecycle.
+func (s *service) Start() error {
+	return nil
+}
+
+// Stop implements node.Lifecycle, ending all active subscriptions.
+func (s *service) Stop() error {
+	if s.events != nil {
+		s.events.Stop()
+	}
+	return nil
+}
+
+// newUpgradeHandler routes websocket upgrade requests to ws and all other
+// requests to h.
+func newUpgradeHandler(h, ws http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if node.IsWebsocket(r) {
+			ws.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,396 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// subscriptionProtocol is the websocket subprotocol implemented by the
+	// subscription handler (subscriptions-transport-ws).
+	subscriptionProtocol = "graphql-ws"
+
+	subscriptionKeepAlive    = 20 * time.Second
+	subscriptionWriteTimeout = 10 * time.Second
+)
+
+// errSubscriptionsUnavailable is returned by the subscription resolvers if the
+// handler was created without an event system to feed them.
+var errSubscriptionsUnavailable = errors.New("subscriptions are not available")
+
+// Message types of the graphql-ws protocol.
+const (
+	gqlConnectionInit      = "connection_init"
+	gqlConnectionAck       = "connection_ack"
+	gqlConnectionError     = "connection_error"
+	gqlConnectionKeepAlive = "ka"
+	gqlConnectionTerminate = "connection_terminate"
+	gqlStart               = "start"
+	gqlStop                = "stop"
+	gqlData                = "data"
+	gqlError               = "error"
+	gqlComplete            = "complete"
+)
+
+// SubscriptionResolver is the root resolver of the subscription schema. It resolves
+// subscriptions using the event feeds of the filter event system.
+type SubscriptionResolver struct {
+	*Resolver
+	events *filters.EventSystem
+}
+
+// NewBlocks emits the new heads of the canonical chain.
+func (r *SubscriptionResolver) NewBlocks(ctx context.Context) (<-chan *Block, error) {
+	if r.events == nil {
+		return nil, errSubscriptionsUnavailable
+	}
+	var (
+		headers = make(chan *types.Header)
+		sub     = r.events.SubscribeNewHeads(headers)
+		out     = make(chan *Block)
+	)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case header := <-headers:
+				hash := header.Hash()
+				numberOrHash := rpc.BlockNumberOrHashWithHash(hash, false)
+				block := &Block{
+					backend:      r.backend,
+					numberOrHash: &numberOrHash,
+					hash:         hash,
+					header:       header,
+				}
+				select {
+				case out <- block:
+				case <-ctx.Done():
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// PendingTransactions emits the transactions entering the transaction pool.
+func (r *SubscriptionResolver) PendingTransactions(ctx context.Context) (<-chan *Transaction, error) {
+	if r.events == nil {
+		return nil, errSubscriptionsUnavailable
+	}
+	var (
+		hashes = make(chan []common.Hash)
+		sub    = r.events.SubscribePendingTxs(hashes)
+		out    = make(chan *Transaction)
+	)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case batch := <-hashes:
+				for _, hash := range batch {
+					select {
+					case out <- &Transaction{backend: r.backend, hash: hash}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Logs emits the logs of newly imported blocks matching the given filter.
+func (r *SubscriptionResolver) Logs(ctx context.Context, args struct{ Filter BlockFilterCriteria }) (<-chan *Log, error) {
+	if r.events == nil {
+		return nil, errSubscriptionsUnavailable
+	}
+	var crit ethereum.FilterQuery
+	if args.Filter.Addresses != nil {
+		crit.Addresses = *args.Filter.Addresses
+	}
+	if args.Filter.Topics != nil {
+		crit.Topics = *args.Filter.Topics
+	}
+	var (
+		logs     = make(chan []*types.Log)
+		sub, err = r.events.SubscribeLogs(crit, logs)
+		out      = make(chan *Log)
+	)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case batch := <-logs:
+				for _, log := range batch {
+					entry := &Log{
+						backend:     r.backend,
+						transaction: &Transaction{backend: r.backend, hash: log.TxHash},
+						log:         log,
+					}
+					select {
+					case out <- entry:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func errUnknownMessage(typ string) error {
+	return fmt.Errorf("unknown message type %q", typ)
+}
+
+func errInvalidSubscriptionID(id string) error {
+	return fmt.Errorf("invalid or duplicate operation id %q", id)
+}
+
+// gqlMessage is a message of the graphql-ws protocol.
+type gqlMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// gqlStartPayload is the payload of a start message.
+type gqlStartPayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// subscriptionHandler serves GraphQL subscriptions over WebSocket.
+type subscriptionHandler struct {
+	schema   *graphql.Schema
+	upgrader websocket.Upgrader
+}
+
+func newSubscriptionHandler(schema *graphql.Schema, origins []string) *subscriptionHandler {
+	return &subscriptionHandler{
+		schema: schema,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{subscriptionProtocol},
+			CheckOrigin:  checkOrigin(origins),
+		},
+	}
+}
+
+// checkOrigin returns an origin check accepting non-browser clients and the given
+// browser origins.
+func checkOrigin(origins []string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range origins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (h *subscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug("GraphQL websocket upgrade failed", "err", err)
+		return
+	}
+	if conn.Subprotocol() != subscriptionProtocol {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseProtocolError, "unsupported subprotocol"), time.Now().Add(subscriptionWriteTimeout))
+		conn.Close()
+		return
+	}
+	c := &subscriptionConn{
+		schema: h.schema,
+		conn:   conn,
+		subs:   make(map[string]context.CancelFunc),
+	}
+	c.serve(r.Context())
+}
+
+// subscriptionConn is a single graphql-ws connection.
+type subscriptionConn struct {
+	schema *graphql.Schema
+	conn   *websocket.Conn
+
+	writeMu sync.Mutex // serializes writes to conn
+
+	subsMu sync.Mutex
+	subs   map[string]context.CancelFunc
+}
+
+// serve reads and handles messages until the connection is closed.
+func (c *subscriptionConn) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		c.conn.Close()
+	}()
+	go c.keepAlive(ctx)
+
+	for {
+		var msg gqlMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case gqlConnectionInit:
+			c.write(&gqlMessage{Type: gqlConnectionAck})
+		case gqlStart:
+			var payload gqlStartPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				c.writeError(msg.ID, err)
+				continue
+			}
+			c.start(ctx, msg.ID, &payload)
+		case gqlStop:
+			c.stop(msg.ID)
+		case gqlConnectionTerminate:
+			return
+		default:
+			c.writeError(msg.ID, errUnknownMessage(msg.Type))
+		}
+	}
+}
+
+// start launches the operation of a start message.
+func (c *subscriptionConn) start(ctx context.Context, id string, payload *gqlStartPayload) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	c.subsMu.Lock()
+	if _, exists := c.subs[id]; exists || id == "" {
+		c.subsMu.Unlock()
+		cancel()
+		c.writeError(id, errInvalidSubscriptionID(id))
+		return
+	}
+	c.subs[id] = cancel
+	c.subsMu.Unlock()
+
+	responses, err := c.schema.Subscribe(ctx, payload.Query, payload.OperationName, payload.Variables)
+	if err != nil {
+		c.stop(id)
+		c.writeError(id, err)
+		return
+	}
+	go func() {
+		for resp := range responses {
+			enc, err := json.Marshal(resp)
+			if err != nil {
+				c.writeError(id, err)
+				continue
+			}
+			c.write(&gqlMessage{ID: id, Type: gqlData, Payload: enc})
+		}
+		// Only report completion if the client didn't stop the operation.
+		c.subsMu.Lock()
+		_, active := c.subs[id]
+		delete(c.subs, id)
+		c.subsMu.Unlock()
+		if active && ctx.Err() == nil {
+			c.write(&gqlMessage{ID: id, Type: gqlComplete})
+		}
+		cancel()
+	}()
+}
+
+// stop cancels the operation with the given id.
+func (c *subscriptionConn) stop(id string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	if cancel, ok := c.subs[id]; ok {
+		cancel()
+		delete(c.subs, id)
+	}
+}
+
+// keepAlive periodically sends keep-alive messages until ctx is canceled.
+func (c *subscriptionConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(subscriptionKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.write(&gqlMessage{Type: gqlConnectionKeepAlive})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *subscriptionConn) write(msg *gqlMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(subscriptionWriteTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Debug("Failed to write GraphQL subscription message", "err", err)
+	}
+}
+
+func (c *subscriptionConn) writeError(id string, err error) {
+	payload, _ := json.Marshal(map[string]string{"message": err.Error()})
+	typ := gqlError
+	if id == "" {
+		typ = gqlConnectionError
+	}
+	c.write(&gqlMessage{ID: id, Type: typ, Payload: payload})
+}
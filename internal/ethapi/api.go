@@ -36,6 +36,8 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -64,6 +66,36 @@ func (s *PublicEthereumAPI) GasPrice(ctx context.Context) (*hexutil.Big, error)
 	return (*hexutil.Big)(price), err
 }
 
+type feeHistoryResult struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// FeeHistory returns the gas used ratio and the requested gas price percentiles
+// of a range of recent blocks, ending with lastBlock. The percentiles are weighted
+// by the gas used of the transactions in each block.
+func (s *PublicEthereumAPI) FeeHistory(ctx context.Context, blockCount hexutil.Uint, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*feeHistoryResult, error) {
+	oldest, reward, gasUsed, err := s.b.FeeHistory(ctx, int(blockCount), lastBlock, rewardPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	results := &feeHistoryResult{
+		OldestBlock:  (*hexutil.Big)(oldest),
+		GasUsedRatio: gasUsed,
+	}
+	if reward != nil {
+		results.Reward = make([][]*hexutil.Big, len(reward))
+		for i, w := range reward {
+			results.Reward[i] = make([]*hexutil.Big, len(w))
+			for j, v := range w {
+				results.Reward[i][j] = (*hexutil.Big)(v)
+			}
+		}
+	}
+	return results, nil
+}
+
 // Syncing returns false in case the node is currently not syncing with the network. It can be up to date or has not
 // yet received the latest block headers from its pears. In case it is synchronizing:
 // - startingBlock: block number this node started to synchronise from
@@ -665,6 +697,9 @@ func (s *PublicBlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.B
 		}
 		return response, err
 	}
+	if err == nil && number >= 0 {
+		err = checkPruned(s.b, uint64(number))
+	}
 	return nil, err
 }
 
@@ -675,6 +710,9 @@ func (s *PublicBlockChainAPI) GetBlockByHash(ctx context.Context, hash common.Ha
 	if block != nil {
 		return s.rpcMarshalBlock(ctx, block, true, fullTx)
 	}
+	if header, _ := s.b.HeaderByHash(ctx, hash); err == nil && header != nil {
+		err = checkPruned(s.b, header.Number.Uint64())
+	}
 	return nil, err
 }
 
@@ -728,6 +766,42 @@ func (s *PublicBlockChainAPI) GetUncleCountByBlockHash(ctx context.Context, bloc
 	return nil
 }
 
+// GetBlockReceipts returns the receipts of all transactions in the block for
+// the given block number or hash.
+func (s *PublicBlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		if header, _ := s.b.HeaderByNumberOrHash(ctx, blockNrOrHash); header != nil {
+			return nil, checkPruned(s.b, header.Number.Uint64())
+		}
+		return nil, nil
+	}
+	receipts, err := s.b.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if receipts == nil && block.Transactions().Len() > 0 {
+		if err := checkPruned(s.b, block.NumberU64()); err != nil {
+			return nil, err
+		}
+	}
+	txs := block.Transactions()
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("receipts length mismatch: %d vs %d", len(txs), len(receipts))
+	}
+	// Derive the signer once for the whole block.
+	signer := types.MakeSigner(s.b.ChainConfig(), block.Number())
+
+	result := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		result[i] = marshalReceipt(receipt, block.Hash(), block.NumberU64(), signer, txs[i], i)
+	}
+	return result, nil
+}
+
 // GetCode returns the code stored at the given address in the state for the given block number.
 func (s *PublicBlockChainAPI) GetCode(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
@@ -802,13 +876,13 @@ func (args *CallArgs) ToMessage(globalGasCap uint64) types.Message {
 	return msg
 }
 
-// account indicates the overriding fields of account during the execution of
-// a message call.
+// OverrideAccount indicates the overriding fields of account during the execution
+// of a message call.
 // Note, state and stateDiff can't be specified at the same time. If state is
 // set, message execution will only use the data in the given state. Otherwise
 // if statDiff is set, all diff will be applied first and then execute the call
 // message.
-type account struct {
+type OverrideAccount struct {
 	Nonce     *hexutil.Uint64              `json:"nonce"`
 	Code      *hexutil.Bytes               `json:"code"`
 	Balance   **hexutil.Big                `json:"balance"`
@@ -816,15 +890,15 @@ type account struct {
 	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
 }
 
-func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides map[common.Address]account, vmCfg vm.Config, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
-	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+// StateOverride is the collection of overridden accounts.
+type StateOverride map[common.Address]OverrideAccount
 
-	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
-	if state == nil || err != nil {
-		return nil, err
+// Apply overrides the fields of specified accounts into the given state.
+func (diff *StateOverride) Apply(state *state.StateDB) error {
+	if diff == nil {
+		return nil
 	}
-	// Override the fields of specified contracts before execution.
-	for addr, account := range overrides {
+	for addr, account := range *diff {
 		// Override account nonce.
 		if account.Nonce != nil {
 			state.SetNonce(addr, uint64(*account.Nonce))
@@ -838,7 +912,7 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 			state.SetBalance(addr, (*big.Int)(*account.Balance))
 		}
 		if account.State != nil && account.StateDiff != nil {
-			return nil, fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
 		}
 		// Replace entire state if caller requires.
 		if account.State != nil {
@@ -851,6 +925,51 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 			}
 		}
 	}
+	return nil
+}
+
+// BlockOverrides is a set of header fields to override during the execution
+// of a message call.
+type BlockOverrides struct {
+	Number     *hexutil.Big    `json:"number"`
+	Difficulty *hexutil.Big    `json:"difficulty"`
+	Time       *hexutil.Uint64 `json:"time"`
+	GasLimit   *hexutil.Uint64 `json:"gasLimit"`
+	Coinbase   *common.Address `json:"coinbase"`
+}
+
+// Apply overrides the given header fields into the given block context.
+func (diff *BlockOverrides) Apply(blockCtx *vm.BlockContext) {
+	if diff == nil {
+		return
+	}
+	if diff.Number != nil {
+		blockCtx.BlockNumber = diff.Number.ToInt()
+	}
+	if diff.Difficulty != nil {
+		blockCtx.Difficulty = diff.Difficulty.ToInt()
+	}
+	if diff.Time != nil {
+		blockCtx.Time = new(big.Int).SetUint64(uint64(*diff.Time))
+	}
+	if diff.GasLimit != nil {
+		blockCtx.GasLimit = uint64(*diff.GasLimit)
+	}
+	if diff.Coinbase != nil {
+		blockCtx.Coinbase = *diff.Coinbase
+	}
+}
+
+func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, vmCfg vm.Config, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+
+	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(state); err != nil {
+		return nil, err
+	}
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
 	var cancel context.CancelFunc
@@ -865,7 +984,7 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 
 	// Get a new instance of the EVM.
 	msg := args.ToMessage(globalGasCap)
-	evm, vmError, err := b.GetEVM(ctx, msg, state, header)
+	evm, vmError, err := b.GetEVM(ctx, msg, state, header, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -923,18 +1042,47 @@ func (e *revertError) ErrorData() interface{} {
 	return e.reason
 }
 
+// prunedHistoryError is an API error returned if the requested block data was
+// deleted by history expiry.
+type prunedHistoryError struct {
+	number uint64
+}
+
+func (e *prunedHistoryError) Error() string {
+	return fmt.Sprintf("pruned history unavailable for block #%d", e.number)
+}
+
+// ErrorCode returns the JSON error code for pruned history.
+func (e *prunedHistoryError) ErrorCode() int {
+	return 4444
+}
+
+// checkPruned returns a prunedHistoryError if the body and receipts of the block
+// with the given number were deleted by history expiry.
+func checkPruned(b Backend, number uint64) error {
+	if number < rawdb.ReadHistoryTail(b.ChainDb()) {
+		return &prunedHistoryError{number: number}
+	}
+	return nil
+}
+
+// checkPrunedTransaction returns a prunedHistoryError if the transaction with
+// the given hash is still indexed, but its block was deleted by history expiry.
+func checkPrunedTransaction(b Backend, hash common.Hash) error {
+	if number := rawdb.ReadTxLookupEntry(b.ChainDb(), hash); number != nil {
+		return checkPruned(b, *number)
+	}
+	return nil
+}
+
 // Call executes the given transaction on the state for the given block number.
 //
 // Additionally, the caller can specify a batch of contract for fields overriding.
 //
 // Note, this function doesn't make and changes in the state/blockchain and is
 // useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *map[common.Address]account) (hexutil.Bytes, error) {
-	var accounts map[common.Address]account
-	if overrides != nil {
-		accounts = *overrides
-	}
-	result, err := DoCall(ctx, s.b, args, blockNrOrHash, accounts, vm.Config{}, 5*time.Second, s.b.RPCGasCap())
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride) (hexutil.Bytes, error) {
+	result, err := DoCall(ctx, s.b, args, blockNrOrHash, overrides, vm.Config{}, 5*time.Second, s.b.RPCGasCap())
 	if err != nil {
 		return nil, err
 	}
@@ -945,6 +1093,161 @@ func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOr
 	return result.Return(), result.Err
 }
 
+// BundleCall is a single entry of a call bundle. It is either a plain call, or
+// if Raw is set, a signed transaction in its binary encoding.
+type BundleCall struct {
+	CallArgs
+	Raw *hexutil.Bytes `json:"raw"`
+}
+
+// bundleCallResult is the outcome of a single entry of a call bundle.
+type bundleCallResult struct {
+	ReturnValue hexutil.Bytes  `json:"returnValue"`
+	Logs        []*types.Log   `json:"logs"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	Error       string         `json:"error,omitempty"`
+	Revert      hexutil.Bytes  `json:"revert,omitempty"`
+}
+
+// DoCallBundle executes the given calls in order on top of the state of the given
+// block. Every call sees the state changes left behind by the ones before it. The
+// state overrides are applied before the first call, the block overrides change
+// the block context all calls are executed in.
+//
+// All calls share the gas limit of the (overridden) block. Calls which don't
+// specify gas get the gas remaining in the block, capped by globalGasCap.
+func DoCallBundle(ctx context.Context, b Backend, calls []BundleCall, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides, timeout time.Duration, globalGasCap uint64) ([]*bundleCallResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call bundle finished", "runtime", time.Since(start)) }(time.Now())
+
+	if len(calls) == 0 {
+		return nil, errors.New("empty call bundle")
+	}
+	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(state); err != nil {
+		return nil, err
+	}
+	// Setup context so it may be cancelled the bundle has completed
+	// or, in case of unmetered gas, setup a context with a timeout.
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	// Make sure the context is cancelled when the bundle has completed
+	// this makes sure resources are cleaned up.
+	defer cancel()
+
+	number, gasLimit := header.Number, header.GasLimit
+	if blockOverrides != nil && blockOverrides.Number != nil {
+		number = blockOverrides.Number.ToInt()
+	}
+	if blockOverrides != nil && blockOverrides.GasLimit != nil {
+		gasLimit = uint64(*blockOverrides.GasLimit)
+	}
+	var (
+		signer      = types.MakeSigner(b.ChainConfig(), number)
+		deleteEmpty = b.ChainConfig().IsEIP158(number)
+		blockCtx    *vm.BlockContext
+		gp          = new(core.GasPool).AddGas(gasLimit)
+		results     = make([]*bundleCallResult, 0, len(calls))
+	)
+	for i, call := range calls {
+		// Assemble the message to execute, plain calls have no transaction hash
+		var (
+			msg    core.Message
+			txHash common.Hash
+		)
+		if call.Raw != nil {
+			tx := new(types.Transaction)
+			if err := tx.UnmarshalBinary(*call.Raw); err != nil {
+				return nil, fmt.Errorf("bundle entry %d: %v", i, err)
+			}
+			if msg, err = tx.AsMessage(signer); err != nil {
+				return nil, fmt.Errorf("bundle entry %d: %v", i, err)
+			}
+			txHash = tx.Hash()
+		} else {
+			gasCap := globalGasCap
+			if call.Gas == nil && (gasCap == 0 || gasCap > gp.Gas()) {
+				gasCap = gp.Gas()
+			}
+			msg = call.ToMessage(gasCap)
+		}
+		// Derive the block context from the first call and share it across the
+		// whole bundle, overriding the requested header fields.
+		if blockCtx == nil {
+			evm, _, err := b.GetEVM(ctx, msg, state, header, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			vmctx := evm.Context
+			blockOverrides.Apply(&vmctx)
+			blockCtx = &vmctx
+		}
+		evm, vmError, err := b.GetEVM(ctx, msg, state, header, nil, blockCtx)
+		if err != nil {
+			return nil, err
+		}
+		// Wait for the context to be done and cancel the evm. Even if the
+		// EVM has finished, cancelling may be done (repeatedly)
+		go func() {
+			<-ctx.Done()
+			evm.Cancel()
+		}()
+
+		// Execute the message, collecting only the logs it emitted itself
+		state.Prepare(txHash, header.Hash(), i)
+		prevLogs := len(state.GetLogs(txHash))
+
+		result, err := core.ApplyMessage(evm, msg, gp)
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		// If the timer caused an abort, return an appropriate error message
+		if evm.Cancelled() {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bundle entry %d: %w (supplied gas %d)", i, err, msg.Gas())
+		}
+		state.Finalise(deleteEmpty)
+
+		res := &bundleCallResult{
+			ReturnValue: result.Return(),
+			Logs:        state.GetLogs(txHash)[prevLogs:],
+			GasUsed:     hexutil.Uint64(result.UsedGas),
+		}
+		if res.Logs == nil {
+			res.Logs = []*types.Log{}
+		}
+		if len(result.Revert()) > 0 {
+			res.Error = newRevertError(result).Error()
+			res.Revert = result.Revert()
+		} else if result.Err != nil {
+			res.Error = result.Err.Error()
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// CallBundle executes the given calls or signed transactions in order on the
+// state for the given block number, each of them seeing the state changes of
+// the ones before it.
+//
+// Additionally, the caller can specify a batch of contract for fields overriding
+// and a set of block header fields to override.
+//
+// Note, this function doesn't make and changes in the state/blockchain and is
+// useful to simulate dependent transactions.
+func (s *PublicBlockChainAPI) CallBundle(ctx context.Context, calls []BundleCall, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, blockOverrides *BlockOverrides) ([]*bundleCallResult, error) {
+	return DoCallBundle(ctx, s.b, calls, blockNrOrHash, overrides, blockOverrides, 5*time.Second, s.b.RPCGasCap())
+}
+
 func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, gasCap uint64) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
@@ -1064,6 +1367,106 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, bl
 	return DoEstimateGas(ctx, s.b, args, bNrOrHash, s.b.RPCGasCap())
 }
 
+// accessListResult returns an optional accesslist
+// It's the result of the `eth_createAccessList` RPC call.
+// It contains an error if the transaction itself failed.
+type accessListResult struct {
+	Accesslist *types.AccessList `json:"accessList"`
+	Error      string            `json:"error,omitempty"`
+	GasUsed    hexutil.Uint64    `json:"gasUsed"`
+}
+
+// CreateAccessList creates a EIP-2930 type AccessList for the given call.
+// BlockNrOrHash can be specified to create the accessList on top of a certain state.
+func (s *PublicBlockChainAPI) CreateAccessList(ctx context.Context, args CallArgs, blockNrOrHash *rpc.BlockNumberOrHash) (*accessListResult, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	acl, gasUsed, vmerr, err := AccessList(ctx, s.b, bNrOrHash, args)
+	if err != nil {
+		return nil, err
+	}
+	result := &accessListResult{Accesslist: &acl, GasUsed: hexutil.Uint64(gasUsed)}
+	if vmerr != nil {
+		result.Error = vmerr.Error()
+	}
+	return result, nil
+}
+
+// AccessList creates an access list for the given call. The call is repeatedly
+// executed with the access list gathered in the previous run until the set of
+// touched accounts and storage slots no longer changes.
+// If the accesslist creation fails an error is returned.
+// If the call itself fails, an vmErr is returned.
+func AccessList(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrHash, args CallArgs) (acl types.AccessList, gasUsed uint64, vmErr error, err error) {
+	// Retrieve the execution context
+	db, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if db == nil || err != nil {
+		return nil, 0, nil, err
+	}
+	// Use zero address if sender unspecified.
+	if args.From == nil {
+		args.From = new(common.Address)
+	}
+	// If the gas amount is not set, the estimate depends on the access list
+	// and needs to be recalculated on every iteration.
+	nogas := args.Gas == nil
+
+	// Extract the recipient, deriving the contract address for creations
+	var to common.Address
+	if args.To != nil {
+		to = *args.To
+	} else {
+		to = crypto.CreateAddress(*args.From, db.GetNonce(*args.From))
+	}
+	// Retrieve the precompiles since they don't need to be added to the access list
+	precompiles := vm.ActivePrecompiles(b.ChainConfig().Rules(header.Number))
+
+	// Create an initial tracer
+	prevTracer := vm.NewAccessListTracer(nil, *args.From, to, precompiles)
+	if args.AccessList != nil {
+		prevTracer = vm.NewAccessListTracer(*args.AccessList, *args.From, to, precompiles)
+	}
+	for {
+		// Retrieve the current access list to expand
+		accessList := prevTracer.AccessList()
+		log.Trace("Creating access list", "input", accessList)
+
+		// If no gas amount was specified, each unique access list needs its own
+		// gas calculation. This is quite expensive, but we need to be accurate
+		// and it's covered by the sender only anyway.
+		args.AccessList = &accessList
+		if nogas {
+			args.Gas = nil
+			gas, err := DoEstimateGas(ctx, b, args, blockNrOrHash, b.RPCGasCap())
+			if err != nil {
+				return nil, 0, nil, err
+			}
+			args.Gas = &gas
+		}
+		// Copy the original db so we don't modify it
+		statedb := db.Copy()
+		msg := args.ToMessage(b.RPCGasCap())
+
+		// Apply the call with the access list tracer
+		tracer := vm.NewAccessListTracer(accessList, *args.From, to, precompiles)
+		config := vm.Config{Tracer: tracer, Debug: true}
+		vmenv, _, err := b.GetEVM(ctx, msg, statedb, header, &config, nil)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		res, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("failed to apply call: %v", err)
+		}
+		if tracer.Equal(prevTracer) {
+			return accessList, res.UsedGas, res.Err, nil
+		}
+		prevTracer = tracer
+	}
+}
+
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as transaction
 // execution status, the amount of gas used and the return value
@@ -1401,6 +1804,10 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
 		return newRPCPendingTransaction(tx), nil
 	}
+	// The transaction might be known, but its block body pruned
+	if err := checkPrunedTransaction(s.b, hash); err != nil {
+		return nil, err
+	}
 
 	// Transaction unknown, return as such
 	return nil, nil
@@ -1429,25 +1836,33 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if err != nil {
 		return nil, nil
 	}
+	if tx == nil {
+		return nil, checkPrunedTransaction(s.b, hash)
+	}
 	receipts, err := s.b.GetReceipts(ctx, blockHash)
 	if err != nil {
 		return nil, err
 	}
 	if len(receipts) <= int(index) {
-		return nil, nil
+		return nil, checkPruned(s.b, blockNumber)
 	}
 	receipt := receipts[index]
 
 	// Derive the sender.
 	bigblock := new(big.Int).SetUint64(blockNumber)
 	signer := types.MakeSigner(s.b.ChainConfig(), bigblock)
+	return marshalReceipt(receipt, blockHash, blockNumber, signer, tx, int(index)), nil
+}
+
+// marshalReceipt marshals a transaction receipt into a JSON object.
+func marshalReceipt(receipt *types.Receipt, blockHash common.Hash, blockNumber uint64, signer types.Signer, tx *types.Transaction, txIndex int) map[string]interface{} {
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
 		"blockHash":         blockHash,
 		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
-		"transactionIndex":  hexutil.Uint64(index),
+		"transactionHash":   tx.Hash(),
+		"transactionIndex":  hexutil.Uint64(txIndex),
 		"from":              from,
 		"to":                tx.To(),
 		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
@@ -1471,7 +1886,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
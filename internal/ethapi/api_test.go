@@ -0,0 +1,241 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// callBundleBackend is a Backend serving a single fixed state for call tests.
+// Methods not needed by DoCallBundle are left unimplemented.
+type callBundleBackend struct {
+	Backend
+	state  *state.StateDB
+	header *types.Header
+}
+
+func (b *callBundleBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+
+func (b *callBundleBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	return b.state, b.header, nil
+}
+
+func (b *callBundleBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config, blockCtx *vm.BlockContext) (*vm.EVM, func() error, error) {
+	context := core.NewEVMBlockContext(header, nil, &header.Coinbase)
+	if blockCtx != nil {
+		context = *blockCtx
+	}
+	return vm.NewEVM(context, core.NewEVMTxContext(msg), state, params.TestChainConfig, vm.Config{}), func() error { return nil }, nil
+}
+
+// counterCode increments storage slot zero and returns the new value.
+var counterCode = common.FromHex("6000546001018060005560005260206000f3")
+
+func newCallBundleBackend(t *testing.T, contract common.Address) *callBundleBackend {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb.SetCode(contract, counterCode)
+	return &callBundleBackend{
+		state: statedb,
+		header: &types.Header{
+			Number:     big.NewInt(1),
+			Difficulty: big.NewInt(1),
+			GasLimit:   params.GenesisGasLimit,
+		},
+	}
+}
+
+func TestCallBundleStateCarryOver(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0xc0ffee")
+		backend  = newCallBundleBackend(t, contract)
+		call     = BundleCall{CallArgs: CallArgs{To: &contract}}
+	)
+	results, err := DoCallBundle(context.Background(), backend, []BundleCall{call, call, call}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), nil, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, res := range results {
+		if res.Error != "" {
+			t.Fatalf("call %d failed: %v", i, res.Error)
+		}
+		want := common.BigToHash(big.NewInt(int64(i + 1)))
+		if common.BytesToHash(res.ReturnValue) != want {
+			t.Errorf("call %d: wrong counter value %x, want %x", i, res.ReturnValue, want)
+		}
+	}
+}
+
+func TestCallBundleGasPool(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0xc0ffee")
+		gasLimit = hexutil.Uint64(100000)
+		gas      = hexutil.Uint64(60000)
+		override = &BlockOverrides{GasLimit: &gasLimit}
+		latest   = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	)
+
+	// Calls without gas get the remaining gas of the overridden block.
+	implicit := BundleCall{CallArgs: CallArgs{To: &contract}}
+	results, err := DoCallBundle(context.Background(), newCallBundleBackend(t, contract), []BundleCall{implicit, implicit}, latest, nil, override, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].Error != "" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	// Calls which specify more gas than is left in the block exhaust the pool.
+	explicit := BundleCall{CallArgs: CallArgs{To: &contract, Gas: &gas}}
+	_, err = DoCallBundle(context.Background(), newCallBundleBackend(t, contract), []BundleCall{explicit, explicit}, latest, nil, override, 0, 0)
+	if err == nil || !strings.Contains(err.Error(), "bundle entry 1") || !strings.Contains(err.Error(), core.ErrGasLimitReached.Error()) {
+		t.Fatalf("expected gas pool exhaustion in second entry, got %v", err)
+	}
+
+	// Without the override, the header gas limit applies.
+	_, err = DoCallBundle(context.Background(), newCallBundleBackend(t, contract), []BundleCall{explicit, explicit}, latest, nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("bundle within header gas limit failed: %v", err)
+	}
+}
+
+// prunedDatabase is a database whose ancient bodies and receipts are deleted
+// below a given block.
+type prunedDatabase struct {
+	ethdb.Database
+	tail uint64
+}
+
+func (db *prunedDatabase) AncientTail(kind string) (uint64, error) { return db.tail, nil }
+
+// prunedBackend is a Backend knowing the headers, but no bodies or receipts of
+// a chain whose history was pruned. Methods not needed by the block and
+// transaction retrieval APIs are left unimplemented.
+type prunedBackend struct {
+	Backend
+	db      *prunedDatabase
+	headers map[common.Hash]*types.Header
+}
+
+func (b *prunedBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+
+func (b *prunedBackend) ChainDb() ethdb.Database { return b.db }
+
+func (b *prunedBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	return nil, nil
+}
+
+func (b *prunedBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return nil, nil
+}
+
+func (b *prunedBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	return nil, nil
+}
+
+func (b *prunedBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return b.headers[hash], nil
+}
+
+func (b *prunedBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		return b.headers[hash], nil
+	}
+	number, _ := blockNrOrHash.Number()
+	for _, header := range b.headers {
+		if header.Number.Int64() == number.Int64() {
+			return header, nil
+		}
+	}
+	return nil, nil
+}
+
+func (b *prunedBackend) GetTransaction(ctx context.Context, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	return nil, common.Hash{}, 0, 0, nil
+}
+
+func (b *prunedBackend) GetPoolTransaction(hash common.Hash) *types.Transaction { return nil }
+
+// Tests that requesting block data deleted by history expiry fails with a pruned
+// history error, while unknown data is still reported as missing.
+func TestPrunedHistoryErrors(t *testing.T) {
+	var (
+		header    = &types.Header{Number: big.NewInt(5), Difficulty: big.NewInt(1)}
+		prunedTx  = common.HexToHash("0x01")
+		unknownTx = common.HexToHash("0x02")
+		backend   = &prunedBackend{
+			db:      &prunedDatabase{Database: rawdb.NewMemoryDatabase(), tail: 10},
+			headers: map[common.Hash]*types.Header{header.Hash(): header},
+		}
+		chainAPI = NewPublicBlockChainAPI(backend)
+		txAPI    = NewPublicTransactionPoolAPI(backend, new(AddrLocker))
+		ctx      = context.Background()
+	)
+	rawdb.WriteTxLookupEntries(backend.db, header.Number.Uint64(), []common.Hash{prunedTx})
+
+	checkPruned := func(name string, err error) {
+		t.Helper()
+		rerr, ok := err.(rpc.Error)
+		if !ok {
+			t.Errorf("%s: error mismatch: have %v, want pruned history error", name, err)
+			return
+		}
+		if rerr.ErrorCode() != 4444 {
+			t.Errorf("%s: error code mismatch: have %d, want %d", name, rerr.ErrorCode(), 4444)
+		}
+	}
+	_, err := chainAPI.GetBlockByNumber(ctx, 5, false)
+	checkPruned("GetBlockByNumber", err)
+	_, err = chainAPI.GetBlockByHash(ctx, header.Hash(), false)
+	checkPruned("GetBlockByHash", err)
+	_, err = chainAPI.GetBlockReceipts(ctx, rpc.BlockNumberOrHashWithNumber(5))
+	checkPruned("GetBlockReceipts", err)
+	_, err = txAPI.GetTransactionByHash(ctx, prunedTx)
+	checkPruned("GetTransactionByHash", err)
+	_, err = txAPI.GetTransactionReceipt(ctx, prunedTx)
+	checkPruned("GetTransactionReceipt", err)
+
+	// Data above the tail and unknown transactions are simply missing
+	if block, err := chainAPI.GetBlockByNumber(ctx, 15, false); block != nil || err != nil {
+		t.Errorf("GetBlockByNumber above tail: have %v, %v, want nil, nil", block, err)
+	}
+	if receipts, err := chainAPI.GetBlockReceipts(ctx, rpc.BlockNumberOrHashWithNumber(15)); receipts != nil || err != nil {
+		t.Errorf("GetBlockReceipts above tail: have %v, %v, want nil, nil", receipts, err)
+	}
+	if tx, err := txAPI.GetTransactionByHash(ctx, unknownTx); tx != nil || err != nil {
+		t.Errorf("GetTransactionByHash unknown: have %v, %v, want nil, nil", tx, err)
+	}
+	if receipt, err := txAPI.GetTransactionReceipt(ctx, unknownTx); receipt != nil || err != nil {
+		t.Errorf("GetTransactionReceipt unknown: have %v, %v, want nil, nil", receipt, err)
+	}
+}
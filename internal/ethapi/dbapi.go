@@ -0,0 +1,118 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// maxDbIterateItems is the maximum number of entries returned by DbIterate and
+// DbAncientRange.
+const maxDbIterateItems = 10000
+
+// dbNotFoundError is returned by the database debug methods if the requested
+// item doesn't exist. Its error code allows remote readers to tell missing items
+// apart from database failures.
+type dbNotFoundError struct{}
+
+func (dbNotFoundError) Error() string { return "not found" }
+
+func (dbNotFoundError) ErrorCode() int { return ethdb.NotFoundErrorCode }
+
+// DbGet returns the raw value of a key stored in the database.
+func (api *PrivateDebugAPI) DbGet(key hexutil.Bytes) (hexutil.Bytes, error) {
+	db := api.b.ChainDb()
+	if has, err := db.Has(key); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, dbNotFoundError{}
+	}
+	return db.Get(key)
+}
+
+// DbRange is a batch of consecutive database entries returned by DbIterate.
+type DbRange struct {
+	Keys   []hexutil.Bytes `json:"keys"`
+	Values []hexutil.Bytes `json:"values"`
+	Next   hexutil.Bytes   `json:"next,omitempty"` // Start of the next batch, nil if done
+}
+
+// DbIterate returns up to limit database entries with the given key prefix,
+// starting at the given key (relative to the prefix). If more entries are left,
+// the start key of the next batch is returned too.
+func (api *PrivateDebugAPI) DbIterate(prefix, start hexutil.Bytes, limit int) (*DbRange, error) {
+	if limit <= 0 || limit > maxDbIterateItems {
+		limit = maxDbIterateItems
+	}
+	it := api.b.ChainDb().NewIterator(prefix, start)
+	defer it.Release()
+
+	result := new(DbRange)
+	for it.Next() {
+		if len(result.Keys) == limit {
+			result.Next = common.CopyBytes(it.Key()[len(prefix):])
+			break
+		}
+		result.Keys = append(result.Keys, common.CopyBytes(it.Key()))
+		result.Values = append(result.Values, common.CopyBytes(it.Value()))
+	}
+	return result, it.Error()
+}
+
+// DbAncient retrieves an ancient binary blob from the append-only immutable files.
+// It is a mapping to the `AncientReader.Ancient` method.
+func (api *PrivateDebugAPI) DbAncient(kind string, number uint64) (hexutil.Bytes, error) {
+	db := api.b.ChainDb()
+	if has, err := db.HasAncient(kind, number); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, dbNotFoundError{}
+	}
+	return db.Ancient(kind, number)
+}
+
+// DbAncientRange retrieves up to count consecutive ancient items starting at
+// start, stopping early once their combined size would exceed maxBytes.
+// It is a mapping to the `AncientReader.AncientRange` method.
+func (api *PrivateDebugAPI) DbAncientRange(kind string, start, count, maxBytes uint64) ([]hexutil.Bytes, error) {
+	if count > maxDbIterateItems {
+		count = maxDbIterateItems
+	}
+	db := api.b.ChainDb()
+	if has, err := db.HasAncient(kind, start); err != nil {
+		return nil, err
+	} else if !has {
+		return nil, dbNotFoundError{}
+	}
+	items, err := db.AncientRange(kind, start, count, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	blobs := make([]hexutil.Bytes, len(items))
+	for i, item := range items {
+		blobs[i] = item
+	}
+	return blobs, nil
+}
+
+// DbAncients returns the ancient item numbers in the ancient store.
+// It is a mapping to the `AncientReader.Ancients` method.
+func (api *PrivateDebugAPI) DbAncients() (uint64, error) {
+	return api.b.ChainDb().Ancients()
+}
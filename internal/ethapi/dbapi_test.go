@@ -0,0 +1,143 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/ethdb/remotedb"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// dbBackend is a Backend exposing only a chain database.
+type dbBackend struct {
+	Backend
+	db ethdb.Database
+}
+
+func (b *dbBackend) ChainDb() ethdb.Database { return b.db }
+
+// Tests that a remote database served by the debug API reports missing items
+// without an error, passes on failures and iterates over all entries.
+func TestDebugDbRemote(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	for i := 0; i < maxDbIterateItems+10; i++ {
+		db.Put([]byte(fmt.Sprintf("key-%06d", i)), []byte{byte(i)})
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("debug", NewPrivateDebugAPI(&dbBackend{db: db})); err != nil {
+		t.Fatal(err)
+	}
+	remote := remotedb.New(rpc.DialInProc(server))
+	defer remote.Close()
+
+	if has, err := remote.Has([]byte("key-000001")); err != nil || !has {
+		t.Errorf("Has(key-000001) = %v, %v; want true, nil", has, err)
+	}
+	if has, err := remote.Has([]byte("missing")); err != nil || has {
+		t.Errorf("Has(missing) = %v, %v; want false, nil", has, err)
+	}
+	// The memory database has no ancient store, which is a failure rather
+	// than a missing item.
+	if has, err := remote.HasAncient("headers", 0); err == nil {
+		t.Errorf("HasAncient(headers, 0) = %v, nil; want error", has)
+	}
+	it := remote.NewIterator([]byte("key-"), nil)
+	defer it.Release()
+
+	var count int
+	for it.Next() {
+		if want := []byte(fmt.Sprintf("key-%06d", count)); !bytes.Equal(it.Key(), want) {
+			t.Fatalf("key %d mismatch: have %q, want %q", count, it.Key(), want)
+		}
+		count++
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	if count != maxDbIterateItems+10 {
+		t.Errorf("item count mismatch: have %d, want %d", count, maxDbIterateItems+10)
+	}
+}
+
+// Tests that ranged ancient retrievals through a remote database are limited in
+// size by the serving node.
+func TestDebugDbRemoteAncientRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbapi-ancients")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := rawdb.NewDatabaseWithFreezer(memorydb.New(), dir, "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+	for i := uint64(0); i < 10; i++ {
+		blob := bytes.Repeat([]byte{byte(i)}, 100)
+		if err := db.AppendAncient(i, blob[:32], blob, blob, blob, blob); err != nil {
+			t.Fatalf("failed to append ancient %d: %v", i, err)
+		}
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("debug", NewPrivateDebugAPI(&dbBackend{db: db})); err != nil {
+		t.Fatal(err)
+	}
+	remote := remotedb.New(rpc.DialInProc(server))
+	defer remote.Close()
+
+	tests := []struct {
+		start, count, maxBytes uint64
+		want                   int
+	}{
+		{0, 10, 1000, 10},
+		{0, 10, 350, 3},
+		{2, 5, 1000, 5},
+		{8, 5, 1000, 2},
+		{0, 10, 10, 1}, // Always at least one item
+	}
+	for i, tt := range tests {
+		items, err := remote.AncientRange("headers", tt.start, tt.count, tt.maxBytes)
+		if err != nil {
+			t.Fatalf("test %d: range retrieval failed: %v", i, err)
+		}
+		if len(items) != tt.want {
+			t.Errorf("test %d: item count mismatch: have %d, want %d", i, len(items), tt.want)
+		}
+		for j, item := range items {
+			if want := bytes.Repeat([]byte{byte(tt.start) + byte(j)}, 100); !bytes.Equal(item, want) {
+				t.Errorf("test %d: item %d mismatch: have %x, want %x", i, j, item, want)
+			}
+		}
+	}
+	if has, err := remote.HasAncient("headers", 10); err != nil || has {
+		t.Errorf("HasAncient(headers, 10) = %v, %v; want false, nil", has, err)
+	}
+	if _, err := remote.AncientRange("headers", 10, 1, 1000); err == nil {
+		t.Error("range retrieval beyond the ancients succeeded")
+	}
+}
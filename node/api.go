@@ -19,7 +19,9 @@ package node
 import (
 	"context"
 	"fmt"
+	"net"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -125,6 +127,94 @@ func (api *privateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
 	return true, nil
 }
 
+// BanInfo describes a node ID or IP address ban.
+type BanInfo struct {
+	ID     string     `json:"id,omitempty"`
+	IP     string     `json:"ip,omitempty"`
+	Expiry *time.Time `json:"expiry,omitempty"` // nil for permanent bans
+	Reason string     `json:"reason,omitempty"`
+}
+
+// parseBanTarget converts an enode URL, a node ID or an IP address into a ban.
+func parseBanTarget(target string) (enode.Ban, error) {
+	if ip := net.ParseIP(target); ip != nil {
+		return enode.Ban{IP: ip}, nil
+	}
+	if id, err := enode.ParseID(target); err == nil {
+		return enode.Ban{ID: id}, nil
+	}
+	node, err := enode.Parse(enode.ValidSchemes, target)
+	if err != nil {
+		return enode.Ban{}, fmt.Errorf("invalid ban target, want enode URL, node ID or IP: %v", err)
+	}
+	return enode.Ban{ID: node.ID()}, nil
+}
+
+// BanPeer bans a remote node by enode URL, node ID or IP address. Banned nodes are
+// disconnected, never dialed and their inbound connections are rejected. The ban is
+// lifted after the given number of seconds, or never if no duration is given.
+func (api *privateAdminAPI) BanPeer(target string, seconds *uint64, reason *string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	ban, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	if seconds != nil && *seconds > 0 {
+		ban.Expiry = time.Now().Add(time.Duration(*seconds) * time.Second)
+	}
+	if reason != nil {
+		ban.Reason = *reason
+	}
+	if err := server.BanPeer(ban); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnbanPeer lifts the ban on an enode URL, node ID or IP address. It returns false
+// if no such ban exists.
+func (api *privateAdminAPI) UnbanPeer(target string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	ban, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	return server.UnbanPeer(ban)
+}
+
+// ListBans returns all node ID and IP address bans currently in effect.
+func (api *privateAdminAPI) ListBans() ([]*BanInfo, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	bans := server.Bans()
+	infos := make([]*BanInfo, 0, len(bans))
+	for _, b := range bans {
+		info := &BanInfo{Reason: b.Reason}
+		if b.IP != nil {
+			info.IP = b.IP.String()
+		} else {
+			info.ID = b.ID.String()
+		}
+		if !b.Expiry.IsZero() {
+			expiry := b.Expiry
+			info.Expiry = &expiry
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *privateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
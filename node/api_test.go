@@ -25,6 +25,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/stretchr/testify/assert"
 )
@@ -340,6 +341,34 @@ func checkRPC(url string) bool {
 	return err == nil
 }
 
+func TestParseBanTarget(t *testing.T) {
+	const id = "a979fb575495b8d6db44f750317d0f4622bf4c2aa3365d6af7c284339968eef29b69ad0dce72a4d8db5ebb4968de0e3bec910127f134779fbcb0cb6d3331163c"
+	node := enode.MustParse("enode://" + id + "@127.0.0.1:30303")
+
+	tests := []struct {
+		target string
+		want   enode.Ban
+		err    bool
+	}{
+		{target: node.String(), want: enode.Ban{ID: node.ID()}},
+		{target: node.ID().String(), want: enode.Ban{ID: node.ID()}},
+		{target: "0x" + node.ID().String(), want: enode.Ban{ID: node.ID()}},
+		{target: "10.0.0.1", want: enode.Ban{IP: net.ParseIP("10.0.0.1")}},
+		{target: "::1", want: enode.Ban{IP: net.ParseIP("::1")}},
+		{target: "foo", err: true},
+		{target: id, err: true}, // public key instead of node ID
+	}
+	for _, test := range tests {
+		ban, err := parseBanTarget(test.target)
+		if test.err {
+			assert.Error(t, err, test.target)
+			continue
+		}
+		assert.NoError(t, err, test.target)
+		assert.Equal(t, test.want, ban, test.target)
+	}
+}
+
 // string/int pointer helpers.
 func sp(s string) *string { return &s }
 func ip(i int) *int       { return &i }
@@ -171,6 +171,21 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// RPCLimits configures the resource limits (batch size, response size, request
+	// rate and method costs) enforced on the HTTP and WebSocket RPC interfaces.
+	RPCLimits rpc.Limits `toml:",omitempty"`
+
+	// HTTPEndpoints declares additional named RPC listeners. Each of them serves
+	// its own set of API modules over HTTP, and optionally WebSocket, on its own
+	// address, independently of the default endpoints configured above.
+	HTTPEndpoints map[string]HTTPEndpointConfig `toml:",omitempty"`
+
+	// JWTSecret is the path to the hex encoded secret used to authenticate requests
+	// to the HTTP and WebSocket RPC interfaces with HS256 JSON web tokens. If the
+	// file doesn't exist, it is created with a new random secret. If this field is
+	// empty, the interfaces don't require authentication.
+	JWTSecret string `toml:",omitempty"`
+
 	// GraphQLCors is the Cross-Origin Resource Sharing header to send to requesting
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
 	// useless for custom HTTP clients.
@@ -196,6 +211,37 @@ type Config struct {
 	AllowUnprotectedTxs bool `toml:",omitempty"`
 }
 
+// HTTPEndpointConfig is the configuration of a named RPC listener.
+type HTTPEndpointConfig struct {
+	// Host and Port define the interface and port the listener binds to.
+	Host string
+	Port int
+
+	// Modules is the list of API modules served by the listener. If the list is
+	// empty, all RPC API endpoints designated public will be exposed.
+	Modules []string
+
+	// Cors and VirtualHosts configure the CORS and Host-header checks of the
+	// listener, analogous to HTTPCors and HTTPVirtualHosts.
+	Cors         []string `toml:",omitempty"`
+	VirtualHosts []string `toml:",omitempty"`
+
+	// PathPrefix specifies a path prefix on which the listener serves RPC.
+	PathPrefix string `toml:",omitempty"`
+
+	// WS enables JSON-RPC over WebSocket on the listener. WebSocket connections
+	// are served the same modules on the same path prefix as HTTP requests.
+	WS bool `toml:",omitempty"`
+
+	// WSOrigins is the list of domains to accept WebSocket requests from,
+	// analogous to the WSOrigins setting of the default WebSocket endpoint.
+	WSOrigins []string `toml:",omitempty"`
+
+	// JWTSecret is the path to the secret used to authenticate requests to the
+	// listener. Authentication is disabled if it is empty.
+	JWTSecret string `toml:",omitempty"`
+}
+
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
 // account the set data folders as well as the designated platform we're currently
 // running on.
@@ -17,16 +17,22 @@
 package node
 
 import (
+	crand "crypto/rand"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -57,6 +63,8 @@ type Node struct {
 	ipc           *ipcServer  // Stores information about the ipc http server
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
 
+	endpoints map[string]*httpServer // Named HTTP RPC listeners
+
 	databases map[*closeTrackingDB]struct{} // All open databases
 }
 
@@ -142,10 +150,22 @@ func New(conf *Config) (*Node, error) {
 	if err := validatePrefix("WebSocket", conf.WSPathPrefix); err != nil {
 		return nil, err
 	}
+	for name, endpoint := range conf.HTTPEndpoints {
+		if endpoint.Host == "" {
+			return nil, fmt.Errorf("HTTP endpoint %q has no listening host", name)
+		}
+		if err := validatePrefix("HTTP endpoint "+name, endpoint.PathPrefix); err != nil {
+			return nil, err
+		}
+	}
 
 	// Configure RPC servers.
 	node.http = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ws = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
+	node.endpoints = make(map[string]*httpServer, len(conf.HTTPEndpoints))
+	for name := range conf.HTTPEndpoints {
+		node.endpoints[name] = newHTTPServer(node.log.New("listener", name), conf.HTTPTimeouts)
+	}
 	node.ipc = newIPCServer(node.log, conf.IPCEndpoint())
 
 	return node, nil
@@ -348,13 +368,24 @@ func (n *Node) startRPC() error {
 		}
 	}
 
+	// Load the JWT secret if authentication is requested.
+	var secret []byte
+	if n.config.JWTSecret != "" && (n.config.HTTPHost != "" || n.config.WSHost != "") {
+		var err error
+		if secret, err = n.obtainJWTSecret(n.config.JWTSecret); err != nil {
+			return err
+		}
+	}
+
 	// Configure HTTP.
 	if n.config.HTTPHost != "" {
 		config := httpConfig{
 			CorsAllowedOrigins: n.config.HTTPCors,
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
+			Limits:             n.config.RPCLimits,
 			prefix:             n.config.HTTPPathPrefix,
+			jwtSecret:          secret,
 		}
 		if err := n.http.setListenAddr(n.config.HTTPHost, n.config.HTTPPort); err != nil {
 			return err
@@ -368,9 +399,11 @@ func (n *Node) startRPC() error {
 	if n.config.WSHost != "" {
 		server := n.wsServerForPort(n.config.WSPort)
 		config := wsConfig{
-			Modules: n.config.WSModules,
-			Origins: n.config.WSOrigins,
-			prefix:  n.config.WSPathPrefix,
+			Modules:   n.config.WSModules,
+			Origins:   n.config.WSOrigins,
+			Limits:    n.config.RPCLimits,
+			prefix:    n.config.WSPathPrefix,
+			jwtSecret: secret,
 		}
 		if err := server.setListenAddr(n.config.WSHost, n.config.WSPort); err != nil {
 			return err
@@ -380,10 +413,89 @@ func (n *Node) startRPC() error {
 		}
 	}
 
+	// Configure the named endpoints.
+	names := make([]string, 0, len(n.config.HTTPEndpoints))
+	for name := range n.config.HTTPEndpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		endpoint := n.config.HTTPEndpoints[name]
+		config := httpConfig{
+			CorsAllowedOrigins: endpoint.Cors,
+			Vhosts:             endpoint.VirtualHosts,
+			Modules:            endpoint.Modules,
+			Limits:             n.config.RPCLimits,
+			prefix:             endpoint.PathPrefix,
+		}
+		if endpoint.JWTSecret != "" {
+			var err error
+			if config.jwtSecret, err = n.obtainJWTSecret(endpoint.JWTSecret); err != nil {
+				return err
+			}
+		}
+		server := n.endpoints[name]
+		if err := server.setListenAddr(endpoint.Host, endpoint.Port); err != nil {
+			return err
+		}
+		if err := server.enableRPC(n.rpcAPIs, config); err != nil {
+			return err
+		}
+		if endpoint.WS {
+			wsConf := wsConfig{
+				Modules:   endpoint.Modules,
+				Origins:   endpoint.WSOrigins,
+				Limits:    n.config.RPCLimits,
+				prefix:    endpoint.PathPrefix,
+				jwtSecret: config.jwtSecret,
+			}
+			if err := server.enableWS(n.rpcAPIs, wsConf); err != nil {
+				return err
+			}
+		}
+	}
+
 	if err := n.http.start(); err != nil {
 		return err
 	}
-	return n.ws.start()
+	if err := n.ws.start(); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := n.endpoints[name].start(); err != nil {
+			return fmt.Errorf("HTTP endpoint %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// obtainJWTSecret loads the hex encoded JWT secret from the given file, creating
+// the file with a new random secret if it doesn't exist yet.
+func (n *Node) obtainJWTSecret(fileName string) ([]byte, error) {
+	// Try to read the secret from the file.
+	if data, err := ioutil.ReadFile(fileName); err == nil {
+		secret := common.FromHex(strings.TrimSpace(string(data)))
+		if len(secret) != jwtSecretLength {
+			return nil, fmt.Errorf("invalid JWT secret in %s: want %d bytes, have %d", fileName, jwtSecretLength, len(secret))
+		}
+		n.log.Info("Loaded JWT secret file", "path", fileName, "crc32", fmt.Sprintf("%#x", crc32.ChecksumIEEE(secret)))
+		return secret, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	// Otherwise, generate a new secret and store it.
+	secret := make([]byte, jwtSecretLength)
+	if _, err := crand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(fileName, []byte(hexutil.Encode(secret)), 0600); err != nil {
+		return nil, err
+	}
+	n.log.Info("Generated JWT secret", "path", fileName)
+	return secret, nil
 }
 
 func (n *Node) wsServerForPort(port int) *httpServer {
@@ -396,6 +508,9 @@ func (n *Node) wsServerForPort(port int) *httpServer {
 func (n *Node) stopRPC() {
 	n.http.stop()
 	n.ws.stop()
+	for _, server := range n.endpoints {
+		server.stop()
+	}
 	n.ipc.stop()
 	n.stopInProc()
 }
@@ -530,6 +645,27 @@ func (n *Node) HTTPEndpoint() string {
 	return "http://" + n.http.listenAddr()
 }
 
+// NamedHTTPEndpoint returns the current JSON-RPC over HTTP endpoint of the named
+// listener, or the empty string if no such listener is configured.
+func (n *Node) NamedHTTPEndpoint(name string) string {
+	server, ok := n.endpoints[name]
+	if !ok {
+		return ""
+	}
+	return "http://" + server.listenAddr()
+}
+
+// NamedWSEndpoint returns the current JSON-RPC over WebSocket endpoint of the named
+// listener, or the empty string if the listener doesn't exist or has WebSocket
+// disabled.
+func (n *Node) NamedWSEndpoint(name string) string {
+	server, ok := n.endpoints[name]
+	if !ok || !server.wsAllowed() {
+		return ""
+	}
+	return "ws://" + server.listenAddr() + server.wsConfig.prefix
+}
+
 // WSEndpoint returns the current JSON-RPC over WebSocket endpoint.
 func (n *Node) WSEndpoint() string {
 	if n.http.wsAllowed() {
@@ -24,7 +24,9 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -591,6 +593,103 @@ func (test rpcPrefixTest) check(t *testing.T, node *Node) {
 	}
 }
 
+// Tests that the JWT secret file is created on first start and reused afterwards.
+func TestNodeJWTSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtsecret")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwtsecret")
+
+	start := func() *Node {
+		conf := testNodeConfig()
+		conf.HTTPHost = "127.0.0.1"
+		conf.JWTSecret = path
+		stack, err := New(conf)
+		if err != nil {
+			t.Fatalf("failed to create protocol stack: %v", err)
+		}
+		if err := stack.Start(); err != nil {
+			t.Fatalf("failed to start protocol stack: %v", err)
+		}
+		return stack
+	}
+	stack := start()
+	stack.Close()
+
+	generated, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("JWT secret not created: %v", err)
+	}
+	if secret, err := stack.obtainJWTSecret(path); err != nil {
+		t.Fatalf("failed to load JWT secret: %v", err)
+	} else if fmt.Sprintf("%#x", secret) != string(generated) {
+		t.Fatalf("JWT secret mismatch: have %#x, want %s", secret, generated)
+	}
+	// Restart the node and check that the secret is retained.
+	stack = start()
+	stack.Close()
+	if loaded, _ := ioutil.ReadFile(path); string(loaded) != string(generated) {
+		t.Fatalf("JWT secret changed on restart: have %s, want %s", loaded, generated)
+	}
+	// Invalid secrets should be rejected.
+	ioutil.WriteFile(path, []byte("0x1234"), 0600)
+	if _, err := stack.obtainJWTSecret(path); err == nil {
+		t.Fatal("expected error for invalid JWT secret")
+	}
+}
+
+// Tests that named HTTP endpoints serve their own set of modules.
+func TestNodeHTTPEndpoints(t *testing.T) {
+	conf := testNodeConfig()
+	conf.HTTPEndpoints = map[string]HTTPEndpointConfig{
+		"public":  {Host: "127.0.0.1", Modules: []string{"web3"}},
+		"private": {Host: "127.0.0.1", Modules: []string{"admin", "debug"}, PathPrefix: "/private", WS: true},
+	}
+	stack, err := New(conf)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if stack.NamedHTTPEndpoint("unknown") != "" {
+		t.Fatal("unknown endpoint has an address")
+	}
+	if stack.NamedWSEndpoint("public") != "" {
+		t.Fatal("endpoint without WebSocket has a WebSocket address")
+	}
+	tests := []struct {
+		name, url string
+		modules   []string
+	}{
+		{"public", stack.NamedHTTPEndpoint("public"), []string{"rpc", "web3"}},
+		{"private", stack.NamedHTTPEndpoint("private") + "/private", []string{"admin", "debug", "rpc"}},
+		{"private-ws", stack.NamedWSEndpoint("private"), []string{"admin", "debug", "rpc"}},
+	}
+	for _, test := range tests {
+		client, err := rpc.Dial(test.url)
+		if err != nil {
+			t.Fatalf("%s: failed to dial: %v", test.name, err)
+		}
+		modules, err := client.SupportedModules()
+		client.Close()
+		if err != nil {
+			t.Fatalf("%s: failed to query modules: %v", test.name, err)
+		}
+		var have []string
+		for module := range modules {
+			have = append(have, module)
+		}
+		sort.Strings(have)
+		if !reflect.DeepEqual(have, test.modules) {
+			t.Errorf("%s: module mismatch: have %v, want %v", test.name, have, test.modules)
+		}
+	}
+}
+
 func createNode(t *testing.T, httpPort, wsPort int) *Node {
 	conf := &Config{
 		HTTPHost: "127.0.0.1",
@@ -24,10 +24,12 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/internal/testlog"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
@@ -163,15 +165,15 @@ func TestWebsocketOrigins(t *testing.T) {
 func TestIsWebsocket(t *testing.T) {
 	r, _ := http.NewRequest("GET", "/", nil)
 
-	assert.False(t, isWebsocket(r))
+	assert.False(t, IsWebsocket(r))
 	r.Header.Set("upgrade", "websocket")
-	assert.False(t, isWebsocket(r))
+	assert.False(t, IsWebsocket(r))
 	r.Header.Set("connection", "upgrade")
-	assert.True(t, isWebsocket(r))
+	assert.True(t, IsWebsocket(r))
 	r.Header.Set("connection", "upgrade,keep-alive")
-	assert.True(t, isWebsocket(r))
+	assert.True(t, IsWebsocket(r))
 	r.Header.Set("connection", " UPGRADE,keep-alive")
-	assert.True(t, isWebsocket(r))
+	assert.True(t, IsWebsocket(r))
 }
 
 func Test_checkPath(t *testing.T) {
@@ -229,6 +231,67 @@ func Test_checkPath(t *testing.T) {
 	}
 }
 
+// TestJWT makes sure that JWT authentication is enforced on the HTTP and WS endpoints.
+func TestJWT(t *testing.T) {
+	var secret = []byte("secret")
+	issueToken := func(secret []byte, method jwt.SigningMethod, input map[string]interface{}) string {
+		if method == nil {
+			method = jwt.SigningMethodHS256
+		}
+		ss, _ := jwt.NewWithClaims(method, jwt.MapClaims(input)).SignedString(secret)
+		return ss
+	}
+	srv := createAndStartServer(t, &httpConfig{jwtSecret: secret}, true, &wsConfig{Origins: []string{"*"}, jwtSecret: secret})
+	defer srv.stop()
+	wsUrl := fmt.Sprintf("ws://%v", srv.listenAddr())
+	htUrl := fmt.Sprintf("http://%v", srv.listenAddr())
+
+	expOk := []string{
+		fmt.Sprintf("Bearer %v", issueToken(secret, nil, map[string]interface{}{"iat": time.Now().Unix()})),
+		fmt.Sprintf("Bearer %v", issueToken(secret, nil, map[string]interface{}{"iat": time.Now().Unix() + 4})),
+		fmt.Sprintf("Bearer %v", issueToken(secret, nil, map[string]interface{}{"iat": time.Now().Unix() - 4})),
+		fmt.Sprintf("Bearer %v", issueToken(secret, nil, map[string]interface{}{
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Unix() + 2,
+		})),
+	}
+	for i, token := range expOk {
+		if err := wsRequest(t, wsUrl, "", "Authorization", token); err != nil {
+			t.Errorf("test %d-ws, token '%v': expected ok, got %v", i, token, err)
+		}
+		if resp := rpcRequest(t, htUrl, "Authorization", token); resp.StatusCode != http.StatusOK {
+			t.Errorf("test %d-http, token '%v': expected ok, got %v", i, token, resp.StatusCode)
+		}
+	}
+	expFail := []string{
+		// future
+		fmt.Sprintf("Bearer %v", issueToken(secret, nil, map[string]interface{}{"iat": time.Now().Unix() + int64(jwtExpiryTimeout.Seconds()) + 1})),
+		// stale
+		fmt.Sprintf("Bearer %v", issueToken(secret, nil, map[string]interface{}{"iat": time.Now().Unix() - int64(jwtExpiryTimeout.Seconds()) - 1})),
+		// wrong algo
+		fmt.Sprintf("Bearer %v", issueToken(secret, jwt.SigningMethodHS512, map[string]interface{}{"iat": time.Now().Unix() + 4})),
+		// expired
+		fmt.Sprintf("Bearer %v", issueToken(secret, nil, map[string]interface{}{"iat": time.Now().Unix(), "exp": time.Now().Unix()})),
+		// missing mandatory iat
+		fmt.Sprintf("Bearer %v", issueToken(secret, nil, map[string]interface{}{})),
+		// wrong secret
+		fmt.Sprintf("Bearer %v", issueToken([]byte("wrong"), nil, map[string]interface{}{"iat": time.Now().Unix()})),
+		// missing bearer prefix
+		issueToken(secret, nil, map[string]interface{}{"iat": time.Now().Unix()}),
+		"Bearer: ",
+		"Bearer",
+		"",
+	}
+	for i, token := range expFail {
+		if err := wsRequest(t, wsUrl, "", "Authorization", token); err == nil {
+			t.Errorf("tc %d-ws, token '%v': expected not to allow, got ok", i, token)
+		}
+		if resp := rpcRequest(t, htUrl, "Authorization", token); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("tc %d-http, token '%v': expected not to allow, got %v", i, token, resp.StatusCode)
+		}
+	}
+}
+
 func createAndStartServer(t *testing.T, conf *httpConfig, ws bool, wsConf *wsConfig) *httpServer {
 	t.Helper()
 
@@ -243,7 +306,7 @@ func createAndStartServer(t *testing.T, conf *httpConfig, ws bool, wsConf *wsCon
 }
 
 // wsRequest attempts to open a WebSocket connection to the given URL.
-func wsRequest(t *testing.T, url, browserOrigin string) error {
+func wsRequest(t *testing.T, url, browserOrigin string, extraHeaders ...string) error {
 	t.Helper()
 	t.Logf("checking WebSocket on %s (origin %q)", url, browserOrigin)
 
@@ -251,6 +314,12 @@ func wsRequest(t *testing.T, url, browserOrigin string) error {
 	if browserOrigin != "" {
 		headers.Set("Origin", browserOrigin)
 	}
+	if len(extraHeaders)%2 != 0 {
+		panic("odd extraHeaders length")
+	}
+	for i := 0; i < len(extraHeaders); i += 2 {
+		headers.Set(extraHeaders[i], extraHeaders[i+1])
+	}
 	conn, _, err := websocket.DefaultDialer.Dial(url, headers)
 	if conn != nil {
 		conn.Close()
@@ -79,6 +79,7 @@ var (
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
 	errNoPort           = errors.New("node does not provide TCP port")
+	errBanned           = errors.New("node is banned")
 )
 
 // dialer creates outbound connections and submits them into Server.
@@ -103,6 +104,7 @@ type dialScheduler struct {
 	remStaticCh chan *enode.Node
 	addPeerCh   chan *conn
 	remPeerCh   chan *conn
+	recheckCh   chan struct{}
 
 	// Everything below here belongs to loop and
 	// should only be accessed by code on the loop goroutine.
@@ -130,10 +132,11 @@ type dialScheduler struct {
 type dialSetupFunc func(net.Conn, connFlag, *enode.Node) error
 
 type dialConfig struct {
-	self           enode.ID         // our own ID
-	maxDialPeers   int              // maximum number of dialed peers
-	maxActiveDials int              // maximum number of active dials
-	netRestrict    *netutil.Netlist // IP whitelist, disabled if nil
+	self           enode.ID               // our own ID
+	maxDialPeers   int                    // maximum number of dialed peers
+	maxActiveDials int                    // maximum number of active dials
+	netRestrict    *netutil.Netlist       // IP whitelist, disabled if nil
+	banned         func(*enode.Node) bool // reports whether a node is banned, disabled if nil
 	resolver       nodeResolver
 	dialer         NodeDialer
 	log            log.Logger
@@ -173,6 +176,7 @@ func newDialScheduler(config dialConfig, it enode.Iterator, setupFunc dialSetupF
 		remStaticCh: make(chan *enode.Node),
 		addPeerCh:   make(chan *conn),
 		remPeerCh:   make(chan *conn),
+		recheckCh:   make(chan struct{}),
 	}
 	d.lastStatsLog = d.clock.Now()
 	d.ctx, d.cancel = context.WithCancel(context.Background())
@@ -204,6 +208,15 @@ func (d *dialScheduler) removeStatic(n *enode.Node) {
 	}
 }
 
+// recheckStatic re-evaluates whether static dial candidates may be dialed. This must
+// be called when the result of the banned function changes.
+func (d *dialScheduler) recheckStatic() {
+	select {
+	case d.recheckCh <- struct{}{}:
+	case <-d.ctx.Done():
+	}
+}
+
 // peerAdded updates the peer set.
 func (d *dialScheduler) peerAdded(c *conn) {
 	select {
@@ -298,6 +311,15 @@ loop:
 				}
 			}
 
+		case <-d.recheckCh:
+			for id, task := range d.static {
+				if task.staticPoolIndex >= 0 && d.checkDial(task.dest) != nil {
+					d.removeFromStaticPool(task.staticPoolIndex)
+				} else {
+					d.updateStaticPool(id)
+				}
+			}
+
 		case <-historyExp:
 			d.expireHistory()
 
@@ -404,6 +426,9 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 	if d.netRestrict != nil && !d.netRestrict.Contains(n.IP()) {
 		return errNotWhitelisted
 	}
+	if d.banned != nil && d.banned(n) {
+		return errBanned
+	}
 	if d.history.contains(string(n.ID().Bytes())) {
 		return errRecentlyDialed
 	}
@@ -152,6 +152,58 @@ func TestDialSchedNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that banned nodes are not dialed and that static
+// nodes are dialed again once their ban is lifted.
+func TestDialSchedBanned(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		banned = map[enode.ID]bool{uintID(0x02): true, uintID(0x04): true}
+	)
+	config := dialConfig{
+		maxActiveDials: 5,
+		maxDialPeers:   5,
+		banned: func(n *enode.Node) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return banned[n.ID()]
+		},
+	}
+	runDialTest(t, config, []dialTestRound{
+		{
+			update: func(d *dialScheduler) {
+				d.addStatic(newNode(uintID(0x01), "127.0.0.1:30303"))
+				d.addStatic(newNode(uintID(0x02), "127.0.0.2:30303"))
+			},
+			discovered: []*enode.Node{
+				newNode(uintID(0x03), "127.0.0.3:30303"),
+				newNode(uintID(0x04), "127.0.0.4:30303"),
+			},
+			wantNewDials: []*enode.Node{
+				newNode(uintID(0x01), "127.0.0.1:30303"),
+				newNode(uintID(0x03), "127.0.0.3:30303"),
+			},
+		},
+		// Lifting the ban on static node 0x02 launches a dial.
+		{
+			succeeded: []enode.ID{
+				uintID(0x01),
+				uintID(0x03),
+			},
+			update: func(d *dialScheduler) {
+				mu.Lock()
+				delete(banned, uintID(0x02))
+				mu.Unlock()
+				d.recheckStatic()
+			},
+			wantNewDials: []*enode.Node{
+				newNode(uintID(0x02), "127.0.0.2:30303"),
+			},
+		},
+	})
+}
+
 // This test checks that static dials work and obey the limits.
 func TestDialSchedStaticDial(t *testing.T) {
 	t.Parallel()
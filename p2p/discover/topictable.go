@@ -0,0 +1,278 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"errors"
+	"math"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	topicAdLifetime    = 15 * time.Minute // how long an ad stays in the table
+	topicTableCapacity = 5000             // max number of ads in the table
+	topicQueueCapacity = 100              // max number of ads for a single topic
+	topicTicketSlack   = 10 * time.Second // ticket validity after the wait time is over
+
+	// Parameters of the waiting time function.
+	topicOccupancyExp = 10   // P_occ, grows waiting time as the table fills up
+	topicBaseWeight   = 1e-7 // G, lower bound of waiting time for empty tables
+)
+
+var (
+	errTicketDecrypt  = errors.New("can't decrypt ticket")
+	errTicketMismatch = errors.New("ticket issued for different registration")
+	errTicketEarly    = errors.New("ticket used before wait time is over")
+	errTicketExpired  = errors.New("ticket expired")
+)
+
+// topicTable stores topic advertisements placed by other nodes.
+//
+// Ads are admitted by waiting time: a registrant which can't be placed immediately
+// receives a ticket and must come back after the wait time has passed. Waiting time
+// grows with table occupancy and with the share of ads for the same topic and from
+// the same IP network, which keeps any single topic or network from taking over the
+// table.
+//
+// The table is not safe for concurrent use. UDPv5 accesses it in the dispatch
+// goroutine only.
+type topicTable struct {
+	clock  mclock.Clock
+	aead   cipher.AEAD
+	queues map[v5wire.TopicID][]*topicAd
+	ips    map[string]int // number of ads by IP network
+	count  int
+}
+
+// topicAd is an entry in a topic queue.
+type topicAd struct {
+	node  *enode.Node
+	ipkey string
+	added mclock.AbsTime
+}
+
+// topicTicket is the content of a ticket. Tickets are encrypted with a local key,
+// so only the issuing node can read them.
+type topicTicket struct {
+	Topic       v5wire.TopicID
+	Node        enode.ID
+	IP          net.IP
+	FirstIssued uint64 // time when the first ticket of this registration was issued
+	LastIssued  uint64 // time when this ticket was issued
+	WaitTime    uint64 // wait time of this ticket
+}
+
+func newTopicTable(clock mclock.Clock) *topicTable {
+	key := make([]byte, 16)
+	crand.Read(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic("can't create AES cipher: " + err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic("can't create GCM: " + err.Error())
+	}
+	return &topicTable{
+		clock:  clock,
+		aead:   aead,
+		queues: make(map[v5wire.TopicID][]*topicAd),
+		ips:    make(map[string]int),
+	}
+}
+
+// register attempts to place an ad for n. The ticket is the one presented by the
+// registrant and may be nil. It returns true if the ad was placed. Otherwise, it
+// returns a new ticket and the time the registrant has to wait before using it.
+func (tab *topicTable) register(topic v5wire.TopicID, n *enode.Node, ip net.IP, ticket []byte) (bool, []byte, time.Duration) {
+	now := tab.clock.Now()
+	tab.expire(now)
+
+	// An existing ad for the node is kept until it expires.
+	if ad := tab.find(topic, n.ID()); ad != nil {
+		return false, nil, topicAdLifetime - now.Sub(ad.added)
+	}
+
+	// Registrants with a valid ticket get credit for the time they already waited.
+	tk := &topicTicket{Topic: topic, Node: n.ID(), IP: ip, FirstIssued: uint64(now)}
+	waited := time.Duration(0)
+	if len(ticket) > 0 {
+		prev, err := tab.decodeTicket(ticket)
+		if err == nil {
+			err = prev.check(tk, now)
+		}
+		switch err {
+		case nil:
+			tk.FirstIssued = prev.FirstIssued
+			waited = now.Sub(mclock.AbsTime(prev.FirstIssued))
+		case errTicketEarly:
+			// Don't reset the registration, but make the node wait for the rest of it.
+			wait := mclock.AbsTime(prev.LastIssued).Add(time.Duration(prev.WaitTime)).Sub(now)
+			return false, tab.issueTicket(prev, now, wait), wait
+		}
+	}
+
+	// Waiting times below the one second resolution of TICKET are not enforced.
+	wait := tab.waitTime(topic, ip, now) - waited
+	if wait < time.Second {
+		tab.add(topic, n, ip, now)
+		return true, nil, 0
+	}
+	return false, tab.issueTicket(tk, now, wait), wait
+}
+
+// nodes returns up to limit nodes advertising the given topic, newest ads first.
+func (tab *topicTable) nodes(topic v5wire.TopicID, limit int) []*enode.Node {
+	tab.expire(tab.clock.Now())
+	queue := tab.queues[topic]
+	nodes := make([]*enode.Node, 0, min(limit, len(queue)))
+	for i := len(queue) - 1; i >= 0 && len(nodes) < limit; i-- {
+		nodes = append(nodes, queue[i].node)
+	}
+	return nodes
+}
+
+// waitTime computes the waiting time of a new ad.
+func (tab *topicTable) waitTime(topic v5wire.TopicID, ip net.IP, now mclock.AbsTime) time.Duration {
+	// When the table or the topic queue is full, the node has to wait until the
+	// oldest ad expires.
+	queue := tab.queues[topic]
+	if len(queue) >= topicQueueCapacity {
+		return topicAdLifetime - now.Sub(queue[0].added)
+	}
+	if tab.count >= topicTableCapacity {
+		return topicAdLifetime - now.Sub(tab.oldest())
+	}
+
+	var (
+		occupancy  = float64(tab.count) / topicTableCapacity
+		topicShare = float64(len(queue)) / topicTableCapacity
+		ipShare    = float64(tab.ips[ipKey(ip)]) / topicTableCapacity
+	)
+	w := float64(topicAdLifetime) * (topicShare + ipShare + topicBaseWeight) / math.Pow(1-occupancy, topicOccupancyExp)
+	return time.Duration(math.Min(w, float64(topicAdLifetime)))
+}
+
+func (tab *topicTable) add(topic v5wire.TopicID, n *enode.Node, ip net.IP, now mclock.AbsTime) {
+	ad := &topicAd{node: n, ipkey: ipKey(ip), added: now}
+	tab.queues[topic] = append(tab.queues[topic], ad)
+	tab.ips[ad.ipkey]++
+	tab.count++
+}
+
+func (tab *topicTable) find(topic v5wire.TopicID, id enode.ID) *topicAd {
+	for _, ad := range tab.queues[topic] {
+		if ad.node.ID() == id {
+			return ad
+		}
+	}
+	return nil
+}
+
+// oldest returns the time when the oldest ad in the table was added.
+func (tab *topicTable) oldest() mclock.AbsTime {
+	oldest := mclock.AbsTime(math.MaxInt64)
+	for _, queue := range tab.queues {
+		if len(queue) > 0 && queue[0].added < oldest {
+			oldest = queue[0].added
+		}
+	}
+	return oldest
+}
+
+// expire removes ads which have reached the end of their lifetime. Queues are
+// ordered by insertion time, so expired ads are always at the front.
+func (tab *topicTable) expire(now mclock.AbsTime) {
+	for topic, queue := range tab.queues {
+		i := 0
+		for ; i < len(queue) && now.Sub(queue[i].added) >= topicAdLifetime; i++ {
+			if tab.ips[queue[i].ipkey]--; tab.ips[queue[i].ipkey] == 0 {
+				delete(tab.ips, queue[i].ipkey)
+			}
+			tab.count--
+		}
+		if i == len(queue) {
+			delete(tab.queues, topic)
+		} else if i > 0 {
+			tab.queues[topic] = append(queue[:0:0], queue[i:]...)
+		}
+	}
+}
+
+// issueTicket encrypts a ticket.
+func (tab *topicTable) issueTicket(tk *topicTicket, now mclock.AbsTime, wait time.Duration) []byte {
+	tk.LastIssued = uint64(now)
+	tk.WaitTime = uint64(wait)
+	enc, err := rlp.EncodeToBytes(tk)
+	if err != nil {
+		panic("can't encode ticket: " + err.Error())
+	}
+	nonce := make([]byte, tab.aead.NonceSize())
+	crand.Read(nonce)
+	return tab.aead.Seal(nonce, nonce, enc, nil)
+}
+
+// decodeTicket decrypts a ticket issued by this table.
+func (tab *topicTable) decodeTicket(ticket []byte) (*topicTicket, error) {
+	nonceSize := tab.aead.NonceSize()
+	if len(ticket) < nonceSize {
+		return nil, errTicketDecrypt
+	}
+	enc, err := tab.aead.Open(nil, ticket[:nonceSize], ticket[nonceSize:], nil)
+	if err != nil {
+		return nil, errTicketDecrypt
+	}
+	tk := new(topicTicket)
+	if err := rlp.DecodeBytes(enc, tk); err != nil {
+		return nil, errTicketDecrypt
+	}
+	return tk, nil
+}
+
+// check verifies that a ticket belongs to the given registration and is used
+// at the right time.
+func (tk *topicTicket) check(reg *topicTicket, now mclock.AbsTime) error {
+	if tk.Topic != reg.Topic || tk.Node != reg.Node || !tk.IP.Equal(reg.IP) {
+		return errTicketMismatch
+	}
+	due := mclock.AbsTime(tk.LastIssued).Add(time.Duration(tk.WaitTime))
+	switch {
+	case now < due:
+		return errTicketEarly
+	case now > due.Add(topicTicketSlack):
+		return errTicketExpired
+	}
+	return nil
+}
+
+// ipKey returns the IP network used for waiting time computation. IPv4 addresses are
+// grouped by /24 network, IPv6 addresses by /64 network.
+func ipKey(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String()
+}
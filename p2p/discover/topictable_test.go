@@ -0,0 +1,139 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestTopicTable_register(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		tab   = newTopicTable(clock)
+		topic = v5wire.NewTopicID("foo")
+		n     = newTopicTestNode(net.IP{10, 0, 0, 1})
+	)
+
+	// The first registration in an empty table succeeds immediately.
+	if placed, _, _ := tab.register(topic, n, n.IP(), nil); !placed {
+		t.Fatal("registration in empty table not placed")
+	}
+	if nodes := tab.nodes(topic, 10); len(nodes) != 1 || nodes[0].ID() != n.ID() {
+		t.Fatalf("wrong topic nodes: %v", nodes)
+	}
+
+	// Registering again is refused until the ad expires.
+	placed, _, wait := tab.register(topic, n, n.IP(), nil)
+	if placed || wait != topicAdLifetime {
+		t.Fatalf("duplicate registration: placed=%t wait=%v", placed, wait)
+	}
+	clock.Run(topicAdLifetime)
+	if nodes := tab.nodes(topic, 10); len(nodes) != 0 {
+		t.Fatalf("expired ad still in table: %v", nodes)
+	}
+	if tab.count != 0 || len(tab.ips) != 0 {
+		t.Fatalf("table not empty after expiry: count=%d ips=%v", tab.count, tab.ips)
+	}
+}
+
+func TestTopicTable_tickets(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		tab   = newTopicTable(clock)
+		topic = v5wire.NewTopicID("foo")
+		ip    = net.IP{10, 0, 0, 1}
+	)
+
+	// Fill the topic queue from a single network, so the next registration has to wait.
+	for i := 0; i < topicQueueCapacity/2; i++ {
+		n := newTopicTestNode(ip)
+		if placed, ticket, wait := tab.register(topic, n, ip, nil); !placed {
+			clock.Run(wait)
+			if placed, _, _ = tab.register(topic, n, ip, ticket); !placed {
+				t.Fatalf("registration %d not placed after waiting", i)
+			}
+		}
+	}
+	n := newTopicTestNode(ip)
+	placed, ticket, wait := tab.register(topic, n, ip, nil)
+	if placed || wait <= 0 || len(ticket) == 0 {
+		t.Fatalf("registration should require ticket: placed=%t wait=%v", placed, wait)
+	}
+
+	// Using the ticket early keeps the registrant waiting.
+	clock.Run(wait / 2)
+	placed, early, remaining := tab.register(topic, n, ip, ticket)
+	if placed || remaining != wait-wait/2 {
+		t.Fatalf("early ticket: placed=%t remaining=%v, want %v", placed, remaining, wait-wait/2)
+	}
+
+	// The ticket can't be used by another node.
+	clock.Run(remaining)
+	other := newTopicTestNode(ip)
+	if placed, _, _ := tab.register(topic, other, ip, early); placed {
+		t.Fatal("ticket accepted for wrong node")
+	}
+
+	// Coming back on time places the ad.
+	if placed, _, _ := tab.register(topic, n, ip, early); !placed {
+		t.Fatal("registration with valid ticket not placed")
+	}
+}
+
+func TestTopicTable_waitTime(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		tab   = newTopicTable(clock)
+		foo   = v5wire.NewTopicID("foo")
+		bar   = v5wire.NewTopicID("bar")
+	)
+	for i := 0; i < 50; i++ {
+		ip := net.IP{10, 0, byte(i), 1}
+		tab.add(foo, newTopicTestNode(ip), ip, clock.Now())
+	}
+
+	// Popular topics and networks get longer waiting times.
+	var (
+		fresh   = tab.waitTime(bar, net.IP{10, 1, 0, 1}, clock.Now())
+		popular = tab.waitTime(foo, net.IP{10, 1, 0, 1}, clock.Now())
+		sameNet = tab.waitTime(bar, net.IP{10, 0, 0, 2}, clock.Now())
+		maxWait = tab.waitTime(foo, net.IP{10, 0, 0, 2}, clock.Now())
+	)
+	if fresh >= popular || fresh >= sameNet || popular >= maxWait {
+		t.Fatalf("wrong wait times: fresh=%v popular=%v sameNet=%v both=%v", fresh, popular, sameNet, maxWait)
+	}
+
+	// Full queues make registrants wait for the oldest ad.
+	clock.Run(time.Minute)
+	for i := len(tab.queues[foo]); i < topicQueueCapacity; i++ {
+		ip := net.IP{10, 2, byte(i), 1}
+		tab.add(foo, newTopicTestNode(ip), ip, clock.Now())
+	}
+	if wait := tab.waitTime(foo, net.IP{10, 1, 0, 1}, clock.Now()); wait != topicAdLifetime-time.Minute {
+		t.Fatalf("wrong wait time for full queue: %v", wait)
+	}
+}
+
+func newTopicTestNode(ip net.IP) *enode.Node {
+	return unwrapNode(nodeAtDistance(enode.ID{}, 250, ip))
+}
@@ -0,0 +1,322 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+const (
+	topicRegistrarLimit   = 16               // number of nodes an ad is placed on
+	topicLookupInterval   = 5 * time.Minute  // time between lookups while registering
+	topicLookupRetry      = 30 * time.Second // time between lookups when no registrar was found
+	topicSearchInterval   = 30 * time.Second // time between search rounds
+	topicQueryResultLimit = totalNodesResponseLimit * nodesResponseItemLimit
+)
+
+// RegisterTopic starts advertising the local node under the given topic. Ads are placed
+// on the nodes closest to the topic ID and renewed until StopRegisterTopic is called or
+// the transport is closed.
+func (t *UDPv5) RegisterTopic(topic string) {
+	id := v5wire.NewTopicID(topic)
+
+	t.topicMu.Lock()
+	defer t.topicMu.Unlock()
+	if _, ok := t.topicRegs[id]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	t.topicRegs[id] = cancel
+	go t.topicRegisterLoop(ctx, id)
+}
+
+// StopRegisterTopic stops advertising the local node under the given topic.
+// Ads that were already placed remain on other nodes until they expire.
+func (t *UDPv5) StopRegisterTopic(topic string) {
+	id := v5wire.NewTopicID(topic)
+
+	t.topicMu.Lock()
+	defer t.topicMu.Unlock()
+	if cancel, ok := t.topicRegs[id]; ok {
+		cancel()
+		delete(t.topicRegs, id)
+	}
+}
+
+// TopicSearch returns an iterator that finds nodes advertising the given topic. Each
+// node is returned at most once. The search repeats periodically to find new
+// advertisers until the iterator is closed.
+func (t *UDPv5) TopicSearch(topic string) enode.Iterator {
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	return &topicSearchIterator{
+		t:      t,
+		topic:  v5wire.NewTopicID(topic),
+		ctx:    ctx,
+		cancel: cancel,
+		seen:   make(map[enode.ID]bool),
+	}
+}
+
+// topicRegistrar is a node on which the local node places ads.
+type topicRegistrar struct {
+	node   *enode.Node
+	ticket []byte
+	next   mclock.AbsTime // time of next REGTOPIC
+}
+
+// topicRegisterLoop places ads for a topic. It runs until ctx is canceled.
+func (t *UDPv5) topicRegisterLoop(ctx context.Context, topic v5wire.TopicID) {
+	var (
+		registrars = make(map[enode.ID]*topicRegistrar)
+		nextLookup = t.clock.Now()
+		timer      = t.clock.NewTimer(0)
+	)
+	defer timer.Stop()
+
+	for {
+		// Find registrars when there aren't enough.
+		now := t.clock.Now()
+		if len(registrars) < topicRegistrarLimit && now >= nextLookup {
+			nextLookup = now.Add(topicLookupInterval)
+			for _, n := range t.newLookup(ctx, enode.ID(topic)).run() {
+				if len(registrars) >= topicRegistrarLimit {
+					break
+				}
+				if registrars[n.ID()] == nil {
+					registrars[n.ID()] = &topicRegistrar{node: n, next: now}
+				}
+			}
+			if len(registrars) == 0 {
+				nextLookup = now.Add(topicLookupRetry)
+			}
+		}
+
+		// Send REGTOPIC to registrars that are due.
+		next := nextLookup
+		for id, r := range registrars {
+			if r.next <= t.clock.Now() {
+				if err := t.registerAt(r, topic); err != nil {
+					if err == errClosed {
+						return
+					}
+					t.log.Debug("Topic registration failed", "id", id, "err", err)
+					delete(registrars, id)
+					continue
+				}
+			}
+			if r.next < next {
+				next = r.next
+			}
+		}
+
+		timer.Reset(time.Duration(next - t.clock.Now()))
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// registerAt sends REGTOPIC to a registrar and schedules the next attempt.
+func (t *UDPv5) registerAt(r *topicRegistrar, topic v5wire.TopicID) error {
+	resp, err := t.regtopic(r.node, topic, r.ticket)
+	if err != nil {
+		return err
+	}
+	switch resp := resp.(type) {
+	case *v5wire.Ticket:
+		r.ticket = resp.Ticket
+		r.next = t.clock.Now().Add(time.Duration(resp.WaitTime) * time.Second)
+	case *v5wire.Regconfirmation:
+		if resp.Topic != topic {
+			return errors.New("wrong topic in confirmation")
+		}
+		r.ticket = nil
+		r.next = t.clock.Now().Add(topicAdLifetime)
+		t.log.Trace("Registered topic ad", "id", r.node.ID(), "topic", topic)
+	}
+	return nil
+}
+
+// regtopic calls REGTOPIC on a node and waits for a TICKET or REGCONFIRMATION response.
+func (t *UDPv5) regtopic(n *enode.Node, topic v5wire.TopicID, ticket []byte) (v5wire.Packet, error) {
+	req := &v5wire.Regtopic{Topic: topic, ENR: t.localNode.Node().Record(), Ticket: ticket}
+	resp := t.call(n, v5wire.TicketMsg, req)
+	defer t.callDone(resp)
+
+	select {
+	case p := <-resp.ch:
+		return p, nil
+	case err := <-resp.err:
+		return nil, err
+	}
+}
+
+// topicQuery calls TOPICQUERY on a node and waits for NODES responses.
+func (t *UDPv5) topicQuery(n *enode.Node, topic v5wire.TopicID) ([]*enode.Node, error) {
+	resp := t.call(n, v5wire.NodesMsg, &v5wire.TopicQuery{Topic: topic})
+	return t.waitForNodes(resp, nil)
+}
+
+// handleRegtopic places an ad for the sender or gives it a ticket.
+func (t *UDPv5) handleRegtopic(p *v5wire.Regtopic, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if p.ENR == nil {
+		t.log.Debug("Missing record in "+p.Name(), "id", fromID, "addr", fromAddr)
+		return
+	}
+	n, err := enode.New(t.validSchemes, p.ENR)
+	if err != nil || n.ID() != fromID {
+		t.log.Debug("Invalid record in "+p.Name(), "id", fromID, "addr", fromAddr, "err", err)
+		return
+	}
+	// The ad is served to other nodes, so it must point back to the registrant.
+	if !fromAddr.IP.Equal(n.IP()) || fromAddr.Port != n.UDP() {
+		t.log.Debug("Record endpoint mismatch in "+p.Name(), "id", fromID, "addr", fromAddr, "ip", n.IP(), "udp", n.UDP())
+		return
+	}
+
+	placed, ticket, wait := t.topics.register(p.Topic, n, fromAddr.IP, p.Ticket)
+	if placed {
+		t.sendResponse(fromID, fromAddr, &v5wire.Regconfirmation{ReqID: p.ReqID, Topic: p.Topic})
+		return
+	}
+	// Wait time is rounded up so the ticket is due when the registrant comes back.
+	secs := uint((wait + time.Second - 1) / time.Second)
+	t.sendResponse(fromID, fromAddr, &v5wire.Ticket{ReqID: p.ReqID, Ticket: ticket, WaitTime: secs})
+}
+
+// handleTopicQuery returns the nodes advertising a topic to the requester.
+func (t *UDPv5) handleTopicQuery(p *v5wire.TopicQuery, fromID enode.ID, fromAddr *net.UDPAddr) {
+	var nodes []*enode.Node
+	for _, n := range t.topics.nodes(p.Topic, topicQueryResultLimit) {
+		if netutil.CheckRelayIP(fromAddr.IP, n.IP()) == nil {
+			nodes = append(nodes, n)
+		}
+	}
+	for _, resp := range packNodes(p.ReqID, nodes) {
+		t.sendResponse(fromID, fromAddr, resp)
+	}
+}
+
+// topicSearchIterator runs lookups toward a topic ID and sends TOPICQUERY to all
+// nodes found by the lookup.
+type topicSearchIterator struct {
+	t      *UDPv5
+	topic  v5wire.TopicID
+	ctx    context.Context
+	cancel func()
+	lookup *lookup
+	seen   map[enode.ID]bool
+	buffer []*enode.Node
+
+	mu      sync.Mutex
+	results []*enode.Node // filled by lookup queries
+}
+
+// Node returns the current node.
+func (it *topicSearchIterator) Node() *enode.Node {
+	if len(it.buffer) == 0 {
+		return nil
+	}
+	return it.buffer[0]
+}
+
+// Next moves to the next node.
+func (it *topicSearchIterator) Next() bool {
+	if len(it.buffer) > 0 {
+		it.buffer = it.buffer[1:]
+	}
+	for len(it.buffer) == 0 {
+		if it.ctx.Err() != nil {
+			it.lookup = nil
+			it.buffer = nil
+			return false
+		}
+		if it.lookup == nil {
+			it.lookup = it.t.newTopicLookup(it.ctx, it.topic, it.addResults)
+			continue
+		}
+		more := it.lookup.advance()
+		it.takeResults()
+		if !more {
+			it.lookup = nil
+			if len(it.buffer) == 0 {
+				it.wait()
+			}
+		}
+	}
+	return true
+}
+
+// Close ends the iterator.
+func (it *topicSearchIterator) Close() {
+	it.cancel()
+}
+
+// addResults is called by lookup queries when a TOPICQUERY response arrives.
+func (it *topicSearchIterator) addResults(nodes []*enode.Node) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.results = append(it.results, nodes...)
+}
+
+// takeResults moves new results into the buffer.
+func (it *topicSearchIterator) takeResults() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	for _, n := range it.results {
+		if !it.seen[n.ID()] && n.ID() != it.t.Self().ID() {
+			it.seen[n.ID()] = true
+			it.buffer = append(it.buffer, n)
+		}
+	}
+	it.results = it.results[:0]
+}
+
+// wait delays the next search round.
+func (it *topicSearchIterator) wait() {
+	timer := it.t.clock.NewTimer(topicSearchInterval)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-it.ctx.Done():
+	}
+}
+
+// newTopicLookup creates a lookup toward the topic ID which also queries every
+// contacted node for ads.
+func (t *UDPv5) newTopicLookup(ctx context.Context, topic v5wire.TopicID, found func([]*enode.Node)) *lookup {
+	target := enode.ID(topic)
+	return newLookup(ctx, t.tab, target, func(n *node) ([]*node, error) {
+		if ads, err := t.topicQuery(unwrapNode(n), topic); len(ads) > 0 {
+			found(ads)
+		} else if err == errClosed {
+			return nil, err
+		}
+		return t.lookupWorker(n, target)
+	})
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// This test checks that incoming REGTOPIC and TOPICQUERY calls are handled correctly.
+func TestUDPv5_topicHandling(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	var (
+		topic  = v5wire.NewTopicID("foo")
+		remote = test.getNode(test.remotekey, test.remoteaddr).Node()
+	)
+
+	// Registration in the empty table is confirmed immediately.
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte{1}, Topic: topic, ENR: remote.Record()})
+	test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !bytes.Equal(p.ReqID, []byte{1}) {
+			t.Error("wrong request ID in response:", p.ReqID)
+		}
+		if p.Topic != topic {
+			t.Errorf("wrong topic in response: %x", p.Topic)
+		}
+	})
+
+	// Registering again yields a ticket.
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte{2}, Topic: topic, ENR: remote.Record()})
+	test.waitPacketOut(func(p *v5wire.Ticket, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !bytes.Equal(p.ReqID, []byte{2}) {
+			t.Error("wrong request ID in response:", p.ReqID)
+		}
+		if p.WaitTime != uint(topicAdLifetime/time.Second) {
+			t.Errorf("wrong wait time %d", p.WaitTime)
+		}
+	})
+
+	// Registrations for other nodes are ignored.
+	other := test.getNode(newkey(), &net.UDPAddr{IP: net.IP{10, 0, 1, 100}, Port: 30303}).Node()
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte{3}, Topic: topic, ENR: other.Record()})
+
+	// TOPICQUERY returns the registered node.
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte{4}, Topic: topic})
+	test.expectNodes([]byte{4}, 1, []*enode.Node{remote})
+
+	// Unknown topics return no nodes.
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte{5}, Topic: v5wire.NewTopicID("bar")})
+	test.expectNodes([]byte{5}, 1, nil)
+}
+
+// This test checks that REGTOPIC is ignored if the record doesn't match the
+// endpoint of the sender.
+func TestUDPv5_regtopicEndpointMismatch(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+	ln := enode.NewLocalNode(db, test.remotekey)
+	ln.SetStaticIP(net.IP{10, 0, 1, 100})
+	ln.Set(enr.UDP(test.remoteaddr.Port))
+
+	topic := v5wire.NewTopicID("foo")
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte{1}, Topic: topic, ENR: ln.Node().Record()})
+
+	// The registration is not placed.
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte{2}, Topic: topic})
+	test.expectNodes([]byte{2}, 1, nil)
+}
+
+// This test checks that outgoing REGTOPIC calls work.
+func TestUDPv5_regtopicCall(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	var (
+		topic  = v5wire.NewTopicID("foo")
+		remote = test.getNode(test.remotekey, test.remoteaddr).Node()
+		r      = &topicRegistrar{node: remote}
+		done   = make(chan error, 1)
+	)
+
+	// The first attempt gets a ticket.
+	go func() { done <- test.udp.registerAt(r, topic) }()
+	test.waitPacketOut(func(p *v5wire.Regtopic, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if p.Topic != topic {
+			t.Errorf("wrong topic in request: %x", p.Topic)
+		}
+		if len(p.Ticket) != 0 {
+			t.Errorf("non-empty ticket in first request: %x", p.Ticket)
+		}
+		test.packetIn(&v5wire.Ticket{ReqID: p.ReqID, Ticket: []byte("ticket"), WaitTime: 10})
+	})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(r.ticket, []byte("ticket")) {
+		t.Fatalf("wrong ticket stored: %q", r.ticket)
+	}
+
+	// The second attempt presents the ticket and gets confirmed.
+	go func() { done <- test.udp.registerAt(r, topic) }()
+	test.waitPacketOut(func(p *v5wire.Regtopic, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !bytes.Equal(p.Ticket, []byte("ticket")) {
+			t.Errorf("wrong ticket in request: %q", p.Ticket)
+		}
+		test.packetIn(&v5wire.Regconfirmation{ReqID: p.ReqID, Topic: topic})
+	})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if r.ticket != nil {
+		t.Fatalf("ticket not cleared after confirmation: %q", r.ticket)
+	}
+}
+
+// Real sockets, real crypto: this test checks that nodes registering a topic can be
+// found by topic search.
+func TestUDPv5_topicSearchE2E(t *testing.T) {
+	t.Parallel()
+
+	const N = 5
+	var nodes []*UDPv5
+	for i := 0; i < N; i++ {
+		var cfg Config
+		if len(nodes) > 0 {
+			bn := nodes[0].Self()
+			cfg.Bootnodes = []*enode.Node{bn}
+		}
+		node := startLocalhostV5(t, cfg)
+		nodes = append(nodes, node)
+		defer node.Close()
+	}
+	nodes[1].RegisterTopic("foo")
+	nodes[2].RegisterTopic("foo")
+
+	// Search until both registered nodes are found. Each search is stopped
+	// after a while because ads might not be placed yet.
+	want := map[enode.ID]bool{nodes[1].Self().ID(): true, nodes[2].Self().ID(): true}
+	for attempt := 0; attempt < 10 && len(want) > 0; attempt++ {
+		it := nodes[N-1].TopicSearch("foo")
+		timer := time.AfterFunc(2*time.Second, it.Close)
+		for len(want) > 0 && it.Next() {
+			id := it.Node().ID()
+			if id != nodes[1].Self().ID() && id != nodes[2].Self().ID() {
+				t.Fatalf("search returned unregistered node %v", id)
+			}
+			delete(want, id)
+		}
+		timer.Stop()
+		it.Close()
+	}
+	if len(want) > 0 {
+		t.Fatalf("nodes not found by topic search: %v", want)
+	}
+}
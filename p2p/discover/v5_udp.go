@@ -76,6 +76,11 @@ type UDPv5 struct {
 	trlock     sync.Mutex
 	trhandlers map[string]TalkRequestHandler
 
+	// topic advertisement
+	topics    *topicTable // ads placed on this node, accessed by dispatch only
+	topicMu   sync.Mutex
+	topicRegs map[v5wire.TopicID]context.CancelFunc // active registrations
+
 	// channels into dispatch
 	packetInCh    chan ReadPacket
 	readNextCh    chan struct{}
@@ -149,6 +154,8 @@ func newUDPv5(conn UDPConn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
 		validSchemes: cfg.ValidSchemes,
 		clock:        cfg.Clock,
 		trhandlers:   make(map[string]TalkRequestHandler),
+		topics:       newTopicTable(cfg.Clock),
+		topicRegs:    make(map[v5wire.TopicID]context.CancelFunc),
 		// channels into dispatch
 		packetInCh:    make(chan ReadPacket, 1),
 		readNextCh:    make(chan struct{}, 1),
@@ -673,7 +680,7 @@ func (t *UDPv5) handleCallResponse(fromID enode.ID, fromAddr *net.UDPAddr, p v5w
 		t.log.Debug(fmt.Sprintf("%s from wrong endpoint", p.Name()), "id", fromID, "addr", fromAddr)
 		return false
 	}
-	if p.Kind() != ac.responseType {
+	if !responseTypeMatches(ac.responseType, p.Kind()) {
 		t.log.Debug(fmt.Sprintf("Wrong discv5 response type %s", p.Name()), "id", fromID, "addr", fromAddr)
 		return false
 	}
@@ -682,6 +689,15 @@ func (t *UDPv5) handleCallResponse(fromID enode.ID, fromAddr *net.UDPAddr, p v5w
 	return true
 }
 
+// responseTypeMatches reports whether a response packet of the given kind answers a
+// call expecting responseType. REGTOPIC is answered by either TICKET or REGCONFIRMATION.
+func responseTypeMatches(responseType, kind byte) bool {
+	if responseType == v5wire.TicketMsg && kind == v5wire.RegconfirmationMsg {
+		return true
+	}
+	return responseType == kind
+}
+
 // getNode looks for a node record in table and database.
 func (t *UDPv5) getNode(id enode.ID) *enode.Node {
 	if n := t.tab.getNode(id); n != nil {
@@ -714,6 +730,14 @@ func (t *UDPv5) handle(p v5wire.Packet, fromID enode.ID, fromAddr *net.UDPAddr)
 		t.handleTalkRequest(p, fromID, fromAddr)
 	case *v5wire.TalkResponse:
 		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5wire.Regtopic:
+		t.handleRegtopic(p, fromID, fromAddr)
+	case *v5wire.Ticket:
+		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5wire.Regconfirmation:
+		t.handleCallResponse(fromID, fromAddr, p)
+	case *v5wire.TopicQuery:
+		t.handleTopicQuery(p, fromID, fromAddr)
 	}
 }
 
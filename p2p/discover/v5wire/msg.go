@@ -17,6 +17,7 @@
 package v5wire
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"net"
 
@@ -42,9 +43,8 @@ const (
 	NodesMsg
 	TalkRequestMsg
 	TalkResponseMsg
-	RequestTicketMsg
-	TicketMsg
 	RegtopicMsg
+	TicketMsg
 	RegconfirmationMsg
 	TopicQueryMsg
 
@@ -52,6 +52,14 @@ const (
 	WhoareyouPacket = byte(254) // the WHOAREYOU packet
 )
 
+// TopicID is the identifier of a topic, the SHA256 hash of the topic name.
+type TopicID [32]byte
+
+// NewTopicID computes the identifier of a topic name.
+func NewTopicID(name string) TopicID {
+	return sha256.Sum256([]byte(name))
+}
+
 // Protocol messages.
 type (
 	// Unknown represents any packet that can't be decrypted.
@@ -113,35 +121,34 @@ type (
 		Message []byte
 	}
 
-	// REQUESTTICKET requests a ticket for a topic queue.
-	RequestTicket struct {
-		ReqID []byte
-		Topic []byte
-	}
-
-	// TICKET is the response to REQUESTTICKET.
-	Ticket struct {
+	// REGTOPIC requests placement of an ad for the sender in a topic queue.
+	// The ticket is empty on the first attempt.
+	Regtopic struct {
 		ReqID  []byte
+		Topic  TopicID
+		ENR    *enr.Record
 		Ticket []byte
 	}
 
-	// REGTOPIC registers the sender in a topic queue using a ticket.
-	Regtopic struct {
-		ReqID  []byte
-		Ticket []byte
-		ENR    *enr.Record
+	// TICKET is the reply to REGTOPIC when the ad could not be placed yet.
+	// The ticket must be presented in the next REGTOPIC after the wait time
+	// (in seconds) has passed.
+	Ticket struct {
+		ReqID    []byte
+		Ticket   []byte
+		WaitTime uint
 	}
 
-	// REGCONFIRMATION is the reply to REGTOPIC.
+	// REGCONFIRMATION is the reply to REGTOPIC when the ad was placed.
 	Regconfirmation struct {
-		ReqID      []byte
-		Registered bool
+		ReqID []byte
+		Topic TopicID
 	}
 
-	// TOPICQUERY asks for nodes with the given topic.
+	// TOPICQUERY asks for nodes advertising the given topic.
 	TopicQuery struct {
 		ReqID []byte
-		Topic []byte
+		Topic TopicID
 	}
 )
 
@@ -161,8 +168,6 @@ func DecodeMessage(ptype byte, body []byte) (Packet, error) {
 		dec = new(TalkRequest)
 	case TalkResponseMsg:
 		dec = new(TalkResponse)
-	case RequestTicketMsg:
-		dec = new(RequestTicket)
 	case TicketMsg:
 		dec = new(Ticket)
 	case RegtopicMsg:
@@ -223,11 +228,6 @@ func (*TalkResponse) Kind() byte               { return TalkResponseMsg }
 func (p *TalkResponse) RequestID() []byte      { return p.ReqID }
 func (p *TalkResponse) SetRequestID(id []byte) { p.ReqID = id }
 
-func (*RequestTicket) Name() string             { return "REQTICKET/v5" }
-func (*RequestTicket) Kind() byte               { return RequestTicketMsg }
-func (p *RequestTicket) RequestID() []byte      { return p.ReqID }
-func (p *RequestTicket) SetRequestID(id []byte) { p.ReqID = id }
-
 func (*Regtopic) Name() string             { return "REGTOPIC/v5" }
 func (*Regtopic) Kind() byte               { return RegtopicMsg }
 func (p *Regtopic) RequestID() []byte      { return p.ReqID }
@@ -40,6 +40,8 @@ const (
 	dbVersionKey   = "version" // Version of the database to flush if changes
 	dbNodePrefix   = "n:"      // Identifier to prefix node entries with
 	dbLocalPrefix  = "local:"
+	dbPeerPrefix   = "peer:" // Prefix of the persistent static/trusted peer registry
+	dbBanPrefix    = "ban:"  // Prefix of the node ID and IP ban entries
 	dbDiscoverRoot = "v4"
 	dbDiscv5Root   = "v5"
 
@@ -53,6 +55,16 @@ const (
 	// Local information is keyed by ID only, the full key is "local:<ID>:seq".
 	// Use localItemKey to create those keys.
 	dbLocalSeq = "seq"
+
+	// Registry entries are keyed by kind and ID, the full key is "peer:static:<ID>".
+	// Use peerKey to create those keys.
+	dbPeerStatic  = "static"
+	dbPeerTrusted = "trusted"
+
+	// Bans are keyed by either ID or IP, the full key is "ban:id:<ID>" or "ban:ip:<IP>".
+	// Use banKey to create those keys.
+	dbBanID = "id"
+	dbBanIP = "ip"
 )
 
 const (
@@ -62,7 +74,8 @@ const (
 )
 
 var (
-	errInvalidIP = errors.New("invalid IP")
+	errInvalidIP  = errors.New("invalid IP")
+	errInvalidBan = errors.New("ban must specify either a node ID or an IP")
 )
 
 var zeroIP = make(net.IP, 16)
@@ -195,6 +208,32 @@ func localItemKey(id ID, field string) []byte {
 	return key
 }
 
+// peerKey returns the key of a peer registry entry.
+func peerKey(kind string, id ID) []byte {
+	key := append([]byte(dbPeerPrefix), kind...)
+	key = append(key, ':')
+	key = append(key, id[:]...)
+	return key
+}
+
+// banKey returns the key of a ban entry.
+func banKey(b *Ban) ([]byte, error) {
+	var (
+		key   = []byte(dbBanPrefix)
+		hasID = b.ID != ID{}
+	)
+	switch {
+	case hasID && b.IP == nil:
+		key = append(key, dbBanID+":"...)
+		return append(key, b.ID[:]...), nil
+	case !hasID && b.IP.To16() != nil:
+		key = append(key, dbBanIP+":"...)
+		return append(key, b.IP.To16()...), nil
+	default:
+		return nil, errInvalidBan
+	}
+}
+
 // fetchInt64 retrieves an integer associated with a particular key.
 func (db *DB) fetchInt64(key []byte) int64 {
 	blob, err := db.lvl.Get(key, nil)
@@ -489,6 +528,141 @@ func nextNode(it iterator.Iterator) *Node {
 	return nil
 }
 
+// StoreStaticNode adds the node to the persistent static peer registry.
+func (db *DB) StoreStaticNode(n *Node) error {
+	return db.storePeer(dbPeerStatic, n)
+}
+
+// DeleteStaticNode removes the node from the persistent static peer registry.
+func (db *DB) DeleteStaticNode(id ID) error {
+	return db.lvl.Delete(peerKey(dbPeerStatic, id), nil)
+}
+
+// StaticNodes returns all nodes in the persistent static peer registry.
+func (db *DB) StaticNodes() []*Node {
+	return db.peers(dbPeerStatic)
+}
+
+// StoreTrustedNode adds the node to the persistent trusted peer registry.
+func (db *DB) StoreTrustedNode(n *Node) error {
+	return db.storePeer(dbPeerTrusted, n)
+}
+
+// DeleteTrustedNode removes the node from the persistent trusted peer registry.
+func (db *DB) DeleteTrustedNode(id ID) error {
+	return db.lvl.Delete(peerKey(dbPeerTrusted, id), nil)
+}
+
+// TrustedNodes returns all nodes in the persistent trusted peer registry.
+func (db *DB) TrustedNodes() []*Node {
+	return db.peers(dbPeerTrusted)
+}
+
+// storePeer writes a registry entry. Nodes are stored in their textual form
+// because registry entries are often unsigned enode:// URLs, which cannot be
+// encoded as a record.
+func (db *DB) storePeer(kind string, n *Node) error {
+	return db.lvl.Put(peerKey(kind, n.ID()), []byte(n.String()), nil)
+}
+
+// peers reads all registry entries of the given kind. Entries which cannot be
+// parsed are skipped.
+func (db *DB) peers(kind string) []*Node {
+	prefix := append([]byte(dbPeerPrefix), kind+":"...)
+	it := db.lvl.NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+
+	var nodes []*Node
+	for it.Next() {
+		n, err := Parse(ValidSchemes, string(it.Value()))
+		if err != nil {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// Ban is a persistent ban of a node ID or an IP address. Exactly one of ID and
+// IP must be set.
+type Ban struct {
+	ID     ID        // banned node ID
+	IP     net.IP    // banned IP address
+	Expiry time.Time // time at which the ban is lifted, zero for permanent bans
+	Reason string    // optional human readable reason
+}
+
+// banEntry is the database encoding of a ban.
+type banEntry struct {
+	Expiry uint64 // unix time in seconds, zero for permanent bans
+	Reason string
+}
+
+// Expired reports whether the ban is no longer in effect at the given time.
+func (b *Ban) Expired(now time.Time) bool {
+	return !b.Expiry.IsZero() && !now.Before(b.Expiry)
+}
+
+// StoreBan inserts - potentially overwriting - a ban into the database.
+func (db *DB) StoreBan(b Ban) error {
+	key, err := banKey(&b)
+	if err != nil {
+		return err
+	}
+	var entry banEntry
+	if !b.Expiry.IsZero() {
+		entry.Expiry = uint64(b.Expiry.Unix())
+	}
+	entry.Reason = b.Reason
+	blob, err := rlp.EncodeToBytes(&entry)
+	if err != nil {
+		return err
+	}
+	return db.lvl.Put(key, blob, nil)
+}
+
+// DeleteBan removes the ban on the ID or IP of b from the database.
+func (db *DB) DeleteBan(b Ban) error {
+	key, err := banKey(&b)
+	if err != nil {
+		return err
+	}
+	return db.lvl.Delete(key, nil)
+}
+
+// Bans returns all bans stored in the database, including expired ones.
+func (db *DB) Bans() []Ban {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbBanPrefix)), nil)
+	defer it.Release()
+
+	var bans []Ban
+	for it.Next() {
+		var entry banEntry
+		if err := rlp.DecodeBytes(it.Value(), &entry); err != nil {
+			continue
+		}
+		b := Ban{Reason: entry.Reason}
+		if entry.Expiry != 0 {
+			b.Expiry = time.Unix(int64(entry.Expiry), 0)
+		}
+		key := it.Key()[len(dbBanPrefix):]
+		switch {
+		case bytes.HasPrefix(key, []byte(dbBanID+":")) && len(key) == len(dbBanID)+1+len(b.ID):
+			copy(b.ID[:], key[len(dbBanID)+1:])
+		case bytes.HasPrefix(key, []byte(dbBanIP+":")) && len(key) == len(dbBanIP)+1+net.IPv6len:
+			b.IP = make(net.IP, net.IPv6len)
+			copy(b.IP, key[len(dbBanIP)+1:])
+			if ip4 := b.IP.To4(); ip4 != nil {
+				b.IP = ip4
+			}
+		default:
+			continue
+		}
+		bans = append(bans, b)
+	}
+	return bans
+}
+
 // close flushes and closes the database files.
 func (db *DB) Close() {
 	close(db.quit)
@@ -473,3 +473,86 @@ func TestDBExpireV5(t *testing.T) {
 	db.UpdateFindFailsV5(ID{}, ip, 4)
 	db.expireNodes()
 }
+
+// This test checks that static and trusted registry entries are stored separately
+// and survive other database operations.
+func TestDBPeerRegistry(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	static := MustParse("enode://1dd9d65c4552b5eb43d5ad55a2ee3f56c6cbc1c64a5c8d659f51fcd51bace24351232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439@127.0.0.1:30303")
+	trusted := MustParse("enode://a979fb575495b8d6db44f750317d0f4622bf4c2aa3365d6af7c284339968eef29b69ad0dce72a4d8db5ebb4968de0e3bec910127f134779fbcb0cb6d3331163c@10.0.0.1:30303?discport=30301")
+
+	if err := db.StoreStaticNode(static); err != nil {
+		t.Fatal("can't store static node:", err)
+	}
+	if err := db.StoreTrustedNode(trusted); err != nil {
+		t.Fatal("can't store trusted node:", err)
+	}
+	db.expireNodes()
+
+	if nodes := db.StaticNodes(); len(nodes) != 1 || nodes[0].String() != static.String() {
+		t.Fatalf("wrong static nodes: %v", nodes)
+	}
+	if nodes := db.TrustedNodes(); len(nodes) != 1 || nodes[0].String() != trusted.String() {
+		t.Fatalf("wrong trusted nodes: %v", nodes)
+	}
+	if err := db.DeleteStaticNode(static.ID()); err != nil {
+		t.Fatal("can't delete static node:", err)
+	}
+	if nodes := db.StaticNodes(); len(nodes) != 0 {
+		t.Fatalf("static nodes not empty after delete: %v", nodes)
+	}
+	if nodes := db.TrustedNodes(); len(nodes) != 1 {
+		t.Fatalf("trusted node lost after deleting static node: %v", nodes)
+	}
+}
+
+func TestDBBans(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	var (
+		expiry = time.Unix(time.Now().Unix()+3600, 0)
+		idBan  = Ban{ID: keytestID, Reason: "spam"}
+		ipBan  = Ban{IP: net.IP{10, 0, 0, 1}, Expiry: expiry}
+	)
+	if err := db.StoreBan(idBan); err != nil {
+		t.Fatal("can't store ID ban:", err)
+	}
+	if err := db.StoreBan(ipBan); err != nil {
+		t.Fatal("can't store IP ban:", err)
+	}
+	if err := db.StoreBan(Ban{}); err != errInvalidBan {
+		t.Fatalf("stored empty ban, err %v", err)
+	}
+	if err := db.StoreBan(Ban{ID: keytestID, IP: net.IP{10, 0, 0, 1}}); err != errInvalidBan {
+		t.Fatalf("stored ban with both ID and IP, err %v", err)
+	}
+
+	bans := db.Bans()
+	if len(bans) != 2 {
+		t.Fatalf("wrong number of bans: %d", len(bans))
+	}
+	for _, b := range bans {
+		var want Ban
+		if b.IP == nil {
+			want = idBan
+		} else {
+			want = ipBan
+		}
+		if !reflect.DeepEqual(b, want) {
+			t.Errorf("wrong ban loaded:\ngot  %+v\nwant %+v", b, want)
+		}
+	}
+	if ipBan.Expired(time.Now()) || !ipBan.Expired(expiry) || idBan.Expired(expiry) {
+		t.Error("wrong expiry status")
+	}
+
+	if err := db.DeleteBan(Ban{IP: net.ParseIP("10.0.0.1")}); err != nil {
+		t.Fatal("can't delete IP ban:", err)
+	}
+	if bans := db.Bans(); len(bans) != 1 || bans[0].ID != keytestID {
+		t.Fatalf("wrong bans after delete: %+v", bans)
+	}
+}
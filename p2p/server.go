@@ -179,6 +179,7 @@ type Server struct {
 	log          log.Logger
 
 	nodedb    *enode.DB
+	bans      *banList
 	localnode *enode.LocalNode
 	ntab      *discover.UDPv4
 	DiscV5    *discover.UDPv5
@@ -318,13 +319,17 @@ func (srv *Server) PeerCount() int {
 
 // AddPeer adds the given node to the static node set. When there is room in the peer set,
 // the server will connect to the node. If the connection fails for any reason, the server
-// will attempt to reconnect the peer.
+// will attempt to reconnect the peer. The node is also stored in the peer registry of the
+// node database and is dialed again after a restart.
 func (srv *Server) AddPeer(node *enode.Node) {
+	if err := srv.nodedb.StoreStaticNode(node); err != nil {
+		srv.log.Warn("Failed to store static node", "id", node.ID(), "err", err)
+	}
 	srv.dialsched.addStatic(node)
 }
 
-// RemovePeer removes a node from the static node set. It also disconnects from the given
-// node if it is currently connected as a peer.
+// RemovePeer removes a node from the static node set and the peer registry. It also
+// disconnects from the given node if it is currently connected as a peer.
 //
 // This method blocks until all protocols have exited and the peer is removed. Do not use
 // RemovePeer in protocol implementations, call Disconnect on the Peer instead.
@@ -333,6 +338,9 @@ func (srv *Server) RemovePeer(node *enode.Node) {
 		ch  chan *PeerEvent
 		sub event.Subscription
 	)
+	if err := srv.nodedb.DeleteStaticNode(node.ID()); err != nil {
+		srv.log.Warn("Failed to delete static node", "id", node.ID(), "err", err)
+	}
 	// Disconnect the peer on the main loop.
 	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
 		srv.dialsched.removeStatic(node)
@@ -354,22 +362,92 @@ func (srv *Server) RemovePeer(node *enode.Node) {
 }
 
 // AddTrustedPeer adds the given node to a reserved whitelist which allows the
-// node to always connect, even if the slot are full.
+// node to always connect, even if the slot are full. The node is also stored in
+// the peer registry of the node database and remains trusted after a restart.
 func (srv *Server) AddTrustedPeer(node *enode.Node) {
+	if err := srv.nodedb.StoreTrustedNode(node); err != nil {
+		srv.log.Warn("Failed to store trusted node", "id", node.ID(), "err", err)
+	}
 	select {
 	case srv.addtrusted <- node:
 	case <-srv.quit:
 	}
 }
 
-// RemoveTrustedPeer removes the given node from the trusted peer set.
+// RemoveTrustedPeer removes the given node from the trusted peer set and the
+// peer registry.
 func (srv *Server) RemoveTrustedPeer(node *enode.Node) {
+	if err := srv.nodedb.DeleteTrustedNode(node.ID()); err != nil {
+		srv.log.Warn("Failed to delete trusted node", "id", node.ID(), "err", err)
+	}
 	select {
 	case srv.removetrusted <- node:
 	case <-srv.quit:
 	}
 }
 
+// BanPeer bans the node ID or IP address given in b. Banned nodes are not dialed
+// and their inbound connections are rejected. Connected peers matching the ban are
+// disconnected. The ban is stored in the node database and lasts until b.Expiry,
+// or forever if no expiry time is set.
+func (srv *Server) BanPeer(b enode.Ban) error {
+	if !srv.isRunning() {
+		return errServerStopped
+	}
+	if err := srv.nodedb.StoreBan(b); err != nil {
+		return err
+	}
+	srv.bans.add(b)
+	srv.dialsched.recheckStatic()
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		now := time.Now()
+		for _, p := range peers {
+			if srv.bans.containsNode(p.Node(), now) {
+				p.Disconnect(DiscRequested)
+			}
+		}
+	})
+	return nil
+}
+
+// UnbanPeer removes the ban on the node ID or IP address given in b. It reports
+// whether a matching ban existed.
+func (srv *Server) UnbanPeer(b enode.Ban) (bool, error) {
+	if !srv.isRunning() {
+		return false, errServerStopped
+	}
+	if err := srv.nodedb.DeleteBan(b); err != nil {
+		return false, err
+	}
+	removed := srv.bans.remove(b)
+	srv.dialsched.recheckStatic()
+	return removed, nil
+}
+
+// Bans returns all bans which are currently in effect. Expired bans are removed
+// from the node database.
+func (srv *Server) Bans() []enode.Ban {
+	if !srv.isRunning() {
+		return nil
+	}
+	for _, b := range srv.bans.expire(time.Now()) {
+		srv.nodedb.DeleteBan(b)
+	}
+	return srv.bans.list()
+}
+
+// isBanned reports whether the ID or IP of n is banned.
+func (srv *Server) isBanned(n *enode.Node) bool {
+	return srv.bans.containsNode(n, time.Now())
+}
+
+// isRunning reports whether the server is started and not yet stopped.
+func (srv *Server) isRunning() bool {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.running
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -503,6 +581,15 @@ func (srv *Server) setupLocalNode() error {
 		return err
 	}
 	srv.nodedb = db
+	srv.bans = newBanList()
+	now := time.Now()
+	for _, b := range db.Bans() {
+		if b.Expired(now) {
+			db.DeleteBan(b)
+			continue
+		}
+		srv.bans.add(b)
+	}
 	srv.localnode = enode.NewLocalNode(db, srv.PrivateKey)
 	srv.localnode.SetFallbackIP(net.IP{127, 0, 0, 1})
 	// TODO: check conflicts
@@ -621,6 +708,7 @@ func (srv *Server) setupDialScheduler() {
 		maxActiveDials: srv.MaxPendingPeers,
 		log:            srv.Logger,
 		netRestrict:    srv.NetRestrict,
+		banned:         srv.isBanned,
 		dialer:         srv.Dialer,
 		clock:          srv.clock,
 	}
@@ -634,6 +722,9 @@ func (srv *Server) setupDialScheduler() {
 	for _, n := range srv.StaticNodes {
 		srv.dialsched.addStatic(n)
 	}
+	for _, n := range srv.nodedb.StaticNodes() {
+		srv.dialsched.addStatic(n)
+	}
 }
 
 func (srv *Server) maxInboundConns() int {
@@ -704,10 +795,14 @@ func (srv *Server) run() {
 		trusted      = make(map[enode.ID]bool, len(srv.TrustedNodes))
 	)
 	// Put trusted nodes into a map to speed up checks.
-	// Trusted peers are loaded on startup or added via AddTrustedPeer RPC.
+	// Trusted peers are loaded on startup from the config and the peer
+	// registry, or added via AddTrustedPeer RPC.
 	for _, n := range srv.TrustedNodes {
 		trusted[n.ID()] = true
 	}
+	for _, n := range srv.nodedb.TrustedNodes() {
+		trusted[n.ID()] = true
+	}
 
 running:
 	for {
@@ -802,6 +897,8 @@ running:
 
 func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
 	switch {
+	case srv.isBanned(c.node):
+		return errBanned
 	case !c.is(trustedConn) && len(peers) >= srv.MaxPeers:
 		return DiscTooManyPeers
 	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
@@ -905,6 +1002,10 @@ func (srv *Server) checkInboundConn(remoteIP net.IP) error {
 	if srv.NetRestrict != nil && !srv.NetRestrict.Contains(remoteIP) {
 		return fmt.Errorf("not whitelisted in NetRestrict")
 	}
+	// Reject banned IPs.
+	if srv.bans.containsIP(remoteIP, time.Now()) {
+		return errBanned
+	}
 	// Reject Internet peers that try too often.
 	now := srv.clock.Now()
 	srv.inboundHistory.expire(now, nil)
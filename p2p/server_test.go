@@ -21,8 +21,11 @@ import (
 	"crypto/sha256"
 	"errors"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -569,6 +572,156 @@ func TestServerInboundThrottle(t *testing.T) {
 	}
 }
 
+// This test checks that banned node IDs and IPs are rejected and that
+// connected peers are dropped when they get banned.
+func TestServerBans(t *testing.T) {
+	var (
+		remkey    = newkey()
+		connected = make(chan *Peer, 1)
+		events    = make(chan *PeerEvent, 10)
+	)
+	srv := startTestServer(t, &remkey.PublicKey, func(p *Peer) { connected <- p })
+	defer srv.Stop()
+	sub := srv.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	// Connect the remote node, then ban it. It should be dropped.
+	conn, err := net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close()
+	var peer *Peer
+	select {
+	case peer = <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not accept within two seconds")
+	}
+	if err := srv.BanPeer(enode.Ban{ID: peer.ID(), Reason: "test"}); err != nil {
+		t.Fatal("can't ban peer:", err)
+	}
+	timeout := time.After(2 * time.Second)
+	for dropped := false; !dropped; {
+		select {
+		case ev := <-events:
+			dropped = ev.Type == PeerEventTypeDrop && ev.Peer == peer.ID()
+		case <-timeout:
+			t.Fatal("banned peer not disconnected")
+		}
+	}
+
+	// Check that inbound connections from the banned ID are rejected after the
+	// encryption handshake.
+	tp := &setupTransport{pubkey: &remkey.PublicKey}
+	srv.newTransport = func(fd net.Conn, dialDest *ecdsa.PublicKey) transport { return tp }
+	p1, _ := net.Pipe()
+	srv.SetupConn(p1, inboundConn, nil)
+	if tp.closeErr != errBanned {
+		t.Errorf("wrong close error for banned ID: %v", tp.closeErr)
+	}
+	if tp.calls != "doEncHandshake,close," {
+		t.Errorf("wrong calls for banned ID: %q", tp.calls)
+	}
+
+	// Check IP bans.
+	ip := net.IP{95, 33, 21, 2}
+	if err := srv.BanPeer(enode.Ban{IP: ip}); err != nil {
+		t.Fatal("can't ban IP:", err)
+	}
+	if err := srv.checkInboundConn(ip); err != errBanned {
+		t.Errorf("wrong error for banned IP: %v", err)
+	}
+	if !srv.isBanned(enode.NewV4(&newkey().PublicKey, ip, 30303, 30303)) {
+		t.Error("node with banned IP not reported as banned")
+	}
+
+	// Expired bans are not enforced and are removed from the list.
+	expired := enode.Ban{ID: randomID(), Expiry: time.Now().Add(-time.Second)}
+	if err := srv.BanPeer(expired); err != nil {
+		t.Fatal("can't ban peer:", err)
+	}
+	if bans := srv.Bans(); len(bans) != 2 {
+		t.Errorf("wrong number of bans: %d", len(bans))
+	}
+
+	// Lift the bans.
+	if ok, err := srv.UnbanPeer(enode.Ban{IP: ip}); !ok || err != nil {
+		t.Fatalf("can't unban IP: %t, %v", ok, err)
+	}
+	if ok, err := srv.UnbanPeer(enode.Ban{ID: peer.ID()}); !ok || err != nil {
+		t.Fatalf("can't unban ID: %t, %v", ok, err)
+	}
+	if ok, _ := srv.UnbanPeer(enode.Ban{ID: peer.ID()}); ok {
+		t.Error("unbanned ID twice")
+	}
+	if err := srv.checkInboundConn(ip); err != nil {
+		t.Errorf("unbanned IP rejected: %v", err)
+	}
+	if bans := srv.Bans(); len(bans) != 0 {
+		t.Errorf("bans not empty after unban: %+v", bans)
+	}
+}
+
+// This test checks that static and trusted peers and bans are persisted across
+// server restarts.
+func TestServerPeerRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		static  = enode.NewV4(&newkey().PublicKey, net.IP{127, 0, 0, 1}, 30303, 30303)
+		trusted = enode.NewV4(&newkey().PublicKey, net.IP{127, 0, 0, 2}, 30303, 30303)
+		banned  = randomID()
+		config  = Config{
+			PrivateKey:   newkey(),
+			MaxPeers:     10,
+			NoDial:       true,
+			NoDiscovery:  true,
+			NodeDatabase: filepath.Join(dir, "nodes"),
+			Logger:       testlog.Logger(t, log.LvlTrace),
+		}
+	)
+	srv := &Server{Config: config}
+	if err := srv.Start(); err != nil {
+		t.Fatal("can't start server:", err)
+	}
+	srv.AddPeer(static)
+	srv.AddTrustedPeer(trusted)
+	if err := srv.BanPeer(enode.Ban{ID: banned}); err != nil {
+		t.Fatal("can't ban peer:", err)
+	}
+	srv.Stop()
+
+	srv = &Server{Config: config}
+	if err := srv.Start(); err != nil {
+		t.Fatal("can't restart server:", err)
+	}
+	defer srv.Stop()
+
+	if nodes := srv.nodedb.StaticNodes(); len(nodes) != 1 || nodes[0].ID() != static.ID() {
+		t.Errorf("wrong static nodes after restart: %v", nodes)
+	}
+	if nodes := srv.nodedb.TrustedNodes(); len(nodes) != 1 || nodes[0].ID() != trusted.ID() {
+		t.Errorf("wrong trusted nodes after restart: %v", nodes)
+	}
+	if bans := srv.Bans(); len(bans) != 1 || bans[0].ID != banned {
+		t.Errorf("wrong bans after restart: %+v", bans)
+	}
+
+	// Removing the peers should delete them from the registry.
+	srv.RemovePeer(static)
+	srv.RemoveTrustedPeer(trusted)
+	if nodes := srv.nodedb.StaticNodes(); len(nodes) != 0 {
+		t.Errorf("static nodes not removed: %v", nodes)
+	}
+	if nodes := srv.nodedb.TrustedNodes(); len(nodes) != 0 {
+		t.Errorf("trusted nodes not removed: %v", nodes)
+	}
+}
+
 func listenFakeAddr(network, laddr string, remoteAddr net.Addr) (net.Listener, error) {
 	l, err := net.Listen(network, laddr)
 	if err == nil {
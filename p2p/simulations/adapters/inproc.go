@@ -17,6 +17,7 @@
 package adapters
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -35,11 +36,13 @@ import (
 )
 
 // SimAdapter is a NodeAdapter which creates in-memory simulation nodes and
-// connects them using net.Pipe
+// connects them using net.Pipe. The conditions of the connections between two
+// nodes can be configured using SetLinkModel.
 type SimAdapter struct {
 	pipe       func() (net.Conn, net.Conn, error)
 	mtx        sync.RWMutex
 	nodes      map[enode.ID]*SimNode
+	links      map[[2]enode.ID]*link
 	lifecycles LifecycleConstructors
 }
 
@@ -51,6 +54,7 @@ func NewSimAdapter(services LifecycleConstructors) *SimAdapter {
 	return &SimAdapter{
 		pipe:       pipes.NetPipe,
 		nodes:      make(map[enode.ID]*SimNode),
+		links:      make(map[[2]enode.ID]*link),
 		lifecycles: services,
 	}
 }
@@ -96,7 +100,7 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 			PrivateKey:      config.PrivateKey,
 			MaxPeers:        math.MaxInt32,
 			NoDiscovery:     true,
-			Dialer:          s,
+			Dialer:          &simDialer{adapter: s, src: id},
 			EnableMsgEvents: config.EnableMsgEvents,
 		},
 		ExternalSigner: config.ExternalSigner,
@@ -120,6 +124,12 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 // Dial implements the p2p.NodeDialer interface by connecting to the node using
 // an in-memory net.Pipe
 func (s *SimAdapter) Dial(ctx context.Context, dest *enode.Node) (conn net.Conn, err error) {
+	return s.dial(ctx, dest, nil)
+}
+
+// dial connects to the destination node, emulating the conditions of the given
+// link on the connection if it is not nil.
+func (s *SimAdapter) dial(ctx context.Context, dest *enode.Node, l *link) (conn net.Conn, err error) {
 	node, ok := s.GetNode(dest.ID())
 	if !ok {
 		return nil, fmt.Errorf("unknown node: %s", dest.ID())
@@ -133,6 +143,9 @@ func (s *SimAdapter) Dial(ctx context.Context, dest *enode.Node) (conn net.Conn,
 	if err != nil {
 		return nil, err
 	}
+	if l != nil {
+		pipe1, pipe2 = newLinkConn(pipe1, l), newLinkConn(pipe2, l)
+	}
 	// this is simulated 'listening'
 	// asynchronously call the dialed destination node's p2p server
 	// to set up connection on the 'listening' side
@@ -140,6 +153,45 @@ func (s *SimAdapter) Dial(ctx context.Context, dest *enode.Node) (conn net.Conn,
 	return pipe2, nil
 }
 
+// SetLinkModel implements the LinkShaper interface, changing the conditions of
+// all current and future connections between the two nodes.
+func (s *SimAdapter) SetLinkModel(one, other enode.ID, model LinkModel) error {
+	if err := model.Validate(); err != nil {
+		return err
+	}
+	s.link(one, other).set(model)
+	return nil
+}
+
+// link returns the link between two nodes, creating it if necessary.
+func (s *SimAdapter) link(one, other enode.ID) *link {
+	key := [2]enode.ID{one, other}
+	if bytes.Compare(one[:], other[:]) > 0 {
+		key = [2]enode.ID{other, one}
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	l, ok := s.links[key]
+	if !ok {
+		l = newLink()
+		s.links[key] = l
+	}
+	return l
+}
+
+// simDialer dials other simulation nodes on behalf of a single node, so that
+// the connections are subject to the link between the two nodes.
+type simDialer struct {
+	adapter *SimAdapter
+	src     enode.ID
+}
+
+// Dial implements the p2p.NodeDialer interface.
+func (d *simDialer) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	return d.adapter.dial(ctx, dest, d.adapter.link(d.src, dest.ID()))
+}
+
 // DialRPC implements the RPCDialer interface by creating an in-memory RPC
 // client of the given node
 func (s *SimAdapter) DialRPC(id enode.ID) (*rpc.Client, error) {
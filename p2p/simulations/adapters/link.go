@@ -0,0 +1,336 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adapters
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	// minRetransmitTimeout is the minimal delay caused by a lost write, which
+	// is emulated as a retransmission of the data.
+	minRetransmitTimeout = 200 * time.Millisecond
+
+	// linkQueueSize is the number of writes which may be in flight on a link
+	// in each direction before further writes block.
+	linkQueueSize = 1024
+)
+
+// LinkModel describes the network conditions emulated on a simulated
+// connection. The zero value is a perfect link.
+//
+// Connections between simulation nodes are reliable streams, so packet loss
+// does not drop data. Instead, each write is lost with the given probability
+// and delivered after a retransmission timeout, like it would be over TCP.
+type LinkModel struct {
+	Latency     time.Duration `json:"latency,omitempty"`     // One-way delay of transmitted data
+	Jitter      time.Duration `json:"jitter,omitempty"`      // Maximum random deviation from the latency
+	Bandwidth   uint64        `json:"bandwidth,omitempty"`   // Throughput cap in bytes per second, zero for unlimited
+	Loss        float64       `json:"loss,omitempty"`        // Probability of a write being lost and retransmitted
+	Partitioned bool          `json:"partitioned,omitempty"` // Whether no data gets through at all, data in flight is held back
+}
+
+// Validate checks whether the model parameters are in range.
+func (m LinkModel) Validate() error {
+	if m.Latency < 0 {
+		return errors.New("negative link latency")
+	}
+	if m.Jitter < 0 {
+		return errors.New("negative link jitter")
+	}
+	if m.Loss < 0 || m.Loss >= 1 {
+		return errors.New("link loss must be in range [0, 1)")
+	}
+	return nil
+}
+
+// transmitTime returns the time it takes to put the given amount of data on
+// the link.
+func (m LinkModel) transmitTime(size int) time.Duration {
+	if m.Bandwidth == 0 {
+		return 0
+	}
+	return time.Duration(uint64(size) * uint64(time.Second) / m.Bandwidth)
+}
+
+// delay returns a random time it takes transmitted data to reach the other
+// end of the link.
+func (m LinkModel) delay() time.Duration {
+	d := m.Latency
+	if m.Jitter > 0 {
+		d += time.Duration(rand.Int63n(2*int64(m.Jitter)+1)) - m.Jitter
+		if d < 0 {
+			d = 0
+		}
+	}
+	rto := 2 * m.Latency
+	if rto < minRetransmitTimeout {
+		rto = minRetransmitTimeout
+	}
+	for m.Loss > 0 && rand.Float64() < m.Loss {
+		d += rto
+	}
+	return d
+}
+
+// LinkShaper is implemented by node adapters which can emulate network
+// conditions on the connections between their nodes.
+type LinkShaper interface {
+	// SetLinkModel sets the conditions of the link between two nodes. The
+	// model applies to established connections as well as future ones.
+	SetLinkModel(one, other enode.ID, model LinkModel) error
+}
+
+// link is the shared state of the connections between two nodes. Its model
+// may be changed while connections are using it.
+type link struct {
+	mu      sync.Mutex
+	model   LinkModel
+	changed chan struct{} // closed when the model is updated
+}
+
+func newLink() *link {
+	return &link{changed: make(chan struct{})}
+}
+
+// get returns the current model and a channel which is closed on change.
+func (l *link) get() (LinkModel, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.model, l.changed
+}
+
+// set updates the model of the link.
+func (l *link) set(model LinkModel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.model = model
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// linkPacket is a write travelling on a link.
+type linkPacket struct {
+	data []byte
+	at   time.Time // delivery time
+}
+
+// linkConn wraps one end of a connection, emulating the conditions of a link
+// on all data written to it.
+type linkConn struct {
+	net.Conn
+	link   *link
+	queue  chan linkPacket
+	closed chan struct{}
+	once   sync.Once
+
+	wmu  sync.Mutex // serializes writes
+	last time.Time  // delivery time of the last queued write
+
+	mu            sync.Mutex
+	writeDeadline time.Time
+	err           error
+}
+
+func newLinkConn(conn net.Conn, l *link) *linkConn {
+	c := &linkConn{
+		Conn:   conn,
+		link:   l,
+		queue:  make(chan linkPacket, linkQueueSize),
+		closed: make(chan struct{}),
+	}
+	go c.deliver()
+	return c
+}
+
+// Write queues data for delivery to the other end of the link. It blocks while
+// the link is partitioned or the data is being transmitted.
+func (c *linkConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	select {
+	case <-c.closed:
+		return 0, c.closeErr()
+	default:
+	}
+	model, changed := c.link.get()
+	for model.Partitioned {
+		if err := c.wait(changed, nil); err != nil {
+			return 0, err
+		}
+		model, changed = c.link.get()
+	}
+	if t := model.transmitTime(len(b)); t > 0 {
+		timer := time.NewTimer(t)
+		err := c.wait(nil, timer.C)
+		timer.Stop()
+		if err != nil {
+			return 0, err
+		}
+	}
+	// Deliver writes in order, even if the delay of a write is shorter than
+	// the one of its predecessor.
+	at := time.Now().Add(model.delay())
+	if at.Before(c.last) {
+		at = c.last
+	}
+	c.last = at
+
+	expired, stop := c.deadline()
+	defer stop()
+
+	select {
+	case c.queue <- linkPacket{data: append([]byte{}, b...), at: at}:
+		return len(b), nil
+	case <-expired:
+		return 0, os.ErrDeadlineExceeded
+	case <-c.closed:
+		return 0, c.closeErr()
+	}
+}
+
+// wait blocks until one of the given channels fires, or returns an error if
+// the connection is closed or the write deadline expires.
+func (c *linkConn) wait(changed <-chan struct{}, timeout <-chan time.Time) error {
+	expired, stop := c.deadline()
+	defer stop()
+
+	select {
+	case <-changed:
+		return nil
+	case <-timeout:
+		return nil
+	case <-expired:
+		return os.ErrDeadlineExceeded
+	case <-c.closed:
+		return c.closeErr()
+	}
+}
+
+// deadline returns a channel which fires when the write deadline expires, and
+// a function releasing its timer.
+func (c *linkConn) deadline() (<-chan time.Time, func()) {
+	c.mu.Lock()
+	deadline := c.writeDeadline
+	c.mu.Unlock()
+
+	if deadline.IsZero() {
+		return nil, func() {}
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	return timer.C, func() { timer.Stop() }
+}
+
+// deliver writes queued data to the underlying connection when it's due.
+func (c *linkConn) deliver() {
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		select {
+		case pkt := <-c.queue:
+			if !c.await(pkt.at, timer) {
+				return
+			}
+			if _, err := c.Conn.Write(pkt.data); err != nil {
+				c.closeWithError(err)
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// await blocks until the given delivery time is reached and the link is not
+// partitioned, holding back data which was already in flight when the link got
+// partitioned. It returns false if the connection is closed meanwhile.
+func (c *linkConn) await(at time.Time, timer *time.Timer) bool {
+	for {
+		model, changed := c.link.get()
+		if model.Partitioned {
+			select {
+			case <-changed:
+				continue
+			case <-c.closed:
+				return false
+			}
+		}
+		d := time.Until(at)
+		if d <= 0 {
+			return true
+		}
+		timer.Reset(d)
+		select {
+		case <-timer.C:
+		case <-changed:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-c.closed:
+			return false
+		}
+	}
+}
+
+// SetDeadline sets the read and write deadlines of the connection.
+func (c *linkConn) SetDeadline(t time.Time) error {
+	c.SetWriteDeadline(t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for writes to be accepted by the link.
+func (c *linkConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
+
+// Close closes the connection, discarding any data still in flight.
+func (c *linkConn) Close() error {
+	return c.closeWithError(io.ErrClosedPipe)
+}
+
+func (c *linkConn) closeWithError(err error) error {
+	var closeErr error
+	c.once.Do(func() {
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		close(c.closed)
+		closeErr = c.Conn.Close()
+	})
+	return closeErr
+}
+
+func (c *linkConn) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
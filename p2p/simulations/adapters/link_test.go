@@ -0,0 +1,191 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adapters
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestLink(model LinkModel) (*link, net.Conn, net.Conn) {
+	l := newLink()
+	l.set(model)
+	p1, p2 := net.Pipe()
+	return l, newLinkConn(p1, l), newLinkConn(p2, l)
+}
+
+// timedRead reads the given amount of data and reports how long it took.
+func timedRead(t *testing.T, c net.Conn, size int) ([]byte, time.Duration) {
+	start := time.Now()
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal("read error:", err)
+	}
+	return buf, time.Since(start)
+}
+
+func TestLinkLatency(t *testing.T) {
+	l, c1, c2 := newTestLink(LinkModel{Latency: 100 * time.Millisecond})
+	defer c1.Close()
+	defer c2.Close()
+
+	if _, err := c1.Write([]byte("ping")); err != nil {
+		t.Fatal("write error:", err)
+	}
+	if msg, elapsed := timedRead(t, c2, 4); string(msg) != "ping" || elapsed < 90*time.Millisecond {
+		t.Fatalf("wrong delivery: %q after %v", msg, elapsed)
+	}
+	// Check that model changes apply to established connections
+	l.set(LinkModel{})
+	if _, err := c2.Write([]byte("pong")); err != nil {
+		t.Fatal("write error:", err)
+	}
+	if msg, elapsed := timedRead(t, c1, 4); string(msg) != "pong" || elapsed > 50*time.Millisecond {
+		t.Fatalf("wrong delivery: %q after %v", msg, elapsed)
+	}
+}
+
+func TestLinkJitterOrdering(t *testing.T) {
+	_, c1, c2 := newTestLink(LinkModel{Latency: 20 * time.Millisecond, Jitter: 20 * time.Millisecond, Loss: 0.2})
+	defer c1.Close()
+	defer c2.Close()
+
+	var want bytes.Buffer
+	for i := 0; i < 50; i++ {
+		msg := []byte(fmt.Sprintf("msg %02d", i))
+		want.Write(msg)
+		if _, err := c1.Write(msg); err != nil {
+			t.Fatal("write error:", err)
+		}
+	}
+	if have, _ := timedRead(t, c2, want.Len()); !bytes.Equal(have, want.Bytes()) {
+		t.Fatalf("data reordered:\nhave %q\nwant %q", have, want.Bytes())
+	}
+}
+
+func TestLinkBandwidth(t *testing.T) {
+	_, c1, c2 := newTestLink(LinkModel{Bandwidth: 10000})
+	defer c1.Close()
+	defer c2.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c1.Write(make([]byte, 2000))
+		errc <- err
+	}()
+	if _, elapsed := timedRead(t, c2, 2000); elapsed < 190*time.Millisecond {
+		t.Fatalf("transmission too fast: %v", elapsed)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal("write error:", err)
+	}
+}
+
+func TestLinkPartition(t *testing.T) {
+	l, c1, c2 := newTestLink(LinkModel{Partitioned: true})
+	defer c1.Close()
+	defer c2.Close()
+
+	// Writes should time out while partitioned
+	c1.SetWriteDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := c1.Write([]byte("lost")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("wrong error for partitioned write: %v", err)
+	}
+	c1.SetWriteDeadline(time.Time{})
+
+	// Writes should go through once the partition is lifted
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c1.Write([]byte("ping"))
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		t.Fatalf("write returned while partitioned: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.set(LinkModel{})
+	if msg, _ := timedRead(t, c2, 4); string(msg) != "ping" {
+		t.Fatalf("wrong delivery: %q", msg)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal("write error:", err)
+	}
+}
+
+func TestLinkPartitionInFlight(t *testing.T) {
+	l, c1, c2 := newTestLink(LinkModel{Latency: 50 * time.Millisecond})
+	defer c1.Close()
+	defer c2.Close()
+
+	// Data in flight should be held back once the link gets partitioned
+	if _, err := c1.Write([]byte("ping")); err != nil {
+		t.Fatal("write error:", err)
+	}
+	l.set(LinkModel{Latency: 50 * time.Millisecond, Partitioned: true})
+
+	c2.SetReadDeadline(time.Now().Add(150 * time.Millisecond))
+	if n, err := c2.Read(make([]byte, 4)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("data delivered while partitioned: %d bytes, err %v", n, err)
+	}
+	c2.SetReadDeadline(time.Time{})
+
+	// And delivered once the partition is lifted
+	l.set(LinkModel{})
+	if msg, _ := timedRead(t, c2, 4); string(msg) != "ping" {
+		t.Fatalf("wrong delivery: %q", msg)
+	}
+}
+
+func TestLinkClose(t *testing.T) {
+	_, c1, c2 := newTestLink(LinkModel{Partitioned: true})
+	defer c2.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c1.Write([]byte("ping"))
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	c1.Close()
+	if err := <-errc; err != io.ErrClosedPipe {
+		t.Fatalf("wrong error for write on closed connection: %v", err)
+	}
+}
+
+func TestLinkModelValidate(t *testing.T) {
+	invalid := []LinkModel{
+		{Latency: -1},
+		{Jitter: -1},
+		{Loss: -0.1},
+		{Loss: 1},
+	}
+	for _, model := range invalid {
+		if err := model.Validate(); err == nil {
+			t.Errorf("no error for invalid model %+v", model)
+		}
+	}
+	if err := (LinkModel{Latency: time.Second, Loss: 0.5}).Validate(); err != nil {
+		t.Errorf("error for valid model: %v", err)
+	}
+}
@@ -205,6 +205,24 @@ func (c *Client) DisconnectNode(nodeID, peerID string) error {
 	return c.Delete(fmt.Sprintf("/nodes/%s/conn/%s", nodeID, peerID))
 }
 
+// GetLinkModel returns the network conditions emulated on the connection
+// between a node and a peer node
+func (c *Client) GetLinkModel(nodeID, peerID string) (*adapters.LinkModel, error) {
+	model := &adapters.LinkModel{}
+	return model, c.Get(fmt.Sprintf("/nodes/%s/link/%s", nodeID, peerID), model)
+}
+
+// SetLinkModel sets the network conditions emulated on the connection between
+// a node and a peer node
+func (c *Client) SetLinkModel(nodeID, peerID string, model *adapters.LinkModel) error {
+	return c.Post(fmt.Sprintf("/nodes/%s/link/%s", nodeID, peerID), model, nil)
+}
+
+// ResetLinkModel restores a perfect link between a node and a peer node
+func (c *Client) ResetLinkModel(nodeID, peerID string) error {
+	return c.Delete(fmt.Sprintf("/nodes/%s/link/%s", nodeID, peerID))
+}
+
 // RPCClient returns an RPC client connected to a node
 func (c *Client) RPCClient(ctx context.Context, nodeID string) (*rpc.Client, error) {
 	baseURL := strings.Replace(c.URL, "http", "ws", 1)
@@ -295,6 +313,9 @@ func NewServer(network *Network) *Server {
 	s.POST("/nodes/:nodeid/stop", s.StopNode)
 	s.POST("/nodes/:nodeid/conn/:peerid", s.ConnectNode)
 	s.DELETE("/nodes/:nodeid/conn/:peerid", s.DisconnectNode)
+	s.GET("/nodes/:nodeid/link/:peerid", s.GetLinkModel)
+	s.POST("/nodes/:nodeid/link/:peerid", s.SetLinkModel)
+	s.DELETE("/nodes/:nodeid/link/:peerid", s.ResetLinkModel)
 	s.GET("/nodes/:nodeid/rpc", s.NodeRPC)
 
 	return s
@@ -642,6 +663,51 @@ func (s *Server) DisconnectNode(w http.ResponseWriter, req *http.Request) {
 	s.JSON(w, http.StatusOK, node.NodeInfo())
 }
 
+// GetLinkModel returns the network conditions emulated on the connection
+// between a node and a peer node
+func (s *Server) GetLinkModel(w http.ResponseWriter, req *http.Request) {
+	node := req.Context().Value("node").(*Node)
+	peer := req.Context().Value("peer").(*Node)
+
+	s.JSON(w, http.StatusOK, s.network.GetLinkModel(node.ID(), peer.ID()))
+}
+
+// SetLinkModel sets the network conditions emulated on the connection between
+// a node and a peer node
+func (s *Server) SetLinkModel(w http.ResponseWriter, req *http.Request) {
+	node := req.Context().Value("node").(*Node)
+	peer := req.Context().Value("peer").(*Node)
+
+	model := adapters.LinkModel{}
+	if err := json.NewDecoder(req.Body).Decode(&model); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := model.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.network.SetLinkModel(node.ID(), peer.ID(), model); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.JSON(w, http.StatusOK, model)
+}
+
+// ResetLinkModel restores a perfect link between a node and a peer node
+func (s *Server) ResetLinkModel(w http.ResponseWriter, req *http.Request) {
+	node := req.Context().Value("node").(*Node)
+	peer := req.Context().Value("peer").(*Node)
+
+	if err := s.network.SetLinkModel(node.ID(), peer.ID(), adapters.LinkModel{}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.JSON(w, http.StatusOK, adapters.LinkModel{})
+}
+
 // Options responds to the OPTIONS HTTP method by returning a 200 OK response
 // with the "Access-Control-Allow-Headers" header set to "Content-Type"
 func (s *Server) Options(w http.ResponseWriter, req *http.Request) {
@@ -592,6 +592,63 @@ func TestHTTPNodeRPC(t *testing.T) {
 	}
 }
 
+// TestHTTPLinkModel tests changing the network conditions between nodes
+// using the HTTP API
+func TestHTTPLinkModel(t *testing.T) {
+	// start the server
+	network, s := testHTTPServer(t)
+	defer s.Close()
+
+	// create two nodes
+	client := NewClient(s.URL)
+	var ids []string
+	for i := 0; i < 2; i++ {
+		node, err := client.CreateNode(adapters.RandomNodeConfig())
+		if err != nil {
+			t.Fatalf("error creating node: %s", err)
+		}
+		ids = append(ids, node.ID)
+	}
+
+	// set and retrieve a link model
+	model := &adapters.LinkModel{
+		Latency:   50 * time.Millisecond,
+		Jitter:    10 * time.Millisecond,
+		Bandwidth: 1024 * 1024,
+		Loss:      0.01,
+	}
+	if err := client.SetLinkModel(ids[0], ids[1], model); err != nil {
+		t.Fatalf("error setting link model: %s", err)
+	}
+	have, err := client.GetLinkModel(ids[1], ids[0])
+	if err != nil {
+		t.Fatalf("error getting link model: %s", err)
+	}
+	if *have != *model {
+		t.Fatalf("link model mismatch: have %+v, want %+v", have, model)
+	}
+	conn := network.Conns[0]
+	if conn.Link == nil || *conn.Link != *model {
+		t.Fatalf("link model not applied to network: %+v", conn.Link)
+	}
+
+	// check invalid models are rejected
+	if err := client.SetLinkModel(ids[0], ids[1], &adapters.LinkModel{Loss: 2}); err == nil {
+		t.Fatal("expected error setting invalid link model")
+	}
+
+	// reset the link model
+	if err := client.ResetLinkModel(ids[0], ids[1]); err != nil {
+		t.Fatalf("error resetting link model: %s", err)
+	}
+	if have, err = client.GetLinkModel(ids[0], ids[1]); err != nil {
+		t.Fatalf("error getting link model: %s", err)
+	}
+	if *have != (adapters.LinkModel{}) {
+		t.Fatalf("link model not reset: %+v", have)
+	}
+}
+
 // TestHTTPSnapshot tests creating and loading network snapshots
 func TestHTTPSnapshot(t *testing.T) {
 	// start the server
@@ -649,6 +649,52 @@ func (net *Network) getConn(oneID, otherID enode.ID) *Conn {
 	return net.Conns[i]
 }
 
+// GetLinkModel returns the network conditions emulated on the connection
+// between "one" and "other"
+func (net *Network) GetLinkModel(oneID, otherID enode.ID) adapters.LinkModel {
+	net.lock.RLock()
+	defer net.lock.RUnlock()
+
+	if conn := net.getConn(oneID, otherID); conn != nil && conn.Link != nil {
+		return *conn.Link
+	}
+	return adapters.LinkModel{}
+}
+
+// SetLinkModel sets the network conditions emulated on the connection between
+// "one" and "other". The model applies to an established connection right
+// away and to all future connections between the two nodes. Setting the zero
+// model restores a perfect link.
+func (net *Network) SetLinkModel(oneID, otherID enode.ID, model adapters.LinkModel) error {
+	if err := model.Validate(); err != nil {
+		return err
+	}
+	shaper, ok := net.nodeAdapter.(adapters.LinkShaper)
+	if !ok {
+		return fmt.Errorf("%s does not support link models", net.nodeAdapter.Name())
+	}
+	if oneID == otherID {
+		return fmt.Errorf("refusing to set link model to self %v", oneID)
+	}
+	net.lock.Lock()
+	defer net.lock.Unlock()
+
+	conn, err := net.getOrCreateConn(oneID, otherID)
+	if err != nil {
+		return err
+	}
+	if err := shaper.SetLinkModel(oneID, otherID, model); err != nil {
+		return err
+	}
+	if model == (adapters.LinkModel{}) {
+		conn.Link = nil
+	} else {
+		conn.Link = &model
+	}
+	log.Debug("Link model updated", "one", oneID, "other", otherID, "model", model)
+	return nil
+}
+
 // InitConn(one, other) retrieves the connection model for the connection between
 // peers one and other, or creates a new one if it does not exist
 // the order of nodes does not matter, i.e., Conn(i,j) == Conn(j, i)
@@ -817,6 +863,11 @@ type Conn struct {
 
 	// Up tracks whether or not the connection is active
 	Up bool `json:"up"`
+
+	// Link is the model of the network conditions on the connection, nil
+	// for a perfect link
+	Link *adapters.LinkModel `json:"link,omitempty"`
+
 	// Registers when the connection was grabbed to dial
 	initiated time.Time
 
@@ -1034,6 +1085,11 @@ func (net *Network) Load(snap *Snapshot) error {
 			//so it would result in the snapshot `Load` to fail
 			continue
 		}
+		if conn.Link != nil {
+			if err := net.SetLinkModel(conn.One, conn.Other, *conn.Link); err != nil {
+				return err
+			}
+		}
 		if err := net.Connect(conn.One, conn.Other); err != nil {
 			return err
 		}
@@ -436,6 +436,75 @@ func createTestNodesWithProperty(property string, count int, network *Network) (
 // TestGetNodeIDs creates a set of nodes and attempts to retrieve their IDs,.
 // It then tests again whilst excluding a node ID from being returned.
 // If a node ID is not returned, or more node IDs than expected are returned, the test fails.
+// Tests that link models are applied to the connections between nodes and
+// included in network snapshots.
+func TestNetworkLinkModel(t *testing.T) {
+	adapter := adapters.NewSimAdapter(adapters.LifecycleConstructors{
+		"noopwoop": func(ctx *adapters.ServiceContext, stack *node.Node) (node.Lifecycle, error) {
+			return NewNoopService(nil), nil
+		},
+	})
+	network := NewNetwork(adapter, &NetworkConfig{
+		DefaultService: "noopwoop",
+	})
+	defer network.Shutdown()
+
+	nodes, err := createTestNodes(2, network)
+	if err != nil {
+		t.Fatalf("Could not create test nodes %v", err)
+	}
+	one, other := nodes[0].ID(), nodes[1].ID()
+
+	if err := network.SetLinkModel(one, other, adapters.LinkModel{Loss: 1}); err == nil {
+		t.Fatal("Expected error for invalid link model")
+	}
+	model := adapters.LinkModel{Latency: 100 * time.Millisecond}
+	if err := network.SetLinkModel(one, other, model); err != nil {
+		t.Fatalf("Could not set link model: %v", err)
+	}
+	if have := network.GetLinkModel(other, one); have != model {
+		t.Fatalf("Link model mismatch: have %+v, want %+v", have, model)
+	}
+
+	// The connection handshake takes multiple round trips over the link
+	events := make(chan *Event, 10)
+	sub := network.Events().Subscribe(events)
+	defer sub.Unsubscribe()
+
+	start := time.Now()
+	if err := network.Connect(one, other); err != nil {
+		t.Fatalf("Could not connect nodes: %v", err)
+	}
+	timeout := time.After(10 * time.Second)
+	for connected := false; !connected; {
+		select {
+		case ev := <-events:
+			connected = ev.Type == EventTypeConn && ev.Conn.Up
+		case <-timeout:
+			t.Fatal("Timed out waiting for connection")
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*model.Latency {
+		t.Fatalf("Connection established too fast: %v", elapsed)
+	}
+
+	snap, err := network.Snapshot()
+	if err != nil {
+		t.Fatalf("Could not create snapshot: %v", err)
+	}
+	if len(snap.Conns) != 1 || snap.Conns[0].Link == nil || *snap.Conns[0].Link != model {
+		t.Fatalf("Link model missing from snapshot: %+v", snap.Conns)
+	}
+
+	// Resetting the model should restore a perfect link
+	if err := network.SetLinkModel(one, other, adapters.LinkModel{}); err != nil {
+		t.Fatalf("Could not reset link model: %v", err)
+	}
+	if conn := network.GetConn(one, other); conn.Link != nil {
+		t.Fatalf("Link model not reset: %+v", conn.Link)
+	}
+}
+
 func TestGetNodeIDs(t *testing.T) {
 	adapter := adapters.NewSimAdapter(adapters.LifecycleConstructors{
 		"test": newTestService,
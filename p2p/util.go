@@ -18,8 +18,12 @@ package p2p
 
 import (
 	"container/heap"
+	"net"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 )
 
 // expHeap tracks strings and their expiry time.
@@ -73,3 +77,105 @@ func (h *expHeap) Pop() interface{} {
 	*h = old[0 : n-1]
 	return x
 }
+
+// banList is the in-memory set of node ID and IP bans. It is safe for concurrent use.
+type banList struct {
+	mu  sync.RWMutex
+	ids map[enode.ID]enode.Ban
+	ips map[string]enode.Ban
+}
+
+func newBanList() *banList {
+	return &banList{
+		ids: make(map[enode.ID]enode.Ban),
+		ips: make(map[string]enode.Ban),
+	}
+}
+
+// add inserts or replaces a ban.
+func (l *banList) add(b enode.Ban) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b.IP != nil {
+		l.ips[string(b.IP.To16())] = b
+	} else {
+		l.ids[b.ID] = b
+	}
+}
+
+// remove deletes the ban on the ID or IP of b. It reports whether a ban was present.
+func (l *banList) remove(b enode.Ban) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b.IP != nil {
+		key := string(b.IP.To16())
+		_, ok := l.ips[key]
+		delete(l.ips, key)
+		return ok
+	}
+	_, ok := l.ids[b.ID]
+	delete(l.ids, b.ID)
+	return ok
+}
+
+// containsIP reports whether ip is banned at the given time.
+func (l *banList) containsIP(ip net.IP, now time.Time) bool {
+	if ip == nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	b, ok := l.ips[string(ip.To16())]
+	return ok && !b.Expired(now)
+}
+
+// containsNode reports whether the ID or the IP of n is banned at the given time.
+func (l *banList) containsNode(n *enode.Node, now time.Time) bool {
+	l.mu.RLock()
+	b, ok := l.ids[n.ID()]
+	l.mu.RUnlock()
+
+	if ok && !b.Expired(now) {
+		return true
+	}
+	return l.containsIP(n.IP(), now)
+}
+
+// expire removes all bans which are expired at the given time and returns them.
+func (l *banList) expire(now time.Time) []enode.Ban {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var expired []enode.Ban
+	for id, b := range l.ids {
+		if b.Expired(now) {
+			expired = append(expired, b)
+			delete(l.ids, id)
+		}
+	}
+	for ip, b := range l.ips {
+		if b.Expired(now) {
+			expired = append(expired, b)
+			delete(l.ips, ip)
+		}
+	}
+	return expired
+}
+
+// list returns all bans.
+func (l *banList) list() []enode.Ban {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	bans := make([]enode.Ban, 0, len(l.ids)+len(l.ips))
+	for _, b := range l.ids {
+		bans = append(bans, b)
+	}
+	for _, b := range l.ips {
+		bans = append(bans, b)
+	}
+	return bans
+}
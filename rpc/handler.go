@@ -61,6 +61,7 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	limiter        *connLimiter // resource limits, nil if not limited
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -101,6 +102,14 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 		})
 		return
 	}
+	// Reject batches exceeding the configured size:
+	if limit := h.limiter.batchItems(); limit > 0 && len(msgs) > limit {
+		markLimited("batch")
+		h.startCallProc(func(cp *callProc) {
+			h.conn.writeJSON(cp.ctx, errorMessage(&limitExceededError{"batch too large"}))
+		})
+		return
+	}
 
 	// Handle non-call messages first:
 	calls := make([]*jsonrpcMessage, 0, len(msgs))
@@ -114,11 +123,36 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
+		var (
+			answers  = make([]*jsonrpcMessage, 0, len(msgs))
+			limit    = h.limiter.batchResponseSize()
+			size     int
+			exceeded bool
+		)
 		for _, msg := range calls {
-			if answer := h.handleCallMsg(cp, msg); answer != nil {
-				answers = append(answers, answer)
+			// Once the combined response size limit is reached, the remaining
+			// calls are answered with an error instead of being executed.
+			if exceeded {
+				if msg.isCall() {
+					markLimited("response")
+					answers = append(answers, msg.errorResponse(&limitExceededError{"response too large"}))
+				}
+				continue
+			}
+			answer := h.handleCallMsg(cp, msg)
+			if answer == nil {
+				continue
 			}
+			// Check the limit before adding the answer, so the batch response
+			// never grows beyond it.
+			if limit > 0 && size+len(answer.Result) > limit {
+				markLimited("response")
+				answer = msg.errorResponse(&limitExceededError{"response too large"})
+				exceeded = true
+			} else {
+				size += len(answer.Result)
+			}
+			answers = append(answers, answer)
 		}
 		h.addSubscriptions(cp.notifiers)
 		if len(answers) > 0 {
@@ -329,12 +363,20 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if callb == nil {
 		return msg.errorResponse(&methodNotFoundError{method: msg.Method})
 	}
+	if callb != h.unsubscribeCb && !h.limiter.allow(msg.Method) {
+		markLimited("rate")
+		return msg.errorResponse(&limitExceededError{"rate limit exceeded"})
+	}
 	args, err := parsePositionalArguments(msg.Params, callb.argTypes)
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
 	start := time.Now()
 	answer := h.runMethod(cp.ctx, msg, callb, args)
+	if limit := h.limiter.responseSize(msg.Method); limit > 0 && len(answer.Result) > limit {
+		markLimited("response")
+		answer = msg.errorResponse(&limitExceededError{"response too large"})
+	}
 
 	// Collect the statistics for RPC calls if metrics is enabled.
 	// We only care about pure rpc call. Filter out subscription.
@@ -367,6 +409,10 @@ func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMes
 	if callb == nil {
 		return msg.errorResponse(&subscriptionNotFoundError{namespace, name})
 	}
+	if !h.limiter.allow(msg.Method) {
+		markLimited("rate")
+		return msg.errorResponse(&limitExceededError{"rate limit exceeded"})
+	}
 
 	// Parse subscription name arg too, but remove it before calling the callback.
 	argTypes := append([]reflect.Type{stringType}, callb.argTypes...)
@@ -0,0 +1,219 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+)
+
+// httpLimiterCacheSize is the number of remote hosts whose rate limiter state is
+// retained for HTTP requests, which don't have a persistent connection.
+const httpLimiterCacheSize = 1024
+
+// Limits represents the resource limits enforced by the server on every connection.
+// HTTP requests from the same remote host are treated as a single connection. A zero
+// value in any of the fields means that the corresponding limit is not enforced.
+type Limits struct {
+	// BatchItems is the maximum number of requests allowed in a batch.
+	BatchItems int `toml:",omitempty"`
+
+	// ResponseSize is the maximum size in bytes of the result of a single call. For
+	// batches, it also limits the combined size of all results in the batch.
+	ResponseSize int `toml:",omitempty"`
+
+	// RequestsPerSecond is the number of cost units a connection may spend per second.
+	RequestsPerSecond float64 `toml:",omitempty"`
+
+	// Namespaces holds the response size and rate limits for the methods of a given
+	// namespace (e.g. "debug"). These apply in addition to the connection-wide limits.
+	Namespaces map[string]NamespaceLimits `toml:",omitempty"`
+
+	// MethodCosts assigns a cost weight to the given methods (e.g. "eth_getLogs").
+	// Methods not listed here cost one unit per call.
+	MethodCosts map[string]int `toml:",omitempty"`
+}
+
+// NamespaceLimits represents the limits enforced on the methods of a namespace.
+type NamespaceLimits struct {
+	ResponseSize      int     `toml:",omitempty"`
+	RequestsPerSecond float64 `toml:",omitempty"`
+}
+
+// enabled reports whether any limit is configured.
+func (l *Limits) enabled() bool {
+	return l.BatchItems > 0 || l.ResponseSize > 0 || l.RequestsPerSecond > 0 || len(l.Namespaces) > 0
+}
+
+// cost returns the cost weight of the given method.
+func (l *Limits) cost(method string) int {
+	if cost, ok := l.MethodCosts[method]; ok && cost > 0 {
+		return cost
+	}
+	return 1
+}
+
+// burst returns the bucket size of a rate limiter refilled at the given rate. It is
+// large enough to admit a single call of the most expensive method.
+func (l *Limits) burst(rps float64) int {
+	burst := int(math.Ceil(rps))
+	for _, cost := range l.MethodCosts {
+		if cost > burst {
+			burst = cost
+		}
+	}
+	return burst
+}
+
+// connLimiter tracks the resource usage of a single connection.
+type connLimiter struct {
+	limits *Limits
+	conn   *rate.Limiter // nil if there is no connection-wide rate limit
+
+	mu         sync.Mutex
+	namespaces map[string]*rate.Limiter
+}
+
+func newConnLimiter(limits *Limits) *connLimiter {
+	l := &connLimiter{limits: limits, namespaces: make(map[string]*rate.Limiter)}
+	if rps := limits.RequestsPerSecond; rps > 0 {
+		l.conn = rate.NewLimiter(rate.Limit(rps), limits.burst(rps))
+	}
+	return l
+}
+
+// batchItems returns the maximum batch size, or zero if batches are not limited.
+func (l *connLimiter) batchItems() int {
+	if l == nil {
+		return 0
+	}
+	return l.limits.BatchItems
+}
+
+// batchResponseSize returns the maximum combined response size of a batch, or zero
+// if it is not limited.
+func (l *connLimiter) batchResponseSize() int {
+	if l == nil {
+		return 0
+	}
+	return l.limits.ResponseSize
+}
+
+// responseSize returns the maximum response size of the given method, or zero if
+// responses are not limited.
+func (l *connLimiter) responseSize(method string) int {
+	if l == nil {
+		return 0
+	}
+	size := l.limits.ResponseSize
+	if ns, ok := l.limits.Namespaces[methodNamespace(method)]; ok && ns.ResponseSize > 0 {
+		if size == 0 || ns.ResponseSize < size {
+			size = ns.ResponseSize
+		}
+	}
+	return size
+}
+
+// allow charges the cost of the given method against the connection's rate limits
+// and reports whether the call may proceed. Nothing is charged if the call is rejected.
+func (l *connLimiter) allow(method string) bool {
+	if l == nil {
+		return true
+	}
+	var (
+		now      = time.Now()
+		cost     = l.limits.cost(method)
+		limiters = make([]*rate.Limiter, 0, 2)
+	)
+	if l.conn != nil {
+		limiters = append(limiters, l.conn)
+	}
+	if ns := l.namespaceLimiter(methodNamespace(method)); ns != nil {
+		limiters = append(limiters, ns)
+	}
+	reservations := make([]*rate.Reservation, 0, len(limiters))
+	for _, limiter := range limiters {
+		r := limiter.ReserveN(now, cost)
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			r.CancelAt(now)
+			for _, prev := range reservations {
+				prev.CancelAt(now)
+			}
+			return false
+		}
+		reservations = append(reservations, r)
+	}
+	rpcCostGauge.Inc(int64(cost))
+	return true
+}
+
+// namespaceLimiter returns the rate limiter of the given namespace, or nil if the
+// namespace is not rate limited.
+func (l *connLimiter) namespaceLimiter(namespace string) *rate.Limiter {
+	ns, ok := l.limits.Namespaces[namespace]
+	if !ok || ns.RequestsPerSecond <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter := l.namespaces[namespace]
+	if limiter == nil {
+		limiter = rate.NewLimiter(rate.Limit(ns.RequestsPerSecond), l.limits.burst(ns.RequestsPerSecond))
+		l.namespaces[namespace] = limiter
+	}
+	return limiter
+}
+
+// httpLimiters keeps the limiter state of recent HTTP clients, keyed by remote host.
+type httpLimiters struct {
+	limits *Limits
+	mu     sync.Mutex
+	cache  *lru.Cache
+}
+
+func newHTTPLimiters(limits *Limits) *httpLimiters {
+	cache, _ := lru.New(httpLimiterCacheSize)
+	return &httpLimiters{limits: limits, cache: cache}
+}
+
+// get returns the limiter of the given remote address.
+func (hl *httpLimiters) get(remote string) *connLimiter {
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	if l, ok := hl.cache.Get(remote); ok {
+		return l.(*connLimiter)
+	}
+	l := newConnLimiter(hl.limits)
+	hl.cache.Add(remote, l)
+	return l
+}
+
+// methodNamespace returns the namespace part of a method name.
+func methodNamespace(method string) string {
+	return strings.SplitN(method, serviceMethodSeparator, 2)[0]
+}
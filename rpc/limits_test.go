@@ -0,0 +1,158 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// limitTestConn serves a test server with the given limits over an in-memory pipe.
+type limitTestConn struct {
+	t    *testing.T
+	conn net.Conn
+	in   *bufio.Reader
+}
+
+func newLimitTestConn(t *testing.T, limits Limits) *limitTestConn {
+	server := newTestServer()
+	server.SetLimits(limits)
+
+	clientConn, serverConn := net.Pipe()
+	go server.ServeCodec(NewCodec(serverConn), 0)
+	t.Cleanup(func() {
+		clientConn.Close()
+		server.Stop()
+	})
+	return &limitTestConn{t: t, conn: clientConn, in: bufio.NewReader(clientConn)}
+}
+
+// roundTrip sends a request and decodes the response.
+func (c *limitTestConn) roundTrip(req string, resp interface{}) {
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.conn.Write([]byte(req + "\n")); err != nil {
+		c.t.Fatalf("write error: %v", err)
+	}
+	line, err := c.in.ReadBytes('\n')
+	if err != nil {
+		c.t.Fatalf("read error: %v", err)
+	}
+	if err := json.Unmarshal(line, resp); err != nil {
+		c.t.Fatalf("invalid response %s: %v", line, err)
+	}
+}
+
+func (c *limitTestConn) call(method string, args string) *jsonrpcMessage {
+	var resp jsonrpcMessage
+	c.roundTrip(`{"jsonrpc":"2.0","id":1,"method":"`+method+`","params":`+args+`}`, &resp)
+	return &resp
+}
+
+func checkLimitError(t *testing.T, msg *jsonrpcMessage, want string) {
+	t.Helper()
+	if msg.Error == nil {
+		t.Fatalf("expected %q error, got result %s", want, msg.Result)
+	}
+	if msg.Error.Code != -32005 || msg.Error.Message != want {
+		t.Fatalf("wrong error: got %d %q, want %d %q", msg.Error.Code, msg.Error.Message, -32005, want)
+	}
+}
+
+func TestLimitsBatchItems(t *testing.T) {
+	c := newLimitTestConn(t, Limits{BatchItems: 2})
+
+	var answers []*jsonrpcMessage
+	c.roundTrip(`[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["x",2]}]`, &answers)
+	if len(answers) != 2 || answers[0].Error != nil || answers[1].Error != nil {
+		t.Fatalf("unexpected answers to batch within limit: %v", answers)
+	}
+	var resp jsonrpcMessage
+	c.roundTrip(`[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["x",2]},{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["x",3]}]`, &resp)
+	checkLimitError(t, &resp, "batch too large")
+}
+
+func TestLimitsResponseSize(t *testing.T) {
+	c := newLimitTestConn(t, Limits{
+		ResponseSize: 100,
+		Namespaces:   map[string]NamespaceLimits{"test": {ResponseSize: 50}},
+	})
+	if resp := c.call("test_echo", `["x",1]`); resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	resp := c.call("test_echo", `["`+strings.Repeat("x", 60)+`",1]`)
+	checkLimitError(t, resp, "response too large")
+
+	// The answer which would exceed the combined size limit and all calls after
+	// it are replaced by errors.
+	c = newLimitTestConn(t, Limits{ResponseSize: 100})
+	long := strings.Repeat("x", 30)
+	var answers []*jsonrpcMessage
+	c.roundTrip(`[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["`+long+`",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["`+long+`",2]},{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["x",3]}]`, &answers)
+	if len(answers) != 3 {
+		t.Fatalf("wrong number of answers: %d", len(answers))
+	}
+	if answers[0].Error != nil {
+		t.Fatalf("unexpected error in batch: %v", answers[0].Error)
+	}
+	checkLimitError(t, answers[1], "response too large")
+	checkLimitError(t, answers[2], "response too large")
+
+	// Answers which fit into the remaining space are kept.
+	c = newLimitTestConn(t, Limits{ResponseSize: 100})
+	var fitting []*jsonrpcMessage
+	c.roundTrip(`[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["`+long+`",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["x",2]}]`, &fitting)
+	if len(fitting) != 2 || fitting[0].Error != nil || fitting[1].Error != nil {
+		t.Fatalf("unexpected answers to batch within limit: %v", fitting)
+	}
+}
+
+func TestLimitsRate(t *testing.T) {
+	c := newLimitTestConn(t, Limits{
+		RequestsPerSecond: 3,
+		MethodCosts:       map[string]int{"test_echo": 2},
+	})
+	// The bucket holds three units, the first echo call spends two of them.
+	if resp := c.call("test_echo", `["x",1]`); resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	checkLimitError(t, c.call("test_echo", `["x",1]`), "rate limit exceeded")
+
+	// Cheaper methods can still use the remaining unit.
+	if resp := c.call("test_rets", `[]`); resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	checkLimitError(t, c.call("test_rets", `[]`), "rate limit exceeded")
+}
+
+func TestLimitsNamespaceRate(t *testing.T) {
+	c := newLimitTestConn(t, Limits{
+		Namespaces: map[string]NamespaceLimits{"test": {RequestsPerSecond: 1}},
+	})
+	if resp := c.call("test_rets", `[]`); resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	checkLimitError(t, c.call("test_rets", `[]`), "rate limit exceeded")
+
+	// Other namespaces aren't affected.
+	if resp := c.call("rpc_modules", `[]`); resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+}
@@ -27,6 +27,8 @@ var (
 	successfulRequestGauge = metrics.NewRegisteredGauge("rpc/success", nil)
 	failedReqeustGauge     = metrics.NewRegisteredGauge("rpc/failure", nil)
 	rpcServingTimer        = metrics.NewRegisteredTimer("rpc/duration/all", nil)
+	limitedRequestGauge    = metrics.NewRegisteredGauge("rpc/limited", nil)
+	rpcCostGauge           = metrics.NewRegisteredGauge("rpc/cost", nil)
 )
 
 func newRPCServingTimer(method string, valid bool) metrics.Timer {
@@ -37,3 +39,9 @@ func newRPCServingTimer(method string, valid bool) metrics.Timer {
 	m := fmt.Sprintf("rpc/duration/%s/%s", method, flag)
 	return metrics.GetOrRegisterTimer(m, nil)
 }
+
+// markLimited records a request rejected because of the given limit.
+func markLimited(limit string) {
+	limitedRequestGauge.Inc(1)
+	metrics.GetOrRegisterGauge("rpc/limited/"+limit, nil).Inc(1)
+}
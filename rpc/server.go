@@ -46,6 +46,9 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+
+	limits       *Limits       // nil if no limits are configured
+	httpLimiters *httpLimiters // limiter state of HTTP clients
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -66,6 +69,17 @@ func (s *Server) RegisterName(name string, receiver interface{}) error {
 	return s.services.registerName(name, receiver)
 }
 
+// SetLimits configures the resource limits enforced on all connections served by the
+// server. It must be called before the server starts serving requests.
+func (s *Server) SetLimits(limits Limits) {
+	if !limits.enabled() {
+		s.limits, s.httpLimiters = nil, nil
+		return
+	}
+	s.limits = &limits
+	s.httpLimiters = newHTTPLimiters(s.limits)
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes
 // the response back using the given codec. It will block until the codec is closed or the
 // server is stopped. In either case the codec is closed.
@@ -83,7 +97,11 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	var limiter *connLimiter
+	if s.limits != nil {
+		limiter = newConnLimiter(s.limits)
+	}
+	c := initClient(codec, s.idgen, &s.services, limiter)
 	<-codec.closed()
 	c.Close()
 }
@@ -99,6 +117,9 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 
 	h := newHandler(ctx, codec, s.idgen, &s.services)
 	h.allowSubscribe = false
+	if s.httpLimiters != nil {
+		h.limiter = s.httpLimiters.get(codec.remoteAddr())
+	}
 	defer h.close(io.EOF, nil)
 
 	reqs, batch, err := codec.readBatch()
@@ -122,3 +122,27 @@ func TestBlockNumberOrHash_UnmarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockNumberOrHash_RoundTrip(t *testing.T) {
+	tests := []BlockNumberOrHash{
+		BlockNumberOrHashWithNumber(1),
+		BlockNumberOrHashWithNumber(PendingBlockNumber),
+		BlockNumberOrHashWithNumber(LatestBlockNumber),
+		BlockNumberOrHashWithNumber(EarliestBlockNumber),
+		BlockNumberOrHashWithHash(common.HexToHash("0x1234"), false),
+		BlockNumberOrHashWithHash(common.HexToHash("0x1234"), true),
+	}
+	for i, test := range tests {
+		enc, err := json.Marshal(test)
+		if err != nil {
+			t.Fatalf("test %d: failed to marshal: %v", i, err)
+		}
+		var dec BlockNumberOrHash
+		if err := json.Unmarshal(enc, &dec); err != nil {
+			t.Fatalf("test %d: failed to unmarshal %s: %v", i, enc, err)
+		}
+		if dec.String() != test.String() || dec.RequireCanonical != test.RequireCanonical {
+			t.Errorf("test %d: round trip mismatch, want %v, got %v", i, test, dec)
+		}
+	}
+}
@@ -35,6 +35,7 @@ type leaf struct {
 	size int         // size of the rlp data (estimate)
 	hash common.Hash // hash of rlp data
 	node node        // the node to commit
+	path []byte      // path of the node in the trie
 }
 
 // committer is a type used for the trie Commit operation. A committer has some
@@ -49,6 +50,10 @@ type committer struct {
 
 	onleaf LeafCallback
 	leafCh chan *leaf
+
+	owner   common.Hash // Owner of the trie being committed
+	tracer  *tracer     // Structural change tracker, nil unless nodes are stored by path
+	written [][]byte    // Paths of the stored nodes, only tracked alongside a tracer
 }
 
 // committers live in a global sync.Pool
@@ -62,13 +67,17 @@ var committerPool = sync.Pool{
 }
 
 // newCommitter creates a new committer or picks one from the pool.
-func newCommitter() *committer {
-	return committerPool.Get().(*committer)
+func newCommitter(owner common.Hash, tracer *tracer) *committer {
+	c := committerPool.Get().(*committer)
+	c.owner, c.tracer = owner, tracer
+	return c
 }
 
 func returnCommitterToPool(h *committer) {
 	h.onleaf = nil
 	h.leafCh = nil
+	h.tracer = nil
+	h.written = nil
 	committerPool.Put(h)
 }
 
@@ -77,7 +86,7 @@ func (c *committer) Commit(n node, db *Database) (hashNode, error) {
 	if db == nil {
 		return nil, errors.New("no db provided")
 	}
-	h, err := c.commit(n, db)
+	h, err := c.commit(nil, n, db)
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +94,7 @@ func (c *committer) Commit(n node, db *Database) (hashNode, error) {
 }
 
 // commit collapses a node down into a hash node and inserts it into the database
-func (c *committer) commit(n node, db *Database) (node, error) {
+func (c *committer) commit(path []byte, n node, db *Database) (node, error) {
 	// if this path is clean, use available cached data
 	hash, dirty := n.cache()
 	if hash != nil && !dirty {
@@ -100,7 +109,7 @@ func (c *committer) commit(n node, db *Database) (node, error) {
 		// If the child is fullnode, recursively commit.
 		// Otherwise it can only be hashNode or valueNode.
 		if _, ok := cn.Val.(*fullNode); ok {
-			childV, err := c.commit(cn.Val, db)
+			childV, err := c.commit(append(path, cn.Key...), cn.Val, db)
 			if err != nil {
 				return nil, err
 			}
@@ -108,20 +117,20 @@ func (c *committer) commit(n node, db *Database) (node, error) {
 		}
 		// The key needs to be copied, since we're delivering it to database
 		collapsed.Key = hexToCompact(cn.Key)
-		hashedNode := c.store(collapsed, db)
+		hashedNode := c.store(path, collapsed, db)
 		if hn, ok := hashedNode.(hashNode); ok {
 			return hn, nil
 		}
 		return collapsed, nil
 	case *fullNode:
-		hashedKids, err := c.commitChildren(cn, db)
+		hashedKids, err := c.commitChildren(path, cn, db)
 		if err != nil {
 			return nil, err
 		}
 		collapsed := cn.copy()
 		collapsed.Children = hashedKids
 
-		hashedNode := c.store(collapsed, db)
+		hashedNode := c.store(path, collapsed, db)
 		if hn, ok := hashedNode.(hashNode); ok {
 			return hn, nil
 		}
@@ -135,7 +144,7 @@ func (c *committer) commit(n node, db *Database) (node, error) {
 }
 
 // commitChildren commits the children of the given fullnode
-func (c *committer) commitChildren(n *fullNode, db *Database) ([17]node, error) {
+func (c *committer) commitChildren(path []byte, n *fullNode, db *Database) ([17]node, error) {
 	var children [17]node
 	for i := 0; i < 16; i++ {
 		child := n.Children[i]
@@ -152,7 +161,7 @@ func (c *committer) commitChildren(n *fullNode, db *Database) ([17]node, error)
 		// Commit the child recursively and store the "hashed" value.
 		// Note the returned node can be some embedded nodes, so it's
 		// possible the type is not hashnode.
-		hashed, err := c.commit(child, db)
+		hashed, err := c.commit(append(path, byte(i)), child, db)
 		if err != nil {
 			return children, err
 		}
@@ -168,7 +177,7 @@ func (c *committer) commitChildren(n *fullNode, db *Database) ([17]node, error)
 // store hashes the node n and if we have a storage layer specified, it writes
 // the key/value pair to it and tracks any node->child references as well as any
 // node->external trie references.
-func (c *committer) store(n node, db *Database) node {
+func (c *committer) store(path []byte, n node, db *Database) node {
 	// Larger nodes are replaced by their hash and stored in the database.
 	var (
 		hash, _ = n.cache()
@@ -179,12 +188,23 @@ func (c *committer) store(n node, db *Database) node {
 		// In theory we should apply the leafCall here if it's not nil(embedded
 		// node usually contains value). But small value(less than 32bytes) is
 		// not our target.
+		//
+		// If a standalone node was stored at the same path before, it needs
+		// deleting as it's now embedded in its parent.
+		if c.tracer.stored(path) {
+			c.tracer.onDelete(path)
+		}
 		return n
 	} else {
 		// We have the hash already, estimate the RLP encoding-size of the node.
 		// The size is used for mem tracking, does not need to be exact
 		size = estimateSize(n)
 	}
+	// Paths are copied, since the buffers are reused while traversing
+	path = common.CopyBytes(path)
+	if c.tracer != nil {
+		c.written = append(c.written, path)
+	}
 	// If we're using channel-based leaf-reporting, send to channel.
 	// The leaf channel will be active only when there an active leaf-callback
 	if c.leafCh != nil {
@@ -192,12 +212,13 @@ func (c *committer) store(n node, db *Database) node {
 			size: size,
 			hash: common.BytesToHash(hash),
 			node: n,
+			path: path,
 		}
 	} else if db != nil {
 		// No leaf-callback used, but there's still a database. Do serial
 		// insertion
 		db.lock.Lock()
-		db.insert(common.BytesToHash(hash), size, n)
+		db.insert(c.owner, path, common.BytesToHash(hash), size, n)
 		db.lock.Unlock()
 	}
 	return hash
@@ -213,7 +234,7 @@ func (c *committer) commitLoop(db *Database) {
 		)
 		// We are pooling the trie nodes into an intermediate memory cache
 		db.lock.Lock()
-		db.insert(hash, size, n)
+		db.insert(c.owner, item.path, hash, size, n)
 		db.lock.Unlock()
 
 		if c.onleaf != nil {
@@ -68,6 +68,7 @@ var (
 // servers even while the trie is executing expensive garbage collection.
 type Database struct {
 	diskdb ethdb.KeyValueStore // Persistent storage for matured trie nodes
+	scheme string              // Storage scheme of the trie nodes (hash or path)
 
 	cleans  *fastcache.Cache            // GC friendly memory cache of clean node RLPs
 	dirties map[common.Hash]*cachedNode // Data and references relationships of dirty trie nodes
@@ -88,6 +89,14 @@ type Database struct {
 	childrenSize  common.StorageSize // Storage size of the external children tracking
 	preimagesSize common.StorageSize // Storage size of the preimages cache
 
+	// Fields below are only used if trie nodes are stored by path
+	pending  *pathLayer                 // Nodes committed but not yet assigned to a state
+	layers   map[common.Hash]*pathLayer // In-memory diff layers, keyed by state root
+	index    map[string][]*pathLayer    // Diff layers containing a node, keyed by owner and path
+	diskRoot common.Hash                // State root of the trie nodes persisted on disk
+
+	flushHook func(common.Hash) // Callback invoked for every hash-keyed node written to disk
+
 	lock sync.RWMutex
 }
 
@@ -277,6 +286,7 @@ type Config struct {
 	Cache     int    // Memory allowance (MB) to use for caching trie nodes in memory
 	Journal   string // Journal of clean cache to survive node restarts
 	Preimages bool   // Flag whether the preimage of trie key is recorded
+	Scheme    string // Storage scheme of the trie nodes, defaults to the one in the database
 }
 
 // NewDatabase creates a new trie database to store ephemeral trie content before
@@ -300,17 +310,42 @@ func NewDatabaseWithConfig(diskdb ethdb.KeyValueStore, config *Config) *Database
 	}
 	db := &Database{
 		diskdb: diskdb,
+		scheme: rawdb.ReadStateScheme(diskdb),
 		cleans: cleans,
 		dirties: map[common.Hash]*cachedNode{{}: {
 			children: make(map[common.Hash]uint16),
 		}},
 	}
+	if config != nil && config.Scheme != "" {
+		db.scheme = config.Scheme
+	}
+	if db.scheme == "" {
+		db.scheme = rawdb.HashScheme
+	}
+	if db.scheme == rawdb.PathScheme {
+		db.initPathLayers()
+	}
 	if config == nil || config.Preimages { // TODO(karalabe): Flip to default off in the future
 		db.preimages = make(map[common.Hash][]byte)
 	}
 	return db
 }
 
+// Scheme returns the storage scheme of the trie nodes.
+func (db *Database) Scheme() string {
+	return db.scheme
+}
+
+// SetFlushHook installs a callback invoked with the hash of every trie node before
+// it's written to disk, replacing any previous one. A nil hook removes it. Nodes
+// stored by path are not reported.
+func (db *Database) SetFlushHook(hook func(common.Hash)) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.flushHook = hook
+}
+
 // DiskDB retrieves the persistent storage backing the trie database.
 func (db *Database) DiskDB() ethdb.KeyValueStore {
 	return db.diskdb
@@ -320,7 +355,13 @@ func (db *Database) DiskDB() ethdb.KeyValueStore {
 // The blob size must be specified to allow proper size tracking.
 // All nodes inserted by this function will be reference tracked
 // and in theory should only used for **trie nodes** insertion.
-func (db *Database) insert(hash common.Hash, size int, node node) {
+//
+// The owner and path are only used if nodes are stored by path.
+func (db *Database) insert(owner common.Hash, path []byte, hash common.Hash, size int, node node) {
+	if db.scheme == rawdb.PathScheme {
+		db.insertPath(owner, path, hash, node)
+		return
+	}
 	// If the node's already cached, skip
 	if _, ok := db.dirties[hash]; ok {
 		return
@@ -418,6 +459,10 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 			return enc, nil
 		}
 	}
+	// Nodes stored by path can't be looked up by hash alone
+	if db.scheme == rawdb.PathScheme {
+		return nil, errors.New("not found")
+	}
 	// Retrieve the node from the dirty cache if available
 	db.lock.RLock()
 	dirty := db.dirties[hash]
@@ -481,7 +526,12 @@ func (db *Database) Nodes() []common.Hash {
 // This function is used to add reference between internal trie node
 // and external node(e.g. storage trie root), all internal trie nodes
 // are referenced together by database itself.
+//
+// Nodes stored by path are not reference counted, the method is a noop then.
 func (db *Database) Reference(child common.Hash, parent common.Hash) {
+	if db.scheme == rawdb.PathScheme {
+		return
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -510,7 +560,12 @@ func (db *Database) reference(child common.Hash, parent common.Hash) {
 }
 
 // Dereference removes an existing reference from a root node.
+//
+// Nodes stored by path are not reference counted, the method is a noop then.
 func (db *Database) Dereference(root common.Hash) {
+	if db.scheme == rawdb.PathScheme {
+		return
+	}
 	// Sanity check to ensure that the meta-root is not removed
 	if root == (common.Hash{}) {
 		log.Error("Attempted to dereference the trie cache meta root")
@@ -589,7 +644,13 @@ func (db *Database) dereference(child common.Hash, parent common.Hash) {
 //
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
+//
+// If nodes are stored by path, the memory usage is bounded by the number of
+// diff layers instead (see CapLayers), the method is a noop then.
 func (db *Database) Cap(limit common.StorageSize) error {
+	if db.scheme == rawdb.PathScheme {
+		return nil
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -597,6 +658,10 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	nodes, storage, start := len(db.dirties), db.dirtiesSize, time.Now()
 	batch := db.diskdb.NewBatch()
 
+	db.lock.RLock()
+	hook := db.flushHook
+	db.lock.RUnlock()
+
 	// db.dirtiesSize only contains the useful data in the cache, but when reporting
 	// the total memory consumption, the maintenance metadata is also needed to be
 	// counted.
@@ -625,6 +690,9 @@ func (db *Database) Cap(limit common.StorageSize) error {
 		// Fetch the oldest referenced node and push into the batch
 		node := db.dirties[oldest]
 		rawdb.WriteTrieNode(batch, oldest, node.rlp())
+		if hook != nil {
+			hook(oldest)
+		}
 
 		// If we exceeded the ideal batch size, commit and reset
 		if batch.ValueSize() >= ethdb.IdealBatchSize {
@@ -692,7 +760,13 @@ func (db *Database) Cap(limit common.StorageSize) error {
 //
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
+//
+// If nodes are stored by path, the state identified by the given root is
+// flattened into the disk instead, see commitPath.
 func (db *Database) Commit(node common.Hash, report bool, callback func(common.Hash)) error {
+	if db.scheme == rawdb.PathScheme {
+		return db.commitPath(node, report, callback)
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -719,6 +793,18 @@ func (db *Database) Commit(node common.Hash, report bool, callback func(common.H
 	// Move the trie itself into the batch, flushing if enough data is accumulated
 	nodes, storage := len(db.dirties), db.dirtiesSize
 
+	db.lock.RLock()
+	if hook := db.flushHook; hook != nil {
+		inner := callback
+		callback = func(hash common.Hash) {
+			hook(hash)
+			if inner != nil {
+				inner(hash)
+			}
+		}
+	}
+	db.lock.RUnlock()
+
 	uncacher := &cleaner{db}
 	if err := db.commit(node, batch, uncacher, callback); err != nil {
 		log.Error("Failed to commit trie from trie database", "err", err)
@@ -846,6 +932,9 @@ func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
+	if db.scheme == rawdb.PathScheme {
+		return db.pathLayersSize(), db.preimagesSize
+	}
 	// db.dirtiesSize only contains the useful data in the cache, but when reporting
 	// the total memory consumption, the maintenance metadata is also needed to be
 	// counted.
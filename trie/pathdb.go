@@ -0,0 +1,411 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// In the path scheme every trie node is stored keyed by the hash of the account
+// owning the trie (zero for the account trie) and the node's path within it.
+// Contrary to the hash scheme, only a single version of the state is persisted
+// on disk, every state transition overwriting the stale nodes in place.
+//
+// Recent states are kept in memory as a set of diff layers on top of the disk
+// state, each holding the nodes changed by a single state transition. This
+// allows reorgs to be handled, as long as they don't go deeper than the number
+// of layers retained. Once too many layers accumulate, the oldest ones get
+// flattened into the disk, dropping any sibling layers not descending from the
+// newly persisted state.
+//
+// Since every node is verified against the hash referenced by its parent, a
+// node can be served from any layer holding the same owner and path, as well
+// as from the disk.
+//
+// Each layer is flattened into the disk with a single atomic batch, so that an
+// interrupted flatten leaves the disk at the state of the last layer written.
+// The disk state root is derived from the stored root node on startup.
+
+// pathNode is a trie node stored in the path scheme, along with its hash. A nil
+// blob marks the node as deleted.
+type pathNode struct {
+	hash common.Hash
+	blob []byte
+}
+
+// pathLayer is the set of trie node changes made by a single state transition.
+type pathLayer struct {
+	root   common.Hash              // State root after the transition
+	parent common.Hash              // State root the transition was applied on
+	nodes  map[string]*pathNode     // Changed nodes, keyed by owner and path
+	wipes  map[common.Hash]struct{} // Accounts whose entire storage was deleted
+	size   common.StorageSize       // Approximate memory used by the layer
+}
+
+// newPathLayer creates an empty diff layer.
+func newPathLayer() *pathLayer {
+	return &pathLayer{
+		nodes: make(map[string]*pathNode),
+		wipes: make(map[common.Hash]struct{}),
+	}
+}
+
+// pathKey returns the in-memory key of the trie node with the given owner and path.
+func pathKey(owner common.Hash, path []byte) string {
+	return string(owner[:]) + string(path)
+}
+
+// splitPathKey splits an in-memory key into the owner and path of a trie node.
+func splitPathKey(key string) (common.Hash, []byte) {
+	return common.BytesToHash([]byte(key[:common.HashLength])), []byte(key[common.HashLength:])
+}
+
+// initPathLayers sets up an empty diff layer stack on top of the state stored
+// on disk.
+func (db *Database) initPathLayers() {
+	db.pending = newPathLayer()
+	db.layers = make(map[common.Hash]*pathLayer)
+	db.index = make(map[string][]*pathLayer)
+
+	db.diskRoot = emptyRoot
+	if blob := rawdb.ReadAccountTrieNode(db.diskdb, nil); len(blob) > 0 {
+		db.diskRoot = crypto.Keccak256Hash(blob)
+	}
+}
+
+// insertPath tracks a committed trie node until it's assigned to a state.
+//
+// Note, this method assumes that the database's lock is held!
+func (db *Database) insertPath(owner common.Hash, path []byte, hash common.Hash, n node) {
+	blob, err := rlp.EncodeToBytes(simplifyNode(n))
+	if err != nil {
+		panic(err)
+	}
+	db.pending.nodes[pathKey(owner, path)] = &pathNode{hash: hash, blob: blob}
+	db.pending.size += common.StorageSize(len(path) + len(blob) + 2*common.HashLength)
+}
+
+// deletePath tracks a trie node removed from its trie until it's assigned to
+// a state.
+//
+// Note, this method assumes that the database's lock is held!
+func (db *Database) deletePath(owner common.Hash, path []byte) {
+	db.pending.nodes[pathKey(owner, path)] = &pathNode{}
+	db.pending.size += common.StorageSize(len(path) + 2*common.HashLength)
+}
+
+// DeleteStorage marks all the storage trie nodes of an account as deleted, to
+// be removed from disk when the state transition is flattened. It is only
+// relevant if trie nodes are stored by path, the method is a noop otherwise.
+func (db *Database) DeleteStorage(owner common.Hash) {
+	if db.scheme != rawdb.PathScheme {
+		return
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.pending.wipes[owner] = struct{}{}
+}
+
+// pathNode retrieves the trie node with the given owner, path and hash, either
+// from the memory layers or from disk. Nil is returned if no such node exists.
+func (db *Database) pathNode(owner common.Hash, path []byte, hash common.Hash) node {
+	// Retrieve the node from the clean cache if available
+	if db.cleans != nil {
+		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
+			memcacheCleanHitMeter.Mark(1)
+			memcacheCleanReadMeter.Mark(int64(len(enc)))
+			return mustDecodeNode(hash[:], enc)
+		}
+	}
+	// Retrieve the node from the diff layers if available. Any layer holding
+	// the node with the expected hash is good, regardless of its state.
+	key := pathKey(owner, path)
+
+	db.lock.RLock()
+	var blob []byte
+	if n := db.pending.nodes[key]; n != nil && n.hash == hash {
+		blob = n.blob
+	}
+	for _, layer := range db.index[key] {
+		if blob != nil {
+			break
+		}
+		if n := layer.nodes[key]; n.hash == hash {
+			blob = n.blob
+		}
+	}
+	db.lock.RUnlock()
+
+	if blob != nil {
+		memcacheDirtyHitMeter.Mark(1)
+		memcacheDirtyReadMeter.Mark(int64(len(blob)))
+		return mustDecodeNode(hash[:], blob)
+	}
+	memcacheDirtyMissMeter.Mark(1)
+
+	// Content unavailable in memory, attempt to retrieve from disk. The node
+	// stored at the path might belong to a different state, verify it.
+	if owner == (common.Hash{}) {
+		blob = rawdb.ReadAccountTrieNode(db.diskdb, path)
+	} else {
+		blob = rawdb.ReadStorageTrieNode(db.diskdb, owner, path)
+	}
+	if len(blob) == 0 || crypto.Keccak256Hash(blob) != hash {
+		return nil
+	}
+	if db.cleans != nil {
+		db.cleans.Set(hash[:], blob)
+		memcacheCleanMissMeter.Mark(1)
+		memcacheCleanWriteMeter.Mark(int64(len(blob)))
+	}
+	return mustDecodeNode(hash[:], blob)
+}
+
+// Update assigns all the trie nodes committed since the last update to a new
+// diff layer, representing the transition from the parent state to the given
+// root. It is only relevant if trie nodes are stored by path, the method is a
+// noop otherwise.
+func (db *Database) Update(root common.Hash, parent common.Hash) error {
+	if db.scheme != rawdb.PathScheme {
+		return nil
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	layer := db.pending
+	db.pending = newPathLayer()
+
+	// Skip empty transitions and already known states
+	if root == parent || root == db.diskRoot {
+		return nil
+	}
+	if _, ok := db.layers[root]; ok {
+		return nil
+	}
+	if _, ok := db.layers[parent]; !ok && parent != db.diskRoot {
+		return fmt.Errorf("parent state %x not available", parent)
+	}
+	layer.root, layer.parent = root, parent
+	db.layers[root] = layer
+	for key := range layer.nodes {
+		db.index[key] = append(db.index[key], layer)
+	}
+	return nil
+}
+
+// CapLayers flattens the diff layers into the disk until at most the given
+// number of layers remain below the given state root. Layers not descending
+// from the newly persisted state are discarded. It is only relevant if trie
+// nodes are stored by path, the method is a noop otherwise.
+func (db *Database) CapLayers(root common.Hash, layers int) error {
+	if db.scheme != rawdb.PathScheme {
+		return nil
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	_, err := db.capLayers(root, layers, nil)
+	return err
+}
+
+// capLayers is the private locked version of CapLayers, returning the number
+// of trie nodes written to disk.
+func (db *Database) capLayers(root common.Hash, layers int, callback func(common.Hash)) (int, error) {
+	bottom := db.layers[root]
+	if bottom == nil {
+		if root == db.diskRoot {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("state %x not available", root)
+	}
+	for i := 0; i < layers; i++ {
+		parent := db.layers[bottom.parent]
+		if parent == nil {
+			return 0, nil // Not enough layers to flatten any
+		}
+		bottom = parent
+	}
+	// Gather all the layers to flatten, writing them out oldest first
+	var chain []*pathLayer
+	for layer := bottom; layer != nil; layer = db.layers[layer.parent] {
+		chain = append(chain, layer)
+	}
+	if oldest := chain[len(chain)-1]; oldest.parent != db.diskRoot {
+		return 0, fmt.Errorf("layer %x detached from disk state %x", oldest.root, db.diskRoot)
+	}
+	var (
+		start   = time.Now()
+		nodes   int
+		flatErr error
+	)
+	for i := len(chain) - 1; i >= 0; i-- {
+		written, err := db.flattenLayer(chain[i], callback)
+		if err != nil {
+			flatErr = err
+			break
+		}
+		nodes += written
+		db.diskRoot = chain[i].root
+	}
+	if flatErr == nil && db.preimages != nil && db.preimagesSize > 0 {
+		batch := db.diskdb.NewBatch()
+		rawdb.WritePreimages(batch, db.preimages)
+		if err := batch.Write(); err != nil {
+			flatErr = err
+		} else {
+			db.preimages, db.preimagesSize = make(map[common.Hash][]byte), 0
+		}
+	}
+
+	// Drop the flattened layers, along with all the ones on other branches
+	descends := map[common.Hash]bool{db.diskRoot: true}
+	var check func(root common.Hash) bool
+	check = func(root common.Hash) bool {
+		if ok, known := descends[root]; known {
+			return ok
+		}
+		layer := db.layers[root]
+		descends[root] = layer != nil && check(layer.parent)
+		return descends[root]
+	}
+	for root, layer := range db.layers {
+		if root != db.diskRoot && check(root) {
+			continue
+		}
+		delete(db.layers, root)
+		for key := range layer.nodes {
+			db.unindex(key, layer)
+		}
+	}
+	if flatErr != nil {
+		return nodes, flatErr
+	}
+	log.Debug("Flattened trie layers into disk", "root", db.diskRoot, "layers", len(chain), "nodes", nodes, "live", len(db.layers), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nodes, nil
+}
+
+// unindex removes a layer from the node index of the given key.
+func (db *Database) unindex(key string, layer *pathLayer) {
+	list := db.index[key]
+	for i, l := range list {
+		if l == layer {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(db.index, key)
+	} else {
+		db.index[key] = list
+	}
+}
+
+// flattenLayer writes all the changes of a diff layer into the disk, returning
+// the number of trie nodes written. The changes are written with a single batch,
+// so the disk either holds the state before or after the layer, never a mix of
+// both. Storage wipes are applied first, followed by the layer's nodes, which
+// might recreate a wiped storage trie.
+func (db *Database) flattenLayer(layer *pathLayer, callback func(common.Hash)) (int, error) {
+	batch := db.diskdb.NewBatch()
+	for owner := range layer.wipes {
+		it := rawdb.IterateStorageTrieNodes(db.diskdb, owner)
+		for it.Next() {
+			batch.Delete(it.Key())
+		}
+		it.Release()
+	}
+	var written int
+	for key, n := range layer.nodes {
+		owner, path := splitPathKey(key)
+		switch {
+		case n.blob == nil && owner == (common.Hash{}):
+			rawdb.DeleteAccountTrieNode(batch, path)
+		case n.blob == nil:
+			rawdb.DeleteStorageTrieNode(batch, owner, path)
+		case owner == (common.Hash{}):
+			rawdb.WriteAccountTrieNode(batch, path, n.blob)
+		default:
+			rawdb.WriteStorageTrieNode(batch, owner, path, n.blob)
+		}
+		if n.blob != nil {
+			written++
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return 0, err
+	}
+	if callback != nil {
+		for _, n := range layer.nodes {
+			if n.blob != nil {
+				callback(n.hash)
+			}
+		}
+	}
+	return written, nil
+}
+
+// commitPath flattens all the diff layers up to the given state root into the
+// disk. Any trie nodes committed but not yet assigned to a state are assumed
+// to belong to the given root, on top of the disk state.
+func (db *Database) commitPath(root common.Hash, report bool, callback func(common.Hash)) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if len(db.pending.nodes) > 0 || len(db.pending.wipes) > 0 {
+		layer := db.pending
+		db.pending = newPathLayer()
+
+		if _, ok := db.layers[root]; !ok && root != db.diskRoot {
+			layer.root, layer.parent = root, db.diskRoot
+			db.layers[root] = layer
+			for key := range layer.nodes {
+				db.index[key] = append(db.index[key], layer)
+			}
+		}
+	}
+	start := time.Now()
+	nodes, err := db.capLayers(root, 0, callback)
+	if err != nil {
+		log.Error("Failed to commit trie from trie database", "err", err)
+		return err
+	}
+	logger := log.Info
+	if !report {
+		logger = log.Debug
+	}
+	logger("Persisted trie from memory database", "root", root, "nodes", nodes, "time", time.Since(start), "livelayers", len(db.layers))
+	return nil
+}
+
+// pathLayersSize returns the approximate memory used by the diff layers.
+//
+// Note, this method assumes that the database's lock is held!
+func (db *Database) pathLayersSize() common.StorageSize {
+	size := db.pending.size
+	for _, layer := range db.layers {
+		size += layer.size
+	}
+	return size
+}
@@ -0,0 +1,329 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+)
+
+// pathTestKey returns a deterministic key and value for the i-th test entry.
+func pathTestKey(i int, version byte) ([]byte, []byte) {
+	var enc [8]byte
+	binary.BigEndian.PutUint64(enc[:], uint64(i))
+	return crypto.Keccak256(enc[:]), bytes.Repeat([]byte{version, byte(i)}, 16)
+}
+
+// commitPathTrie updates the given trie with the changes, commits it and assigns
+// the result to a new diff layer on top of parent.
+func commitPathTrie(t *testing.T, tr *Trie, parent common.Hash, changes map[int]byte) common.Hash {
+	for i, version := range changes {
+		key, val := pathTestKey(i, version)
+		if version == 0 {
+			tr.Delete(key)
+		} else {
+			tr.Update(key, val)
+		}
+	}
+	root, err := tr.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if err := tr.db.Update(root, parent); err != nil {
+		t.Fatalf("failed to update trie database: %v", err)
+	}
+	return root
+}
+
+// checkPathTrie verifies that the trie at root contains exactly the expected
+// entries.
+func checkPathTrie(t *testing.T, db *Database, owner common.Hash, root common.Hash, content map[int]byte) {
+	tr, err := NewWithOwner(owner, root, db)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", root, err)
+	}
+	for i, version := range content {
+		key, val := pathTestKey(i, version)
+		if have := tr.Get(key); version != 0 && !bytes.Equal(have, val) {
+			t.Fatalf("entry %d mismatch: have %x, want %x", i, have, val)
+		} else if version == 0 && have != nil {
+			t.Fatalf("deleted entry %d present: %x", i, have)
+		}
+	}
+}
+
+// checkPathDisk verifies that the trie nodes stored on disk for the owner are
+// exactly the ones of the trie at root, without any stale leftovers.
+func checkPathDisk(t *testing.T, diskdb ethdb.KeyValueStore, db *Database, owner common.Hash, root common.Hash) {
+	tr, err := NewWithOwner(owner, root, db)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", root, err)
+	}
+	want := make(map[string]struct{})
+	for it := tr.NodeIterator(nil); it.Next(true); {
+		if it.Hash() != (common.Hash{}) {
+			want[string(it.Path())] = struct{}{}
+		}
+	}
+	if err := tr.NodeIterator(nil).Error(); err != nil {
+		t.Fatalf("failed to iterate trie: %v", err)
+	}
+	var it ethdb.Iterator
+	if owner == (common.Hash{}) {
+		it = diskdb.NewIterator(rawdb.TrieNodeAccountPrefix, nil)
+	} else {
+		it = rawdb.IterateStorageTrieNodes(diskdb, owner)
+	}
+	defer it.Release()
+
+	have := 0
+	for it.Next() {
+		path := it.Key()[1+len(ownerPrefix(owner)):]
+		if _, ok := want[string(path)]; !ok {
+			t.Errorf("stale node at path %x", path)
+		}
+		have++
+	}
+	if have != len(want) {
+		t.Fatalf("stored node count mismatch: have %d, want %d", have, len(want))
+	}
+}
+
+// ownerPrefix returns the owner part of the database key of a trie node.
+func ownerPrefix(owner common.Hash) []byte {
+	if owner == (common.Hash{}) {
+		return nil
+	}
+	return owner[:]
+}
+
+// Tests that states stored by path are served from the diff layers until they
+// get flattened into the disk, after which only the latest one is retained.
+func TestPathSchemeLayers(t *testing.T) {
+	diskdb := memorydb.New()
+	rawdb.WriteStateScheme(diskdb, rawdb.PathScheme)
+
+	db := NewDatabase(diskdb)
+	if db.Scheme() != rawdb.PathScheme {
+		t.Fatalf("scheme mismatch: have %s, want %s", db.Scheme(), rawdb.PathScheme)
+	}
+	// Create an initial state and a second one modifying and deleting entries
+	first := make(map[int]byte)
+	for i := 0; i < 500; i++ {
+		first[i] = 1
+	}
+	tr, _ := NewWithOwner(common.Hash{}, emptyRoot, db)
+	root1 := commitPathTrie(t, tr, emptyRoot, first)
+
+	second := make(map[int]byte)
+	for i := 0; i < 500; i++ {
+		switch i % 3 {
+		case 0:
+			second[i] = 2
+		case 1:
+			second[i] = 0
+		default:
+			second[i] = 1
+		}
+	}
+	tr, _ = NewWithOwner(common.Hash{}, root1, db)
+	root2 := commitPathTrie(t, tr, root1, second)
+
+	// Create a sibling of the second state, which should be dropped later
+	sibling := map[int]byte{1: 3}
+	tr, _ = NewWithOwner(common.Hash{}, root1, db)
+	root3 := commitPathTrie(t, tr, root1, sibling)
+
+	// Nothing is written to disk yet, all states are served from memory
+	if blob := rawdb.ReadAccountTrieNode(diskdb, nil); blob != nil {
+		t.Fatalf("root node persisted before flattening")
+	}
+	checkPathTrie(t, db, common.Hash{}, root1, first)
+	checkPathTrie(t, db, common.Hash{}, root2, second)
+	checkPathTrie(t, db, common.Hash{}, root3, map[int]byte{1: 3, 2: 1})
+
+	// Flatten the first state, all of them should still be available
+	if err := db.CapLayers(root2, 1); err != nil {
+		t.Fatalf("failed to cap layers: %v", err)
+	}
+	checkPathTrie(t, db, common.Hash{}, root1, first)
+	checkPathTrie(t, db, common.Hash{}, root2, second)
+	checkPathTrie(t, db, common.Hash{}, root3, map[int]byte{1: 3, 2: 1})
+	checkPathDisk(t, diskdb, db, common.Hash{}, root1)
+
+	// Flatten the second state, the others should become unavailable
+	if err := db.CapLayers(root2, 0); err != nil {
+		t.Fatalf("failed to cap layers: %v", err)
+	}
+	checkPathTrie(t, db, common.Hash{}, root2, second)
+	checkPathDisk(t, diskdb, db, common.Hash{}, root2)
+
+	if _, err := NewWithOwner(common.Hash{}, root1, db); err == nil {
+		t.Fatalf("flattened state still available")
+	}
+	if _, err := NewWithOwner(common.Hash{}, root3, db); err == nil {
+		t.Fatalf("dropped sibling state still available")
+	}
+	if size := db.pathLayersSize(); size != 0 {
+		t.Fatalf("memory not released: %v", size)
+	}
+	// Ensure the persisted state survives a restart
+	checkPathTrie(t, NewDatabase(diskdb), common.Hash{}, root2, second)
+}
+
+// Tests that storage tries of different owners are stored separately and that
+// deleting the storage of an account removes all its nodes.
+func TestPathSchemeStorage(t *testing.T) {
+	diskdb := memorydb.New()
+	db := NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme})
+
+	var (
+		owner1 = common.HexToHash("0x01")
+		owner2 = common.HexToHash("0x02")
+	)
+	content := make(map[int]byte)
+	for i := 0; i < 100; i++ {
+		content[i] = 1
+	}
+	// Store the same content for both owners, followed by an account trie
+	for _, owner := range []common.Hash{owner1, owner2} {
+		tr, _ := NewWithOwner(owner, emptyRoot, db)
+		for i, version := range content {
+			key, val := pathTestKey(i, version)
+			tr.Update(key, val)
+		}
+		if _, err := tr.Commit(nil); err != nil {
+			t.Fatalf("failed to commit storage trie: %v", err)
+		}
+	}
+	tr, _ := NewWithOwner(common.Hash{}, emptyRoot, db)
+	root1 := commitPathTrie(t, tr, emptyRoot, map[int]byte{0: 1})
+	if err := db.CapLayers(root1, 0); err != nil {
+		t.Fatalf("failed to cap layers: %v", err)
+	}
+	var storageRoot common.Hash
+	for _, owner := range []common.Hash{owner1, owner2} {
+		st, _ := NewWithOwner(owner, emptyRoot, db)
+		for i, version := range content {
+			key, val := pathTestKey(i, version)
+			st.Update(key, val)
+		}
+		storageRoot = st.Hash()
+		checkPathTrie(t, db, owner, storageRoot, content)
+		checkPathDisk(t, diskdb, db, owner, storageRoot)
+	}
+	// Delete the storage of the first owner and flatten the change
+	db.DeleteStorage(owner1)
+	tr, _ = NewWithOwner(common.Hash{}, root1, db)
+	root2 := commitPathTrie(t, tr, root1, map[int]byte{0: 2})
+	if err := db.CapLayers(root2, 0); err != nil {
+		t.Fatalf("failed to cap layers: %v", err)
+	}
+	it := rawdb.IterateStorageTrieNodes(diskdb, owner1)
+	if it.Next() {
+		t.Fatalf("deleted storage node still present: %x", it.Key())
+	}
+	it.Release()
+	checkPathTrie(t, db, owner2, storageRoot, content)
+}
+
+// errPathWriteFailed is returned by a failingPathStore once its write limit is
+// exhausted.
+var errPathWriteFailed = errors.New("write failed")
+
+// failingPathStore is a key-value store whose batch writes fail after a given
+// number of successful ones, simulating a crash in the middle of a flatten.
+type failingPathStore struct {
+	ethdb.KeyValueStore
+	writes int // Number of batch writes still permitted
+}
+
+func (s *failingPathStore) NewBatch() ethdb.Batch {
+	return &failingPathBatch{Batch: s.KeyValueStore.NewBatch(), store: s}
+}
+
+type failingPathBatch struct {
+	ethdb.Batch
+	store *failingPathStore
+}
+
+func (b *failingPathBatch) Write() error {
+	if b.store.writes == 0 {
+		return errPathWriteFailed
+	}
+	b.store.writes--
+	return b.Batch.Write()
+}
+
+// Tests that a flatten interrupted at any point leaves a complete state on disk,
+// which is picked up after a restart.
+func TestPathSchemeInterruptedFlatten(t *testing.T) {
+	// Create a number of states, each large enough to exceed an ideal batch
+	// and deleting some of the entries of the previous one
+	var (
+		contents = make([]map[int]byte, 4)
+		entries  = 2000
+	)
+	for v := range contents {
+		contents[v] = make(map[int]byte)
+		for i := 0; i < entries; i++ {
+			if (i+v)%4 == 0 {
+				contents[v][i] = 0
+			} else {
+				contents[v][i] = byte(v + 1)
+			}
+		}
+	}
+	for limit := 0; limit <= len(contents); limit++ {
+		var (
+			memdb  = memorydb.New()
+			diskdb = &failingPathStore{KeyValueStore: memdb, writes: len(contents)}
+			db     = NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme})
+			roots  = []common.Hash{emptyRoot}
+		)
+		for _, content := range contents {
+			tr, _ := NewWithOwner(common.Hash{}, roots[len(roots)-1], db)
+			roots = append(roots, commitPathTrie(t, tr, roots[len(roots)-1], content))
+		}
+		// Flatten all the states, permitting only a limited number of writes
+		diskdb.writes = limit
+		err := db.CapLayers(roots[len(roots)-1], 0)
+		if limit < len(contents) && err != errPathWriteFailed {
+			t.Fatalf("limit %d: flatten error mismatch: have %v, want %v", limit, err, errPathWriteFailed)
+		}
+		if limit == len(contents) && err != nil {
+			t.Fatalf("limit %d: failed to flatten: %v", limit, err)
+		}
+		// Restart and ensure the disk holds exactly the last state written
+		db = NewDatabaseWithConfig(memdb, &Config{Scheme: rawdb.PathScheme})
+		if db.diskRoot != roots[limit] {
+			t.Fatalf("limit %d: disk root mismatch: have %x, want %x", limit, db.diskRoot, roots[limit])
+		}
+		if limit > 0 {
+			checkPathTrie(t, db, common.Hash{}, roots[limit], contents[limit-1])
+			checkPathDisk(t, memdb, db, common.Hash{}, roots[limit])
+		}
+	}
+}
@@ -52,10 +52,17 @@ type SecureTrie struct {
 // A new cache generation is created by each call to Commit.
 // cachelimit sets the number of past cache generations to keep.
 func NewSecure(root common.Hash, db *Database) (*SecureTrie, error) {
+	return NewSecureWithOwner(common.Hash{}, root, db)
+}
+
+// NewSecureWithOwner creates a secure trie owned by the given account hash,
+// which is zero for the account trie. The owner is needed to locate the trie
+// nodes if they are stored by path.
+func NewSecureWithOwner(owner common.Hash, root common.Hash, db *Database) (*SecureTrie, error) {
 	if db == nil {
 		panic("trie.NewSecure called without a database")
 	}
-	trie, err := New(root, db)
+	trie, err := NewWithOwner(owner, root, db)
 	if err != nil {
 		return nil, err
 	}
@@ -169,6 +176,7 @@ func (t *SecureTrie) Hash() common.Hash {
 // Copy returns a copy of SecureTrie.
 func (t *SecureTrie) Copy() *SecureTrie {
 	cpy := *t
+	cpy.trie.tracer = t.trie.tracer.copy()
 	return &cpy
 }
 
@@ -0,0 +1,141 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+// tracer tracks the structural changes of a trie between two commits, which is
+// needed by the path scheme to delete the nodes no longer present in the trie.
+// Nodes stored by hash never need deleting, so tries backed by a hash scheme
+// database run without a tracer.
+//
+// The tracer is nil-safe, all methods are no-ops on a nil tracer.
+type tracer struct {
+	origins map[string]struct{} // Paths of the nodes present in the database
+	inserts map[string]struct{} // Paths of the nodes created since the last commit
+	deletes map[string]struct{} // Paths of the nodes removed since the last commit
+}
+
+// newTracer creates an empty tracer.
+func newTracer() *tracer {
+	return &tracer{
+		origins: make(map[string]struct{}),
+		inserts: make(map[string]struct{}),
+		deletes: make(map[string]struct{}),
+	}
+}
+
+// onRead tracks a node resolved from the database.
+func (t *tracer) onRead(path []byte) {
+	if t == nil {
+		return
+	}
+	t.origins[string(path)] = struct{}{}
+}
+
+// onInsert tracks a newly created node. If it was previously deleted, the two
+// operations cancel out as the path will be overwritten.
+func (t *tracer) onInsert(path []byte) {
+	if t == nil {
+		return
+	}
+	if _, ok := t.deletes[string(path)]; ok {
+		delete(t.deletes, string(path))
+		return
+	}
+	t.inserts[string(path)] = struct{}{}
+}
+
+// onDelete tracks a removed node. If it was created since the last commit, the
+// two operations cancel out as nothing was stored yet.
+func (t *tracer) onDelete(path []byte) {
+	if t == nil {
+		return
+	}
+	if _, ok := t.inserts[string(path)]; ok {
+		delete(t.inserts, string(path))
+		return
+	}
+	t.deletes[string(path)] = struct{}{}
+}
+
+// stored reports whether a standalone node is present in the database at the
+// given path.
+func (t *tracer) stored(path []byte) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.origins[string(path)]
+	return ok
+}
+
+// deleted returns the paths of all the removed nodes which are present in the
+// database, and as such need deleting on commit.
+func (t *tracer) deleted() [][]byte {
+	if t == nil {
+		return nil
+	}
+	var paths [][]byte
+	for path := range t.deletes {
+		if _, ok := t.origins[path]; ok {
+			paths = append(paths, []byte(path))
+		}
+	}
+	return paths
+}
+
+// committed resets the tracer after a commit, updating the set of nodes present
+// in the database with the ones written and deleted.
+func (t *tracer) committed(written [][]byte, deleted [][]byte) {
+	if t == nil {
+		return
+	}
+	for _, path := range deleted {
+		delete(t.origins, string(path))
+	}
+	for _, path := range written {
+		t.origins[string(path)] = struct{}{}
+	}
+	t.inserts = make(map[string]struct{})
+	t.deletes = make(map[string]struct{})
+}
+
+// reset clears all the tracked changes and origins.
+func (t *tracer) reset() {
+	if t == nil {
+		return
+	}
+	t.origins = make(map[string]struct{})
+	t.inserts = make(map[string]struct{})
+	t.deletes = make(map[string]struct{})
+}
+
+// copy returns a deep copy of the tracer.
+func (t *tracer) copy() *tracer {
+	if t == nil {
+		return nil
+	}
+	cpy := newTracer()
+	for path := range t.origins {
+		cpy.origins[path] = struct{}{}
+	}
+	for path := range t.inserts {
+		cpy.inserts[path] = struct{}{}
+	}
+	for path := range t.deletes {
+		cpy.deletes[path] = struct{}{}
+	}
+	return cpy
+}
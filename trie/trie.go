@@ -24,6 +24,7 @@ import (
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 )
@@ -47,12 +48,17 @@ type LeafCallback func(path []byte, leaf []byte, parent common.Hash) error
 //
 // Trie is not safe for concurrent use.
 type Trie struct {
-	db   *Database
-	root node
+	db    *Database
+	root  node
+	owner common.Hash // Hash of the account owning a storage trie, zero for the account trie
 	// Keep track of the number leafs which have been inserted since the last
 	// hashing operation. This number will not directly map to the number of
 	// actually unhashed nodes
 	unhashed int
+
+	// tracer tracks the structural changes of the trie, only used if the
+	// database stores the nodes by path.
+	tracer *tracer
 }
 
 // newFlag returns the cache flag value for a newly created node.
@@ -67,11 +73,23 @@ func (t *Trie) newFlag() nodeFlag {
 // New will panic if db is nil and returns a MissingNodeError if root does
 // not exist in the database. Accessing the trie loads nodes from db on demand.
 func New(root common.Hash, db *Database) (*Trie, error) {
+	return NewWithOwner(common.Hash{}, root, db)
+}
+
+// NewWithOwner creates a trie with an existing root node from db, belonging to
+// the given owner. The owner is the hash of the account for storage tries and
+// zero for the account trie. It's only relevant if the database stores the trie
+// nodes by path, where the nodes of different tries are distinguished by it.
+func NewWithOwner(owner common.Hash, root common.Hash, db *Database) (*Trie, error) {
 	if db == nil {
 		panic("trie.New called without a database")
 	}
 	trie := &Trie{
-		db: db,
+		db:    db,
+		owner: owner,
+	}
+	if db.scheme == rawdb.PathScheme {
+		trie.tracer = newTracer()
 	}
 	if root != (common.Hash{}) && root != emptyRoot {
 		rootnode, err := trie.resolveHash(root[:], nil)
@@ -296,7 +314,9 @@ func (t *Trie) insert(n node, prefix, key []byte, value node) (bool, node, error
 		if matchlen == 0 {
 			return true, branch, nil
 		}
-		// Otherwise, replace it with a short node leading up to the branch.
+		// Otherwise, replace it with a short node leading up to the branch,
+		// tracking the branch created below it.
+		t.tracer.onInsert(append(prefix, key[:matchlen]...))
 		return true, &shortNode{key[:matchlen], branch, t.newFlag()}, nil
 
 	case *fullNode:
@@ -310,6 +330,9 @@ func (t *Trie) insert(n node, prefix, key []byte, value node) (bool, node, error
 		return true, n, nil
 
 	case nil:
+		// A new short node is created at the path, the value node is always
+		// embedded in it and not tracked.
+		t.tracer.onInsert(prefix)
 		return true, &shortNode{key, value, t.newFlag()}, nil
 
 	case hashNode:
@@ -362,6 +385,7 @@ func (t *Trie) delete(n node, prefix, key []byte) (bool, node, error) {
 			return false, n, nil // don't replace n on mismatch
 		}
 		if matchlen == len(key) {
+			t.tracer.onDelete(prefix)
 			return true, nil, nil // remove n entirely for whole matches
 		}
 		// The key is longer than n.Key. Remove the remaining suffix
@@ -379,7 +403,8 @@ func (t *Trie) delete(n node, prefix, key []byte) (bool, node, error) {
 			// shortNode{..., shortNode{...}}. Use concat (which
 			// always creates a new slice) instead of append to
 			// avoid modifying n.Key since it might be shared with
-			// other nodes.
+			// other nodes. The child node is merged into its parent.
+			t.tracer.onDelete(append(prefix, n.Key...))
 			return true, &shortNode{concat(n.Key, child.Key...), child.Val, t.newFlag()}, nil
 		default:
 			return true, &shortNode{n.Key, child, t.newFlag()}, nil
@@ -422,11 +447,13 @@ func (t *Trie) delete(n node, prefix, key []byte) (bool, node, error) {
 				// shortNode{..., shortNode{...}}.  Since the entry
 				// might not be loaded yet, resolve it just for this
 				// check.
-				cnode, err := t.resolve(n.Children[pos], prefix)
+				cnode, err := t.resolve(n.Children[pos], append(prefix, byte(pos)))
 				if err != nil {
 					return false, nil, err
 				}
 				if cnode, ok := cnode.(*shortNode); ok {
+					// The child short node is merged into n.
+					t.tracer.onDelete(append(prefix, byte(pos)))
 					k := append([]byte{byte(pos)}, cnode.Key...)
 					return true, &shortNode{k, cnode.Val, t.newFlag()}, nil
 				}
@@ -479,7 +506,12 @@ func (t *Trie) resolve(n node, prefix []byte) (node, error) {
 
 func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 	hash := common.BytesToHash(n)
-	if node := t.db.node(hash); node != nil {
+	if t.db.scheme == rawdb.PathScheme {
+		if node := t.db.pathNode(t.owner, prefix, hash); node != nil {
+			t.tracer.onRead(prefix)
+			return node, nil
+		}
+	} else if node := t.db.node(hash); node != nil {
 		return node, nil
 	}
 	return nil, &MissingNodeError{NodeHash: hash, Path: prefix}
@@ -500,18 +532,20 @@ func (t *Trie) Commit(onleaf LeafCallback) (root common.Hash, err error) {
 		panic("commit called on trie with nil database")
 	}
 	if t.root == nil {
+		t.commitDeletions(nil)
 		return emptyRoot, nil
 	}
 	// Derive the hash for all dirty nodes first. We hold the assumption
 	// in the following procedure that all nodes are hashed.
 	rootHash := t.Hash()
-	h := newCommitter()
+	h := newCommitter(t.owner, t.tracer)
 	defer returnCommitterToPool(h)
 
 	// Do a quick check if we really need to commit, before we spin
 	// up goroutines. This can happen e.g. if we load a trie for reading storage
 	// values, but don't write to it.
 	if _, dirty := t.root.cache(); !dirty {
+		t.commitDeletions(nil)
 		return rootHash, nil
 	}
 	var wg sync.WaitGroup
@@ -537,10 +571,42 @@ func (t *Trie) Commit(onleaf LeafCallback) (root common.Hash, err error) {
 	if err != nil {
 		return common.Hash{}, err
 	}
+	t.commitDeletions(h.written)
 	t.root = newRoot
 	return rootHash, nil
 }
 
+// commitDeletions pushes the deletion of all the nodes removed from the trie
+// since the last commit into the database, if nodes are stored by path.
+func (t *Trie) commitDeletions(written [][]byte) {
+	if t.tracer == nil {
+		return
+	}
+	deleted := t.tracer.deleted()
+	if len(deleted) > 0 && len(written) > 0 {
+		// Nodes overwritten by the commit need no deletion
+		stored := make(map[string]struct{}, len(written))
+		for _, path := range written {
+			stored[string(path)] = struct{}{}
+		}
+		filtered := deleted[:0]
+		for _, path := range deleted {
+			if _, ok := stored[string(path)]; !ok {
+				filtered = append(filtered, path)
+			}
+		}
+		deleted = filtered
+	}
+	if len(deleted) > 0 {
+		t.db.lock.Lock()
+		for _, path := range deleted {
+			t.db.deletePath(t.owner, path)
+		}
+		t.db.lock.Unlock()
+	}
+	t.tracer.committed(written, deleted)
+}
+
 // hashRoot calculates the root hash of the given trie
 func (t *Trie) hashRoot() (node, node, error) {
 	if t.root == nil {
@@ -558,4 +624,5 @@ func (t *Trie) hashRoot() (node, node, error) {
 func (t *Trie) Reset() {
 	t.root = nil
 	t.unhashed = 0
+	t.tracer.reset()
 }